@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"context"
+	"eino-demo/api/response"
+	"eino-demo/service"
+	"eino-demo/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AnalyticsHandler 合同组合分析报表，所有接口支持 start/end/type 过滤，format=csv|xlsx|jsonl 时导出文件
+type AnalyticsHandler struct {
+	analyticsSvc *service.AnalyticsService
+}
+
+func NewAnalyticsHandler(analyticsSvc *service.AnalyticsService) *AnalyticsHandler {
+	return &AnalyticsHandler{analyticsSvc: analyticsSvc}
+}
+
+// Trend 按月/年统计合同数和金额 (period=month|year)
+func (h *AnalyticsHandler) Trend(c *gin.Context) {
+	serve(c, "trend", func(ctx context.Context, f types.AnalyticsFilter) (types.Table, error) {
+		return h.analyticsSvc.Trend(ctx, f)
+	})
+}
+
+// ByType 按合同类型统计
+func (h *AnalyticsHandler) ByType(c *gin.Context) {
+	serve(c, "by_type", func(ctx context.Context, f types.AnalyticsFilter) (types.Table, error) {
+		return h.analyticsSvc.ByType(ctx, f)
+	})
+}
+
+// ByStatus 按合同状态统计
+func (h *AnalyticsHandler) ByStatus(c *gin.Context) {
+	serve(c, "by_status", func(ctx context.Context, f types.AnalyticsFilter) (types.Table, error) {
+		return h.analyticsSvc.ByStatus(ctx, f)
+	})
+}
+
+// TopCounterparties 金额最高的参与方 (limit 默认 10)
+func (h *AnalyticsHandler) TopCounterparties(c *gin.Context) {
+	serve(c, "top_counterparties", func(ctx context.Context, f types.AnalyticsFilter) (types.Table, error) {
+		return h.analyticsSvc.TopCounterparties(ctx, f)
+	})
+}
+
+// SignedVsExpiring 每个周期签署与到期的合同
+func (h *AnalyticsHandler) SignedVsExpiring(c *gin.Context) {
+	serve(c, "signed_vs_expiring", func(ctx context.Context, f types.AnalyticsFilter) (types.Table, error) {
+		return h.analyticsSvc.SignedVsExpiring(ctx, f)
+	})
+}
+
+// YoY 同比
+func (h *AnalyticsHandler) YoY(c *gin.Context) {
+	serve(c, "yoy", func(ctx context.Context, f types.AnalyticsFilter) (types.Table, error) {
+		return h.analyticsSvc.YoY(ctx, f)
+	})
+}
+
+// serve 解析查询参数、执行报表并按 format 返回 JSON 或导出文件
+func serve(c *gin.Context, name string, run func(ctx context.Context, f types.AnalyticsFilter) (types.Table, error)) {
+	var f types.AnalyticsFilter
+	if err := c.ShouldBindQuery(&f); err != nil {
+		response.Fail(c, "参数错误: "+err.Error())
+		return
+	}
+	table, err := run(c.Request.Context(), f)
+	if err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	if format := c.Query("format"); format != "" && format != "json" {
+		e := response.NewExporter(c, name, format, table.Header())
+		for _, record := range table.Records() {
+			if err := e.Write(record); err != nil {
+				e.Finish(err)
+				return
+			}
+		}
+		e.Finish(nil)
+		return
+	}
+	response.Success(c, table)
+}
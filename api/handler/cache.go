@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"eino-demo/api/response"
+	"eino-demo/storage/cache"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CacheHandler 缓存运行状态
+type CacheHandler struct {
+	embeddingCache *cache.EmbeddingCache
+}
+
+func NewCacheHandler(embeddingCache *cache.EmbeddingCache) *CacheHandler {
+	return &CacheHandler{embeddingCache: embeddingCache}
+}
+
+// EmbeddingStats 入库向量缓存的命中统计
+func (h *CacheHandler) EmbeddingStats(c *gin.Context) {
+	response.Success(c, h.embeddingCache.Stats())
+}
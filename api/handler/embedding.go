@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"eino-demo/api/response"
+	"eino-demo/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EmbeddingHandler 向量集合版本管理 (换模型重新向量化、切换、回滚)
+type EmbeddingHandler struct {
+	reembedSvc *service.ReembedService
+}
+
+func NewEmbeddingHandler(reembedSvc *service.ReembedService) *EmbeddingHandler {
+	return &EmbeddingHandler{reembedSvc: reembedSvc}
+}
+
+type reembedRequest struct {
+	Model string `json:"model" binding:"required"`
+}
+
+// List 全部集合版本，含状态、进度和召回率
+func (h *EmbeddingHandler) List(c *gin.Context) {
+	versions, err := h.reembedSvc.List(c.Request.Context())
+	if err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	response.Success(c, versions)
+}
+
+// Start 用新模型后台构建集合，立即返回新版本，通过 Get 查看进度
+func (h *EmbeddingHandler) Start(c *gin.Context) {
+	var req reembedRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "参数错误")
+		return
+	}
+	v, err := h.reembedSvc.Start(c.Request.Context(), req.Model)
+	if err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	response.Success(c, v)
+}
+
+// Get 单个版本的构建进度
+func (h *EmbeddingHandler) Get(c *gin.Context) {
+	v, err := h.reembedSvc.Get(c.Request.Context(), c.Param("collection"))
+	if err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	response.Success(c, v)
+}
+
+// Activate 把检索别名切到该版本
+func (h *EmbeddingHandler) Activate(c *gin.Context) {
+	v, err := h.reembedSvc.Activate(c.Request.Context(), c.Param("collection"))
+	if err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	response.Success(c, v)
+}
+
+// Rollback 切回上一个版本
+func (h *EmbeddingHandler) Rollback(c *gin.Context) {
+	v, err := h.reembedSvc.Rollback(c.Request.Context())
+	if err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	response.Success(c, v)
+}
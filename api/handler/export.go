@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"eino-demo/api/response"
+	"eino-demo/logic/export"
+	"eino-demo/service"
+	"eino-demo/storage/postgres"
+	"eino-demo/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportHandler 按自然语言问题导出合同列表、统计表和检索证据 (csv/xlsx/jsonl)，数据边查边写
+type ExportHandler struct {
+	retrievalSvc *service.RetrievalService
+}
+
+func NewExportHandler(retrievalSvc *service.RetrievalService) *ExportHandler {
+	return &ExportHandler{retrievalSvc: retrievalSvc}
+}
+
+// Contracts 导出命中的全部合同，列来自 postgres.Contract
+func (h *ExportHandler) Contracts(c *gin.Context) {
+	req, ok := bindExport(c)
+	if !ok {
+		return
+	}
+	e := response.NewExporter(c, "contracts", req.Format, export.ContractHeader())
+	e.Finish(h.retrievalSvc.ExportContracts(c.Request.Context(), req, func(contract *postgres.Contract) error {
+		return e.Write(export.ContractRecord(contract))
+	}))
+}
+
+// Aggregate 导出统计表
+func (h *ExportHandler) Aggregate(c *gin.Context) {
+	req, ok := bindExport(c)
+	if !ok {
+		return
+	}
+	e := response.NewExporter(c, "aggregate", req.Format, nil)
+	result, err := h.retrievalSvc.ExportAggregate(c.Request.Context(), req)
+	if err != nil {
+		e.Finish(err)
+		return
+	}
+	e.SetHeader(result.Columns)
+	for _, record := range export.AggregateRecords(result) {
+		if err := e.Write(record); err != nil {
+			e.Finish(err)
+			return
+		}
+	}
+	e.Finish(nil)
+}
+
+// Evidence 导出混合检索命中的片段原文和分数
+func (h *ExportHandler) Evidence(c *gin.Context) {
+	req, ok := bindExport(c)
+	if !ok {
+		return
+	}
+	e := response.NewExporter(c, "evidence", req.Format, export.EvidenceHeader())
+	e.Finish(h.retrievalSvc.ExportEvidence(c.Request.Context(), req, func(rank int, hit types.ChunkHit) error {
+		return e.Write(export.EvidenceRecord(rank, hit))
+	}))
+}
+
+// bindExport 解析请求并校验格式，在调用 LLM 之前尽早报错
+func bindExport(c *gin.Context) (types.ExportRequest, bool) {
+	var req types.ExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "参数错误: query 不能为空")
+		return req, false
+	}
+	if req.Format == "" {
+		req.Format = export.FormatCSV
+	}
+	if !export.IsValidFormat(req.Format) {
+		response.Fail(c, "参数错误: format 只能是 csv, xlsx 或 jsonl")
+		return req, false
+	}
+	return req, true
+}
@@ -37,23 +37,23 @@ func (h *ContractHandler) Upload(c *gin.Context) {
 	}
 	fmt.Printf(">>> [DEBUG] 2. 收到文件列表，共 %d 个文件\n", len(files))
 
+	// 2. 调用 Service：所有文件一起提交到入库流水线，解析、提取、切分、写入各阶段并发执行
+	results, err := h.ingestionSvc.UploadAndProcess(c.Request.Context(), files)
 	var allDocIDs []string
 	var errorFiles []string
-	// 2. 调用 Service
-	for _, file := range files {
-		fmt.Printf(">>> [DEBUG] ---> 开始处理文件: %s, 大小: %d\n", file.Filename, file.Size)
-
-		// 调用现有的 Service (它负责单个文件处理)
-		ids, err := h.ingestionSvc.UploadAndProcess(c.Request.Context(), file)
-		if err != nil {
-			fmt.Printf(">>> [ERROR] 文件 %s 处理失败: %v\n", file.Filename, err)
-			errorFiles = append(errorFiles, file.Filename)
-			// 这里使用 continue，即使一个文件失败，也不影响其他文件上传
-			continue
+	for _, r := range results {
+		allDocIDs = append(allDocIDs, r.DocIDs...)
+		if len(r.Errors) > 0 {
+			fmt.Printf(">>> [ERROR] 文件 %s 处理失败: %v\n", r.FileName, r.Errors)
+			errorFiles = append(errorFiles, r.FileName)
+		}
+	}
+	if err != nil {
+		fmt.Printf(">>> [ERROR] %v\n", err)
+		if len(results) == 0 {
+			response.Fail(c, err.Error())
+			return
 		}
-
-		// 汇总 ID
-		allDocIDs = append(allDocIDs, ids...)
 	}
 
 	fmt.Printf(">>> [DEBUG] 3. 批量处理完成，成功生成 ID 总数: %d\n", len(allDocIDs))
@@ -71,9 +71,15 @@ func (h *ContractHandler) Upload(c *gin.Context) {
 		"status":      "indexed",
 		"total_count": len(allDocIDs),
 		"fail_files":  errorFiles, // 告诉前端哪些文件失败了
+		"files":       results,    // 每个文件的明细 (含跳过数和错误原因)
 	})
 }
 
+// PipelineStats 入库流水线各阶段的队列长度、处理数、失败数和平均耗时
+func (h *ContractHandler) PipelineStats(c *gin.Context) {
+	response.Success(c, h.ingestionSvc.PipelineStats())
+}
+
 func (h *ContractHandler) Search(c *gin.Context) {
 	var req types.SearchRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -84,11 +90,51 @@ func (h *ContractHandler) Search(c *gin.Context) {
 	fmt.Printf(">>> [DEBUG] 收到搜索请求: %s\n", req.Query)
 
 	// 调用 RetrievalService
-	result, err := h.retrievalSvc.Search(c.Request.Context(), req.Query)
+	result, err := h.retrievalSvc.Search(c.Request.Context(), req)
 	if err != nil {
-		response.Fail(c, err.Error())
+		response.FailErr(c, err)
 		return
 	}
 
 	response.Success(c, result)
 }
+
+// Compare 比较两份以上合同的条款：body 中给出 doc_ids，或由 query 定位合同
+func (h *ContractHandler) Compare(c *gin.Context) {
+	var req types.CompareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "参数错误: "+err.Error())
+		return
+	}
+	if req.Query == "" && len(req.DocIDs) == 0 {
+		response.Fail(c, "参数错误: query 和 doc_ids 不能同时为空")
+		return
+	}
+	result, err := h.retrievalSvc.Compare(c.Request.Context(), req)
+	if err != nil {
+		response.FailErr(c, err)
+		return
+	}
+	response.Success(c, result)
+}
+
+// ListClauses 查询合同条款，可通过 type 参数指定条款类型（如 payment 或 付款）
+func (h *ContractHandler) ListClauses(c *gin.Context) {
+	docID := c.Param("doc_id")
+	clauses, err := h.retrievalSvc.ListClauses(c.Request.Context(), docID, c.Query("type"))
+	if err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	response.Success(c, clauses)
+}
+
+// ListParties 查询合同的全部参与方（甲乙丙方、担保人、签署代表）
+func (h *ContractHandler) ListParties(c *gin.Context) {
+	parties, err := h.retrievalSvc.ListParties(c.Request.Context(), c.Param("doc_id"))
+	if err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	response.Success(c, parties)
+}
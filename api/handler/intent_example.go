@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"eino-demo/api/response"
+	"eino-demo/service"
+	"eino-demo/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IntentExampleHandler 意图解析示例的维护接口
+type IntentExampleHandler struct {
+	exampleSvc *service.IntentExampleService
+}
+
+func NewIntentExampleHandler(exampleSvc *service.IntentExampleService) *IntentExampleHandler {
+	return &IntentExampleHandler{exampleSvc: exampleSvc}
+}
+
+// List 全部示例
+func (h *IntentExampleHandler) List(c *gin.Context) {
+	examples, err := h.exampleSvc.List(c.Request.Context())
+	if err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	response.Success(c, examples)
+}
+
+// Create 添加示例，或纠正线上查询 (search_log_id) 的解析结果后加入示例
+func (h *IntentExampleHandler) Create(c *gin.Context) {
+	var req types.IntentExampleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "参数错误: intent 不能为空")
+		return
+	}
+	example, err := h.exampleSvc.Create(c.Request.Context(), req)
+	if err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	response.Success(c, example)
+}
+
+// Update 纠正示例
+func (h *IntentExampleHandler) Update(c *gin.Context) {
+	var req types.IntentExampleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "参数错误: intent 不能为空")
+		return
+	}
+	example, err := h.exampleSvc.Update(c.Request.Context(), c.Param("id"), req)
+	if err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	response.Success(c, example)
+}
+
+// Delete 删除示例
+func (h *IntentExampleHandler) Delete(c *gin.Context) {
+	if err := h.exampleSvc.Delete(c.Request.Context(), c.Param("id")); err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	response.Success(c, nil)
+}
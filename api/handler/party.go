@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"eino-demo/api/response"
+	"eino-demo/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PartyHandler struct {
+	partySvc *service.PartyService
+}
+
+func NewPartyHandler(partySvc *service.PartyService) *PartyHandler {
+	return &PartyHandler{partySvc: partySvc}
+}
+
+type updatePartyRequest struct {
+	CanonicalName string `json:"canonical_name"`
+	EntityType    string `json:"entity_type"`
+}
+
+type aliasRequest struct {
+	Alias string `json:"alias" binding:"required"`
+}
+
+type moveAliasRequest struct {
+	ToPartyID string `json:"to_party_id" binding:"required"`
+}
+
+type mergePartyRequest struct {
+	SourceID string `json:"source_id" binding:"required"`
+}
+
+// List 分页查询主体，支持 q 关键词过滤
+func (h *PartyHandler) List(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	parties, total, err := h.partySvc.ListParties(c.Request.Context(), c.Query("q"), limit, offset)
+	if err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	response.Success(c, map[string]any{
+		"parties": parties,
+		"total":   total,
+	})
+}
+
+// Get 查询主体详情（含别名）
+func (h *PartyHandler) Get(c *gin.Context) {
+	p, err := h.partySvc.GetParty(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	response.Success(c, p)
+}
+
+// Update 修改主体规范名/主体类型
+func (h *PartyHandler) Update(c *gin.Context) {
+	var req updatePartyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "参数错误")
+		return
+	}
+	if err := h.partySvc.UpdateParty(c.Request.Context(), c.Param("id"), req.CanonicalName, req.EntityType); err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	response.Success(c, nil)
+}
+
+// AddAlias 添加别名
+func (h *PartyHandler) AddAlias(c *gin.Context) {
+	var req aliasRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "参数错误: alias 不能为空")
+		return
+	}
+	if err := h.partySvc.AddAlias(c.Request.Context(), c.Param("id"), req.Alias); err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	response.Success(c, nil)
+}
+
+// DeleteAlias 删除别名
+func (h *PartyHandler) DeleteAlias(c *gin.Context) {
+	if err := h.partySvc.DeleteAlias(c.Request.Context(), c.Param("id"), c.Param("alias_id")); err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	response.Success(c, nil)
+}
+
+// ConfirmAlias 确认自动挂载的别名
+func (h *PartyHandler) ConfirmAlias(c *gin.Context) {
+	if err := h.partySvc.ConfirmAlias(c.Request.Context(), c.Param("id"), c.Param("alias_id")); err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	response.Success(c, nil)
+}
+
+// MoveAlias 把别名改挂到另一个主体
+func (h *PartyHandler) MoveAlias(c *gin.Context) {
+	var req moveAliasRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "参数错误: to_party_id 不能为空")
+		return
+	}
+	if err := h.partySvc.MoveAlias(c.Request.Context(), c.Param("alias_id"), req.ToPartyID); err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	response.Success(c, nil)
+}
+
+// Merge 把 source_id 主体合并到当前主体
+func (h *PartyHandler) Merge(c *gin.Context) {
+	var req mergePartyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "参数错误: source_id 不能为空")
+		return
+	}
+	if err := h.partySvc.MergeParties(c.Request.Context(), c.Param("id"), req.SourceID); err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	response.Success(c, nil)
+}
+
+// Suggestions 查询待确认的合并建议和自动别名
+func (h *PartyHandler) Suggestions(c *gin.Context) {
+	suggestions, err := h.partySvc.ListSuggestions(c.Request.Context())
+	if err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	response.Success(c, suggestions)
+}
+
+// DismissSuggestion 忽略主体的合并建议
+func (h *PartyHandler) DismissSuggestion(c *gin.Context) {
+	if err := h.partySvc.DismissSuggestion(c.Request.Context(), c.Param("id")); err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	response.Success(c, nil)
+}
@@ -0,0 +1,78 @@
+package response
+
+import (
+	"eino-demo/logic/export"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Exporter 以附件形式流式导出表格
+// 响应头在写第一行时才发出，在此之前出错仍可以返回 JSON 错误
+type Exporter struct {
+	c        *gin.Context
+	filename string
+	format   string
+	header   []string
+	w        export.Writer
+}
+
+// NewExporter format 为空时默认 csv，filename 不带扩展名
+func NewExporter(c *gin.Context, filename, format string, header []string) *Exporter {
+	if format == "" {
+		format = export.FormatCSV
+	}
+	return &Exporter{c: c, filename: filename + "." + format, format: format, header: header}
+}
+
+// SetHeader 表头在查询前无法确定时 (如统计表)，在写第一行前设置
+func (e *Exporter) SetHeader(header []string) {
+	e.header = header
+}
+
+// Write 写一行，第一次调用时发出响应头和表头
+func (e *Exporter) Write(record []string) error {
+	if e.w == nil {
+		if err := e.start(); err != nil {
+			return err
+		}
+	}
+	return e.w.Write(record)
+}
+
+// Finish 结束导出：还没写出任何数据时，err 以 JSON 返回；否则只能中断流并记录日志
+func (e *Exporter) Finish(err error) {
+	if err != nil {
+		if e.w == nil {
+			Fail(e.c, err.Error())
+			return
+		}
+		fmt.Printf(">>> [Export] %s 导出中断: %v\n", e.filename, err)
+		return
+	}
+	if e.w == nil {
+		if err := e.start(); err != nil {
+			Fail(e.c, err.Error())
+			return
+		}
+	}
+	if err := e.w.Close(); err != nil {
+		fmt.Printf(">>> [Export] %s 写出失败: %v\n", e.filename, err)
+	}
+}
+
+func (e *Exporter) start() error {
+	if !export.IsValidFormat(e.format) {
+		return fmt.Errorf("不支持的导出格式: %s (可选 csv, xlsx, jsonl)", e.format)
+	}
+	e.c.Header("Content-Type", export.ContentType(e.format))
+	e.c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", e.filename))
+	e.c.Status(http.StatusOK)
+	w, err := export.NewWriter(e.c.Writer, e.format, e.header)
+	if err != nil {
+		return err
+	}
+	e.w = w
+	return nil
+}
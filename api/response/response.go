@@ -1,6 +1,8 @@
 package response
 
 import (
+	"eino-demo/logic/chat"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"net/http"
 )
@@ -26,3 +28,17 @@ func Fail(c *gin.Context, msg string) {
 		Data: nil,
 	})
 }
+
+// FailErr 业务错误；对话模型调用失败时 data.errors 列出每个模型的失败类别 (timeout、circuit_open 等) 和尝试次数
+func FailErr(c *gin.Context, err error) {
+	var chainErr *chat.ChainError
+	var modelErr *chat.ModelError
+	switch {
+	case errors.As(err, &chainErr):
+		c.JSON(http.StatusOK, Response{Code: -1, Msg: err.Error(), Data: chainErr})
+	case errors.As(err, &modelErr):
+		c.JSON(http.StatusOK, Response{Code: -1, Msg: err.Error(), Data: &chat.ChainError{Errors: []*chat.ModelError{modelErr}}})
+	default:
+		Fail(c, err.Error())
+	}
+}
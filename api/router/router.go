@@ -5,17 +5,65 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterRoutes(r *gin.Engine, contractH *handler.ContractHandler) {
+func RegisterRoutes(r *gin.Engine, contractH *handler.ContractHandler, partyH *handler.PartyHandler, analyticsH *handler.AnalyticsHandler, exportH *handler.ExportHandler, cacheH *handler.CacheHandler, embeddingH *handler.EmbeddingHandler, exampleH *handler.IntentExampleHandler) {
 	api := r.Group("/api/v1")
 	{
 		contract := api.Group("/contract")
 		{
 			contract.POST("/upload", contractH.Upload)
+			contract.GET("/pipeline/stats", contractH.PipelineStats)
+			contract.GET("/:doc_id/clauses", contractH.ListClauses)
+			contract.GET("/:doc_id/parties", contractH.ListParties)
 			// contract.GET("/list", contractH.GetList)
 		}
 		retrieval := api.Group("/retrieval")
 		{
 			retrieval.POST("/search", contractH.Search)
+			retrieval.POST("/compare", contractH.Compare)
+		}
+		intent := api.Group("/intent")
+		{
+			intent.GET("/examples", exampleH.List)
+			intent.POST("/examples", exampleH.Create)
+			intent.PUT("/examples/:id", exampleH.Update)
+			intent.DELETE("/examples/:id", exampleH.Delete)
+		}
+		party := api.Group("/party")
+		{
+			party.GET("", partyH.List)
+			party.GET("/suggestions", partyH.Suggestions)
+			party.GET("/:id", partyH.Get)
+			party.PUT("/:id", partyH.Update)
+			party.POST("/:id/merge", partyH.Merge)
+			party.DELETE("/:id/suggestion", partyH.DismissSuggestion)
+			party.POST("/:id/aliases", partyH.AddAlias)
+			party.DELETE("/:id/aliases/:alias_id", partyH.DeleteAlias)
+			party.POST("/:id/aliases/:alias_id/confirm", partyH.ConfirmAlias)
+			party.POST("/:id/aliases/:alias_id/move", partyH.MoveAlias)
+		}
+		analytics := api.Group("/analytics")
+		{
+			analytics.GET("/trend", analyticsH.Trend)
+			analytics.GET("/by-type", analyticsH.ByType)
+			analytics.GET("/by-status", analyticsH.ByStatus)
+			analytics.GET("/top-counterparties", analyticsH.TopCounterparties)
+			analytics.GET("/signed-vs-expiring", analyticsH.SignedVsExpiring)
+			analytics.GET("/yoy", analyticsH.YoY)
+		}
+		export := api.Group("/export")
+		{
+			export.POST("/contracts", exportH.Contracts)
+			export.POST("/aggregate", exportH.Aggregate)
+			export.POST("/evidence", exportH.Evidence)
+		}
+		api.GET("/cache/embedding/stats", cacheH.EmbeddingStats)
+		embedding := api.Group("/embedding")
+		{
+			embedding.GET("/versions", embeddingH.List)
+			embedding.POST("/versions", embeddingH.Start)
+			embedding.GET("/versions/:collection", embeddingH.Get)
+			embedding.POST("/versions/:collection/activate", embeddingH.Activate)
+			embedding.POST("/rollback", embeddingH.Rollback)
 		}
 		// chat := api.Group("/chat")
 		// ...
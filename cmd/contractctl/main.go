@@ -0,0 +1,108 @@
+// contractctl 命令行导出工具，调用服务端 /api/v1/export 接口并把结果写到文件或标准输出
+//
+// 用法:
+//
+//	contractctl [-server URL] [-format csv|xlsx|jsonl] [-o 文件] [-sort 字段] [-order asc|desc] [-limit N] <contracts|aggregate|evidence> <问题>
+//
+// 示例:
+//
+//	contractctl -format xlsx -o 2024.xlsx contracts 列出所有2024年的合同
+//	contractctl aggregate 每个合同类型的总金额
+//	contractctl -format jsonl -limit 500 evidence 违约金条款
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"eino-demo/types"
+)
+
+func main() {
+	server := flag.String("server", "http://localhost:8081", "服务端地址")
+	format := flag.String("format", "csv", "导出格式: csv, xlsx, jsonl")
+	output := flag.String("o", "", "输出文件，默认标准输出")
+	sortBy := flag.String("sort", "", "排序字段: relevance, sign_date, amount, end_date")
+	order := flag.String("order", "", "排序方向: asc, desc")
+	limit := flag.Int("limit", 0, "evidence 最多导出的片段数")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法: contractctl [flags] <contracts|aggregate|evidence> <问题>\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	kind := flag.Arg(0)
+	if kind != "contracts" && kind != "aggregate" && kind != "evidence" {
+		fmt.Fprintf(os.Stderr, "未知的导出类型: %s\n", kind)
+		os.Exit(2)
+	}
+	if *format == "xlsx" && *output == "" {
+		fmt.Fprintln(os.Stderr, "xlsx 是二进制格式，请用 -o 指定输出文件")
+		os.Exit(2)
+	}
+
+	req := types.ExportRequest{
+		Query:  strings.Join(flag.Args()[1:], " "),
+		Format: *format,
+		SortBy: *sortBy,
+		Order:  *order,
+		Limit:  *limit,
+	}
+	if err := run(*server+"/api/v1/export/"+kind, req, *output); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run 发起导出请求，把响应体流式写到输出
+func run(url string, req types.ExportRequest, output string) error {
+	body, _ := json.Marshal(req)
+	resp, err := http.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// 出错时服务端返回 JSON (code=-1)，而不是附件
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		var r struct {
+			Code int    `json:"code"`
+			Msg  string `json:"msg"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+			return fmt.Errorf("解析响应失败: %v", err)
+		}
+		return fmt.Errorf("导出失败: %s", r.Msg)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("导出失败: HTTP %d", resp.StatusCode)
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	n, err := io.Copy(w, resp.Body)
+	if err != nil {
+		return fmt.Errorf("写出失败: %v", err)
+	}
+	if output != "" {
+		fmt.Fprintf(os.Stderr, "已导出 %d 字节到 %s\n", n, output)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+// esindex 切片索引的 mapping 检查和零停机重建，直接连接 ES
+// 默认地址和别名取自服务配置 (CONFIG_FILE / config.yaml + 环境变量)
+//
+// 用法:
+//
+//	esindex [-addr URL] [-alias 别名] [-keep-old] <check|reindex>
+//
+// 示例:
+//
+//	esindex check              # 别名指向的索引 mapping 是否与代码一致，不一致时退出码为 1
+//	esindex reindex            # 按当前 mapping 新建索引、复制数据、切换别名、删除旧索引
+//	esindex -keep-old reindex  # 同上，但保留旧索引
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"eino-demo/config"
+	"eino-demo/storage/es"
+
+	"github.com/elastic/go-elasticsearch/v8"
+)
+
+func main() {
+	cfg, err := config.Read(config.Path())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	addr := flag.String("addr", cfg.ES.Addr, "ES 地址")
+	alias := flag.String("alias", cfg.ES.Index, "切片索引的读写别名")
+	keepOld := flag.Bool("keep-old", false, "reindex 后保留旧索引")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法: esindex [flags] <check|reindex>\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	client, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{*addr}})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "创建 ES 客户端失败: %v\n", err)
+		os.Exit(1)
+	}
+	manager := es.NewIndexManager(client, *alias)
+	ctx := context.Background()
+
+	switch flag.Arg(0) {
+	case "check":
+		status, err := manager.Check(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		printJSON(status)
+		if !status.OK() {
+			os.Exit(1)
+		}
+	case "reindex":
+		result, err := manager.Reindex(ctx, *keepOld)
+		if result != nil {
+			printJSON(result)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "未知命令: %s\n", flag.Arg(0))
+		os.Exit(2)
+	}
+}
+
+func printJSON(v any) {
+	data, _ := json.MarshalIndent(v, "", "  ")
+	fmt.Println(string(data))
+}
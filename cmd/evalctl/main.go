@@ -0,0 +1,282 @@
+// evalctl 离线检索评测：由测试合同的文件名生成标注集，调用服务端检索接口，
+// 计算 recall@k、MRR、nDCG 和意图/过滤条件准确率，结果写成 JSON 报告以便对比不同配置
+//
+// 用法:
+//
+//	evalctl [-dir 目录] [-per-kind N] [-o 文件] generate
+//	evalctl [-server URL] [-k 1,5,10] [-label 名称] [-o 文件] run <标注集.json>...
+//	evalctl compare <基线报告.json> <报告.json>
+//
+// 示例:
+//
+//	evalctl -o generated.json generate                                # 由 deploy/test_file 的文件名生成标注集
+//	evalctl -label es0.5 -o base.json run generated.json manual.json  # 执行标注集中的查询并写报告
+//	evalctl -label es0.7 -o es07.json run generated.json manual.json  # 调整 retrieval.es_weight 热更新后再跑一次
+//	evalctl compare base.json es07.json                               # 对比两次的指标
+//
+// run 时 relevant_files 按文件名从 PG 查 doc_id，relevant_chunk_refs 从 contract_chunks 查切片 ID，
+// 连接信息和报告中的配置快照取自本地服务配置
+// (CONFIG_FILE / config.yaml + 环境变量)，与服务端实际配置不一致时请用 -label 注明
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"eino-demo/config"
+	"eino-demo/logic/eval"
+	"eino-demo/storage/postgres"
+	"eino-demo/types"
+
+	pgdriver "gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func main() {
+	server := flag.String("server", "http://localhost:8081", "服务端地址")
+	dir := flag.String("dir", "deploy/test_file", "generate: 测试合同目录")
+	perKind := flag.Int("per-kind", 20, "generate: 每种查询模板最多生成的条数，0 表示不限")
+	ks := flag.String("k", "1,5,10", "run: 计算 recall@k 和 nDCG@k 的 k，逗号分隔")
+	label := flag.String("label", "", "run: 报告标签，如本次调整的参数")
+	output := flag.String("o", "", "输出文件；generate 默认标准输出，run 默认 eval-<时间>.json")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法: evalctl [flags] <generate | run <标注集.json>... | compare <基线报告.json> <报告.json>>\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var err error
+	switch {
+	case flag.Arg(0) == "generate" && flag.NArg() == 1:
+		err = generate(*dir, *perKind, *output)
+	case flag.Arg(0) == "run" && flag.NArg() >= 2:
+		err = run(*server, flag.Args()[1:], *ks, *label, *output)
+	case flag.Arg(0) == "compare" && flag.NArg() == 3:
+		err = compare(flag.Arg(1), flag.Arg(2))
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// generate 解析目录下的文件名生成标注集，不符合命名格式的文件跳过
+func generate(dir string, perKind int, output string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var files []eval.FileMeta
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		meta, err := eval.ParseFileName(entry.Name())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "跳过: %v\n", err)
+			continue
+		}
+		if !meta.AmountOK {
+			fmt.Fprintf(os.Stderr, "文件 %s 的金额无法解析，该类型不生成金额查询\n", entry.Name())
+		}
+		files = append(files, meta)
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("目录 %s 下没有可用的测试合同", dir)
+	}
+	set := eval.Generate(filepath.Base(filepath.Clean(dir)), files, perKind)
+	fmt.Fprintf(os.Stderr, "由 %d 个文件生成 %d 条查询\n", len(files), len(set.Queries))
+	return writeJSON(output, set)
+}
+
+// run 合并执行多个标注集并写报告，最后打印汇总指标
+func run(server string, goldenPaths []string, ks, label, output string) error {
+	sets := make([]*eval.GoldenSet, 0, len(goldenPaths))
+	for _, path := range goldenPaths {
+		set, err := eval.LoadGoldenSet(path)
+		if err != nil {
+			return err
+		}
+		sets = append(sets, set)
+	}
+	set, err := eval.MergeGoldenSets(sets...)
+	if err != nil {
+		return err
+	}
+	kList, err := parseKs(ks)
+	if err != nil {
+		return err
+	}
+	cfg, err := config.Read(config.Path())
+	if err != nil {
+		return err
+	}
+
+	var resolve eval.Resolver
+	if needsResolve(set) {
+		db, err := gorm.Open(pgdriver.Open(cfg.Postgres.DSN()), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+		if err != nil {
+			return fmt.Errorf("连接 PG 失败: %v", err)
+		}
+		resolve = &pgResolver{repo: postgres.NewContractRepo(db)}
+	}
+
+	client := &http.Client{Timeout: 5 * time.Minute}
+	search := func(ctx context.Context, req types.SearchRequest) (*types.SearchResponse, error) {
+		return searchRemote(ctx, client, server+"/api/v1/retrieval/search", req)
+	}
+	report := eval.NewRunner(search, resolve, kList).Run(context.Background(), set)
+	report.Label = label
+	report.Config = map[string]any{
+		"retrieval":       cfg.Retrieval,
+		"splitter":        cfg.Ingest.Splitter,
+		"prompt_splits":   cfg.Prompts.Splits,
+		"embedding_model": cfg.Models.Embedding,
+		"intent_model":    cfg.Models.Intent.Provider + "/" + cfg.Models.Intent.Model,
+	}
+
+	if output == "" {
+		output = "eval-" + report.CreatedAt.Format("20060102-150405") + ".json"
+	}
+	if err := writeJSON(output, report); err != nil {
+		return err
+	}
+	fmt.Printf("\n%d 条查询，%d 条失败，报告已写入 %s\n", report.Summary.Queries, report.Summary.Errors, output)
+	for _, name := range sortedMetrics(report.Summary.Metrics) {
+		fmt.Printf("  %-36s %10.4f  (n=%d)\n", name, report.Summary.Metrics[name], report.Summary.Counts[name])
+	}
+	return nil
+}
+
+func needsResolve(set *eval.GoldenSet) bool {
+	for _, q := range set.Queries {
+		if len(q.RelevantFiles) > 0 || len(q.RelevantChunkRefs) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// pgResolver 从 PG 的 contracts 和 contract_chunks 解析文件名和切片描述
+type pgResolver struct {
+	repo *postgres.ContractRepo
+}
+
+func (r *pgResolver) DocID(ctx context.Context, fileName string) (string, error) {
+	contract, err := r.repo.GetByFileName(ctx, fileName)
+	if err != nil {
+		return "", err
+	}
+	return contract.DocID, nil
+}
+
+func (r *pgResolver) ChunkIDs(ctx context.Context, docID string, ref eval.ChunkRef) ([]string, error) {
+	return r.repo.FindChunkIDs(ctx, docID, ref.ClauseType, ref.Contains)
+}
+
+// searchRemote 调用检索接口，code != 0 时返回服务端的错误信息
+func searchRemote(ctx context.Context, client *http.Client, url string, req types.SearchRequest) (*types.SearchResponse, error) {
+	body, _ := json.Marshal(req)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("检索失败: HTTP %d", resp.StatusCode)
+	}
+	var r struct {
+		Code int                   `json:"code"`
+		Msg  string                `json:"msg"`
+		Data *types.SearchResponse `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %v", err)
+	}
+	if r.Code != 0 || r.Data == nil {
+		return nil, fmt.Errorf("检索失败: %s", r.Msg)
+	}
+	return r.Data, nil
+}
+
+// compare 打印两份报告共有指标的变化
+func compare(basePath, headPath string) error {
+	base, err := eval.LoadReport(basePath)
+	if err != nil {
+		return err
+	}
+	head, err := eval.LoadReport(headPath)
+	if err != nil {
+		return err
+	}
+	if base.GoldenSet != head.GoldenSet || base.Summary.Queries != head.Summary.Queries {
+		fmt.Fprintf(os.Stderr, "注意: 两份报告的标注集不同 (%s %d 条 / %s %d 条)，指标不能直接比较\n",
+			base.GoldenSet, base.Summary.Queries, head.GoldenSet, head.Summary.Queries)
+	}
+	fmt.Printf("%-36s %10s %10s %10s\n", "metric", reportName(base, basePath), reportName(head, headPath), "diff")
+	for _, d := range eval.Compare(base, head) {
+		fmt.Printf("%-36s %10.4f %10.4f %+10.4f\n", d.Metric, d.Base, d.Head, d.Diff())
+	}
+	return nil
+}
+
+func reportName(report *eval.Report, path string) string {
+	if report.Label != "" {
+		return report.Label
+	}
+	return strings.TrimSuffix(filepath.Base(path), ".json")
+}
+
+func parseKs(s string) ([]int, error) {
+	var ks []int
+	for _, part := range strings.Split(s, ",") {
+		k, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || k <= 0 {
+			return nil, fmt.Errorf("k 必须是正整数: %q", part)
+		}
+		ks = append(ks, k)
+	}
+	return ks, nil
+}
+
+func sortedMetrics(metrics map[string]float64) []string {
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// writeJSON path 为空时写到标准输出
+func writeJSON(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if path == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
@@ -0,0 +1,654 @@
+// Package config 服务的全部配置：默认值 -> YAML 文件 -> 环境变量，启动时校验，打印时隐藏密码
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"eino-demo/vars"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultPath 没有设置 CONFIG_FILE 时读取的配置文件，不存在则只用默认值和环境变量
+const DefaultPath = "config.yaml"
+
+// Config 字段的 env 标签是对应的环境变量 (兼容原来的变量名)，secret 标签的字段打印时隐藏
+// 只有 Retrieval 和 Prompts.Splits 支持热更新，其余修改需要重启
+type Config struct {
+	Server    Server    `yaml:"server"`
+	Postgres  Postgres  `yaml:"postgres"`
+	Milvus    Milvus    `yaml:"milvus"`
+	ES        ES        `yaml:"es"`
+	Ollama    Ollama    `yaml:"ollama"`
+	OpenAI    OpenAI    `yaml:"openai"`
+	Models    Models    `yaml:"models"`
+	LLM       LLM       `yaml:"llm"`
+	Cache     Cache     `yaml:"cache"`
+	Embedder  Embedder  `yaml:"embedder"`
+	Ingest    Ingest    `yaml:"ingest"`
+	Retrieval Retrieval `yaml:"retrieval"`
+	Reembed   Reembed   `yaml:"reembed"`
+	Prompts   Prompts   `yaml:"prompts"`
+}
+
+type Server struct {
+	Addr string `yaml:"addr" env:"SERVER_ADDR"`
+	// ShutdownTimeout 退出时等待在途请求和入库任务的最长时间
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+	// CursorKey 分页游标的签名密钥；为空时启动时随机生成，重启后旧游标失效，多实例部署 (共享 Redis 缓存) 时必须配置成相同的值
+	CursorKey string `yaml:"cursor_key" env:"CURSOR_KEY" secret:"true"`
+}
+
+type Postgres struct {
+	Host     string `yaml:"host" env:"PGHOST"`
+	Port     int    `yaml:"port" env:"PGPORT"`
+	User     string `yaml:"user" env:"PGUSER"`
+	Password string `yaml:"password" env:"PGPWD" secret:"true"`
+	DB       string `yaml:"db" env:"PGDB"`
+	SSLMode  string `yaml:"sslmode" env:"PGSSLMODE"`
+}
+
+// DSN gorm postgres 连接串
+func (p Postgres) DSN() string {
+	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=%s",
+		p.Host, p.User, p.Password, p.DB, p.Port, p.SSLMode)
+}
+
+type Milvus struct {
+	Addr string `yaml:"addr" env:"MILVUSADDR"`
+	// Collection 首次启动登记的集合，之后以 embedding_versions 表里 active 的集合为准
+	Collection string `yaml:"collection" env:"MILVUS_COLLECTION"`
+	// Alias 检索使用的别名，指向当前在用的集合
+	Alias string `yaml:"alias" env:"MILVUS_ALIAS"`
+}
+
+type ES struct {
+	Addr string `yaml:"addr" env:"ESADDR"`
+	// Index 切片索引的读写别名，物理索引按 mapping 版本命名 (见 storage/es/lifecycle.go)
+	Index string `yaml:"index" env:"ES_INDEX"`
+}
+
+type Ollama struct {
+	URL string `yaml:"url" env:"OLLAMA_PATH"`
+}
+
+// OpenAI OpenAI 兼容接口 (vLLM、DeepSeek、通义等) 的默认地址和密钥，各用途可单独覆盖
+type OpenAI struct {
+	BaseURL string `yaml:"base_url" env:"OPENAI_BASE_URL"`
+	APIKey  string `yaml:"api_key" env:"OPENAI_API_KEY" secret:"true"`
+}
+
+// 对话模型的提供方
+const (
+	ProviderOllama = "ollama"
+	ProviderOpenAI = "openai"
+)
+
+// Models 向量模型和按用途区分的对话模型
+// 对话模型字段的 env 标签是前缀，如 EXTRACTION_MODEL、INTENT_PROVIDER、ANSWER_TEMPERATURE
+type Models struct {
+	// Embedding 首次启动登记的向量模型，之后换模型走 POST /api/v1/embedding/versions
+	Embedding string `yaml:"embedding" env:"EMBEDDING_MODEL"`
+
+	Extraction ChatModel `yaml:"extraction" env:"EXTRACTION_"` // 入库时的合同结构化提取
+	Intent     ChatModel `yaml:"intent" env:"INTENT_"`         // 查询意图解析
+	Answer     ChatModel `yaml:"answer" env:"ANSWER_"`         // 单合同问答、统计总结、合同比较
+	Rewrite    ChatModel `yaml:"rewrite" env:"REWRITE_"`       // 查询改写 (预留)
+	Judge      ChatModel `yaml:"judge" env:"JUDGE_"`           // LLM 重排打分 (预留)
+}
+
+// ChatModel 一个用途使用的对话模型
+type ChatModel struct {
+	Provider string `yaml:"provider" env:"PROVIDER"` // ollama 或 openai (OpenAI 兼容接口)
+	Model    string `yaml:"model" env:"MODEL"`
+	// BaseURL、APIKey 为空时使用 ollama.url 或 openai 段的配置
+	BaseURL string `yaml:"base_url" env:"BASE_URL"`
+	APIKey  string `yaml:"api_key" env:"API_KEY" secret:"true"`
+	// Temperature 不填使用模型默认值
+	Temperature *float64      `yaml:"temperature" env:"TEMPERATURE"`
+	MaxTokens   int           `yaml:"max_tokens" env:"MAX_TOKENS"` // 0 不限制
+	Timeout     time.Duration `yaml:"timeout" env:"TIMEOUT"`       // 单次请求超时，0 不限制
+	// Fallbacks 本模型重试用尽或熔断时依次尝试的模型，如本地 qwen 降级到 OpenAI 兼容接口 (只能在配置文件中设置)
+	Fallbacks []ChatModel `yaml:"fallbacks,omitempty"`
+}
+
+// LLM 对话模型调用的重试和熔断，对全部用途生效；熔断按 提供方 + 地址 + 模型 统计
+type LLM struct {
+	MaxRetries  int           `yaml:"max_retries" env:"LLM_MAX_RETRIES"`   // 超时、连接失败、429/5xx 的重试次数
+	BaseBackoff time.Duration `yaml:"base_backoff" env:"LLM_BASE_BACKOFF"` // 第一次重试前的等待，之后指数增长并加随机抖动
+	// BreakerThreshold 连续失败多少次后熔断，熔断期间直接走降级模型
+	BreakerThreshold int `yaml:"breaker_threshold" env:"LLM_BREAKER_THRESHOLD"`
+	// BreakerCooldown 熔断多久后放行一次探测请求
+	BreakerCooldown time.Duration `yaml:"breaker_cooldown" env:"LLM_BREAKER_COOLDOWN"`
+}
+
+// Roles 全部对话模型，键为 yaml 中的名称
+func (m *Models) Roles() map[string]*ChatModel {
+	return map[string]*ChatModel{
+		"extraction": &m.Extraction,
+		"intent":     &m.Intent,
+		"answer":     &m.Answer,
+		"rewrite":    &m.Rewrite,
+		"judge":      &m.Judge,
+	}
+}
+
+// Cache 意图、查询向量、检索结果缓存和入库向量缓存
+type Cache struct {
+	// Backend memory (进程内 LRU) 或 redis (Redis 兼容服务，多实例共享)
+	Backend   string         `yaml:"backend" env:"CACHE_BACKEND"`
+	Size      int            `yaml:"size" env:"CACHE_SIZE"`
+	Redis     Redis          `yaml:"redis"`
+	Embedding EmbeddingCache `yaml:"embedding"`
+}
+
+type Redis struct {
+	Addr     string `yaml:"addr" env:"REDISADDR"`
+	Password string `yaml:"password" env:"REDISPWD" secret:"true"`
+	DB       int    `yaml:"db" env:"REDISDB"`
+}
+
+type EmbeddingCache struct {
+	// Mode pg (embedding_cache 表)、disk (Dir 目录) 或 off
+	Mode string `yaml:"mode" env:"EMBEDDING_CACHE"`
+	Dir  string `yaml:"dir" env:"EMBEDDING_CACHE_DIR"`
+}
+
+// Embedder embedder 中间件链参数 (transform.EmbedderOptions)
+type Embedder struct {
+	BatchSize   int           `yaml:"batch_size" env:"EMBED_BATCH_SIZE"`
+	Concurrency int           `yaml:"concurrency" env:"EMBED_CONCURRENCY"`
+	MaxRetries  int           `yaml:"max_retries" env:"EMBED_MAX_RETRIES"`
+	BaseBackoff time.Duration `yaml:"base_backoff" env:"EMBED_BASE_BACKOFF"`
+	Timeout     time.Duration `yaml:"timeout" env:"EMBED_TIMEOUT"`
+}
+
+// Ingest 入库流水线参数
+type Ingest struct {
+	ParseWorkers   int `yaml:"parse_workers" env:"INGEST_PARSE_WORKERS"`
+	ExtractWorkers int `yaml:"extract_workers" env:"INGEST_EXTRACT_WORKERS"` // LLM 结构化提取
+	SplitWorkers   int `yaml:"split_workers" env:"INGEST_SPLIT_WORKERS"`     // 条款切分 + 语义切分 (embedding)
+	PersistWorkers int `yaml:"persist_workers" env:"INGEST_PERSIST_WORKERS"` // 写 PG (合同、条款、参与方)
+	QueueSize      int `yaml:"queue_size" env:"INGEST_QUEUE_SIZE"`           // 阶段之间的队列长度，满了上游阻塞 (背压)
+	WriteBatchSize int `yaml:"write_batch_size" env:"INGEST_BATCH_CHUNKS"`   // 攒够多少个切片批量写一次 ES/Milvus
+	// WriteBatchWait 批次没攒满时最多等待多久就写入；原来的 INGEST_BATCH_WAIT_MS (毫秒) 仍然生效，见 legacyEnv
+	WriteBatchWait time.Duration `yaml:"write_batch_wait" env:"INGEST_BATCH_WAIT"`
+	Splitter       Splitter      `yaml:"splitter"`
+}
+
+// Splitter 语义切分参数
+type Splitter struct {
+	BufferSize   int     `yaml:"buffer_size" env:"SPLIT_BUFFER_SIZE"`
+	MinChunkSize int     `yaml:"min_chunk_size" env:"SPLIT_MIN_CHUNK_SIZE"` // 按字符数
+	Percentile   float64 `yaml:"percentile" env:"SPLIT_PERCENTILE"`         // 相邻句子距离超过该分位数处切开
+}
+
+// Retrieval 检索参数，支持热更新
+type Retrieval struct {
+	// 混合检索融合权重
+	MilvusWeight float64 `yaml:"milvus_weight" env:"RETRIEVAL_MILVUS_WEIGHT"`
+	ESWeight     float64 `yaml:"es_weight" env:"RETRIEVAL_ES_WEIGHT"`
+	// DirectClauseDocs 条款直查最多覆盖的合同数，超过则认为没定位到具体合同，走混合检索
+	DirectClauseDocs int `yaml:"direct_clause_docs" env:"RETRIEVAL_DIRECT_CLAUSE_DOCS"`
+	// QACandidates 单合同问答命中多份合同时最多列出的候选数
+	QACandidates int `yaml:"qa_candidates" env:"RETRIEVAL_QA_CANDIDATES"`
+	// QAContextChunks 作答时放进 Prompt 的片段数
+	QAContextChunks int `yaml:"qa_context_chunks" env:"RETRIEVAL_QA_CONTEXT_CHUNKS"`
+	// CompareContracts 一次最多比较的合同数
+	CompareContracts int `yaml:"compare_contracts" env:"RETRIEVAL_COMPARE_CONTRACTS"`
+	// CompareChunkTopK 合同缺少某类已抽取条款时，在该合同内检索的片段数
+	CompareChunkTopK int `yaml:"compare_chunk_top_k" env:"RETRIEVAL_COMPARE_CHUNK_TOP_K"`
+	// IntentCacheTTL 意图与数据无关，键里带当前日期，相对时间 ("今年"、"近三个月") 跨天后自然失效
+	IntentCacheTTL time.Duration `yaml:"intent_cache_ttl" env:"RETRIEVAL_INTENT_CACHE_TTL"`
+	// ResultCacheTTL 结果缓存兜底过期时间，正常情况下由入库/变更时的版本号自增失效
+	ResultCacheTTL time.Duration `yaml:"result_cache_ttl" env:"RETRIEVAL_RESULT_CACHE_TTL"`
+	// FewShotK 意图解析时放进提示词的相似标注示例数，0 表示只用提示词里的固定示例
+	FewShotK int `yaml:"few_shot_k" env:"RETRIEVAL_FEW_SHOT_K"`
+	// FewShotMinScore 示例与查询的最低余弦相似度，太不相关的示例会干扰小模型
+	FewShotMinScore float64 `yaml:"few_shot_min_score" env:"RETRIEVAL_FEW_SHOT_MIN_SCORE"`
+}
+
+// Reembed 重新向量化参数
+type Reembed struct {
+	BatchSize  int     `yaml:"batch_size" env:"REEMBED_BATCH_SIZE"`   // 每批从 PG 读取并写入 Milvus 的切片数
+	SampleSize int     `yaml:"sample_size" env:"REEMBED_SAMPLE_SIZE"` // 召回自检抽样的切片数
+	RecallTopK int     `yaml:"recall_top_k" env:"REEMBED_RECALL_TOPK"`
+	MinRecall  float64 `yaml:"min_recall" env:"REEMBED_MIN_RECALL"` // 低于该召回率不允许切换
+}
+
+// Prompts 提示词 (logic/prompt)：内置版本 + Dir 目录下的版本，按 Splits 的权重在版本间分流
+type Prompts struct {
+	// Dir 额外的提示词目录，结构为 <名称>/<版本>.tmpl，与内置同名的版本覆盖内置；留空只用内置
+	Dir string `yaml:"dir" env:"PROMPTS_DIR"`
+	// Splits 名称 -> 版本 -> 权重，如 intent: {v1: 90, v2: 10}；没有配置的提示词使用最新版本，支持热更新
+	Splits map[string]map[string]int `yaml:"splits,omitempty"`
+}
+
+// Default 默认配置，数据库密码没有默认值，必须通过配置文件或 PGPWD 提供
+func Default() *Config {
+	return &Config{
+		Server: Server{Addr: ":8081", ShutdownTimeout: 10 * time.Minute},
+		Postgres: Postgres{
+			Host: "localhost", Port: 5432, User: "root", DB: "einoDB", SSLMode: "disable",
+		},
+		Milvus: Milvus{Addr: "127.0.0.1:19530", Collection: vars.COLLECTION, Alias: "contract_collection"},
+		ES:     ES{Addr: "http://localhost:9200", Index: "contract_chunks"},
+		Ollama: Ollama{URL: "http://localhost:11434"},
+		LLM:    LLM{MaxRetries: 2, BaseBackoff: time.Second, BreakerThreshold: 5, BreakerCooldown: 30 * time.Second},
+		Models: Models{
+			Embedding: vars.NOMIC,
+			// 提取字段多、要换算金额日期，用大一点的模型；意图解析在检索链路上，用快的小模型
+			Extraction: ChatModel{Provider: ProviderOllama, Model: vars.QWEN7B, Temperature: ptr(0.0), Timeout: 5 * time.Minute},
+			Intent:     ChatModel{Provider: ProviderOllama, Model: vars.QWEN3B, Temperature: ptr(0.0), MaxTokens: 1024, Timeout: 30 * time.Second},
+			Answer:     ChatModel{Provider: ProviderOllama, Model: vars.QWEN3B, Temperature: ptr(0.3), Timeout: 2 * time.Minute},
+			Rewrite:    ChatModel{Provider: ProviderOllama, Model: vars.QWEN3B, Temperature: ptr(0.0), MaxTokens: 256, Timeout: 30 * time.Second},
+			Judge:      ChatModel{Provider: ProviderOllama, Model: vars.QWEN3B, Temperature: ptr(0.0), MaxTokens: 512, Timeout: time.Minute},
+		},
+		Cache: Cache{
+			Backend:   "memory",
+			Size:      10000,
+			Redis:     Redis{Addr: "127.0.0.1:6379"},
+			Embedding: EmbeddingCache{Mode: "pg", Dir: "./data/embedding_cache"},
+		},
+		Embedder: Embedder{
+			BatchSize:   32,
+			Concurrency: 4,
+			MaxRetries:  3,
+			BaseBackoff: 500 * time.Millisecond,
+			Timeout:     30 * time.Second,
+		},
+		Ingest: Ingest{
+			ParseWorkers:   2,
+			ExtractWorkers: 2,
+			SplitWorkers:   2,
+			PersistWorkers: 2,
+			QueueSize:      16,
+			WriteBatchSize: 256,
+			WriteBatchWait: 2 * time.Second,
+			Splitter:       Splitter{BufferSize: 5, MinChunkSize: 200, Percentile: 0.85},
+		},
+		Retrieval: Retrieval{
+			MilvusWeight:     0.6,
+			ESWeight:         0.4,
+			DirectClauseDocs: 3,
+			QACandidates:     10,
+			QAContextChunks:  8,
+			CompareContracts: 5,
+			CompareChunkTopK: 3,
+			IntentCacheTTL:   24 * time.Hour,
+			ResultCacheTTL:   10 * time.Minute,
+			FewShotK:         4,
+			FewShotMinScore:  0.5,
+		},
+		Reembed: Reembed{BatchSize: 256, SampleSize: 50, RecallTopK: 5, MinRecall: 0.9},
+	}
+}
+
+// Path 配置文件路径：CONFIG_FILE，没有设置时默认文件存在才读取，返回空表示不读文件
+func Path() string {
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		return path
+	}
+	if _, err := os.Stat(DefaultPath); err == nil {
+		return DefaultPath
+	}
+	return ""
+}
+
+// Load 默认值 -> YAML 文件 (path 为空时跳过) -> 环境变量，最后校验
+func Load(path string) (*Config, error) {
+	cfg, err := Read(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Read 同 Load 但不校验，供只用到部分配置的命令行工具使用
+func Read(path string) (*Config, error) {
+	cfg := Default()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("读取配置文件失败: %v", err)
+		}
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true) // 拼错的字段直接报错，避免配置悄悄不生效
+		if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("解析配置文件 %s 失败: %v", path, err)
+		}
+	}
+	if err := applyEnv(reflect.ValueOf(cfg).Elem(), ""); err != nil {
+		return nil, err
+	}
+	if err := applyLegacyEnv(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// legacyEnv 改名或改了格式的环境变量，新变量没有设置时按原来的含义生效
+var legacyEnv = []struct {
+	key, replacement string
+	apply            func(cfg *Config, raw string) error
+}{
+	{"INGEST_BATCH_WAIT_MS", "INGEST_BATCH_WAIT", func(cfg *Config, raw string) error {
+		ms, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		cfg.Ingest.WriteBatchWait = time.Duration(ms) * time.Millisecond
+		return nil
+	}},
+}
+
+// applyLegacyEnv 两个变量都设置时以新变量为准
+func applyLegacyEnv(cfg *Config) error {
+	var errs []error
+	for _, legacy := range legacyEnv {
+		raw := os.Getenv(legacy.key)
+		if raw == "" {
+			continue
+		}
+		if os.Getenv(legacy.replacement) != "" {
+			fmt.Printf("⚠️ [Config] 同时设置了 %s 和 %s，忽略 %s\n", legacy.key, legacy.replacement, legacy.key)
+			continue
+		}
+		if err := legacy.apply(cfg, raw); err != nil {
+			errs = append(errs, fmt.Errorf("环境变量 %s=%q 格式错误: %v", legacy.key, raw, err))
+			continue
+		}
+		fmt.Printf("⚠️ [Config] 环境变量 %s 已改名为 %s，请尽快替换\n", legacy.key, legacy.replacement)
+	}
+	return errors.Join(errs...)
+}
+
+// applyEnv 带 env 标签的字段用非空的环境变量覆盖，结构体字段的 env 标签是其内部字段的前缀
+func applyEnv(v reflect.Value, prefix string) error {
+	var errs []error
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field, value := t.Field(i), v.Field(i)
+		if field.Type.Kind() == reflect.Struct {
+			if err := applyEnv(value, prefix+field.Tag.Get("env")); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		key := field.Tag.Get("env")
+		if key == "" {
+			continue
+		}
+		key = prefix + key
+		raw := os.Getenv(key)
+		if raw == "" {
+			continue
+		}
+		if err := setValue(value, raw); err != nil {
+			errs = append(errs, fmt.Errorf("环境变量 %s=%q 格式错误: %v", key, raw, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func setValue(v reflect.Value, raw string) error {
+	if v.Kind() == reflect.Pointer {
+		elem := reflect.New(v.Type().Elem())
+		if err := setValue(elem.Elem(), raw); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("不支持的类型 %s", v.Type())
+	}
+	return nil
+}
+
+// Validate 检查全部配置，一次返回所有问题
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(c.Server.Addr != "", "server.addr 不能为空")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout 必须大于 0")
+
+	check(c.Postgres.Host != "", "postgres.host 不能为空")
+	check(c.Postgres.Port > 0 && c.Postgres.Port < 65536, "postgres.port 超出范围: %d", c.Postgres.Port)
+	check(c.Postgres.User != "", "postgres.user 不能为空")
+	check(c.Postgres.Password != "", "postgres.password 未配置 (配置文件或 PGPWD)")
+	check(c.Postgres.DB != "", "postgres.db 不能为空")
+
+	check(c.Milvus.Addr != "", "milvus.addr 不能为空")
+	check(c.Milvus.Collection != "", "milvus.collection 不能为空")
+	check(c.Milvus.Alias != "", "milvus.alias 不能为空")
+	check(c.Milvus.Alias != c.Milvus.Collection, "milvus.alias 不能与 milvus.collection 同名")
+	check(c.ES.Addr != "", "es.addr 不能为空")
+	check(c.ES.Index != "", "es.index 不能为空")
+	check(c.Ollama.URL != "", "ollama.url 不能为空")
+	check(c.Models.Embedding != "", "models.embedding 不能为空")
+	roles := c.Models.Roles()
+	for _, role := range sortedKeys(roles) {
+		problems = append(problems, roles[role].problems("models."+role)...)
+		for i, fb := range roles[role].Fallbacks {
+			problems = append(problems, fb.problems(fmt.Sprintf("models.%s.fallbacks[%d]", role, i))...)
+			check(len(fb.Fallbacks) == 0, "models.%s.fallbacks[%d] 不能再嵌套 fallbacks", role, i)
+		}
+	}
+	check(c.LLM.MaxRetries >= 0, "llm.max_retries 不能为负数")
+	check(c.LLM.BaseBackoff > 0, "llm.base_backoff 必须大于 0")
+	check(c.LLM.BreakerThreshold > 0, "llm.breaker_threshold 必须大于 0")
+	check(c.LLM.BreakerCooldown > 0, "llm.breaker_cooldown 必须大于 0")
+
+	switch c.Cache.Backend {
+	case "memory":
+		check(c.Cache.Size > 0, "cache.size 必须大于 0")
+	case "redis":
+		check(c.Cache.Redis.Addr != "", "cache.redis.addr 不能为空")
+		check(c.Cache.Redis.DB >= 0, "cache.redis.db 不能为负数")
+	default:
+		problems = append(problems, fmt.Sprintf("cache.backend 只能是 memory 或 redis: %q", c.Cache.Backend))
+	}
+	switch c.Cache.Embedding.Mode {
+	case "pg", "off":
+	case "disk":
+		check(c.Cache.Embedding.Dir != "", "cache.embedding.dir 不能为空")
+	default:
+		problems = append(problems, fmt.Sprintf("cache.embedding.mode 只能是 pg、disk 或 off: %q", c.Cache.Embedding.Mode))
+	}
+
+	check(c.Embedder.BatchSize > 0, "embedder.batch_size 必须大于 0")
+	check(c.Embedder.Concurrency > 0, "embedder.concurrency 必须大于 0")
+	check(c.Embedder.MaxRetries >= 0, "embedder.max_retries 不能为负数")
+	check(c.Embedder.BaseBackoff > 0, "embedder.base_backoff 必须大于 0")
+	check(c.Embedder.Timeout >= 0, "embedder.timeout 不能为负数")
+
+	in := c.Ingest
+	check(in.ParseWorkers > 0 && in.ExtractWorkers > 0 && in.SplitWorkers > 0 && in.PersistWorkers > 0, "ingest.*_workers 必须大于 0")
+	check(in.QueueSize > 0, "ingest.queue_size 必须大于 0")
+	check(in.WriteBatchSize > 0, "ingest.write_batch_size 必须大于 0")
+	check(in.WriteBatchWait > 0, "ingest.write_batch_wait 必须大于 0")
+	check(in.Splitter.BufferSize > 0, "ingest.splitter.buffer_size 必须大于 0")
+	check(in.Splitter.MinChunkSize > 0, "ingest.splitter.min_chunk_size 必须大于 0")
+	check(in.Splitter.Percentile > 0 && in.Splitter.Percentile < 1, "ingest.splitter.percentile 必须在 (0, 1) 之间: %v", in.Splitter.Percentile)
+
+	problems = append(problems, c.Retrieval.problems()...)
+
+	for _, name := range sortedKeys(c.Prompts.Splits) {
+		total := 0
+		for _, version := range sortedKeys(c.Prompts.Splits[name]) {
+			weight := c.Prompts.Splits[name][version]
+			check(weight >= 0, "prompts.splits.%s.%s 权重不能为负数", name, version)
+			total += weight
+		}
+		check(total > 0, "prompts.splits.%s 权重之和必须大于 0", name)
+	}
+
+	re := c.Reembed
+	check(re.BatchSize > 0, "reembed.batch_size 必须大于 0")
+	check(re.SampleSize > 0, "reembed.sample_size 必须大于 0")
+	check(re.RecallTopK > 0, "reembed.recall_top_k 必须大于 0")
+	check(re.MinRecall > 0 && re.MinRecall <= 1, "reembed.min_recall 必须在 (0, 1] 之间: %v", re.MinRecall)
+
+	if len(problems) > 0 {
+		return fmt.Errorf("配置校验失败:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
+
+// problems 对话模型配置的校验，path 为配置中的位置
+func (m ChatModel) problems(path string) []string {
+	var problems []string
+	switch m.Provider {
+	case ProviderOllama, ProviderOpenAI:
+	default:
+		problems = append(problems, fmt.Sprintf("%s.provider 只能是 ollama 或 openai: %q", path, m.Provider))
+	}
+	if m.Model == "" {
+		problems = append(problems, path+".model 不能为空")
+	}
+	if m.Temperature != nil && (*m.Temperature < 0 || *m.Temperature > 2) {
+		problems = append(problems, path+".temperature 必须在 [0, 2] 之间")
+	}
+	if m.MaxTokens < 0 {
+		problems = append(problems, path+".max_tokens 不能为负数")
+	}
+	if m.Timeout < 0 {
+		problems = append(problems, path+".timeout 不能为负数")
+	}
+	return problems
+}
+
+// problems 检索参数的校验
+func (r Retrieval) problems() []string {
+	var problems []string
+	if r.MilvusWeight < 0 || r.ESWeight < 0 || r.MilvusWeight+r.ESWeight == 0 {
+		problems = append(problems, fmt.Sprintf("retrieval 融合权重不能为负且不能都为 0: milvus=%v es=%v", r.MilvusWeight, r.ESWeight))
+	}
+	for _, f := range []struct {
+		name string
+		n    int
+	}{
+		{"direct_clause_docs", r.DirectClauseDocs},
+		{"qa_candidates", r.QACandidates},
+		{"qa_context_chunks", r.QAContextChunks},
+		{"compare_contracts", r.CompareContracts},
+		{"compare_chunk_top_k", r.CompareChunkTopK},
+	} {
+		if f.n <= 0 {
+			problems = append(problems, fmt.Sprintf("retrieval.%s 必须大于 0", f.name))
+		}
+	}
+	if r.IntentCacheTTL <= 0 || r.ResultCacheTTL <= 0 {
+		problems = append(problems, "retrieval 缓存时间必须大于 0")
+	}
+	if r.FewShotK < 0 {
+		problems = append(problems, "retrieval.few_shot_k 不能为负数")
+	}
+	if r.FewShotMinScore < -1 || r.FewShotMinScore > 1 {
+		problems = append(problems, fmt.Sprintf("retrieval.few_shot_min_score 必须在 [-1, 1] 之间: %v", r.FewShotMinScore))
+	}
+	return problems
+}
+
+// Redacted 隐藏 secret 字段后的副本
+func (c *Config) Redacted() *Config {
+	cp := *c
+	redact(reflect.ValueOf(&cp).Elem())
+	return &cp
+}
+
+func redact(v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field, value := t.Field(i), v.Field(i)
+		if field.Type.Kind() == reflect.Struct {
+			redact(value)
+			continue
+		}
+		if field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.Struct {
+			// 复制一份再隐藏，不影响原配置
+			items := reflect.MakeSlice(field.Type, value.Len(), value.Len())
+			reflect.Copy(items, value)
+			for j := 0; j < items.Len(); j++ {
+				redact(items.Index(j))
+			}
+			value.Set(items)
+			continue
+		}
+		if field.Tag.Get("secret") == "true" && value.Kind() == reflect.String && value.String() != "" {
+			value.SetString("******")
+		}
+	}
+}
+
+// String 隐藏密码后的 YAML，用于启动日志
+func (c *Config) String() string {
+	data, err := yaml.Marshal(c.Redacted())
+	if err != nil {
+		return fmt.Sprintf("配置序列化失败: %v", err)
+	}
+	return string(data)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// sortedKeys 按键排序，保证校验信息的顺序稳定
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,222 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApplyEnv(t *testing.T) {
+	cases := []struct {
+		name  string
+		env   map[string]string
+		check func(*Config) bool
+	}{
+		{"普通字段", map[string]string{"PGHOST": "db", "PGPORT": "6432"}, func(c *Config) bool {
+			return c.Postgres.Host == "db" && c.Postgres.Port == 6432
+		}},
+		{"duration", map[string]string{"SHUTDOWN_TIMEOUT": "90s"}, func(c *Config) bool {
+			return c.Server.ShutdownTimeout == 90*time.Second
+		}},
+		{"嵌套结构体带前缀", map[string]string{"INTENT_MODEL": "qwen2.5:14b", "ANSWER_PROVIDER": "openai"}, func(c *Config) bool {
+			return c.Models.Intent.Model == "qwen2.5:14b" && c.Models.Answer.Provider == ProviderOpenAI &&
+				c.Models.Extraction.Model == Default().Models.Extraction.Model
+		}},
+		{"指针字段", map[string]string{"JUDGE_TEMPERATURE": "0.7"}, func(c *Config) bool {
+			return c.Models.Judge.Temperature != nil && *c.Models.Judge.Temperature == 0.7 &&
+				*c.Models.Intent.Temperature == 0
+		}},
+		{"没有前缀的嵌套结构体", map[string]string{"SPLIT_PERCENTILE": "0.9", "REDISDB": "2"}, func(c *Config) bool {
+			return c.Ingest.Splitter.Percentile == 0.9 && c.Cache.Redis.DB == 2
+		}},
+		{"旧变量按毫秒生效", map[string]string{"INGEST_BATCH_WAIT_MS": "500"}, func(c *Config) bool {
+			return c.Ingest.WriteBatchWait == 500*time.Millisecond
+		}},
+		{"新旧变量都设置时以新变量为准", map[string]string{"INGEST_BATCH_WAIT_MS": "500", "INGEST_BATCH_WAIT": "3s"}, func(c *Config) bool {
+			return c.Ingest.WriteBatchWait == 3*time.Second
+		}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			for k, v := range c.env {
+				t.Setenv(k, v)
+			}
+			cfg, err := Read("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !c.check(cfg) {
+				t.Errorf("env %v 没有正确应用", c.env)
+			}
+		})
+	}
+}
+
+func TestApplyEnvErrors(t *testing.T) {
+	cases := []struct {
+		env  map[string]string
+		want []string
+	}{
+		{map[string]string{"PGPORT": "abc"}, []string{"PGPORT"}},
+		{map[string]string{"SHUTDOWN_TIMEOUT": "10"}, []string{"SHUTDOWN_TIMEOUT"}},
+		{map[string]string{"INTENT_TEMPERATURE": "hot", "CACHE_SIZE": "1k"}, []string{"INTENT_TEMPERATURE", "CACHE_SIZE"}},
+		{map[string]string{"INGEST_BATCH_WAIT_MS": "2s"}, []string{"INGEST_BATCH_WAIT_MS"}},
+	}
+	for _, c := range cases {
+		t.Run(strings.Join(c.want, ","), func(t *testing.T) {
+			for k, v := range c.env {
+				t.Setenv(k, v)
+			}
+			_, err := Read("")
+			for _, want := range c.want {
+				if err == nil || !strings.Contains(err.Error(), want) {
+					t.Errorf("env %v: err = %v, 应包含 %s", c.env, err, want)
+				}
+			}
+		})
+	}
+}
+
+func validConfig() *Config {
+	cfg := Default()
+	cfg.Postgres.Password = "secret"
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	if err := validConfig().Validate(); err != nil {
+		t.Fatalf("默认配置加上密码应通过校验: %v", err)
+	}
+	cases := []struct {
+		name   string
+		mutate func(*Config)
+		want   string
+	}{
+		{"缺少密码", func(c *Config) { c.Postgres.Password = "" }, "postgres.password"},
+		{"端口越界", func(c *Config) { c.Postgres.Port = 70000 }, "postgres.port"},
+		{"别名与集合同名", func(c *Config) { c.Milvus.Alias = c.Milvus.Collection }, "milvus.alias"},
+		{"未知 provider", func(c *Config) { c.Models.Intent.Provider = "claude" }, "models.intent.provider"},
+		{"temperature 越界", func(c *Config) { c.Models.Answer.Temperature = ptr(3.0) }, "models.answer.temperature"},
+		{"降级模型校验", func(c *Config) { c.Models.Answer.Fallbacks = []ChatModel{{Provider: ProviderOpenAI}} }, "models.answer.fallbacks[0].model"},
+		{"降级模型不能嵌套", func(c *Config) {
+			c.Models.Answer.Fallbacks = []ChatModel{{Provider: ProviderOpenAI, Model: "m", Fallbacks: []ChatModel{{}}}}
+		}, "不能再嵌套"},
+		{"缓存后端", func(c *Config) { c.Cache.Backend = "memcached" }, "cache.backend"},
+		{"批次等待", func(c *Config) { c.Ingest.WriteBatchWait = 0 }, "ingest.write_batch_wait"},
+		{"切分分位数", func(c *Config) { c.Ingest.Splitter.Percentile = 1 }, "ingest.splitter.percentile"},
+		{"融合权重", func(c *Config) { c.Retrieval.MilvusWeight, c.Retrieval.ESWeight = 0, 0 }, "融合权重"},
+		{"示例相似度", func(c *Config) { c.Retrieval.FewShotMinScore = 2 }, "few_shot_min_score"},
+		{"分流权重", func(c *Config) { c.Prompts.Splits = map[string]map[string]int{"intent": {"v1": 0}} }, "prompts.splits.intent"},
+		{"召回率", func(c *Config) { c.Reembed.MinRecall = 0 }, "reembed.min_recall"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cfg := validConfig()
+			c.mutate(cfg)
+			err := cfg.Validate()
+			if err == nil || !strings.Contains(err.Error(), c.want) {
+				t.Errorf("err = %v, 应包含 %s", err, c.want)
+			}
+		})
+	}
+
+	// 多个问题一次列出
+	cfg := validConfig()
+	cfg.Server.Addr = ""
+	cfg.ES.Index = ""
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "server.addr") || !strings.Contains(err.Error(), "es.index") {
+		t.Errorf("应列出全部问题: %v", err)
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := validConfig()
+	cfg.OpenAI.APIKey = "sk-default"
+	cfg.Models.Answer.APIKey = "sk-answer"
+	cfg.Models.Answer.Fallbacks = []ChatModel{
+		{Provider: ProviderOpenAI, Model: "m1", APIKey: "sk-fallback"},
+		{Provider: ProviderOllama, Model: "m2"},
+	}
+
+	r := cfg.Redacted()
+	for name, got := range map[string]string{
+		"postgres.password":                  r.Postgres.Password,
+		"openai.api_key":                     r.OpenAI.APIKey,
+		"models.answer.api_key":              r.Models.Answer.APIKey,
+		"models.answer.fallbacks[0].api_key": r.Models.Answer.Fallbacks[0].APIKey,
+	} {
+		if got != "******" {
+			t.Errorf("%s = %q, 应隐藏", name, got)
+		}
+	}
+	if r.Models.Answer.Fallbacks[1].APIKey != "" || r.Cache.Redis.Password != "" {
+		t.Error("为空的密钥不用替换")
+	}
+	if r.Models.Answer.Model != cfg.Models.Answer.Model {
+		t.Error("非密钥字段应保留")
+	}
+	if cfg.Postgres.Password != "secret" || cfg.Models.Answer.Fallbacks[0].APIKey != "sk-fallback" {
+		t.Error("不能修改原配置 (包括切片里的元素)")
+	}
+	if s := cfg.String(); strings.Contains(s, "sk-") || strings.Contains(s, "secret") {
+		t.Errorf("String 不能包含密钥:\n%s", s)
+	}
+}
+
+func TestStoreReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("postgres:\n  password: secret\nretrieval:\n  es_weight: 0.4\n")
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewStore(path, cfg)
+	var calls int
+	store.OnChange(func(old, updated *Config) {
+		calls++
+		if old.Retrieval.ESWeight != 0.4 || updated.Retrieval.ESWeight != 0.7 {
+			t.Errorf("hook: old=%v updated=%v", old.Retrieval.ESWeight, updated.Retrieval.ESWeight)
+		}
+	})
+
+	// 检索参数和提示词分流热更新，其余字段保持启动时的值
+	write("postgres:\n  password: secret\nserver:\n  addr: \":9090\"\nretrieval:\n  es_weight: 0.7\nprompts:\n  splits:\n    intent: {v1: 90, v2: 10}\n")
+	if err := store.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	got := store.Get()
+	if got.Retrieval.ESWeight != 0.7 || got.Prompts.Splits["intent"]["v2"] != 10 {
+		t.Errorf("热更新字段没有生效: %+v %v", got.Retrieval, got.Prompts.Splits)
+	}
+	if got.Server.Addr != ":8081" {
+		t.Errorf("server.addr 需要重启才生效: %s", got.Server.Addr)
+	}
+	if cfg.Retrieval.ESWeight != 0.4 {
+		t.Error("不能修改原来的配置对象")
+	}
+	if calls != 1 {
+		t.Errorf("hook 调用 %d 次", calls)
+	}
+
+	// 内容没变不回调
+	if err := store.Reload(); err != nil || calls != 1 {
+		t.Errorf("没有变化时不应回调: err=%v calls=%d", err, calls)
+	}
+
+	// 校验失败保留原配置
+	write("postgres:\n  password: secret\nretrieval:\n  es_weight: -1\n")
+	if err := store.Reload(); err == nil {
+		t.Error("非法配置应报错")
+	}
+	if !reflect.DeepEqual(store.Get(), got) {
+		t.Error("校验失败时应继续使用原配置")
+	}
+}
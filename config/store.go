@@ -0,0 +1,109 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Store 运行中的配置，配置文件修改后热更新 Retrieval (融合权重、TopK、缓存时间) 和 Prompts.Splits (提示词分流)
+// 其余字段改动只提示需要重启，服务继续使用启动时的值
+type Store struct {
+	path    string
+	current atomic.Pointer[Config]
+
+	mu      sync.Mutex
+	modTime time.Time
+	hooks   []func(old, updated *Config)
+}
+
+// NewStore path 为空时不监听文件，配置在运行期间不变
+func NewStore(path string, cfg *Config) *Store {
+	s := &Store{path: path}
+	s.current.Store(cfg)
+	if path != "" {
+		if info, err := os.Stat(path); err == nil {
+			s.modTime = info.ModTime()
+		}
+	}
+	return s
+}
+
+// Get 当前配置，调用方不要修改返回值
+func (s *Store) Get() *Config {
+	return s.current.Load()
+}
+
+// OnChange 热更新生效后回调
+func (s *Store) OnChange(fn func(old, updated *Config)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks = append(s.hooks, fn)
+}
+
+// Watch 按 interval 检查配置文件修改时间，直到 ctx 结束
+func (s *Store) Watch(ctx context.Context, interval time.Duration) {
+	if s.path == "" {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(s.path)
+			if err != nil {
+				continue
+			}
+			s.mu.Lock()
+			changed := !info.ModTime().Equal(s.modTime)
+			s.modTime = info.ModTime()
+			s.mu.Unlock()
+			if !changed {
+				continue
+			}
+			if err := s.Reload(); err != nil {
+				fmt.Printf("⚠️ [Config] 配置文件 %s 热更新失败，继续使用原配置: %v\n", s.path, err)
+			}
+		}
+	}
+}
+
+// Reload 重新加载配置文件，校验通过后替换 Retrieval 和 Prompts.Splits
+func (s *Store) Reload() error {
+	loaded, err := Load(s.path)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old := s.Get()
+	updated := *old
+	updated.Retrieval = loaded.Retrieval
+	updated.Prompts.Splits = loaded.Prompts.Splits
+
+	// 热更新范围之外的改动不生效，提示需要重启
+	restart := *loaded
+	restart.Retrieval = old.Retrieval
+	restart.Prompts.Splits = old.Prompts.Splits
+	if !reflect.DeepEqual(&restart, old) {
+		fmt.Printf("⚠️ [Config] %s 中检索参数和提示词分流以外的改动需要重启才能生效\n", s.path)
+	}
+	if reflect.DeepEqual(&updated, old) {
+		return nil
+	}
+
+	s.current.Store(&updated)
+	fmt.Printf(">>> [Config] 热更新已生效: retrieval=%+v prompts.splits=%v\n", updated.Retrieval, updated.Prompts.Splits)
+	for _, fn := range s.hooks {
+		fn(old, &updated)
+	}
+	return nil
+}
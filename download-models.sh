@@ -13,6 +13,7 @@ echo -e "${YELLOW}开始下载 Ollama 模型...${NC}"
 models=(
     "nomic-embed-text"
     "qwen2.5:3b"
+    "qwen2.5:7b"
 )
 
 for model in "${models[@]}"; do
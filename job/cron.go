@@ -2,13 +2,14 @@ package job
 
 import (
 	"context"
+	"eino-demo/storage/cache"
 	"eino-demo/storage/postgres"
 	"fmt"
 	"github.com/robfig/cron/v3"
 	"time"
 )
 
-func StartCronJob(pgRepo *postgres.ContractRepo) {
+func StartCronJob(pgRepo *postgres.ContractRepo, resultCache *cache.Cache) {
 	c := cron.New()
 
 	// 每天凌晨 2 点执行
@@ -19,6 +20,10 @@ func StartCronJob(pgRepo *postgres.ContractRepo) {
 			fmt.Println("[Cron] Error:", err)
 		} else {
 			fmt.Printf("[Cron] 更新了 %d 份过期合同\n", rows)
+			// 合同状态变化，检索结果缓存失效
+			if rows > 0 {
+				resultCache.Invalidate(ctx)
+			}
 		}
 	})
 
@@ -0,0 +1,84 @@
+package chat
+
+import (
+	"context"
+	"eino-demo/config"
+	"fmt"
+	"strings"
+
+	"github.com/cloudwego/eino/components/model"
+)
+
+// Models 按用途区分的对话模型，由 models 配置段决定各自的提供方、模型和参数
+type Models struct {
+	Extraction model.ToolCallingChatModel // 入库时的合同结构化提取
+	Intent     model.ToolCallingChatModel // 查询意图解析
+	Answer     model.ToolCallingChatModel // 单合同问答、统计总结、合同比较
+	Rewrite    model.ToolCallingChatModel // 查询改写 (预留)
+	Judge      model.ToolCallingChatModel // LLM 重排打分 (预留)
+}
+
+// NewModels 按配置创建全部用途的对话模型
+// 每个模型都带重试、超时和熔断 (同一 提供方 + 地址 + 模型 共用熔断器)，配置了 fallbacks 时按顺序降级
+func NewModels(ctx context.Context, cfg *config.Config) (*Models, error) {
+	m := &Models{}
+	breakers := make(map[string]*Breaker)
+	for _, role := range []struct {
+		name string
+		cfg  config.ChatModel
+		dst  *model.ToolCallingChatModel
+	}{
+		{"extraction", cfg.Models.Extraction, &m.Extraction},
+		{"intent", cfg.Models.Intent, &m.Intent},
+		{"answer", cfg.Models.Answer, &m.Answer},
+		{"rewrite", cfg.Models.Rewrite, &m.Rewrite},
+		{"judge", cfg.Models.Judge, &m.Judge},
+	} {
+		chain := append([]config.ChatModel{role.cfg}, role.cfg.Fallbacks...)
+		models := make([]model.ToolCallingChatModel, 0, len(chain))
+		names := make([]string, 0, len(chain))
+		for _, c := range chain {
+			cm, err := NewChatModel(ctx, c, cfg.Ollama, cfg.OpenAI)
+			if err != nil {
+				return nil, fmt.Errorf("创建 %s 模型失败: %v", role.name, err)
+			}
+			name := c.Provider + "/" + c.Model
+			key := c.Provider + "|" + baseURL(c, cfg) + "|" + c.Model
+			if breakers[key] == nil {
+				breakers[key] = NewBreaker(cfg.LLM.BreakerThreshold, cfg.LLM.BreakerCooldown)
+			}
+			models = append(models, NewResilientModel(name, cm, ResilientOptions{
+				MaxRetries:  cfg.LLM.MaxRetries,
+				BaseBackoff: cfg.LLM.BaseBackoff,
+				Timeout:     c.Timeout,
+				Breaker:     breakers[key],
+			}))
+			names = append(names, name)
+		}
+		fmt.Printf(">>> [Chat] %s: %s\n", role.name, strings.Join(names, " -> "))
+		*role.dst = NewFallbackModel(models...)
+	}
+	return m, nil
+}
+
+// baseURL 模型实际使用的地址
+func baseURL(c config.ChatModel, cfg *config.Config) string {
+	switch {
+	case c.BaseURL != "":
+		return c.BaseURL
+	case c.Provider == config.ProviderOllama:
+		return cfg.Ollama.URL
+	}
+	return cfg.OpenAI.BaseURL
+}
+
+// NewChatModel 按提供方创建对话模型，BaseURL、APIKey 为空时使用提供方的公共配置
+func NewChatModel(ctx context.Context, c config.ChatModel, ollamaCfg config.Ollama, openaiCfg config.OpenAI) (model.ToolCallingChatModel, error) {
+	switch c.Provider {
+	case config.ProviderOllama:
+		return newOllamaChatModel(ctx, c, ollamaCfg)
+	case config.ProviderOpenAI:
+		return newOpenAIChatModel(ctx, c, openaiCfg)
+	}
+	return nil, fmt.Errorf("不支持的模型提供方: %q", c.Provider)
+}
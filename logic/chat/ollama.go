@@ -2,19 +2,39 @@ package chat
 
 import (
 	"context"
-	"log"
+	"eino-demo/config"
+	"fmt"
 
 	"github.com/cloudwego/eino-ext/components/model/ollama"
 	"github.com/cloudwego/eino/components/model"
 )
 
-func CreateOllamaChatModel(ctx context.Context, url string, model string) model.ToolCallingChatModel {
+func CreateOllamaChatModel(ctx context.Context, url string, model string) (model.ToolCallingChatModel, error) {
 	chatModel, err := ollama.NewChatModel(ctx, &ollama.ChatModelConfig{
 		BaseURL: url,   // Ollama 服务地址
 		Model:   model, // 模型名称
 	})
 	if err != nil {
-		log.Fatalf("create ollama chat model failed: %v", err)
+		return nil, fmt.Errorf("create ollama chat model failed: %w", err)
 	}
-	return chatModel
+	return chatModel, nil
+}
+
+// newOllamaChatModel Ollama 对话模型，BaseURL 为空时使用 ollama.url
+func newOllamaChatModel(ctx context.Context, c config.ChatModel, ollamaCfg config.Ollama) (model.ToolCallingChatModel, error) {
+	baseURL := c.BaseURL
+	if baseURL == "" {
+		baseURL = ollamaCfg.URL
+	}
+	opts := &ollama.Options{NumPredict: c.MaxTokens}
+	if c.Temperature != nil {
+		// Ollama 的 options 序列化时会丢掉 0 值 (回到模型默认温度)，0 按极小值发送
+		opts.Temperature = max(float32(*c.Temperature), 0.01)
+	}
+	return ollama.NewChatModel(ctx, &ollama.ChatModelConfig{
+		BaseURL: baseURL,
+		Model:   c.Model,
+		Timeout: c.Timeout,
+		Options: opts,
+	})
 }
@@ -2,24 +2,32 @@ package chat
 
 import (
 	"context"
-	"log"
-	"os"
+	"eino-demo/config"
 
 	"github.com/cloudwego/eino-ext/components/model/openai"
 	"github.com/cloudwego/eino/components/model"
 )
 
-func CreateOpenAIChatModel(ctx context.Context) model.ToolCallingChatModel {
-	key := os.Getenv("OPENAI_API_KEY")
-	modelName := os.Getenv("OPENAI_MODEL_NAME")
-	baseURL := os.Getenv("OPENAI_BASE_URL")
-	chatModel, err := openai.NewChatModel(ctx, &openai.ChatModelConfig{
-		BaseURL: baseURL,
-		Model:   modelName,
-		APIKey:  key,
-	})
-	if err != nil {
-		log.Fatalf("create openai chat model failed, err=%v", err)
+// newOpenAIChatModel OpenAI 兼容接口的对话模型
+func newOpenAIChatModel(ctx context.Context, c config.ChatModel, openaiCfg config.OpenAI) (model.ToolCallingChatModel, error) {
+	conf := &openai.ChatModelConfig{
+		BaseURL: c.BaseURL,
+		APIKey:  c.APIKey,
+		Model:   c.Model,
+		Timeout: c.Timeout,
 	}
-	return chatModel
+	if conf.BaseURL == "" {
+		conf.BaseURL = openaiCfg.BaseURL
+	}
+	if conf.APIKey == "" {
+		conf.APIKey = openaiCfg.APIKey
+	}
+	if c.Temperature != nil {
+		t := float32(*c.Temperature)
+		conf.Temperature = &t
+	}
+	if c.MaxTokens > 0 {
+		conf.MaxTokens = &c.MaxTokens
+	}
+	return openai.NewChatModel(ctx, conf)
 }
@@ -0,0 +1,342 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// ErrorKind 对话模型调用失败的类别
+type ErrorKind string
+
+const (
+	ErrKindTimeout     ErrorKind = "timeout"      // 单次调用超时
+	ErrKindUnavailable ErrorKind = "unavailable"  // 连接失败、5xx
+	ErrKindRateLimited ErrorKind = "rate_limited" // 429
+	ErrKindCircuitOpen ErrorKind = "circuit_open" // 熔断中，未发出请求
+	ErrKindRejected    ErrorKind = "rejected"     // 4xx 等请求本身的问题，重试无用
+	ErrKindCanceled    ErrorKind = "canceled"     // 调用方取消
+)
+
+// ModelError 一个模型重试用尽后的错误
+type ModelError struct {
+	Model    string    `json:"model"`
+	Kind     ErrorKind `json:"kind"`
+	Attempts int       `json:"attempts"` // 实际发出的请求次数，熔断时为 0
+	Cause    string    `json:"cause"`
+	Err      error     `json:"-"`
+}
+
+func (e *ModelError) Error() string {
+	return fmt.Sprintf("模型 %s 调用失败 (%s，共 %d 次): %s", e.Model, e.Kind, e.Attempts, e.Cause)
+}
+
+func (e *ModelError) Unwrap() error {
+	return e.Err
+}
+
+// Retryable 换个时间或换个模型可能成功
+func (e *ModelError) Retryable() bool {
+	switch e.Kind {
+	case ErrKindTimeout, ErrKindUnavailable, ErrKindRateLimited, ErrKindCircuitOpen:
+		return true
+	}
+	return false
+}
+
+// ChainError 降级链上全部模型都失败，按尝试顺序列出
+type ChainError struct {
+	Errors []*ModelError `json:"errors"`
+}
+
+func (e *ChainError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return "全部模型调用失败: " + strings.Join(msgs, "; ")
+}
+
+func (e *ChainError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
+
+// Breaker 熔断器：连续 Threshold 次可重试的失败后熔断 Cooldown，之后放行一个探测请求，成功则恢复
+// 同一个模型的多个包装共用一个 Breaker
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	open     bool
+	probing  bool
+}
+
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	if threshold <= 0 {
+		threshold = 5
+	}
+	return &Breaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// allow 是否放行本次调用；probe 为 true 表示这是熔断冷却后的探测请求，结束时要调用 release
+func (b *Breaker) allow() (ok, probe bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.open {
+		return true, false
+	}
+	if b.probing || b.now().Sub(b.openedAt) < b.cooldown {
+		return false, false
+	}
+	b.probing = true
+	return true, true
+}
+
+// release 归还探测名额：探测请求被调用方取消时既不算成功也不算失败，
+// 不归还的话熔断器会一直停在探测中，之后的请求全部被拒绝
+func (b *Breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+func (b *Breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures, b.open, b.probing = 0, false, false
+}
+
+func (b *Breaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.probing || b.failures >= b.threshold {
+		b.open, b.probing, b.openedAt = true, false, b.now()
+	}
+}
+
+// ResilientOptions 重试、超时和熔断参数
+type ResilientOptions struct {
+	MaxRetries  int           // 可重试错误的最大重试次数
+	BaseBackoff time.Duration // 第一次重试前的等待，之后指数增长并加随机抖动
+	Timeout     time.Duration // 单次调用超时 (每次重试单独计时)，0 不限制；Stream 只限制建立流，不限制之后的读取
+	Breaker     *Breaker      // 为空时单独创建
+}
+
+// resilientModel 给一个模型加上重试、超时和熔断，失败时返回 *ModelError
+type resilientModel struct {
+	name  string
+	inner model.ToolCallingChatModel
+	opts  ResilientOptions
+}
+
+// NewResilientModel name 用于日志和错误信息，如 ollama/qwen2.5:7b
+func NewResilientModel(name string, inner model.ToolCallingChatModel, opts ResilientOptions) model.ToolCallingChatModel {
+	if opts.Breaker == nil {
+		opts.Breaker = NewBreaker(5, 30*time.Second)
+	}
+	return &resilientModel{name: name, inner: inner, opts: opts}
+}
+
+func (m *resilientModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	return call(ctx, m, func(ctx context.Context) (*schema.Message, error) {
+		if m.opts.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, m.opts.Timeout)
+			defer cancel()
+		}
+		return m.inner.Generate(ctx, input, opts...)
+	})
+}
+
+// Stream 只重试建立流的调用，流建立后的读取错误由调用方处理
+// 流的读取仍依赖 ctx，返回时不能取消，所以超时用定时器实现：建立成功后停掉定时器，之后的读取不受 Timeout 限制
+func (m *resilientModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	return call(ctx, m, func(ctx context.Context) (*schema.StreamReader[*schema.Message], error) {
+		if m.opts.Timeout <= 0 {
+			return m.inner.Stream(ctx, input, opts...)
+		}
+		ctx, cancel := context.WithCancel(ctx)
+		timer := time.AfterFunc(m.opts.Timeout, cancel)
+		sr, err := m.inner.Stream(ctx, input, opts...)
+		if !timer.Stop() {
+			// 定时器已触发，即使建立成功，流也已随 ctx 取消
+			if err == nil {
+				sr.Close()
+			}
+			return nil, fmt.Errorf("建立流超过 %v: %w", m.opts.Timeout, context.DeadlineExceeded)
+		}
+		if err != nil {
+			cancel()
+		}
+		return sr, err
+	})
+}
+
+func (m *resilientModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	inner, err := m.inner.WithTools(tools)
+	if err != nil {
+		return nil, err
+	}
+	return &resilientModel{name: m.name, inner: inner, opts: m.opts}, nil
+}
+
+// call 熔断检查 + 按指数退避加抖动重试可重试错误
+func call[T any](ctx context.Context, m *resilientModel, fn func(context.Context) (T, error)) (T, error) {
+	var zero T
+	ok, probe := m.opts.Breaker.allow()
+	if !ok {
+		return zero, &ModelError{Model: m.name, Kind: ErrKindCircuitOpen, Cause: "熔断中"}
+	}
+	defer func() {
+		if probe {
+			m.opts.Breaker.release()
+		}
+	}()
+	for attempt := 0; ; attempt++ {
+		result, err := fn(ctx)
+		if err == nil {
+			m.opts.Breaker.success()
+			return result, nil
+		}
+		kind := classify(ctx, err)
+		switch kind {
+		case ErrKindTimeout, ErrKindUnavailable, ErrKindRateLimited:
+			m.opts.Breaker.failure()
+		case ErrKindRejected:
+			m.opts.Breaker.success() // 模型有响应，只是请求本身有问题
+		}
+		modelErr := &ModelError{Model: m.name, Kind: kind, Attempts: attempt + 1, Cause: err.Error(), Err: err}
+		if !modelErr.Retryable() || attempt >= m.opts.MaxRetries {
+			return zero, modelErr
+		}
+		ok, retryProbe := m.opts.Breaker.allow()
+		if !ok {
+			modelErr.Kind = ErrKindCircuitOpen
+			return zero, modelErr
+		}
+		probe = probe || retryProbe
+		backoff := m.opts.BaseBackoff << attempt
+		backoff += time.Duration(rand.Int63n(int64(backoff)/2 + 1))
+		fmt.Printf(">>> [Chat] %s 第 %d 次重试 (%s)，%v 后重试: %v\n", m.name, attempt+1, kind, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return zero, &ModelError{Model: m.name, Kind: ErrKindCanceled, Attempts: attempt + 1, Cause: ctx.Err().Error(), Err: ctx.Err()}
+		}
+	}
+}
+
+// statusPattern Ollama 的错误形如 "503 Service Unavailable"，OpenAI 兼容接口形如 "status code: 429"
+var statusPattern = regexp.MustCompile(`(?:^|status code: ?)(\d{3})\b`)
+
+// classify 按错误判断类别；ctx 是调用方的 ctx，它已结束说明是调用方取消或整体超时，不再重试
+func classify(ctx context.Context, err error) ErrorKind {
+	if ctx.Err() != nil {
+		return ErrKindCanceled
+	}
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ErrKindTimeout
+	case errors.Is(err, io.EOF),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.ECONNRESET):
+		return ErrKindUnavailable
+	}
+	msg := err.Error()
+	if m := statusPattern.FindStringSubmatch(msg); m != nil {
+		switch {
+		case m[1] == "429":
+			return ErrKindRateLimited
+		case m[1][0] == '5':
+			return ErrKindUnavailable
+		}
+		return ErrKindRejected
+	}
+	if strings.Contains(msg, "connection refused") || strings.Contains(msg, "connection reset") || strings.Contains(msg, "no such host") {
+		return ErrKindUnavailable
+	}
+	return ErrKindRejected
+}
+
+// fallbackModel 按顺序尝试多个模型，前一个失败时换下一个
+type fallbackModel struct {
+	models []model.ToolCallingChatModel
+}
+
+// NewFallbackModel 降级链，如 本地 qwen -> OpenAI 兼容接口；全部失败时返回 *ChainError
+// 链上的模型应当已用 NewResilientModel 包装，这样错误能归类
+func NewFallbackModel(models ...model.ToolCallingChatModel) model.ToolCallingChatModel {
+	if len(models) == 1 {
+		return models[0]
+	}
+	return &fallbackModel{models: models}
+}
+
+func (f *fallbackModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	return fallback(ctx, f.models, func(m model.ToolCallingChatModel) (*schema.Message, error) {
+		return m.Generate(ctx, input, opts...)
+	})
+}
+
+func (f *fallbackModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	return fallback(ctx, f.models, func(m model.ToolCallingChatModel) (*schema.StreamReader[*schema.Message], error) {
+		return m.Stream(ctx, input, opts...)
+	})
+}
+
+func (f *fallbackModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	models := make([]model.ToolCallingChatModel, len(f.models))
+	for i, m := range f.models {
+		withTools, err := m.WithTools(tools)
+		if err != nil {
+			return nil, err
+		}
+		models[i] = withTools
+	}
+	return &fallbackModel{models: models}, nil
+}
+
+func fallback[T any](ctx context.Context, models []model.ToolCallingChatModel, fn func(model.ToolCallingChatModel) (T, error)) (T, error) {
+	var zero T
+	chainErr := &ChainError{}
+	for i, m := range models {
+		result, err := fn(m)
+		if err == nil {
+			return result, nil
+		}
+		var modelErr *ModelError
+		if !errors.As(err, &modelErr) {
+			modelErr = &ModelError{Model: fmt.Sprintf("#%d", i), Kind: classify(ctx, err), Attempts: 1, Cause: err.Error(), Err: err}
+		}
+		chainErr.Errors = append(chainErr.Errors, modelErr)
+		if modelErr.Kind == ErrKindCanceled {
+			break
+		}
+		if i+1 < len(models) {
+			fmt.Printf(">>> [Chat] %s 不可用 (%s)，降级到下一个模型\n", modelErr.Model, modelErr.Kind)
+		}
+	}
+	return zero, chainErr
+}
@@ -0,0 +1,358 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// errBlock 让假模型阻塞到 ctx 结束，用于测试超时
+var errBlock = errors.New("阻塞到 ctx 结束")
+
+// fakeModel 按预设的顺序失败：schedule[i] 是第 i 次调用的结果，nil 表示成功，用完后一直成功
+type fakeModel struct {
+	name     string
+	mu       sync.Mutex
+	schedule []error
+	calls    int
+}
+
+func newFake(name string, schedule ...error) *fakeModel {
+	return &fakeModel{name: name, schedule: schedule}
+}
+
+func (f *fakeModel) next() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.calls <= len(f.schedule) {
+		return f.schedule[f.calls-1]
+	}
+	return nil
+}
+
+func (f *fakeModel) Calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+func (f *fakeModel) Generate(ctx context.Context, _ []*schema.Message, _ ...model.Option) (*schema.Message, error) {
+	err := f.next()
+	if errors.Is(err, errBlock) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return schema.AssistantMessage(f.name, nil), nil
+}
+
+func (f *fakeModel) Stream(ctx context.Context, in []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	msg, err := f.Generate(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return schema.StreamReaderFromArray([]*schema.Message{msg}), nil
+}
+
+func (f *fakeModel) WithTools([]*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return f, nil
+}
+
+var (
+	errUnavailable = errors.New("503 Service Unavailable: server busy")
+	errRateLimited = errors.New("error, status code: 429, message: too many requests")
+	errBadRequest  = errors.New("400 Bad Request: invalid prompt")
+)
+
+func fastOptions(maxRetries int, breaker *Breaker) ResilientOptions {
+	return ResilientOptions{MaxRetries: maxRetries, BaseBackoff: time.Millisecond, Breaker: breaker}
+}
+
+func generate(m model.ToolCallingChatModel) (string, error) {
+	msg, err := m.Generate(context.Background(), []*schema.Message{schema.UserMessage("hi")})
+	if err != nil {
+		return "", err
+	}
+	return msg.Content, nil
+}
+
+func TestRetryUntilSuccess(t *testing.T) {
+	fake := newFake("qwen", errUnavailable, errRateLimited, fmt.Errorf("dial: %w", syscall.ECONNREFUSED))
+	m := NewResilientModel("ollama/qwen", fake, fastOptions(3, nil))
+
+	got, err := generate(m)
+	if err != nil {
+		t.Fatalf("重试后应成功: %v", err)
+	}
+	if got != "qwen" || fake.Calls() != 4 {
+		t.Fatalf("got %q, calls %d, want qwen / 4", got, fake.Calls())
+	}
+}
+
+func TestRetriesExhausted(t *testing.T) {
+	fake := newFake("qwen", errUnavailable, errUnavailable, errUnavailable)
+	m := NewResilientModel("ollama/qwen", fake, fastOptions(2, nil))
+
+	_, err := generate(m)
+	var modelErr *ModelError
+	if !errors.As(err, &modelErr) {
+		t.Fatalf("应返回 *ModelError: %v", err)
+	}
+	if modelErr.Kind != ErrKindUnavailable || modelErr.Attempts != 3 || modelErr.Model != "ollama/qwen" {
+		t.Fatalf("unexpected error: %+v", modelErr)
+	}
+	if !errors.Is(err, errUnavailable) {
+		t.Fatal("应能取到原始错误")
+	}
+}
+
+func TestRejectedNotRetried(t *testing.T) {
+	fake := newFake("qwen", errBadRequest)
+	m := NewResilientModel("ollama/qwen", fake, fastOptions(3, nil))
+
+	_, err := generate(m)
+	var modelErr *ModelError
+	if !errors.As(err, &modelErr) || modelErr.Kind != ErrKindRejected || modelErr.Retryable() {
+		t.Fatalf("400 应直接返回 rejected: %v", err)
+	}
+	if fake.Calls() != 1 {
+		t.Fatalf("calls = %d, want 1", fake.Calls())
+	}
+}
+
+func TestTimeoutPerAttempt(t *testing.T) {
+	fake := newFake("qwen", errBlock)
+	opts := fastOptions(1, nil)
+	opts.Timeout = 20 * time.Millisecond
+	m := NewResilientModel("ollama/qwen", fake, opts)
+
+	got, err := generate(m)
+	if err != nil || got != "qwen" {
+		t.Fatalf("超时的那次应重试成功: %q %v", got, err)
+	}
+
+	fake = newFake("qwen", errBlock, errBlock)
+	m = NewResilientModel("ollama/qwen", fake, opts)
+	_, err = generate(m)
+	var modelErr *ModelError
+	if !errors.As(err, &modelErr) || modelErr.Kind != ErrKindTimeout {
+		t.Fatalf("应返回 timeout: %v", err)
+	}
+}
+
+// slowStreamModel 立即建立流，delay 之后才返回第一块内容；ctx 先结束时流返回 ctx 的错误
+type slowStreamModel struct {
+	fakeModel
+	delay time.Duration
+}
+
+func (f *slowStreamModel) Stream(ctx context.Context, _ []*schema.Message, _ ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	sr, sw := schema.Pipe[*schema.Message](1)
+	go func() {
+		defer sw.Close()
+		select {
+		case <-time.After(f.delay):
+			sw.Send(schema.AssistantMessage("slow", nil), nil)
+		case <-ctx.Done():
+			sw.Send(nil, ctx.Err())
+		}
+	}()
+	return sr, nil
+}
+
+func TestStreamTimeout(t *testing.T) {
+	opts := fastOptions(1, nil)
+	opts.Timeout = 20 * time.Millisecond
+
+	// 建立流超时，重试一次后仍超时
+	fake := newFake("qwen", errBlock, errBlock)
+	_, err := NewResilientModel("ollama/qwen", fake, opts).Stream(context.Background(), nil)
+	var modelErr *ModelError
+	if !errors.As(err, &modelErr) || modelErr.Kind != ErrKindTimeout || fake.Calls() != 2 {
+		t.Fatalf("建立流超时应重试后返回 timeout: %v (calls=%d)", err, fake.Calls())
+	}
+
+	// 流建立后读取超过 Timeout 不受影响
+	sr, err := NewResilientModel("ollama/qwen", &slowStreamModel{delay: 3 * opts.Timeout}, opts).Stream(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sr.Close()
+	msg, err := sr.Recv()
+	if err != nil || msg.Content != "slow" {
+		t.Fatalf("流建立后的读取不应被超时取消: %v %v", msg, err)
+	}
+}
+
+func TestCallerCancelNotRetried(t *testing.T) {
+	fake := newFake("qwen", errBlock)
+	m := NewResilientModel("ollama/qwen", fake, fastOptions(3, nil))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := m.Generate(ctx, nil)
+	var modelErr *ModelError
+	if !errors.As(err, &modelErr) || modelErr.Kind != ErrKindCanceled {
+		t.Fatalf("调用方超时不应重试: %v", err)
+	}
+	if fake.Calls() != 1 {
+		t.Fatalf("calls = %d, want 1", fake.Calls())
+	}
+}
+
+func TestBreakerOpensAndRecovers(t *testing.T) {
+	now := time.Now()
+	breaker := NewBreaker(3, time.Minute)
+	breaker.now = func() time.Time { return now }
+	fake := newFake("qwen", errUnavailable, errUnavailable, errUnavailable, errUnavailable)
+	m := NewResilientModel("ollama/qwen", fake, fastOptions(0, breaker))
+
+	for i := 0; i < 3; i++ {
+		if _, err := generate(m); err == nil {
+			t.Fatalf("第 %d 次应失败", i+1)
+		}
+	}
+	// 连续 3 次失败后熔断，不再发请求
+	_, err := generate(m)
+	var modelErr *ModelError
+	if !errors.As(err, &modelErr) || modelErr.Kind != ErrKindCircuitOpen || modelErr.Attempts != 0 {
+		t.Fatalf("应熔断: %v", err)
+	}
+	if fake.Calls() != 3 {
+		t.Fatalf("熔断期间不应调用模型, calls = %d", fake.Calls())
+	}
+
+	// 冷却后放行一次探测，探测失败重新熔断
+	now = now.Add(time.Minute)
+	if _, err := generate(m); !errors.As(err, &modelErr) || modelErr.Kind != ErrKindUnavailable {
+		t.Fatalf("探测请求应发出并失败: %v", err)
+	}
+	if _, err := generate(m); !errors.As(err, &modelErr) || modelErr.Kind != ErrKindCircuitOpen {
+		t.Fatalf("探测失败后应重新熔断: %v", err)
+	}
+
+	// 再次冷却，探测成功后恢复
+	now = now.Add(time.Minute)
+	for i := 0; i < 2; i++ {
+		if _, err := generate(m); err != nil {
+			t.Fatalf("恢复后应成功: %v", err)
+		}
+	}
+	if fake.Calls() != 6 {
+		t.Fatalf("calls = %d, want 6", fake.Calls())
+	}
+}
+
+func TestBreakerProbeCanceled(t *testing.T) {
+	now := time.Now()
+	breaker := NewBreaker(1, time.Minute)
+	breaker.now = func() time.Time { return now }
+	fake := newFake("qwen", errUnavailable, errBlock)
+	m := NewResilientModel("ollama/qwen", fake, fastOptions(0, breaker))
+
+	if _, err := generate(m); err == nil {
+		t.Fatal("第 1 次应失败并熔断")
+	}
+
+	// 冷却后的探测请求被调用方取消，不记成功也不记失败
+	now = now.Add(time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := m.Generate(ctx, nil)
+	var modelErr *ModelError
+	if !errors.As(err, &modelErr) || modelErr.Kind != ErrKindCanceled {
+		t.Fatalf("探测请求应被取消: %v", err)
+	}
+
+	// 探测名额已归还，下一次请求能发到模型并恢复
+	if _, err := generate(m); err != nil {
+		t.Fatalf("取消探测后应能再次探测: %v", err)
+	}
+	if fake.Calls() != 3 {
+		t.Fatalf("calls = %d, want 3", fake.Calls())
+	}
+}
+
+func TestBreakerSharedAcrossWrappers(t *testing.T) {
+	breaker := NewBreaker(2, time.Minute)
+	fake := newFake("qwen", errUnavailable, errUnavailable)
+	intent := NewResilientModel("ollama/qwen", fake, fastOptions(0, breaker))
+	answer := NewResilientModel("ollama/qwen", fake, fastOptions(0, breaker))
+
+	_, _ = generate(intent)
+	_, _ = generate(answer)
+	_, err := generate(intent)
+	var modelErr *ModelError
+	if !errors.As(err, &modelErr) || modelErr.Kind != ErrKindCircuitOpen {
+		t.Fatalf("同一模型的失败应累计到同一个熔断器: %v", err)
+	}
+}
+
+func TestFallbackChain(t *testing.T) {
+	local := newFake("qwen", errUnavailable, errUnavailable, errUnavailable)
+	remote := newFake("gpt")
+	m := NewFallbackModel(
+		NewResilientModel("ollama/qwen", local, fastOptions(1, nil)),
+		NewResilientModel("openai/gpt", remote, fastOptions(1, nil)),
+	)
+
+	got, err := generate(m)
+	if err != nil || got != "gpt" {
+		t.Fatalf("应降级到 gpt: %q %v", got, err)
+	}
+	if local.Calls() != 2 || remote.Calls() != 1 {
+		t.Fatalf("calls local=%d remote=%d, want 2 / 1", local.Calls(), remote.Calls())
+	}
+
+	// 下一次仍先试本地：第 3 次失败，重试成功
+	got, err = generate(m)
+	if err != nil || got != "qwen" {
+		t.Fatalf("本地恢复后应优先用本地: %q %v", got, err)
+	}
+}
+
+func TestFallbackAllFail(t *testing.T) {
+	m := NewFallbackModel(
+		NewResilientModel("ollama/qwen", newFake("qwen", errUnavailable), fastOptions(0, nil)),
+		NewResilientModel("openai/gpt", newFake("gpt", errRateLimited), fastOptions(0, nil)),
+	)
+
+	_, err := generate(m)
+	var chainErr *ChainError
+	if !errors.As(err, &chainErr) || len(chainErr.Errors) != 2 {
+		t.Fatalf("应返回两个模型的错误: %v", err)
+	}
+	if chainErr.Errors[0].Kind != ErrKindUnavailable || chainErr.Errors[1].Kind != ErrKindRateLimited {
+		t.Fatalf("unexpected kinds: %s, %s", chainErr.Errors[0].Kind, chainErr.Errors[1].Kind)
+	}
+	var modelErr *ModelError
+	if !errors.As(err, &modelErr) || modelErr.Model != "ollama/qwen" {
+		t.Fatalf("errors.As 应能取到第一个模型的错误: %v", err)
+	}
+}
+
+func TestFallbackStream(t *testing.T) {
+	m := NewFallbackModel(
+		NewResilientModel("ollama/qwen", newFake("qwen", errBadRequest), fastOptions(0, nil)),
+		NewResilientModel("openai/gpt", newFake("gpt"), fastOptions(0, nil)),
+	)
+	sr, err := m.Stream(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("流式调用应降级成功: %v", err)
+	}
+	defer sr.Close()
+	msg, err := sr.Recv()
+	if err != nil || msg.Content != "gpt" {
+		t.Fatalf("got %v %v", msg, err)
+	}
+}
@@ -1,23 +1,24 @@
 package chat
 
 import (
+	"fmt"
 	"io"
 	"log"
 
 	"github.com/cloudwego/eino/schema"
 )
 
-func ReportStream(sr *schema.StreamReader[*schema.Message]) {
+func ReportStream(sr *schema.StreamReader[*schema.Message]) error {
 	defer sr.Close()
 
 	i := 0
 	for {
 		message, err := sr.Recv()
 		if err == io.EOF {
-			return
+			return nil
 		}
 		if err != nil {
-			log.Fatalf("recv failed: %v", err)
+			return fmt.Errorf("recv failed: %w", err)
 		}
 		log.Printf("message[%d]: %+v\n", i, message)
 		i++
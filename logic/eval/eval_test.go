@@ -0,0 +1,301 @@
+package eval
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"eino-demo/types"
+	"eino-demo/vars"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestRankMetrics(t *testing.T) {
+	relevant := toSet([]string{"a", "c", "e"})
+	ranked := []string{"b", "a", "c", "d"}
+
+	if got := Recall(ranked, relevant, 2); !near(got, 1.0/3) {
+		t.Errorf("recall@2 = %v", got)
+	}
+	if got := Recall(ranked, relevant, 10); !near(got, 2.0/3) {
+		t.Errorf("recall@10 = %v", got)
+	}
+	if got := ReciprocalRank(ranked, relevant); !near(got, 0.5) {
+		t.Errorf("rr = %v", got)
+	}
+	if got := ReciprocalRank([]string{"x"}, relevant); got != 0 {
+		t.Errorf("没有命中时 rr 应为 0: %v", got)
+	}
+
+	// 相关项在第 2、3 位；理想排序前 3 位都是相关项
+	dcg := 1/math.Log2(3) + 1/math.Log2(4)
+	ideal := 1 + 1/math.Log2(3) + 1/math.Log2(4)
+	if got := NDCG(ranked, relevant, 3); !near(got, dcg/ideal) {
+		t.Errorf("ndcg@3 = %v, want %v", got, dcg/ideal)
+	}
+	if got := NDCG([]string{"a", "c", "e"}, relevant, 3); !near(got, 1) {
+		t.Errorf("理想排序 ndcg 应为 1: %v", got)
+	}
+	// 相关项少于 k 时理想得分只算相关项个数
+	if got := NDCG([]string{"a"}, toSet([]string{"a"}), 10); !near(got, 1) {
+		t.Errorf("ndcg@10 = %v", got)
+	}
+}
+
+func TestParseFileName(t *testing.T) {
+	cases := []struct {
+		name   string
+		amount float64
+		ok     bool
+	}{
+		{"2023-01-03_众信置业有限公司_深蓝网络服务公司_物资采购合同_2913000.00元_48.pdf", 2913000, true},
+		{"2023-01-04_未来置业有限公司_众信科技有限公司_物资采购合同_202.17万元_95.pdf", 2021700, true},
+		{"2023-01-21_北斗建筑工程公司_深蓝科技有限公司_劳动合同_1.64亿元_63.pdf", 164000000, true},
+		{"2023-03-03_泰坦建筑工程公司_深蓝商贸有限公司_保密协议(NDA)_RMB1136000_82.pdf", 1136000, true},
+		{"2023-06-19_泰坦网络服务公司_千帆物流集团_股权转让协议_二十百元整_73.pdf", 2000, true},
+		{"2023-02-06_深蓝网络服务公司_极客商贸有限公司_装修工程合同_五十元整_14.pdf", 50, true},
+		{"2023-02-06_深蓝网络服务公司_极客商贸有限公司_装修工程合同_一百二十元_14.pdf", 0, false},
+	}
+	for _, c := range cases {
+		meta, err := ParseFileName(c.name)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if meta.AmountOK != c.ok || (c.ok && !near(meta.Amount, c.amount)) {
+			t.Errorf("%s: amount = %v (%v), want %v (%v)", c.name, meta.Amount, meta.AmountOK, c.amount, c.ok)
+		}
+	}
+
+	meta, _ := ParseFileName("2023-03-03_泰坦建筑工程公司_深蓝商贸有限公司_保密协议(NDA)_RMB1136000_82.pdf")
+	if meta.PartyA != "泰坦建筑工程公司" || meta.PartyB != "深蓝商贸有限公司" || meta.ContractType != "保密协议(NDA)" || meta.SignDate.Year() != 2023 {
+		t.Errorf("unexpected meta: %+v", meta)
+	}
+	if _, err := ParseFileName("合同.pdf"); err == nil {
+		t.Error("格式不对的文件名应报错")
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	var files []FileMeta
+	for _, name := range []string{
+		"2023-01-03_众信置业有限公司_王五_房屋租赁合同_100000.00元_1.pdf",
+		"2023-05-03_众信置业有限公司_王五_房屋租赁合同_300000.00元_2.pdf",
+		"2024-02-03_众信置业有限公司_李四_房屋租赁合同_500000.00元_3.pdf",
+		"2023-07-03_北斗科技有限公司_王五_软件开发合同_十百元整_4.pdf",
+		"2023-08-03_北斗科技有限公司_李四_软件开发合同_一百二十元_5.pdf",
+	} {
+		meta, err := ParseFileName(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, meta)
+	}
+
+	set := Generate("test_file", files, 0)
+	byQuery := make(map[string]GoldenQuery)
+	for _, q := range set.Queries {
+		if q.ID == "" || q.Intent == "" {
+			t.Errorf("unexpected query: %+v", q)
+		}
+		byQuery[q.Query] = q
+	}
+
+	q, ok := byQuery["众信置业有限公司和王五签署的房屋租赁合同"]
+	if !ok || len(q.RelevantFiles) != 2 || q.Kind != KindPartyPair {
+		t.Errorf("同一对参与方的两份合同应合并为一条查询: %+v", q)
+	}
+	if q, ok := byQuery["王五在2023年签署的合同"]; !ok || len(q.RelevantFiles) != 3 || q.Filters.DateRange.End != "2023-12-31" {
+		t.Errorf("party_year: %+v", q)
+	}
+	if q, ok := byQuery["2024年签署的房屋租赁合同"]; !ok || len(q.RelevantFiles) != 1 {
+		t.Errorf("type_year: %+v", q)
+	}
+	q, ok = byQuery["金额大于30万元的房屋租赁合同"]
+	if !ok || len(q.RelevantFiles) != 1 || *q.Filters.AmountRange.Min != 300000 {
+		t.Errorf("type_amount: %+v", q)
+	}
+	for _, q := range set.Queries {
+		if q.Kind == KindTypeAmount && q.Filters.ContractType == "软件开发合同" {
+			t.Errorf("有金额无法解析的类型不应生成金额查询: %s", q.Query)
+		}
+	}
+
+	q, ok = byQuery["众信置业有限公司和王五签署的房屋租赁合同的付款条款"]
+	if !ok || q.Intent != vars.CL || len(q.RelevantFiles) != 2 || q.Filters.ClauseType != "payment" {
+		t.Errorf("clause: %+v", q)
+	}
+	q, ok = byQuery["2023年签署的房屋租赁合同中关于租金和押金的约定"]
+	if !ok || q.Intent != vars.HY || len(q.RelevantFiles) != 2 || q.Filters.ClauseType != "payment" {
+		t.Errorf("type_clause: %+v", q)
+	}
+	if len(q.RelevantChunkRefs) != 2 || q.RelevantChunkRefs[0].Contains != "租金及押金" {
+		t.Errorf("type_clause 应按条款标题标注相关切片: %+v", q.RelevantChunkRefs)
+	}
+	if q := byQuery["众信置业有限公司和王五签署的房屋租赁合同"]; q.Intent != vars.PG {
+		t.Errorf("列表类查询期望结构化检索: %+v", q)
+	}
+
+	if n := len(Generate("test_file", files, 1).Queries); n != 6 {
+		t.Errorf("每种模板 1 条应共 6 条, got %d", n)
+	}
+}
+
+func TestCompareFilters(t *testing.T) {
+	min := 300000.0
+	want := &types.FilterConditions{
+		AnyParty:     []string{"众信置业有限公司", "王五"},
+		ContractType: "房屋租赁合同",
+		DateRange:    &types.DateRange{Start: "2023-01-01", End: "2023-12-31"},
+		AmountRange:  &types.AmountRange{Min: &min},
+	}
+	gotMin := 300000.0
+	got := &types.FilterConditions{
+		AnyParty:     []string{"王五", "众信置业"},
+		ContractType: "租赁",
+		DateRange:    &types.DateRange{Start: "2023-01-01", End: "2023-12-31"},
+		AmountRange:  &types.AmountRange{Min: &gotMin},
+	}
+	for field, ok := range CompareFilters(want, got) {
+		if !ok {
+			t.Errorf("%s 应一致", field)
+		}
+	}
+
+	got.AnyParty = []string{"王五"}
+	got.AmountRange.Max = &gotMin
+	fields := CompareFilters(want, got)
+	if fields[FieldAnyParty] || fields[FieldAmountRange] || !fields[FieldDateRange] {
+		t.Errorf("unexpected: %v", fields)
+	}
+	if len(CompareFilters(&types.FilterConditions{ContractType: "租赁"}, nil)) != 1 {
+		t.Error("只比较期望中填写了的字段")
+	}
+}
+
+func TestRunner(t *testing.T) {
+	set := &GoldenSet{Name: "test", Queries: []GoldenQuery{
+		{
+			ID:             "q1",
+			Query:          "王五签署的合同",
+			RelevantFiles:  []string{"a.pdf", "b.pdf", "missing.pdf"},
+			RelevantChunks: []string{"c2"},
+			Intent:         vars.PG,
+			Filters:        &types.FilterConditions{AnyParty: []string{"王五"}},
+		},
+		{ID: "q2", Query: "失败的查询", RelevantDocs: []string{"doc-a"}, Intent: vars.PG},
+		{
+			ID:    "q3",
+			Query: "租金的约定",
+			RelevantChunkRefs: []ChunkRef{
+				{File: "a.pdf", Contains: "租金"},
+				{File: "b.pdf", Contains: "押金"},
+				{File: "missing.pdf", Contains: "租金"},
+			},
+		},
+	}}
+	search := func(_ context.Context, req types.SearchRequest) (*types.SearchResponse, error) {
+		if req.Query == "失败的查询" {
+			return nil, errors.New("boom")
+		}
+		return &types.SearchResponse{
+			Intent:    vars.HY,
+			Filters:   &types.FilterConditions{AnyParty: []string{"王五"}},
+			Contracts: []types.ContractHit{{DocID: "doc-x"}, {DocID: "doc-a"}},
+			Chunks:    []types.ChunkHit{{ID: "c1", DocID: "doc-a"}, {ID: "c2", DocID: "doc-b"}},
+		}, nil
+	}
+	resolve := &fakeResolver{
+		docs:   map[string]string{"a.pdf": "doc-a", "b.pdf": "doc-b"},
+		chunks: map[string][]string{"doc-a#租金": {"c1", "c3"}},
+	}
+
+	report := NewRunner(search, resolve, []int{3, 1}).Run(context.Background(), set)
+	q1 := report.Queries[0]
+	if len(q1.Unresolved) != 1 || len(q1.Relevant) != 2 {
+		t.Fatalf("relevant = %v, unresolved = %v", q1.Relevant, q1.Unresolved)
+	}
+	// 排名: doc-x, doc-a, doc-b
+	checks := map[string]float64{
+		"doc_recall@1":              0,
+		"doc_recall@3":              1,
+		"doc_mrr":                   0.5,
+		"chunk_recall@1":            0,
+		"chunk_mrr":                 0.5,
+		MetricIntentAccuracy:        0,
+		MetricFilterAccuracy:        1,
+		"filter_accuracy.any_party": 1,
+	}
+	for name, want := range checks {
+		if got, ok := q1.Metrics[name]; !ok || !near(got, want) {
+			t.Errorf("%s = %v (%v), want %v", name, got, ok, want)
+		}
+	}
+
+	q3 := report.Queries[2]
+	if len(q3.RelevantChunks) != 2 || len(q3.Unresolved) != 2 {
+		t.Fatalf("没有匹配到切片或合同的描述应记为未解析: %+v", q3)
+	}
+	// 排名: c1, c2；相关: c1, c3
+	if !near(q3.Metrics["chunk_recall@3"], 0.5) || !near(q3.Metrics["chunk_mrr"], 1) {
+		t.Errorf("q3 metrics: %v", q3.Metrics)
+	}
+	if _, ok := q3.Metrics["doc_recall@3"]; ok {
+		t.Error("没有标注相关合同时不计算 doc 指标")
+	}
+	if resolve.calls["a.pdf"] != 1 {
+		t.Errorf("同一文件只应解析一次: %v", resolve.calls)
+	}
+
+	q2 := report.Queries[1]
+	if q2.Error == "" || q2.Metrics["doc_recall@3"] != 0 || q2.Metrics[MetricIntentAccuracy] != 0 {
+		t.Errorf("失败的查询各项指标应为 0: %+v", q2)
+	}
+	if report.Summary.Errors != 1 || report.Summary.Counts["doc_recall@3"] != 2 || report.Summary.Counts["chunk_mrr"] != 2 || report.Summary.Counts[MetricFilterAccuracy] != 1 {
+		t.Errorf("summary: %+v", report.Summary)
+	}
+	if !near(report.Summary.Metrics["doc_recall@3"], 0.5) {
+		t.Errorf("doc_recall@3 平均值 = %v", report.Summary.Metrics["doc_recall@3"])
+	}
+
+	deltas := Compare(report, &Report{Summary: Summary{Metrics: map[string]float64{"doc_recall@3": 0.75, "other": 1}}})
+	if len(deltas) != 1 || !near(deltas[0].Diff(), 0.25) {
+		t.Errorf("deltas: %+v", deltas)
+	}
+}
+
+type fakeResolver struct {
+	docs   map[string]string
+	chunks map[string][]string
+	calls  map[string]int
+}
+
+func (f *fakeResolver) DocID(_ context.Context, file string) (string, error) {
+	if f.calls == nil {
+		f.calls = make(map[string]int)
+	}
+	f.calls[file]++
+	if id, ok := f.docs[file]; ok {
+		return id, nil
+	}
+	return "", errors.New("not found")
+}
+
+func (f *fakeResolver) ChunkIDs(_ context.Context, docID string, ref ChunkRef) ([]string, error) {
+	return f.chunks[docID+"#"+ref.Contains], nil
+}
+
+func TestMergeGoldenSets(t *testing.T) {
+	a := &GoldenSet{Name: "generated", Queries: []GoldenQuery{{ID: "q1"}, {ID: "q2"}}}
+	b := &GoldenSet{Name: "manual", Queries: []GoldenQuery{{ID: "m1"}}}
+	merged, err := MergeGoldenSets(a, b)
+	if err != nil || merged.Name != "generated+manual" || len(merged.Queries) != 3 {
+		t.Fatalf("merged = %+v, err = %v", merged, err)
+	}
+	if _, err := MergeGoldenSets(a, &GoldenSet{Name: "dup", Queries: []GoldenQuery{{ID: "q2"}}}); err == nil {
+		t.Error("查询 ID 重复应报错")
+	}
+}
@@ -0,0 +1,357 @@
+package eval
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"eino-demo/logic/ingestion/clause"
+	"eino-demo/logic/ingestion/extract"
+	"eino-demo/types"
+	"eino-demo/vars"
+)
+
+// 生成查询的模板
+const (
+	KindPartyPair  = "party_pair"  // {甲方}和{乙方}签署的{合同类型}
+	KindPartyYear  = "party_year"  // {参与方}在{年份}年签署的合同
+	KindTypeYear   = "type_year"   // {年份}年签署的{合同类型}
+	KindTypeAmount = "type_amount" // 金额大于{该类型金额中位数}的{合同类型}
+	KindClause     = "clause"      // {甲方}和{乙方}签署的{合同类型}的{条款}
+	KindTypeClause = "type_clause" // {年份}年签署的{合同类型}中关于{条款内容}的约定
+)
+
+// clauseSpec 测试合同中可以按条款查询的一类条款
+type clauseSpec struct {
+	Heading string // 条款标题，切片原文包含标题的即为相关切片
+	Topic   string // 查询中的说法
+}
+
+// templateClauses deploy/test_file 下每种合同按固定模板生成，条款标题取自模板；
+// 股权转让协议的条款标题归不到标准条款类型，不生成条款查询
+var templateClauses = map[string]clauseSpec{
+	"物资采购合同":    {"验收标准", "验收"},
+	"软件开发合同":    {"开发费用", "开发费用支付"},
+	"劳动合同":      {"劳动报酬", "劳动报酬"},
+	"房屋租赁合同":    {"租金及押金", "租金和押金"},
+	"装修工程合同":    {"工期要求", "工期"},
+	"保密协议(NDA)": {"违约责任", "违约责任"},
+	"借款合同":      {"利率与还款", "还款"},
+	"品牌加盟合同":    {"费用支付", "加盟费用支付"},
+	"居间服务合同":    {"居间费用", "居间费用"},
+}
+
+// FileMeta 测试合同文件名中的元数据，文件名形如 签署日期_甲方_乙方_合同类型_金额_序号.pdf
+type FileMeta struct {
+	FileName     string
+	SignDate     time.Time
+	PartyA       string
+	PartyB       string
+	ContractType string
+	Amount       float64
+	AmountOK     bool // 金额能解析
+}
+
+// ParseFileName 解析 deploy/test_file 下的文件名
+// 金额有 2913000.00元、202.17万元、RMB1136000 和 二十百元整 (数字 × 单位) 几种写法
+func ParseFileName(name string) (FileMeta, error) {
+	base := strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+	parts := strings.Split(base, "_")
+	if len(parts) != 6 {
+		return FileMeta{}, fmt.Errorf("文件名 %s 不是 日期_甲方_乙方_类型_金额_序号 格式", name)
+	}
+	date, err := time.Parse("2006-01-02", parts[0])
+	if err != nil {
+		return FileMeta{}, fmt.Errorf("文件名 %s 的日期无法解析: %v", name, err)
+	}
+	meta := FileMeta{
+		FileName:     filepath.Base(name),
+		SignDate:     date,
+		PartyA:       parts[1],
+		PartyB:       parts[2],
+		ContractType: parts[3],
+	}
+	meta.Amount, meta.AmountOK = parseFileAmount(parts[4])
+	return meta, nil
+}
+
+func parseFileAmount(s string) (float64, bool) {
+	if amount := extract.ParseAmount(strings.TrimPrefix(s, "RMB")); amount > 0 {
+		return amount, true
+	}
+	return parseChineseAmount(s)
+}
+
+var (
+	chineseDigits = map[rune]float64{'一': 1, '二': 2, '两': 2, '三': 3, '四': 4, '五': 5, '六': 6, '七': 7, '八': 8, '九': 9}
+	chineseUnits  = map[rune]float64{'十': 10, '百': 100, '千': 1000, '万': 1e4, '亿': 1e8}
+)
+
+// parseChineseAmount 测试文件里的中文金额是 可选的数字 + 若干单位 相乘，如 二十百 = 2000、十十 = 100
+func parseChineseAmount(s string) (float64, bool) {
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "整"), "元")
+	runes := []rune(s)
+	if len(runes) == 0 {
+		return 0, false
+	}
+	amount := 1.0
+	if d, ok := chineseDigits[runes[0]]; ok {
+		amount, runes = d, runes[1:]
+	}
+	for _, r := range runes {
+		unit, ok := chineseUnits[r]
+		if !ok {
+			return 0, false
+		}
+		amount *= unit
+	}
+	return amount, true
+}
+
+// Generate 由文件名元数据生成标注集：相关合同按全部文件计算；列表类查询期望结构化检索，
+// 指定合同的条款查询期望 clause_lookup，按类型查条款内容的期望 hybrid 并标注相关切片
+// perKind 为每种模板最多生成的查询数 (在候选中均匀抽取)，0 表示不限
+func Generate(name string, files []FileMeta, perKind int) *GoldenSet {
+	set := &GoldenSet{Name: name}
+	for _, kind := range []struct {
+		name    string
+		intent  string
+		queries []GoldenQuery
+	}{
+		{KindPartyPair, vars.PG, partyPairQueries(files)},
+		{KindPartyYear, vars.PG, partyYearQueries(files)},
+		{KindTypeYear, vars.PG, typeYearQueries(files)},
+		{KindTypeAmount, vars.PG, typeAmountQueries(files)},
+		{KindClause, vars.CL, clauseQueries(files)},
+		{KindTypeClause, vars.HY, typeClauseQueries(files)},
+	} {
+		for i, q := range sample(kind.queries, perKind) {
+			q.ID = fmt.Sprintf("%s-%03d", kind.name, i+1)
+			q.Kind = kind.name
+			q.Intent = kind.intent
+			set.Queries = append(set.Queries, q)
+		}
+	}
+	return set
+}
+
+func partyPairQueries(files []FileMeta) []GoldenQuery {
+	var queries []GoldenQuery
+	seen := make(map[string]bool)
+	for _, f := range files {
+		query := fmt.Sprintf("%s和%s签署的%s", f.PartyA, f.PartyB, f.ContractType)
+		if seen[query] {
+			continue
+		}
+		seen[query] = true
+		queries = append(queries, GoldenQuery{
+			Query: query,
+			RelevantFiles: matchFiles(files, func(m FileMeta) bool {
+				return hasParty(m, f.PartyA) && hasParty(m, f.PartyB) && m.ContractType == f.ContractType
+			}),
+			Filters: &types.FilterConditions{AnyParty: []string{f.PartyA, f.PartyB}, ContractType: f.ContractType},
+		})
+	}
+	return queries
+}
+
+func partyYearQueries(files []FileMeta) []GoldenQuery {
+	var queries []GoldenQuery
+	seen := make(map[string]bool)
+	for _, f := range files {
+		year := f.SignDate.Year()
+		for _, p := range []string{f.PartyA, f.PartyB} {
+			query := fmt.Sprintf("%s在%d年签署的合同", p, year)
+			if seen[query] {
+				continue
+			}
+			seen[query] = true
+			queries = append(queries, GoldenQuery{
+				Query: query,
+				RelevantFiles: matchFiles(files, func(m FileMeta) bool {
+					return hasParty(m, p) && m.SignDate.Year() == year
+				}),
+				Filters: &types.FilterConditions{AnyParty: []string{p}, DateRange: yearRange(year)},
+			})
+		}
+	}
+	return queries
+}
+
+func typeYearQueries(files []FileMeta) []GoldenQuery {
+	var queries []GoldenQuery
+	seen := make(map[string]bool)
+	for _, f := range files {
+		year := f.SignDate.Year()
+		query := fmt.Sprintf("%d年签署的%s", year, f.ContractType)
+		if seen[query] {
+			continue
+		}
+		seen[query] = true
+		queries = append(queries, GoldenQuery{
+			Query: query,
+			RelevantFiles: matchFiles(files, func(m FileMeta) bool {
+				return m.ContractType == f.ContractType && m.SignDate.Year() == year
+			}),
+			Filters: &types.FilterConditions{ContractType: f.ContractType, DateRange: yearRange(year)},
+		})
+	}
+	return queries
+}
+
+// typeAmountQueries 每种合同类型一条，门槛取金额中位数；有金额无法解析的类型跳过 (相关合同算不准)
+func typeAmountQueries(files []FileMeta) []GoldenQuery {
+	byType := make(map[string][]FileMeta)
+	var contractTypes []string
+	for _, f := range files {
+		if byType[f.ContractType] == nil {
+			contractTypes = append(contractTypes, f.ContractType)
+		}
+		byType[f.ContractType] = append(byType[f.ContractType], f)
+	}
+
+	var queries []GoldenQuery
+	for _, contractType := range contractTypes {
+		group := byType[contractType]
+		amounts := make([]float64, 0, len(group))
+		for _, f := range group {
+			if !f.AmountOK {
+				amounts = nil
+				break
+			}
+			amounts = append(amounts, f.Amount)
+		}
+		if len(amounts) < 2 {
+			continue
+		}
+		sort.Float64s(amounts)
+		threshold := roundAmount(amounts[len(amounts)/2])
+		relevant := matchFiles(group, func(m FileMeta) bool { return m.Amount > threshold })
+		if len(relevant) == 0 {
+			continue
+		}
+		queries = append(queries, GoldenQuery{
+			Query:         fmt.Sprintf("金额大于%s的%s", formatAmount(threshold), contractType),
+			RelevantFiles: relevant,
+			Filters:       &types.FilterConditions{ContractType: contractType, AmountRange: &types.AmountRange{Min: &threshold}},
+		})
+	}
+	return queries
+}
+
+// clauseQueries 指定参与方和类型的合同的某类条款；clause_lookup 直接返回 contract_clauses 中的条款原文，
+// 不返回切片，只标注相关合同
+func clauseQueries(files []FileMeta) []GoldenQuery {
+	var queries []GoldenQuery
+	seen := make(map[string]bool)
+	for _, f := range files {
+		spec, ok := templateClauses[f.ContractType]
+		if !ok {
+			continue
+		}
+		clauseType := clause.Classify(spec.Heading, "")
+		query := fmt.Sprintf("%s和%s签署的%s的%s", f.PartyA, f.PartyB, f.ContractType, clause.Label(clauseType))
+		if seen[query] {
+			continue
+		}
+		seen[query] = true
+		queries = append(queries, GoldenQuery{
+			Query: query,
+			RelevantFiles: matchFiles(files, func(m FileMeta) bool {
+				return hasParty(m, f.PartyA) && hasParty(m, f.PartyB) && m.ContractType == f.ContractType
+			}),
+			Filters: &types.FilterConditions{AnyParty: []string{f.PartyA, f.PartyB}, ContractType: f.ContractType, ClauseType: clauseType},
+		})
+	}
+	return queries
+}
+
+// typeClauseQueries 某年某类合同中的某类条款，相关切片为这些合同中包含条款标题的切片
+func typeClauseQueries(files []FileMeta) []GoldenQuery {
+	var queries []GoldenQuery
+	seen := make(map[string]bool)
+	for _, f := range files {
+		spec, ok := templateClauses[f.ContractType]
+		if !ok {
+			continue
+		}
+		year := f.SignDate.Year()
+		query := fmt.Sprintf("%d年签署的%s中关于%s的约定", year, f.ContractType, spec.Topic)
+		if seen[query] {
+			continue
+		}
+		seen[query] = true
+		relevant := matchFiles(files, func(m FileMeta) bool {
+			return m.ContractType == f.ContractType && m.SignDate.Year() == year
+		})
+		refs := make([]ChunkRef, 0, len(relevant))
+		for _, file := range relevant {
+			refs = append(refs, ChunkRef{File: file, Contains: spec.Heading})
+		}
+		queries = append(queries, GoldenQuery{
+			Query:             query,
+			RelevantFiles:     relevant,
+			RelevantChunkRefs: refs,
+			Filters: &types.FilterConditions{
+				ContractType: f.ContractType,
+				ClauseType:   clause.Classify(spec.Heading, ""),
+				DateRange:    yearRange(year),
+			},
+		})
+	}
+	return queries
+}
+
+func hasParty(m FileMeta, name string) bool {
+	return m.PartyA == name || m.PartyB == name
+}
+
+func matchFiles(files []FileMeta, match func(FileMeta) bool) []string {
+	var names []string
+	for _, f := range files {
+		if match(f) {
+			names = append(names, f.FileName)
+		}
+	}
+	return names
+}
+
+func yearRange(year int) *types.DateRange {
+	return &types.DateRange{Start: fmt.Sprintf("%d-01-01", year), End: fmt.Sprintf("%d-12-31", year)}
+}
+
+// roundAmount 保留两位有效数字，生成的查询读起来像人写的
+func roundAmount(amount float64) float64 {
+	if amount <= 0 {
+		return 0
+	}
+	scale := math.Pow(10, math.Floor(math.Log10(amount))-1)
+	return math.Round(amount/scale) * scale
+}
+
+func formatAmount(amount float64) string {
+	switch {
+	case amount >= 1e8:
+		return strconv.FormatFloat(amount/1e8, 'f', -1, 64) + "亿元"
+	case amount >= 1e4:
+		return strconv.FormatFloat(amount/1e4, 'f', -1, 64) + "万元"
+	}
+	return strconv.FormatFloat(amount, 'f', -1, 64) + "元"
+}
+
+// sample 在候选中均匀抽取 n 条，结果与候选顺序一致，同一批文件每次生成的标注集相同
+func sample(queries []GoldenQuery, n int) []GoldenQuery {
+	if n <= 0 || len(queries) <= n {
+		return queries
+	}
+	picked := make([]GoldenQuery, n)
+	step := float64(len(queries)) / float64(n)
+	for i := range picked {
+		picked[i] = queries[int(float64(i)*step)]
+	}
+	return picked
+}
@@ -0,0 +1,109 @@
+// Package eval 离线检索评测：标注集 (查询 -> 相关合同/切片、期望意图和过滤条件)、指标计算和评测报告
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"eino-demo/types"
+)
+
+// GoldenSet 一组标注好的查询
+type GoldenSet struct {
+	Name    string        `json:"name"`
+	Queries []GoldenQuery `json:"queries"`
+}
+
+// GoldenQuery 一条标注好的查询
+type GoldenQuery struct {
+	ID    string `json:"id"`
+	Query string `json:"query"`
+	Kind  string `json:"kind,omitempty"` // 生成时使用的模板，手工标注的可留空
+
+	// 相关合同：relevant_docs 直接给 doc_id；relevant_files 给文件名，评测时按 PG 中的 file_name 解析为 doc_id
+	// (重新入库后 doc_id 会变，由文件名生成的标注集只记文件名)
+	RelevantDocs  []string `json:"relevant_docs,omitempty"`
+	RelevantFiles []string `json:"relevant_files,omitempty"`
+	// 相关切片：relevant_chunks 直接给切片 ID；relevant_chunk_refs 按合同文件名和条款类型/原文片段描述，
+	// 评测时从 PG 的 contract_chunks 解析为切片 ID (切片 ID 同样在入库时生成)
+	RelevantChunks    []string   `json:"relevant_chunks,omitempty"`
+	RelevantChunkRefs []ChunkRef `json:"relevant_chunk_refs,omitempty"`
+
+	// 期望的意图和过滤条件，为空时不计入意图/过滤条件准确率；过滤条件只比较填写了的字段
+	Intent  string                  `json:"intent,omitempty"`
+	Filters *types.FilterConditions `json:"filters,omitempty"`
+}
+
+// ChunkRef 描述一份合同中的相关切片，匹配到的全部切片都算相关
+type ChunkRef struct {
+	File       string `json:"file"`
+	ClauseType string `json:"clause_type,omitempty"` // 切片的条款类型，为空不限
+	Contains   string `json:"contains,omitempty"`    // 切片原文包含的文字 (如条款标题)，为空不限
+}
+
+func (c ChunkRef) String() string {
+	s := c.File
+	if c.ClauseType != "" {
+		s += "#" + c.ClauseType
+	}
+	if c.Contains != "" {
+		s += "#" + c.Contains
+	}
+	return s
+}
+
+// LoadGoldenSet 读取 JSON 格式的标注集
+func LoadGoldenSet(path string) (*GoldenSet, error) {
+	set := &GoldenSet{}
+	if err := readJSON(path, set); err != nil {
+		return nil, err
+	}
+	if len(set.Queries) == 0 {
+		return nil, fmt.Errorf("标注集 %s 没有查询", path)
+	}
+	return set, nil
+}
+
+// MergeGoldenSets 合并多个标注集 (如生成的和手工标注的)，查询 ID 不能重复
+func MergeGoldenSets(sets ...*GoldenSet) (*GoldenSet, error) {
+	if len(sets) == 1 {
+		return sets[0], nil
+	}
+	merged := &GoldenSet{}
+	var names []string
+	seen := make(map[string]string)
+	for _, set := range sets {
+		names = append(names, set.Name)
+		for _, q := range set.Queries {
+			if other, ok := seen[q.ID]; ok {
+				return nil, fmt.Errorf("标注集 %s 和 %s 有重复的查询 ID %s", other, set.Name, q.ID)
+			}
+			seen[q.ID] = set.Name
+			merged.Queries = append(merged.Queries, q)
+		}
+	}
+	merged.Name = strings.Join(names, "+")
+	return merged, nil
+}
+
+// LoadReport 读取评测报告
+func LoadReport(path string) (*Report, error) {
+	report := &Report{}
+	if err := readJSON(path, report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func readJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("解析 %s 失败: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package eval
+
+import (
+	"math"
+	"strings"
+
+	"eino-demo/logic/party"
+	"eino-demo/types"
+)
+
+// Recall 前 k 个结果覆盖的相关项比例
+func Recall(ranked []string, relevant map[string]bool, k int) float64 {
+	if len(relevant) == 0 {
+		return 0
+	}
+	hits := 0
+	for _, id := range top(ranked, k) {
+		if relevant[id] {
+			hits++
+		}
+	}
+	return float64(hits) / float64(len(relevant))
+}
+
+// ReciprocalRank 第一个相关项排名的倒数，没有命中为 0
+func ReciprocalRank(ranked []string, relevant map[string]bool) float64 {
+	for i, id := range ranked {
+		if relevant[id] {
+			return 1 / float64(i+1)
+		}
+	}
+	return 0
+}
+
+// NDCG 二值相关性的 nDCG@k：第 i 位 (从 1 开始) 的相关项贡献 1/log2(i+1)，再除以理想排序的得分
+func NDCG(ranked []string, relevant map[string]bool, k int) float64 {
+	var dcg, ideal float64
+	for i, id := range top(ranked, k) {
+		if relevant[id] {
+			dcg += 1 / math.Log2(float64(i+2))
+		}
+	}
+	for i := 0; i < k && i < len(relevant); i++ {
+		ideal += 1 / math.Log2(float64(i+2))
+	}
+	if ideal == 0 {
+		return 0
+	}
+	return dcg / ideal
+}
+
+func top(ranked []string, k int) []string {
+	if len(ranked) > k {
+		return ranked[:k]
+	}
+	return ranked
+}
+
+// 过滤条件字段，与 FilterConditions 的 JSON 字段名一致
+const (
+	FieldAnyParty     = "any_party"
+	FieldPartyA       = "party_a"
+	FieldPartyB       = "party_b"
+	FieldContractType = "contract_type"
+	FieldClauseType   = "clause_type"
+	FieldStatus       = "status"
+	FieldDateRange    = "date_range"
+	FieldAmountRange  = "amount_range"
+)
+
+// CompareFilters 逐个比较 want 中填写了的字段，返回每个字段是否一致
+// 名称类字段允许互相包含 (如期望 "物资采购合同"、解析出 "采购")，检索时这些字段本来就是模糊匹配
+func CompareFilters(want, got *types.FilterConditions) map[string]bool {
+	if got == nil {
+		got = &types.FilterConditions{}
+	}
+	fields := make(map[string]bool)
+	if len(want.AnyParty) > 0 {
+		fields[FieldAnyParty] = sameParties(want.AnyParty, got.AnyParty)
+	}
+	if want.PartyA != "" {
+		fields[FieldPartyA] = sameName(party.NormalizeName(want.PartyA), party.NormalizeName(got.PartyA))
+	}
+	if want.PartyB != "" {
+		fields[FieldPartyB] = sameName(party.NormalizeName(want.PartyB), party.NormalizeName(got.PartyB))
+	}
+	if want.ContractType != "" {
+		fields[FieldContractType] = sameName(want.ContractType, got.ContractType)
+	}
+	if want.ClauseType != "" {
+		fields[FieldClauseType] = want.ClauseType == got.ClauseType
+	}
+	if want.Status != "" {
+		fields[FieldStatus] = want.Status == got.Status
+	}
+	if want.DateRange != nil {
+		fields[FieldDateRange] = got.DateRange != nil && *want.DateRange == *got.DateRange
+	}
+	if want.AmountRange != nil {
+		fields[FieldAmountRange] = got.AmountRange != nil &&
+			sameAmount(want.AmountRange.Min, got.AmountRange.Min) &&
+			sameAmount(want.AmountRange.Max, got.AmountRange.Max)
+	}
+	return fields
+}
+
+// sameParties 期望的每个参与方都被解析出来，且没有多出的参与方
+func sameParties(want, got []string) bool {
+	if len(want) != len(got) {
+		return false
+	}
+	for _, w := range want {
+		found := false
+		for _, g := range got {
+			if sameName(party.NormalizeName(w), party.NormalizeName(g)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func sameName(a, b string) bool {
+	if a == "" || b == "" {
+		return a == b
+	}
+	return strings.Contains(a, b) || strings.Contains(b, a)
+}
+
+func sameAmount(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return math.Abs(*a-*b) < 0.01
+}
@@ -0,0 +1,320 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"eino-demo/types"
+)
+
+// Searcher 执行一次检索，通常是调用服务端的 /api/v1/retrieval/search
+type Searcher func(ctx context.Context, req types.SearchRequest) (*types.SearchResponse, error)
+
+// Resolver 把标注集中的文件名和切片描述解析为当前库中的 ID
+type Resolver interface {
+	// DocID 按文件名查 doc_id
+	DocID(ctx context.Context, fileName string) (string, error)
+	// ChunkIDs 查询合同中符合描述的切片 ID，ref.File 已解析为 docID
+	ChunkIDs(ctx context.Context, docID string, ref ChunkRef) ([]string, error)
+}
+
+// 指标名，recall 和 ndcg 后面带 @k
+const (
+	MetricDocRecall      = "doc_recall"
+	MetricDocMRR         = "doc_mrr"
+	MetricDocNDCG        = "doc_ndcg"
+	MetricChunkRecall    = "chunk_recall"
+	MetricChunkMRR       = "chunk_mrr"
+	MetricChunkNDCG      = "chunk_ndcg"
+	MetricIntentAccuracy = "intent_accuracy"
+	MetricFilterAccuracy = "filter_accuracy" // 全部期望字段一致；单个字段为 filter_accuracy.<字段>
+	MetricLatencyMs      = "latency_ms"
+)
+
+// Report 一次评测的结果，写成 JSON 后可以和其他配置下的报告对比
+type Report struct {
+	Label     string    `json:"label,omitempty"`
+	GoldenSet string    `json:"golden_set"`
+	CreatedAt time.Time `json:"created_at"`
+	Ks        []int     `json:"ks"`
+	// Config 评测时的检索配置快照 (融合权重、切分参数、提示词分流等)，由调用方填写
+	Config  map[string]any `json:"config,omitempty"`
+	Summary Summary        `json:"summary"`
+	Queries []QueryResult  `json:"queries"`
+}
+
+// Summary 每个指标在参与计算的查询上的平均值，Counts 为参与计算的查询数
+type Summary struct {
+	Queries int                `json:"queries"`
+	Errors  int                `json:"errors"`
+	Metrics map[string]float64 `json:"metrics"`
+	Counts  map[string]int     `json:"counts"`
+}
+
+// QueryResult 单条查询的检索结果和指标
+type QueryResult struct {
+	ID             string                  `json:"id"`
+	Query          string                  `json:"query"`
+	Kind           string                  `json:"kind,omitempty"`
+	ExpectedIntent string                  `json:"expected_intent,omitempty"`
+	Intent         string                  `json:"intent,omitempty"`
+	PromptVersion  string                  `json:"prompt_version,omitempty"`
+	Filters        *types.FilterConditions `json:"filters,omitempty"`
+	FilterMismatch []string                `json:"filter_mismatch,omitempty"` // 与期望不一致的过滤条件字段
+	Relevant       []string                `json:"relevant,omitempty"`        // 相关 doc_id (relevant_docs + 解析后的 relevant_files)
+	RelevantChunks []string                `json:"relevant_chunks,omitempty"` // 相关切片 ID (relevant_chunks + 解析后的 relevant_chunk_refs)
+	Unresolved     []string                `json:"unresolved,omitempty"`      // 没能解析的文件名和切片描述
+	Docs           []string                `json:"docs,omitempty"`            // 返回的 doc_id，按排名截取到最大的 k
+	Chunks         []string                `json:"chunks,omitempty"`          // 返回的切片 ID，同上
+	Metrics        map[string]float64      `json:"metrics"`
+	LatencyMs      int64                   `json:"latency_ms"`
+	Error          string                  `json:"error,omitempty"`
+}
+
+// Runner 逐条执行标注集中的查询并计算指标
+type Runner struct {
+	search   Searcher
+	resolve  Resolver
+	ks       []int
+	docIDs   map[string]string   // 文件名 -> doc_id
+	chunkIDs map[string][]string // 切片描述 -> 切片 ID
+}
+
+// NewRunner resolve 为空时 relevant_files 和 relevant_chunk_refs 不参与评测；ks 为空时取 1、5、10
+func NewRunner(search Searcher, resolve Resolver, ks []int) *Runner {
+	if len(ks) == 0 {
+		ks = []int{1, 5, 10}
+	}
+	ks = append([]int(nil), ks...)
+	sort.Ints(ks)
+	return &Runner{search: search, resolve: resolve, ks: ks, docIDs: make(map[string]string), chunkIDs: make(map[string][]string)}
+}
+
+// Run 顺序执行 (避免并发影响耗时统计)，单条查询失败记为全部指标 0
+func (r *Runner) Run(ctx context.Context, set *GoldenSet) *Report {
+	report := &Report{GoldenSet: set.Name, CreatedAt: time.Now(), Ks: r.ks}
+	for i, q := range set.Queries {
+		if ctx.Err() != nil {
+			break
+		}
+		result := r.runQuery(ctx, q)
+		report.Queries = append(report.Queries, result)
+		status := "ok"
+		if result.Error != "" {
+			status = result.Error
+		}
+		fmt.Printf(">>> [Eval] %d/%d %s %q 意图 %s，返回 %d 份合同，%dms，%s\n",
+			i+1, len(set.Queries), q.ID, q.Query, result.Intent, len(result.Docs), result.LatencyMs, status)
+	}
+	report.Summary = summarize(report.Queries)
+	return report
+}
+
+func (r *Runner) runQuery(ctx context.Context, q GoldenQuery) QueryResult {
+	result := QueryResult{
+		ID:             q.ID,
+		Query:          q.Query,
+		Kind:           q.Kind,
+		ExpectedIntent: q.Intent,
+		Metrics:        make(map[string]float64),
+	}
+	result.Relevant, result.Unresolved = r.relevantDocs(ctx, q)
+	var unresolved []string
+	result.RelevantChunks, unresolved = r.relevantChunks(ctx, q)
+	result.Unresolved = append(result.Unresolved, unresolved...)
+
+	maxK := r.ks[len(r.ks)-1]
+	start := time.Now()
+	resp, err := r.search(ctx, types.SearchRequest{Query: q.Query, PageSize: maxK})
+	result.LatencyMs = time.Since(start).Milliseconds()
+	result.Metrics[MetricLatencyMs] = float64(result.LatencyMs)
+	if err != nil {
+		result.Error = err.Error()
+		resp = &types.SearchResponse{}
+	}
+	result.Intent, result.PromptVersion, result.Filters = resp.Intent, resp.PromptVersion, resp.Filters
+
+	docs, chunks := rankedDocs(resp), rankedChunks(resp)
+	result.Docs, result.Chunks = top(docs, maxK), top(chunks, maxK)
+	if len(result.Relevant) > 0 {
+		r.addRankMetrics(result.Metrics, MetricDocRecall, MetricDocMRR, MetricDocNDCG, docs, toSet(result.Relevant))
+	}
+	if len(result.RelevantChunks) > 0 {
+		r.addRankMetrics(result.Metrics, MetricChunkRecall, MetricChunkMRR, MetricChunkNDCG, chunks, toSet(result.RelevantChunks))
+	}
+
+	if q.Intent != "" {
+		result.Metrics[MetricIntentAccuracy] = boolMetric(resp.Intent == q.Intent)
+	}
+	if q.Filters != nil {
+		fields := CompareFilters(q.Filters, resp.Filters)
+		if len(fields) > 0 {
+			allOK := true
+			for field, ok := range fields {
+				result.Metrics[MetricFilterAccuracy+"."+field] = boolMetric(ok)
+				if !ok {
+					allOK = false
+					result.FilterMismatch = append(result.FilterMismatch, field)
+				}
+			}
+			sort.Strings(result.FilterMismatch)
+			result.Metrics[MetricFilterAccuracy] = boolMetric(allOK)
+		}
+	}
+	return result
+}
+
+func (r *Runner) addRankMetrics(metrics map[string]float64, recall, mrr, ndcg string, ranked []string, relevant map[string]bool) {
+	for _, k := range r.ks {
+		metrics[fmt.Sprintf("%s@%d", recall, k)] = Recall(ranked, relevant, k)
+		metrics[fmt.Sprintf("%s@%d", ndcg, k)] = NDCG(ranked, relevant, k)
+	}
+	metrics[mrr] = ReciprocalRank(ranked, relevant)
+}
+
+// relevantDocs relevant_docs 加上 relevant_files 解析出的 doc_id
+func (r *Runner) relevantDocs(ctx context.Context, q GoldenQuery) (relevant, unresolved []string) {
+	relevant = append(relevant, q.RelevantDocs...)
+	for _, file := range q.RelevantFiles {
+		docID := r.docID(ctx, file)
+		if docID == "" {
+			unresolved = append(unresolved, file)
+			continue
+		}
+		relevant = append(relevant, docID)
+	}
+	return relevant, unresolved
+}
+
+// relevantChunks relevant_chunks 加上 relevant_chunk_refs 解析出的切片 ID，没有匹配到切片的描述算未解析
+func (r *Runner) relevantChunks(ctx context.Context, q GoldenQuery) (relevant, unresolved []string) {
+	relevant = append(relevant, q.RelevantChunks...)
+	seen := toSet(relevant)
+	for _, ref := range q.RelevantChunkRefs {
+		key := ref.String()
+		ids, ok := r.chunkIDs[key]
+		if !ok {
+			if docID := r.docID(ctx, ref.File); docID != "" {
+				var err error
+				if ids, err = r.resolve.ChunkIDs(ctx, docID, ref); err != nil {
+					fmt.Printf(">>> [Eval] ⚠️ 切片 %s 查询失败: %v\n", key, err)
+				}
+			}
+			r.chunkIDs[key] = ids
+		}
+		if len(ids) == 0 {
+			unresolved = append(unresolved, key)
+			continue
+		}
+		for _, id := range ids {
+			if !seen[id] {
+				seen[id] = true
+				relevant = append(relevant, id)
+			}
+		}
+	}
+	return relevant, unresolved
+}
+
+// docID 按文件名查 doc_id，同一文件只查一次，查不到返回空
+func (r *Runner) docID(ctx context.Context, file string) string {
+	docID, ok := r.docIDs[file]
+	if !ok && r.resolve != nil {
+		var err error
+		if docID, err = r.resolve.DocID(ctx, file); err != nil {
+			fmt.Printf(">>> [Eval] ⚠️ 文件 %s 没有找到对应的合同: %v\n", file, err)
+			docID = ""
+		}
+		r.docIDs[file] = docID
+	}
+	return docID
+}
+
+// rankedDocs 返回结果中的合同按排名去重：结构化命中、问答候选，再到切片所属的合同
+func rankedDocs(resp *types.SearchResponse) []string {
+	var docs []string
+	seen := make(map[string]bool)
+	add := func(docID string) {
+		if docID != "" && !seen[docID] {
+			seen[docID] = true
+			docs = append(docs, docID)
+		}
+	}
+	for _, c := range resp.Contracts {
+		add(c.DocID)
+	}
+	for _, c := range resp.Candidates {
+		add(c.DocID)
+	}
+	for _, c := range resp.Chunks {
+		add(c.DocID)
+	}
+	return docs
+}
+
+func rankedChunks(resp *types.SearchResponse) []string {
+	chunks := make([]string, 0, len(resp.Chunks))
+	for _, c := range resp.Chunks {
+		chunks = append(chunks, c.ID)
+	}
+	return chunks
+}
+
+func toSet(ids []string) map[string]bool {
+	set := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
+
+func boolMetric(ok bool) float64 {
+	if ok {
+		return 1
+	}
+	return 0
+}
+
+// summarize 按指标求平均，没有标注对应内容的查询不参与该指标
+func summarize(results []QueryResult) Summary {
+	summary := Summary{Queries: len(results), Metrics: make(map[string]float64), Counts: make(map[string]int)}
+	for _, result := range results {
+		if result.Error != "" {
+			summary.Errors++
+		}
+		for name, value := range result.Metrics {
+			summary.Metrics[name] += value
+			summary.Counts[name]++
+		}
+	}
+	for name, n := range summary.Counts {
+		summary.Metrics[name] /= float64(n)
+	}
+	return summary
+}
+
+// Delta 两份报告中同一指标的变化
+type Delta struct {
+	Metric string
+	Base   float64
+	Head   float64
+}
+
+// Diff head 相对 base 的变化
+func (d Delta) Diff() float64 {
+	return d.Head - d.Base
+}
+
+// Compare 两份报告都有的指标，按名称排序
+func Compare(base, head *Report) []Delta {
+	var deltas []Delta
+	for name, value := range base.Summary.Metrics {
+		if headValue, ok := head.Summary.Metrics[name]; ok {
+			deltas = append(deltas, Delta{Metric: name, Base: value, Head: headValue})
+		}
+	}
+	sort.Slice(deltas, func(i, j int) bool { return deltas[i].Metric < deltas[j].Metric })
+	return deltas
+}
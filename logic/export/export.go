@@ -0,0 +1,48 @@
+package export
+
+import (
+	"fmt"
+	"io"
+)
+
+// 导出格式
+const (
+	FormatCSV   = "csv"
+	FormatXLSX  = "xlsx"
+	FormatJSONL = "jsonl"
+)
+
+// Writer 逐行写出表格，调用方写完后必须 Close (XLSX 在 Close 时才写完压缩包)
+type Writer interface {
+	Write(record []string) error
+	Close() error
+}
+
+// NewWriter 按格式创建 Writer 并写入表头；数据逐行写到 w，不在内存里攒整张表
+func NewWriter(w io.Writer, format string, header []string) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w, header)
+	case FormatXLSX:
+		return newXLSXWriter(w, header)
+	case FormatJSONL:
+		return newJSONLWriter(w, header), nil
+	}
+	return nil, fmt.Errorf("不支持的导出格式: %s (可选 csv, xlsx, jsonl)", format)
+}
+
+// IsValidFormat 是否为支持的导出格式
+func IsValidFormat(format string) bool {
+	return format == FormatCSV || format == FormatXLSX || format == FormatJSONL
+}
+
+// ContentType 导出格式对应的 HTTP Content-Type
+func ContentType(format string) string {
+	switch format {
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case FormatJSONL:
+		return "application/x-ndjson; charset=utf-8"
+	}
+	return "text/csv; charset=utf-8"
+}
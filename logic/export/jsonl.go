@@ -0,0 +1,44 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// jsonlWriter 每行一个 JSON 对象，键为表头，保持表头顺序
+type jsonlWriter struct {
+	w      io.Writer
+	header []string
+	buf    bytes.Buffer
+}
+
+func newJSONLWriter(w io.Writer, header []string) *jsonlWriter {
+	return &jsonlWriter{w: w, header: header}
+}
+
+func (j *jsonlWriter) Write(record []string) error {
+	j.buf.Reset()
+	j.buf.WriteByte('{')
+	for i, key := range j.header {
+		if i > 0 {
+			j.buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		j.buf.Write(k)
+		j.buf.WriteByte(':')
+		var v string
+		if i < len(record) {
+			v = record[i]
+		}
+		val, _ := json.Marshal(v)
+		j.buf.Write(val)
+	}
+	j.buf.WriteString("}\n")
+	_, err := j.w.Write(j.buf.Bytes())
+	return err
+}
+
+func (j *jsonlWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,142 @@
+package export
+
+import (
+	"eino-demo/storage/postgres"
+	"eino-demo/types"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// contractField 导出列与 postgres.Contract 字段的对应关系
+type contractField struct {
+	index  int
+	column string
+}
+
+// contractFields postgres.Contract 中可导出的字段，列名取 gorm 的 column 标签
+// 新增字段时导出列自动跟随，不需要另外维护列表
+var contractFields = func() []contractField {
+	var fields []contractField
+	t := reflect.TypeOf(postgres.Contract{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() || f.Tag.Get("gorm") == "-" {
+			continue
+		}
+		column := snakeCase(f.Name)
+		for _, part := range strings.Split(f.Tag.Get("gorm"), ";") {
+			if name, ok := strings.CutPrefix(part, "column:"); ok {
+				column = name
+			}
+		}
+		fields = append(fields, contractField{index: i, column: column})
+	}
+	return fields
+}()
+
+// ContractHeader 合同列表的表头
+func ContractHeader() []string {
+	header := make([]string, len(contractFields))
+	for i, f := range contractFields {
+		header[i] = f.column
+	}
+	return header
+}
+
+// ContractRecord 合同的一行
+func ContractRecord(c *postgres.Contract) []string {
+	v := reflect.ValueOf(c).Elem()
+	record := make([]string, len(contractFields))
+	for i, f := range contractFields {
+		record[i] = formatValue(v.Field(f.index))
+	}
+	return record
+}
+
+// EvidenceHeader 混合检索证据的表头
+func EvidenceHeader() []string {
+	return []string{"rank", "doc_id", "chunk_id", "score", "sources", "party_a", "party_b", "contract_type", "clause_type", "content"}
+}
+
+// EvidenceRecord 检索命中片段的一行，包含片段原文和融合分数
+func EvidenceRecord(rank int, hit types.ChunkHit) []string {
+	meta := func(key string) string {
+		if v, ok := hit.Metadata[key]; ok && v != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return ""
+	}
+	return []string{
+		strconv.Itoa(rank),
+		hit.DocID,
+		hit.ID,
+		strconv.FormatFloat(hit.Score, 'f', 4, 64),
+		strings.Join(hit.Sources, "+"),
+		meta("party_a"),
+		meta("party_b"),
+		meta("contract_type"),
+		meta("clause_type"),
+		hit.Content,
+	}
+}
+
+// AggregateRecords 聚合结果的全部行，表头为 result.Columns
+func AggregateRecords(result *types.AggregateResult) [][]string {
+	records := make([][]string, len(result.Rows))
+	for i, row := range result.Rows {
+		records[i] = make([]string, len(row))
+		for j, v := range row {
+			records[i][j] = formatValue(reflect.ValueOf(v))
+		}
+	}
+	return records
+}
+
+// formatValue 单元格取值：日期只保留到天 (时分秒非零时保留)，金额保留两位小数
+func formatValue(v reflect.Value) string {
+	if !v.IsValid() {
+		return ""
+	}
+	if v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if t, ok := v.Interface().(time.Time); ok {
+		if t.IsZero() {
+			return ""
+		}
+		if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 {
+			return t.Format("2006-01-02")
+		}
+		return t.Format("2006-01-02 15:04:05")
+	}
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', 2, 64)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.String:
+		return v.String()
+	}
+	return fmt.Sprintf("%v", v.Interface())
+}
+
+// snakeCase CreatedAt -> created_at，与 gorm 默认列名一致
+func snakeCase(name string) string {
+	var sb strings.Builder
+	for i, r := range name {
+		if r >= 'A' && r <= 'Z' {
+			if i > 0 {
+				sb.WriteByte('_')
+			}
+			r += 'a' - 'A'
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
@@ -0,0 +1,158 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// xlsx 用标准库 zip + 手写 XML 生成，不依赖第三方库
+// 单元格使用内联字符串 (inlineStr)，不需要共享字符串表，因此可以边查边写
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/><Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/></Types>`
+
+	xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+
+	xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets></workbook>`
+
+	xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/><Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/></Relationships>`
+
+	// 样式 0 为默认，样式 1 为加粗 (表头)
+	xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts><fills count="1"><fill><patternFill patternType="none"/></fill></fills><borders count="1"><border/></borders><cellStyleXfs count="1"><xf/></cellStyleXfs><cellXfs count="2"><xf fontId="0"/><xf fontId="1" applyFont="1"/></cellXfs></styleSheet>`
+
+	xlsxSheetHead = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews><sheetData>`
+
+	xlsxSheetTail = `</sheetData></worksheet>`
+)
+
+// xlsxMaxCellLen Excel 单元格最多 32767 个字符，超出会报文件损坏
+const xlsxMaxCellLen = 32767
+
+type xlsxWriter struct {
+	zw  *zip.Writer
+	bw  *bufio.Writer
+	row int
+}
+
+func newXLSXWriter(w io.Writer, header []string) (*xlsxWriter, error) {
+	zw := zip.NewWriter(w)
+	static := []struct{ name, body string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", xlsxWorkbook},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/styles.xml", xlsxStyles},
+	}
+	for _, f := range static {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(fw, f.body); err != nil {
+			return nil, err
+		}
+	}
+
+	// sheet 放在最后一个条目，行数据直接流式写入
+	sheet, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	x := &xlsxWriter{zw: zw, bw: bufio.NewWriter(sheet)}
+	if _, err := x.bw.WriteString(xlsxSheetHead); err != nil {
+		return nil, err
+	}
+	if err := x.writeRow(header, true); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+func (x *xlsxWriter) Write(record []string) error {
+	return x.writeRow(record, false)
+}
+
+func (x *xlsxWriter) writeRow(record []string, bold bool) error {
+	x.row++
+	rowNum := strconv.Itoa(x.row)
+	x.bw.WriteString(`<row r="` + rowNum + `">`)
+	for i, v := range record {
+		ref := columnName(i) + rowNum
+		switch {
+		case bold:
+			x.bw.WriteString(`<c r="` + ref + `" s="1" t="inlineStr"><is><t xml:space="preserve">`)
+			writeCellText(x.bw, v)
+			x.bw.WriteString(`</t></is></c>`)
+		case isNumeric(v):
+			x.bw.WriteString(`<c r="` + ref + `"><v>` + v + `</v></c>`)
+		default:
+			x.bw.WriteString(`<c r="` + ref + `" t="inlineStr"><is><t xml:space="preserve">`)
+			writeCellText(x.bw, v)
+			x.bw.WriteString(`</t></is></c>`)
+		}
+	}
+	_, err := x.bw.WriteString(`</row>`)
+	return err
+}
+
+func (x *xlsxWriter) Close() error {
+	if _, err := x.bw.WriteString(xlsxSheetTail); err != nil {
+		return err
+	}
+	if err := x.bw.Flush(); err != nil {
+		return err
+	}
+	return x.zw.Close()
+}
+
+// columnName 0 -> A, 25 -> Z, 26 -> AA
+func columnName(i int) string {
+	name := ""
+	for i >= 0 {
+		name = string(rune('A'+i%26)) + name
+		i = i/26 - 1
+	}
+	return name
+}
+
+// isNumeric 是否按数字单元格写出；带前导 0 的编号 (如 "007") 和超过 15 位的数字保留为文本，避免精度和格式丢失
+func isNumeric(v string) bool {
+	if v == "" || len(v) > 15 {
+		return false
+	}
+	digits := strings.TrimPrefix(v, "-")
+	if len(digits) > 1 && digits[0] == '0' && digits[1] != '.' {
+		return false
+	}
+	for _, r := range digits {
+		if (r < '0' || r > '9') && r != '.' {
+			return false
+		}
+	}
+	_, err := strconv.ParseFloat(v, 64)
+	return err == nil
+}
+
+// writeCellText 转义 XML 并去掉 XML 1.0 不允许的控制字符，超长内容截断
+func writeCellText(w *bufio.Writer, v string) {
+	if utf8.RuneCountInString(v) > xlsxMaxCellLen {
+		v = string([]rune(v)[:xlsxMaxCellLen])
+	}
+	v = strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || r == '\r' || r >= 0x20 && r != 0xFFFE && r != 0xFFFF {
+			return r
+		}
+		return -1
+	}, v)
+	_ = xml.EscapeText(w, []byte(v))
+}
@@ -0,0 +1,188 @@
+package clause
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// 条款类型 (存入 PG/ES/Milvus 的标准值)
+const (
+	TypePayment         = "payment"         // 付款
+	TypeDelivery        = "delivery"        // 交付
+	TypeAcceptance      = "acceptance"      // 验收
+	TypeLiability       = "liability"       // 违约责任
+	TypeConfidentiality = "confidentiality" // 保密
+	TypeTermination     = "termination"     // 解除/终止
+	TypeDispute         = "dispute"         // 争议解决
+	TypeForceMajeure    = "force_majeure"   // 不可抗力
+	TypeOther           = "other"           // 其他
+)
+
+// Clause 切分出的单个条款
+// Start/End 为条款在原文中的 rune 偏移 (左闭右开)
+type Clause struct {
+	Seq     int
+	Type    string
+	Title   string
+	Content string
+	Start   int
+	End     int
+}
+
+// typeKeywords 条款类型关键词，按优先级排列 (先命中先返回)
+var typeKeywords = []struct {
+	Type     string
+	Keywords []string
+}{
+	{TypeForceMajeure, []string{"不可抗力"}},
+	{TypeDispute, []string{"争议解决", "争议", "仲裁", "管辖", "诉讼"}},
+	{TypeLiability, []string{"违约责任", "违约", "赔偿", "违约金", "滞纳金"}},
+	{TypeConfidentiality, []string{"保密", "商业秘密", "知识产权"}},
+	{TypeTermination, []string{"解除", "终止", "期满", "续约"}},
+	{TypeAcceptance, []string{"验收", "质量", "检验", "质保"}},
+	{TypeDelivery, []string{"交付", "交货", "运输", "工期", "履行期限", "交房"}},
+	{TypePayment, []string{"付款", "支付", "还款", "金额", "价款", "结算", "租金", "报酬", "费用", "利息", "发票", "工资"}},
+}
+
+// labels 条款类型的中文名，用于展示和 Prompt
+var labels = map[string]string{
+	TypePayment:         "付款条款",
+	TypeDelivery:        "交付条款",
+	TypeAcceptance:      "验收条款",
+	TypeLiability:       "违约责任",
+	TypeConfidentiality: "保密条款",
+	TypeTermination:     "解除/终止条款",
+	TypeDispute:         "争议解决",
+	TypeForceMajeure:    "不可抗力",
+	TypeOther:           "其他条款",
+}
+
+// headingRe 匹配条款标题："第三条 付款方式"、"三、付款方式"、"3. 付款方式"
+// 要求前面是文本开头、换行、空白或句号，避免误匹配正文里的 "第三条规定"
+var headingRe = regexp.MustCompile(`(?:^|[\n\s。；;])((?:第[一二三四五六七八九十百零〇\d]+条)|(?:[一二三四五六七八九十]+、)|(?:\d{1,2}[、.．]\s*[^\d\s]))`)
+
+// Label 返回条款类型的中文名
+func Label(t string) string {
+	if l, ok := labels[t]; ok {
+		return l
+	}
+	return labels[TypeOther]
+}
+
+// Types 返回全部标准条款类型
+func Types() []string {
+	return []string{TypePayment, TypeDelivery, TypeAcceptance, TypeLiability, TypeConfidentiality, TypeTermination, TypeDispute, TypeForceMajeure, TypeOther}
+}
+
+// Normalize 将 LLM 或用户输入的条款类型 (中文或英文) 转为标准值，无法识别时返回空
+func Normalize(t string) string {
+	t = strings.TrimSpace(t)
+	if t == "" {
+		return ""
+	}
+	if _, ok := labels[t]; ok {
+		return t
+	}
+	return Classify(t, "")
+}
+
+// Mentioned 返回文本中提到的全部条款类型 (按关键词优先级排列)，用于从问题中识别要比较的条款
+// 如 "比较付款和违约条款" -> [liability, payment]
+func Mentioned(text string) []string {
+	var found []string
+	for _, tk := range typeKeywords {
+		for _, kw := range tk.Keywords {
+			if strings.Contains(text, kw) {
+				found = append(found, tk.Type)
+				break
+			}
+		}
+	}
+	return found
+}
+
+// Classify 根据标题和正文判断条款类型，标题权重更高
+func Classify(title, content string) string {
+	for _, tk := range typeKeywords {
+		for _, kw := range tk.Keywords {
+			if strings.Contains(title, kw) {
+				return tk.Type
+			}
+		}
+	}
+	if content == "" {
+		return ""
+	}
+	// 标题未命中时，只看正文前 100 字，避免长条款里的顺带提及
+	head := content
+	if r := []rune(head); len(r) > 100 {
+		head = string(r[:100])
+	}
+	for _, tk := range typeKeywords {
+		for _, kw := range tk.Keywords {
+			if strings.Contains(head, kw) {
+				return tk.Type
+			}
+		}
+	}
+	return TypeOther
+}
+
+// Segment 将合同全文切分为带类型的条款
+// 没有识别到任何条款标题时返回 nil
+func Segment(content string) []Clause {
+	locs := headingRe.FindAllStringSubmatchIndex(content, -1)
+	if len(locs) == 0 {
+		return nil
+	}
+
+	var clauses []Clause
+	for i, loc := range locs {
+		// loc[2] 是标题分组的起始字节偏移
+		start := loc[2]
+		end := len(content)
+		if i+1 < len(locs) {
+			end = locs[i+1][2]
+		}
+		text := strings.TrimSpace(content[start:end])
+		if text == "" {
+			continue
+		}
+		title := firstLine(text)
+		clauses = append(clauses, Clause{
+			Seq:     len(clauses),
+			Type:    Classify(title, text),
+			Title:   title,
+			Content: text,
+			Start:   utf8.RuneCountInString(content[:start]),
+			End:     utf8.RuneCountInString(content[:end]),
+		})
+	}
+	return clauses
+}
+
+// TypeForSpan 返回与 [start, end) 重叠最多的条款类型，用于给 chunk 打标签
+func TypeForSpan(clauses []Clause, start, end int) string {
+	best := ""
+	bestOverlap := 0
+	for _, c := range clauses {
+		overlap := min(end, c.End) - max(start, c.Start)
+		if overlap > bestOverlap {
+			best = c.Type
+			bestOverlap = overlap
+		}
+	}
+	return best
+}
+
+// firstLine 取条款的标题行 (第一行或第一个句号前，最多 30 字)
+func firstLine(text string) string {
+	if i := strings.IndexAny(text, "\n。"); i > 0 {
+		text = text[:i]
+	}
+	if r := []rune(text); len(r) > 30 {
+		text = string(r[:30])
+	}
+	return strings.TrimSpace(text)
+}
@@ -2,14 +2,15 @@ package extract
 
 import (
 	"context"
+	"eino-demo/logic/prompt"
 	"eino-demo/types"
-	"eino-demo/vars"
 	"encoding/json"
 	"fmt"
 	"github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/schema"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 // 1. 手动定义参数 Map
@@ -26,17 +27,43 @@ import (
 //	},
 //}
 
-func ExtractAndClean(ctx context.Context, model model.ToolCallingChatModel, data *schema.Document) (*types.ContractRawData, error) {
-	content := data.Content
-	if len(content) > 10000 {
-		content = content[:10000]
+// ExtractAndClean 结构化提取入口，p 为选中的提取提示词版本 (同一份合同的各窗口用同一版本)
+// 短文本直接整篇提取；长文本按窗口 map 提取候选字段，再 reduce 合并，避免截断丢失尾部签署页
+func ExtractAndClean(ctx context.Context, model model.ToolCallingChatModel, p *prompt.Prompt, data *schema.Document) (*types.ContractRawData, error) {
+	windows := splitWindows(data.Content, windowSize, windowOverlap)
+	if len(windows) <= 1 {
+		return extractWindow(ctx, model, p, data.Content)
 	}
 
-	prompt := strings.ReplaceAll(vars.EXTARACT, "{{.Content}}", content)
-	prompt = strings.ReplaceAll(prompt, "{{.CurrentDate}}", time.Now().Format("2006-01-02"))
+	fmt.Printf(">>> [Extract] 长文本 %d 字，切分为 %d 个窗口做 map-reduce 提取\n", utf8.RuneCountInString(data.Content), len(windows))
+	candidates := make([]*windowCandidate, 0, len(windows))
+	for i, w := range windows {
+		info, err := extractWindow(ctx, model, p, w)
+		if err != nil {
+			fmt.Printf(">>> [Extract] 窗口 %d/%d 提取失败，跳过: %v\n", i+1, len(windows), err)
+			continue
+		}
+		candidates = append(candidates, &windowCandidate{index: i, total: len(windows), data: info})
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("all %d extraction windows failed", len(windows))
+	}
+
+	return reduceCandidates(candidates), nil
+}
+
+// extractWindow 对单段文本调用 LLM 提取结构化字段
+func extractWindow(ctx context.Context, model model.ToolCallingChatModel, p *prompt.Prompt, content string) (*types.ContractRawData, error) {
+	text, err := p.Render(map[string]any{
+		"Content":     content,
+		"CurrentDate": time.Now().Format("2006-01-02"),
+	})
+	if err != nil {
+		return nil, err
+	}
 	// 2. 调用 LLM
 	resp, err := model.Generate(ctx, []*schema.Message{
-		schema.UserMessage(prompt),
+		schema.UserMessage(text),
 	})
 	if err != nil {
 		return nil, err
@@ -0,0 +1,225 @@
+package extract
+
+import (
+	"eino-demo/types"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// windowSize 单个提取窗口的字符数 (按 rune 计，不按字节)
+	windowSize = 3000
+	// windowOverlap 相邻窗口的重叠字符数，防止字段恰好被切断
+	windowOverlap = 200
+	// maxKeywords 合并后保留的关键词数量
+	maxKeywords = 5
+)
+
+// windowCandidate 单个窗口提取出的候选字段
+type windowCandidate struct {
+	index int // 窗口序号，0 为开头 (序言部分)
+	total int
+	data  *types.ContractRawData
+}
+
+// splitWindows 按 rune 切分文本，尽量在换行或句号处断开
+func splitWindows(content string, size, overlap int) []string {
+	runes := []rune(content)
+	if len(runes) <= size {
+		return []string{content}
+	}
+
+	var windows []string
+	start := 0
+	for start < len(runes) {
+		end := start + size
+		if end >= len(runes) {
+			windows = append(windows, string(runes[start:]))
+			break
+		}
+		// 在窗口后 1/5 范围内向前找自然断点
+		for i := end; i > end-size/5; i-- {
+			if runes[i-1] == '\n' || runes[i-1] == '。' {
+				end = i
+				break
+			}
+		}
+		windows = append(windows, string(runes[start:end]))
+		start = end - overlap
+	}
+	return windows
+}
+
+// reduceCandidates 合并多个窗口的候选字段并解决冲突
+// - 甲乙方：优先取最靠前的窗口 (合同序言)
+// - 签署日期/截止日期：优先取最靠后的窗口 (签署页)
+// - 合同类型/金额：多数投票，平票时类型取靠前、金额取较大值
+// - 摘要：取最靠前的非空摘要；关键词按出现频次合并；参与方取并集
+func reduceCandidates(candidates []*windowCandidate) *types.ContractRawData {
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].index < candidates[j].index
+	})
+
+	result := &types.ContractRawData{}
+
+	for _, c := range candidates {
+		if result.PartyA == "" && strings.TrimSpace(c.data.PartyA) != "" {
+			result.PartyA = strings.TrimSpace(c.data.PartyA)
+		}
+		if result.PartyB == "" && strings.TrimSpace(c.data.PartyB) != "" {
+			result.PartyB = strings.TrimSpace(c.data.PartyB)
+		}
+		if result.Summary == "" && strings.TrimSpace(c.data.Summary) != "" {
+			result.Summary = strings.TrimSpace(c.data.Summary)
+		}
+	}
+
+	for i := len(candidates) - 1; i >= 0; i-- {
+		c := candidates[i]
+		if result.SignDate == nil && validDate(c.data.SignDate) {
+			result.SignDate = c.data.SignDate
+		}
+		if result.EndDate == nil && validDate(c.data.EndDate) {
+			result.EndDate = c.data.EndDate
+		}
+	}
+
+	// 合同类型投票
+	typeVotes := make(map[string]int)
+	typeFirst := make(map[string]int)
+	for _, c := range candidates {
+		t := strings.TrimSpace(c.data.ContractType)
+		if t == "" {
+			continue
+		}
+		if _, ok := typeFirst[t]; !ok {
+			typeFirst[t] = c.index
+		}
+		typeVotes[t]++
+	}
+	bestVotes := 0
+	for t, v := range typeVotes {
+		if v > bestVotes || (v == bestVotes && typeFirst[t] < typeFirst[result.ContractType]) {
+			result.ContractType = t
+			bestVotes = v
+		}
+	}
+
+	// 金额投票 (忽略 0，0 通常表示该窗口没有提到金额)
+	amountVotes := make(map[float64]int)
+	for _, c := range candidates {
+		if v := ParseAmount(c.data.TotalAmount); v > 0 {
+			amountVotes[v]++
+		}
+	}
+	var bestAmount float64
+	bestVotes = 0
+	for v, n := range amountVotes {
+		if n > bestVotes || (n == bestVotes && v > bestAmount) {
+			bestAmount = v
+			bestVotes = n
+		}
+	}
+	result.TotalAmount = bestAmount
+
+	// 关键词按频次合并
+	kwCount := make(map[string]int)
+	var kwOrder []string
+	for _, c := range candidates {
+		for _, kw := range c.data.Keywords {
+			kw = strings.TrimSpace(kw)
+			if kw == "" {
+				continue
+			}
+			if _, ok := kwCount[kw]; !ok {
+				kwOrder = append(kwOrder, kw)
+			}
+			kwCount[kw]++
+		}
+	}
+	sort.SliceStable(kwOrder, func(i, j int) bool {
+		return kwCount[kwOrder[i]] > kwCount[kwOrder[j]]
+	})
+	if len(kwOrder) > maxKeywords {
+		kwOrder = kwOrder[:maxKeywords]
+	}
+	result.Keywords = kwOrder
+
+	// 参与方按 (名称, 角色) 去重合并，保留首次出现的顺序
+	seenParty := make(map[string]bool)
+	for _, c := range candidates {
+		for _, p := range c.data.Parties {
+			key := strings.TrimSpace(p.Name) + "|" + strings.TrimSpace(p.Role)
+			if strings.TrimSpace(p.Name) == "" || seenParty[key] {
+				continue
+			}
+			seenParty[key] = true
+			result.Parties = append(result.Parties, p)
+		}
+	}
+
+	return result
+}
+
+// validDate 判断 LLM 返回的日期是否为合法的 YYYY-MM-DD
+func validDate(s *string) bool {
+	if s == nil || *s == "" {
+		return false
+	}
+	_, err := time.Parse("2006-01-02", *s)
+	return err == nil
+}
+
+// ContractTypeFromFileName 从文件名中取合同类型：按 "_" / "-" / 空格分段，取最后一个以 "合同"、"协议" 等结尾的段
+// (括号里的补充说明不算，如 "保密协议(NDA)")；LLM 没有提取出类型时作为兜底，取不到返回空
+func ContractTypeFromFileName(name string) string {
+	base := strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+	parts := strings.FieldsFunc(base, func(r rune) bool { return r == '_' || r == '-' || r == ' ' })
+	for i := len(parts) - 1; i >= 0; i-- {
+		core := parts[i]
+		if j := strings.IndexAny(core, "(（"); j > 0 {
+			core = core[:j]
+		}
+		for _, suffix := range contractTypeSuffixes {
+			if strings.HasSuffix(core, suffix) && core != suffix {
+				return parts[i]
+			}
+		}
+	}
+	return ""
+}
+
+var contractTypeSuffixes = []string{"合同", "协议", "协议书", "契约"}
+
+// ParseAmount 将 LLM 返回的金额 (数字或字符串) 统一转为元
+func ParseAmount(v interface{}) float64 {
+	switch v := v.(type) {
+	case float64:
+		// 情况1: LLM 返回了数字 (例如 0, 10000)
+		return v
+	case string:
+		// 情况2: LLM 返回了字符串 (例如 "1000", "1,000", "100万")
+		if v == "" {
+			return 0
+		}
+		cleanAmount := strings.ReplaceAll(v, ",", "")
+		// 简单处理 "万"/"亿" (根据 Prompt 情况，如果 Prompt 没强制纯数字的话)
+		multiplier := 1.0
+		if strings.Contains(cleanAmount, "亿") {
+			cleanAmount = strings.ReplaceAll(cleanAmount, "亿", "")
+			multiplier = 100000000.0
+		} else if strings.Contains(cleanAmount, "万") {
+			cleanAmount = strings.ReplaceAll(cleanAmount, "万", "")
+			multiplier = 10000.0
+		}
+		cleanAmount = strings.TrimSuffix(strings.TrimSpace(cleanAmount), "元")
+
+		if val, err := strconv.ParseFloat(cleanAmount, 64); err == nil {
+			return val * multiplier
+		}
+	}
+	return 0
+}
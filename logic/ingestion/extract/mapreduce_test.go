@@ -0,0 +1,20 @@
+package extract
+
+import "testing"
+
+func TestContractTypeFromFileName(t *testing.T) {
+	cases := map[string]string{
+		"2023-01-03_众信置业有限公司_深蓝网络服务公司_物资采购合同_2913000.00元_48.pdf": "物资采购合同",
+		"2023-02-01_甲_乙_保密协议(NDA)_10万元_3.pdf":                    "保密协议(NDA)",
+		"房屋租赁合同.pdf":                   "房屋租赁合同",
+		"股权转让协议书 终稿.docx":              "股权转让协议书",
+		"scan_0001.pdf":                "",
+		"2023-01-03_合同_1.pdf":          "", // 只有 "合同" 两个字不算类型
+		"/tmp/upload/2024-借款合同-v2.pdf": "借款合同",
+	}
+	for name, want := range cases {
+		if got := ContractTypeFromFileName(name); got != want {
+			t.Errorf("ContractTypeFromFileName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
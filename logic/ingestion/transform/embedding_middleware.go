@@ -0,0 +1,222 @@
+package transform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/cloudwego/eino/components/embedding"
+)
+
+// EmbedderMiddleware 包装一个 embedder，返回增加了某种能力的 embedder
+type EmbedderMiddleware func(embedding.Embedder) embedding.Embedder
+
+// EmbedderFunc 让普通函数实现 embedding.Embedder
+type EmbedderFunc func(ctx context.Context, texts []string, opts ...embedding.Option) ([][]float64, error)
+
+func (f EmbedderFunc) EmbedStrings(ctx context.Context, texts []string, opts ...embedding.Option) ([][]float64, error) {
+	return f(ctx, texts, opts...)
+}
+
+// ChainEmbedder 按顺序套上中间件，第一个在最外层
+// ChainEmbedder(raw, A, B) 的调用顺序为 A -> B -> raw
+func ChainEmbedder(inner embedding.Embedder, mws ...EmbedderMiddleware) embedding.Embedder {
+	for i := len(mws) - 1; i >= 0; i-- {
+		inner = mws[i](inner)
+	}
+	return inner
+}
+
+// EmbedderOptions 默认中间件链的参数
+type EmbedderOptions struct {
+	BatchSize   int           // 单次请求最多的文本数
+	Concurrency int           // 同时进行的请求数
+	MaxRetries  int           // 可重试错误的最大重试次数
+	BaseBackoff time.Duration // 第一次重试前的等待，之后指数增长
+	Timeout     time.Duration // 单次请求超时 (每次重试单独计时)
+	Dimension   int           // 期望的向量维度，0 表示以第一次返回的维度为准
+}
+
+// DefaultEmbedderOptions 适配本地 Ollama 的默认值
+var DefaultEmbedderOptions = EmbedderOptions{
+	BatchSize:   32,
+	Concurrency: 4,
+	MaxRetries:  3,
+	BaseBackoff: 500 * time.Millisecond,
+	Timeout:     30 * time.Second,
+}
+
+// NewResilientEmbedder 默认中间件链：分批并发 -> 重试 -> 单次超时 -> 维度校验 -> NaN/Inf 清理 -> 原始 embedder
+func NewResilientEmbedder(inner embedding.Embedder, opts EmbedderOptions) embedding.Embedder {
+	return ChainEmbedder(inner,
+		WithBatching(opts.BatchSize, opts.Concurrency),
+		WithRetry(opts.MaxRetries, opts.BaseBackoff),
+		WithTimeout(opts.Timeout),
+		WithDimensionCheck(opts.Dimension),
+		WithNaNGuard(),
+	)
+}
+
+// WithBatching 大批量文本按 batchSize 拆分，最多 concurrency 个请求并发，结果按原顺序拼回
+// 任一批失败即取消其余批次并返回错误
+func WithBatching(batchSize, concurrency int) EmbedderMiddleware {
+	if batchSize <= 0 {
+		batchSize = DefaultEmbedderOptions.BatchSize
+	}
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	return func(inner embedding.Embedder) embedding.Embedder {
+		return EmbedderFunc(func(ctx context.Context, texts []string, opts ...embedding.Option) ([][]float64, error) {
+			if len(texts) <= batchSize {
+				return inner.EmbedStrings(ctx, texts, opts...)
+			}
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+
+			vectors := make([][]float64, len(texts))
+			sem := make(chan struct{}, concurrency)
+			var wg sync.WaitGroup
+			var once sync.Once
+			var firstErr error
+			for start := 0; start < len(texts); start += batchSize {
+				end := min(start+batchSize, len(texts))
+				select {
+				case sem <- struct{}{}:
+				case <-ctx.Done():
+				}
+				if ctx.Err() != nil {
+					break
+				}
+				wg.Add(1)
+				go func(start, end int) {
+					defer wg.Done()
+					defer func() { <-sem }()
+					batch, err := inner.EmbedStrings(ctx, texts[start:end], opts...)
+					if err == nil && len(batch) != end-start {
+						err = fmt.Errorf("embedder 返回 %d 个向量，期望 %d 个", len(batch), end-start)
+					}
+					if err != nil {
+						once.Do(func() {
+							firstErr = fmt.Errorf("第 %d-%d 条文本向量化失败: %w", start, end, err)
+							cancel()
+						})
+						return
+					}
+					copy(vectors[start:end], batch)
+				}(start, end)
+			}
+			wg.Wait()
+			if firstErr != nil {
+				return nil, firstErr
+			}
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			return vectors, nil
+		})
+	}
+}
+
+// WithRetry 可重试错误 (超时、连接失败、429/5xx) 按指数退避加随机抖动重试，其余错误直接返回
+func WithRetry(maxRetries int, baseBackoff time.Duration) EmbedderMiddleware {
+	return func(inner embedding.Embedder) embedding.Embedder {
+		return EmbedderFunc(func(ctx context.Context, texts []string, opts ...embedding.Option) ([][]float64, error) {
+			for attempt := 0; ; attempt++ {
+				vectors, err := inner.EmbedStrings(ctx, texts, opts...)
+				if err == nil || attempt >= maxRetries || ctx.Err() != nil || !isTransientEmbedError(err) {
+					return vectors, err
+				}
+				backoff := baseBackoff << attempt
+				backoff += time.Duration(rand.Int63n(int64(backoff)/2 + 1))
+				fmt.Printf(">>> [Embedder] 第 %d 次重试 (%d 条文本)，%v 后重试: %v\n", attempt+1, len(texts), backoff, err)
+				select {
+				case <-time.After(backoff):
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				}
+			}
+		})
+	}
+}
+
+// statusPattern Ollama 返回的 HTTP 状态错误形如 "503 Service Unavailable: server busy"
+var statusPattern = regexp.MustCompile(`^(429|500|502|503|504)\b`)
+
+// isTransientEmbedError 是否值得重试
+func isTransientEmbedError(err error) bool {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, io.EOF),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return true
+	}
+	msg := err.Error()
+	return statusPattern.MatchString(msg) || strings.Contains(msg, "connection reset") || strings.Contains(msg, "connection refused")
+}
+
+// WithTimeout 每次请求单独的超时，配合 WithRetry 时超时的那一次可以重试
+func WithTimeout(timeout time.Duration) EmbedderMiddleware {
+	return func(inner embedding.Embedder) embedding.Embedder {
+		if timeout <= 0 {
+			return inner
+		}
+		return EmbedderFunc(func(ctx context.Context, texts []string, opts ...embedding.Option) ([][]float64, error) {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			return inner.EmbedStrings(ctx, texts, opts...)
+		})
+	}
+}
+
+// WithDimensionCheck 校验返回的向量个数和维度；dim 为 0 时记住第一次返回的维度，之后都按它校验
+// 维度不一致通常是换了模型，写进 Milvus 会直接报错，这里提前拦下
+func WithDimensionCheck(dim int) EmbedderMiddleware {
+	return func(inner embedding.Embedder) embedding.Embedder {
+		var expected atomic.Int64
+		expected.Store(int64(dim))
+		return EmbedderFunc(func(ctx context.Context, texts []string, opts ...embedding.Option) ([][]float64, error) {
+			vectors, err := inner.EmbedStrings(ctx, texts, opts...)
+			if err != nil {
+				return nil, err
+			}
+			if len(vectors) != len(texts) {
+				return nil, fmt.Errorf("embedder 返回 %d 个向量，期望 %d 个", len(vectors), len(texts))
+			}
+			for i, vec := range vectors {
+				if len(vec) == 0 {
+					return nil, fmt.Errorf("第 %d 条文本的向量为空", i)
+				}
+				want := expected.Load()
+				if want == 0 {
+					expected.CompareAndSwap(0, int64(len(vec)))
+					want = expected.Load()
+				}
+				if int64(len(vec)) != want {
+					return nil, fmt.Errorf("第 %d 条文本的向量维度为 %d，期望 %d", i, len(vec), want)
+				}
+			}
+			return vectors, nil
+		})
+	}
+}
+
+// WithNaNGuard NaN/Inf 替换为 0 (CleanEmbedder)
+func WithNaNGuard() EmbedderMiddleware {
+	return func(inner embedding.Embedder) embedding.Embedder {
+		return NewCleanEmbedder(inner)
+	}
+}
@@ -0,0 +1,258 @@
+package transform
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/components/embedding"
+)
+
+// errBlock 让假 embedder 阻塞到 ctx 结束，用于测试超时
+var errBlock = errors.New("阻塞到 ctx 结束")
+
+// fakeEmbedder 按预设的顺序失败：schedule[i] 是第 i 次调用的结果，nil 表示成功，用完后一直成功
+// 成功时每条文本返回 [文本解析成的数字, 1, ...]，长度为 dim
+type fakeEmbedder struct {
+	dim      int
+	mu       sync.Mutex
+	schedule []error
+	calls    int
+	batches  [][]string
+}
+
+func (f *fakeEmbedder) next(texts []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	f.batches = append(f.batches, texts)
+	if f.calls <= len(f.schedule) {
+		return f.schedule[f.calls-1]
+	}
+	return nil
+}
+
+func (f *fakeEmbedder) Calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+func (f *fakeEmbedder) EmbedStrings(ctx context.Context, texts []string, _ ...embedding.Option) ([][]float64, error) {
+	err := f.next(texts)
+	if errors.Is(err, errBlock) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return nil, err
+	}
+	// 打乱各批完成的先后顺序
+	time.Sleep(time.Duration(rand.Intn(3)) * time.Millisecond)
+	vectors := make([][]float64, len(texts))
+	for i, text := range texts {
+		n, _ := strconv.Atoi(text)
+		vectors[i] = make([]float64, max(f.dim, 1))
+		vectors[i][0] = float64(n)
+		for j := 1; j < len(vectors[i]); j++ {
+			vectors[i][j] = 1
+		}
+	}
+	return vectors, nil
+}
+
+func numbered(n int) []string {
+	texts := make([]string, n)
+	for i := range texts {
+		texts[i] = strconv.Itoa(i)
+	}
+	return texts
+}
+
+func TestBatching(t *testing.T) {
+	cases := []struct {
+		name        string
+		texts       int
+		batchSize   int
+		concurrency int
+		schedule    []error
+		wantCalls   int
+		wantErr     bool
+	}{
+		{name: "不足一批直接调用", texts: 5, batchSize: 8, concurrency: 2, wantCalls: 1},
+		{name: "多批并发按原顺序拼回", texts: 50, batchSize: 4, concurrency: 3, wantCalls: 13},
+		{name: "串行", texts: 10, batchSize: 3, concurrency: 1, wantCalls: 4},
+		{name: "一批失败整体失败", texts: 10, batchSize: 3, concurrency: 1, schedule: []error{nil, errors.New("400 Bad Request")}, wantCalls: 2, wantErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fake := &fakeEmbedder{dim: 2, schedule: c.schedule}
+			e := ChainEmbedder(fake, WithBatching(c.batchSize, c.concurrency))
+			vectors, err := e.EmbedStrings(context.Background(), numbered(c.texts))
+			if c.wantErr {
+				if err == nil {
+					t.Fatal("应返回错误")
+				}
+				// 串行时失败之后的批次不再发出
+				if fake.Calls() != c.wantCalls {
+					t.Errorf("calls = %d, want %d", fake.Calls(), c.wantCalls)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fake.Calls() != c.wantCalls {
+				t.Errorf("calls = %d, want %d", fake.Calls(), c.wantCalls)
+			}
+			for _, batch := range fake.batches {
+				if len(batch) > c.batchSize {
+					t.Errorf("批次大小 %d 超过 %d", len(batch), c.batchSize)
+				}
+			}
+			if len(vectors) != c.texts {
+				t.Fatalf("返回 %d 个向量，期望 %d 个", len(vectors), c.texts)
+			}
+			for i, vec := range vectors {
+				if vec[0] != float64(i) {
+					t.Fatalf("第 %d 个向量对应文本 %v，顺序错乱", i, vec[0])
+				}
+			}
+		})
+	}
+}
+
+func TestRetryClassification(t *testing.T) {
+	cases := []struct {
+		err       error
+		wantCalls int // MaxRetries=2：可重试的错误一共调用 3 次
+	}{
+		{errors.New("503 Service Unavailable: server busy"), 3},
+		{errors.New("429 Too Many Requests"), 3},
+		{errors.New("dial tcp 127.0.0.1:11434: connect: connection refused"), 3},
+		{io.ErrUnexpectedEOF, 3},
+		{context.DeadlineExceeded, 3},
+		{errors.New("400 Bad Request: input too long"), 1},
+		{errors.New("404 model \"bge-m3\" not found"), 1},
+		{errors.New("请求体解析失败"), 1},
+	}
+	for _, c := range cases {
+		fake := &fakeEmbedder{schedule: []error{c.err, c.err, c.err}}
+		e := ChainEmbedder(fake, WithRetry(2, time.Millisecond))
+		if _, err := e.EmbedStrings(context.Background(), []string{"1"}); !errors.Is(err, c.err) {
+			t.Errorf("%v: 应返回原始错误: %v", c.err, err)
+		}
+		if fake.Calls() != c.wantCalls {
+			t.Errorf("%v: calls = %d, want %d", c.err, fake.Calls(), c.wantCalls)
+		}
+	}
+
+	// 重试成功
+	fake := &fakeEmbedder{schedule: []error{io.EOF}}
+	if _, err := ChainEmbedder(fake, WithRetry(2, time.Millisecond)).EmbedStrings(context.Background(), []string{"1"}); err != nil || fake.Calls() != 2 {
+		t.Errorf("第二次应成功: %v (calls=%d)", err, fake.Calls())
+	}
+}
+
+func TestTimeoutPerAttempt(t *testing.T) {
+	cases := []struct {
+		name      string
+		schedule  []error
+		wantCalls int
+		wantErr   bool
+	}{
+		{name: "超时的那次重试成功", schedule: []error{errBlock}, wantCalls: 2},
+		{name: "每次都超时", schedule: []error{errBlock, errBlock, errBlock}, wantCalls: 3, wantErr: true},
+	}
+	for _, c := range cases {
+		fake := &fakeEmbedder{schedule: c.schedule}
+		e := ChainEmbedder(fake, WithRetry(2, time.Millisecond), WithTimeout(20*time.Millisecond))
+		start := time.Now()
+		_, err := e.EmbedStrings(context.Background(), []string{"1"})
+		if (err != nil) != c.wantErr || (c.wantErr && !errors.Is(err, context.DeadlineExceeded)) {
+			t.Errorf("%s: err = %v", c.name, err)
+		}
+		if fake.Calls() != c.wantCalls {
+			t.Errorf("%s: calls = %d, want %d", c.name, fake.Calls(), c.wantCalls)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("%s: 耗时 %v，超时没有按单次生效", c.name, elapsed)
+		}
+	}
+
+	// 调用方取消不再重试
+	fake := &fakeEmbedder{schedule: []error{errBlock, errBlock, errBlock}}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := ChainEmbedder(fake, WithRetry(2, time.Millisecond)).EmbedStrings(ctx, []string{"1"}); err == nil || fake.Calls() != 1 {
+		t.Errorf("调用方超时不应重试: %v (calls=%d)", err, fake.Calls())
+	}
+}
+
+func TestDimensionCheck(t *testing.T) {
+	cases := []struct {
+		name    string
+		dim     int
+		returns []int // 依次调用时假 embedder 返回的维度
+		wantErr []bool
+	}{
+		{name: "与集合维度一致", dim: 4, returns: []int{4, 4}, wantErr: []bool{false, false}},
+		{name: "与集合维度不一致", dim: 4, returns: []int{8}, wantErr: []bool{true}},
+		{name: "未指定时以第一次为准", dim: 0, returns: []int{8, 4}, wantErr: []bool{false, true}},
+	}
+	for _, c := range cases {
+		fake := &fakeEmbedder{}
+		e := ChainEmbedder(fake, WithDimensionCheck(c.dim))
+		for i, d := range c.returns {
+			fake.dim = d
+			_, err := e.EmbedStrings(context.Background(), []string{"1", "2"})
+			if (err != nil) != c.wantErr[i] {
+				t.Errorf("%s: 第 %d 次 err = %v", c.name, i+1, err)
+			}
+		}
+	}
+}
+
+func TestNaNGuard(t *testing.T) {
+	cases := []struct {
+		name string
+		in   []float64
+		want []float64
+	}{
+		{name: "正常向量不变", in: []float64{0.1, -0.2, 0.3}, want: []float64{0.1, -0.2, 0.3}},
+		{name: "NaN", in: []float64{math.NaN(), 0.5}, want: []float64{0, 0.5}},
+		{name: "正负 Inf", in: []float64{math.Inf(1), 0.5, math.Inf(-1)}, want: []float64{0, 0.5, 0}},
+	}
+	for _, c := range cases {
+		raw := EmbedderFunc(func(context.Context, []string, ...embedding.Option) ([][]float64, error) {
+			return [][]float64{append([]float64(nil), c.in...)}, nil
+		})
+		vectors, err := ChainEmbedder(raw, WithNaNGuard()).EmbedStrings(context.Background(), []string{"x"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j, v := range vectors[0] {
+			if v != c.want[j] {
+				t.Errorf("%s: 第 %d 维 = %v, want %v", c.name, j, v, c.want[j])
+			}
+		}
+	}
+}
+
+// 默认链：维度校验在重试之内，维度不一致不会被当成可重试错误
+func TestResilientEmbedderDimension(t *testing.T) {
+	fake := &fakeEmbedder{dim: 8}
+	e := NewResilientEmbedder(fake, EmbedderOptions{BatchSize: 2, Concurrency: 2, MaxRetries: 3, BaseBackoff: time.Millisecond, Timeout: time.Second, Dimension: 4})
+	if _, err := e.EmbedStrings(context.Background(), []string{"1"}); err == nil {
+		t.Fatal("维度与集合不一致应报错")
+	}
+	if fake.Calls() != 1 {
+		t.Errorf("calls = %d, 维度错误不应重试", fake.Calls())
+	}
+}
@@ -0,0 +1,97 @@
+package party
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	// AutoLinkThreshold 入库时相似度达到该值，新主体记下与已有主体的合并建议 (待人工确认)
+	AutoLinkThreshold = 0.85
+	// QueryThreshold 查询时相似度达到该值的主体作为候选返回，由用户选定
+	QueryThreshold = 0.85
+	// PrefixScore 简称是全称主干前缀时的相似度。"腾讯" 和 "腾讯音乐娱乐" 可能是不同主体，
+	// 查询时只有高于该值 (主干一致) 才直接视为指向该主体
+	PrefixScore = 0.9
+)
+
+// regionPrefixRe 公司名前的行政区划，如 "深圳市"、"广东省深圳市"、"北京市海淀区"
+var regionPrefixRe = regexp.MustCompile(`^(中国|\p{Han}{2,3}?(省|市|自治区|特别行政区|区|县))+`)
+
+// bracketRe 公司名里的括号注记，如 "(中国)"、"(北京)"
+var bracketRe = regexp.MustCompile(`\([^)]*\)`)
+
+// companySuffixes 公司组织形式后缀，按长度从长到短排列
+var companySuffixes = []string{"集团股份有限公司", "集团有限公司", "股份有限公司", "有限责任公司", "有限公司", "分公司", "集团", "公司"}
+
+// CoreName 提取名称主干：去掉行政区划前缀、括号注记和组织形式后缀
+// "深圳市腾讯计算机系统有限公司" -> "腾讯计算机系统"，"腾讯公司" -> "腾讯"
+func CoreName(name string) string {
+	core := bracketRe.ReplaceAllString(NormalizeName(name), "")
+	if stripped := regionPrefixRe.ReplaceAllString(core, ""); len([]rune(stripped)) >= 2 {
+		core = stripped
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, suffix := range companySuffixes {
+			if strings.HasSuffix(core, suffix) && len([]rune(core)) > len([]rune(suffix))+1 {
+				core = strings.TrimSuffix(core, suffix)
+				changed = true
+			}
+		}
+	}
+	return core
+}
+
+// Similarity 计算两个名称的相似度 [0, 1]
+// personLike 为 true 时 (任一方是自然人) 只认主干完全一致，避免 "张三" 匹配 "张三丰"
+func Similarity(a, b string, personLike bool) float64 {
+	na, nb := NormalizeName(a), NormalizeName(b)
+	if na == "" || nb == "" {
+		return 0
+	}
+	if na == nb {
+		return 1
+	}
+	ca, cb := CoreName(na), CoreName(nb)
+	if ca == cb {
+		return 0.95
+	}
+	if personLike || len([]rune(ca)) < 2 || len([]rune(cb)) < 2 {
+		return 0
+	}
+	// 简称是全称主干的前缀："腾讯" vs "腾讯计算机系统"
+	if strings.HasPrefix(ca, cb) || strings.HasPrefix(cb, ca) {
+		return PrefixScore
+	}
+	return dice(ca, cb)
+}
+
+// dice 基于字符 bigram 的 Dice 系数
+func dice(a, b string) float64 {
+	ba, bb := bigrams(a), bigrams(b)
+	if len(ba) == 0 || len(bb) == 0 {
+		return 0
+	}
+	overlap := 0
+	for g, n := range ba {
+		overlap += min(n, bb[g])
+	}
+	total := 0
+	for _, n := range ba {
+		total += n
+	}
+	for _, n := range bb {
+		total += n
+	}
+	return 2 * float64(overlap) / float64(total)
+}
+
+func bigrams(s string) map[string]int {
+	r := []rune(s)
+	grams := make(map[string]int)
+	for i := 0; i+1 < len(r); i++ {
+		grams[string(r[i:i+2])]++
+	}
+	return grams
+}
@@ -0,0 +1,92 @@
+package party
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// 参与方角色
+const (
+	RolePartyA      = "甲方"
+	RolePartyB      = "乙方"
+	RolePartyC      = "丙方"
+	RoleGuarantor   = "担保人"
+	RoleSignatory   = "签署代表"
+	RoleUnspecified = "其他"
+)
+
+// 主体类型
+const (
+	EntityPerson       = "person"
+	EntityOrganization = "organization"
+)
+
+// orgSuffixes 机构名称常见后缀，用于判断主体类型
+var orgSuffixes = []string{"公司", "集团", "有限", "厂", "中心", "银行", "事务所", "研究院", "研究所", "大学", "学院", "学校", "医院", "协会", "合作社", "工作室", "商行", "门店", "分行", "支行", "局", "委员会"}
+
+// annotationRe 去掉签章处常见的附注，如 "（盖章）"、"(签字)"
+var annotationRe = regexp.MustCompile(`[（(](盖章|签字|签章|公章|签名|授权代表)[）)]`)
+
+// roleAliases LLM 可能返回的角色写法 -> 标准角色
+var roleAliases = map[string]string{
+	"甲方": RolePartyA, "甲": RolePartyA, "party_a": RolePartyA, "委托方": RolePartyA, "出租方": RolePartyA, "出借人": RolePartyA, "雇主": RolePartyA,
+	"乙方": RolePartyB, "乙": RolePartyB, "party_b": RolePartyB, "受托方": RolePartyB, "承租方": RolePartyB, "借款人": RolePartyB, "员工": RolePartyB,
+	"丙方": RolePartyC, "丙": RolePartyC, "party_c": RolePartyC,
+	"担保人": RoleGuarantor, "保证人": RoleGuarantor, "担保方": RoleGuarantor, "guarantor": RoleGuarantor,
+	"签署代表": RoleSignatory, "法定代表人": RoleSignatory, "授权代表": RoleSignatory, "委托代理人": RoleSignatory, "signatory": RoleSignatory,
+}
+
+// NormalizeName 规范化参与方名称：全角转半角、去空白和签章附注
+func NormalizeName(name string) string {
+	name = annotationRe.ReplaceAllString(name, "")
+	var sb strings.Builder
+	for _, r := range name {
+		// 全角字符转半角 (全角括号统一成半角，便于后续比较)
+		if r == 0x3000 {
+			continue
+		}
+		if r >= 0xFF01 && r <= 0xFF5E {
+			r -= 0xFEE0
+		}
+		if unicode.IsSpace(r) {
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// NormalizeRole 将 LLM 返回的角色转为标准角色
+func NormalizeRole(role string) string {
+	role = strings.TrimSpace(role)
+	if r, ok := roleAliases[strings.ToLower(role)]; ok {
+		return r
+	}
+	return RoleUnspecified
+}
+
+// GuessEntityType 根据名称推断主体类型 (机构/个人)
+func GuessEntityType(name string) string {
+	for _, suffix := range orgSuffixes {
+		if strings.Contains(name, suffix) {
+			return EntityOrganization
+		}
+	}
+	// 超过 4 个字的一般不是人名
+	if len([]rune(name)) > 4 {
+		return EntityOrganization
+	}
+	return EntityPerson
+}
+
+// NormalizeEntityType 校验 LLM 返回的主体类型，不合法时根据名称推断
+func NormalizeEntityType(entityType, name string) string {
+	switch strings.ToLower(strings.TrimSpace(entityType)) {
+	case EntityPerson, "个人", "自然人":
+		return EntityPerson
+	case EntityOrganization, "org", "company", "机构", "公司", "法人":
+		return EntityOrganization
+	}
+	return GuessEntityType(name)
+}
@@ -0,0 +1,331 @@
+// Package prompt 提示词注册表：按 名称/版本 管理模板，统一用 text/template 渲染，支持按权重在版本间分流
+package prompt
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// 提示词名称，对应 templates 下的目录
+const (
+	Extract = "extract" // 合同结构化提取，变量: Content, CurrentDate
+	Intent  = "intent"  // 查询意图解析，变量: CurrentDate, Examples ([]retrieval.Example)
+)
+
+// builtin 随程序发布的提示词，templates/<名称>/<版本>.tmpl
+//
+//go:embed templates
+var builtin embed.FS
+
+const ext = ".tmpl"
+
+// Prompt 某个名称的一个版本
+type Prompt struct {
+	Name    string
+	Version string
+	tmpl    *template.Template
+}
+
+// ID 记录到合同和检索日志里的版本标识，如 intent/v2
+func (p *Prompt) ID() string {
+	return p.Name + "/" + p.Version
+}
+
+// Render 渲染模板，模板引用了 data 中没有的字段时报错
+func (p *Prompt) Render(data map[string]any) (string, error) {
+	var buf strings.Builder
+	if err := p.tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("渲染提示词 %s 失败: %w", p.ID(), err)
+	}
+	return buf.String(), nil
+}
+
+// split 分流权重
+type split struct {
+	version string
+	weight  int
+}
+
+// Registry 内置提示词 + 外部目录 (同名版本覆盖内置)，外部目录修改后热更新
+type Registry struct {
+	dir string
+
+	mu      sync.RWMutex
+	prompts map[string]map[string]*Prompt // 名称 -> 版本 -> 模板
+	splits  map[string][]split
+	stamp   string // 外部目录的文件列表和修改时间，用于判断是否需要重新加载
+}
+
+// NewRegistry dir 为空时只用内置提示词
+// splits 为 名称 -> 版本 -> 权重，没有配置分流的提示词使用最新版本
+func NewRegistry(dir string, splits map[string]map[string]int) (*Registry, error) {
+	r := &Registry{dir: dir}
+	prompts, stamp, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := parseSplits(prompts, splits)
+	if err != nil {
+		return nil, err
+	}
+	r.prompts, r.splits, r.stamp = prompts, parsed, stamp
+	r.print()
+	return r, nil
+}
+
+// Pick 按分流权重选择版本
+// key 相同则选中的版本相同 (如同一查询、同一文件)，便于复现和缓存；key 为空时随机
+func (r *Registry) Pick(name, key string) (*Prompt, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	versions := r.prompts[name]
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("提示词 %s 不存在", name)
+	}
+	weights := r.splits[name]
+	if len(weights) == 0 {
+		return versions[latest(versions)], nil
+	}
+
+	total := 0
+	for _, w := range weights {
+		total += w.weight
+	}
+	var n int
+	if key == "" {
+		n = rand.Intn(total)
+	} else {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(key))
+		n = int(h.Sum32() % uint32(total))
+	}
+	for _, w := range weights {
+		if n < w.weight {
+			return versions[w.version], nil
+		}
+		n -= w.weight
+	}
+	return versions[weights[len(weights)-1].version], nil
+}
+
+// Get 指定版本
+func (r *Registry) Get(name, version string) (*Prompt, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.prompts[name][version]
+	if !ok {
+		return nil, fmt.Errorf("提示词 %s/%s 不存在", name, version)
+	}
+	return p, nil
+}
+
+// SetSplits 替换分流配置，引用了不存在的版本时不生效
+func (r *Registry) SetSplits(splits map[string]map[string]int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	parsed, err := parseSplits(r.prompts, splits)
+	if err != nil {
+		return err
+	}
+	r.splits = parsed
+	r.print()
+	return nil
+}
+
+// Reload 重新加载外部目录，模板解析失败或分流引用的版本被删除时保留原来的提示词
+func (r *Registry) Reload() error {
+	prompts, stamp, err := r.load()
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name, weights := range r.splits {
+		for _, w := range weights {
+			if prompts[name][w.version] == nil {
+				return fmt.Errorf("分流中的提示词 %s/%s 已不存在", name, w.version)
+			}
+		}
+	}
+	r.prompts, r.stamp = prompts, stamp
+	r.print()
+	return nil
+}
+
+// Watch 按 interval 检查外部目录，有文件增删改时重新加载，直到 ctx 结束
+func (r *Registry) Watch(ctx context.Context, interval time.Duration) {
+	if r.dir == "" {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stamp, err := dirStamp(r.dir)
+			if err != nil {
+				continue
+			}
+			r.mu.RLock()
+			changed := stamp != r.stamp
+			r.mu.RUnlock()
+			if !changed {
+				continue
+			}
+			if err := r.Reload(); err != nil {
+				fmt.Printf("⚠️ [Prompt] 提示词目录 %s 热更新失败，继续使用原提示词: %v\n", r.dir, err)
+				// 记下这次的状态，文件再次修改前不重复报错
+				r.mu.Lock()
+				r.stamp = stamp
+				r.mu.Unlock()
+			}
+		}
+	}
+}
+
+// load 读取内置提示词和外部目录
+func (r *Registry) load() (map[string]map[string]*Prompt, string, error) {
+	prompts := make(map[string]map[string]*Prompt)
+	sub, _ := fs.Sub(builtin, "templates")
+	if err := loadFS(prompts, sub, "builtin"); err != nil {
+		return nil, "", err
+	}
+	if r.dir == "" {
+		return prompts, "", nil
+	}
+	stamp, err := dirStamp(r.dir)
+	if err != nil {
+		return nil, "", fmt.Errorf("读取提示词目录失败: %w", err)
+	}
+	if err := loadFS(prompts, os.DirFS(r.dir), r.dir); err != nil {
+		return nil, "", err
+	}
+	return prompts, stamp, nil
+}
+
+// loadFS 解析 <名称>/<版本>.tmpl，其余文件忽略
+func loadFS(prompts map[string]map[string]*Prompt, fsys fs.FS, source string) error {
+	files, err := fs.Glob(fsys, "*/*"+ext)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		name, version := path.Dir(file), strings.TrimSuffix(path.Base(file), ext)
+		text, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+		tmpl, err := template.New(name + "/" + version).Option("missingkey=error").Parse(string(text))
+		if err != nil {
+			return fmt.Errorf("解析提示词 %s 失败: %w", path.Join(source, file), err)
+		}
+		if prompts[name] == nil {
+			prompts[name] = make(map[string]*Prompt)
+		}
+		prompts[name][version] = &Prompt{Name: name, Version: version, tmpl: tmpl}
+	}
+	return nil
+}
+
+// dirStamp 外部目录中模板文件的路径、大小和修改时间
+func dirStamp(dir string) (string, error) {
+	files, err := fs.Glob(os.DirFS(dir), "*/*"+ext)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, file := range files {
+		info, err := os.Stat(path.Join(dir, file))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "%s:%d:%d;", file, info.Size(), info.ModTime().UnixNano())
+	}
+	return b.String(), nil
+}
+
+// parseSplits 校验并按版本排序分流配置 (排序保证同一个 key 总是落在同一个版本)
+func parseSplits(prompts map[string]map[string]*Prompt, splits map[string]map[string]int) (map[string][]split, error) {
+	parsed := make(map[string][]split, len(splits))
+	for name, weights := range splits {
+		total := 0
+		for version, weight := range weights {
+			if prompts[name][version] == nil {
+				return nil, fmt.Errorf("分流配置引用了不存在的提示词 %s/%s", name, version)
+			}
+			if weight < 0 {
+				return nil, fmt.Errorf("提示词 %s/%s 的分流权重不能为负数", name, version)
+			}
+			if weight > 0 {
+				parsed[name] = append(parsed[name], split{version: version, weight: weight})
+			}
+			total += weight
+		}
+		if total == 0 {
+			return nil, fmt.Errorf("提示词 %s 的分流权重之和必须大于 0", name)
+		}
+		sort.Slice(parsed[name], func(i, j int) bool {
+			return versionLess(parsed[name][i].version, parsed[name][j].version)
+		})
+	}
+	return parsed, nil
+}
+
+// latest 最新版本：v2 < v10，无法按数字比较的按字符串比较
+func latest(versions map[string]*Prompt) string {
+	var newest string
+	for version := range versions {
+		if newest == "" || versionLess(newest, version) {
+			newest = version
+		}
+	}
+	return newest
+}
+
+func versionLess(a, b string) bool {
+	na, errA := strconv.Atoi(strings.TrimPrefix(a, "v"))
+	nb, errB := strconv.Atoi(strings.TrimPrefix(b, "v"))
+	if errA == nil && errB == nil && na != nb {
+		return na < nb
+	}
+	return a < b
+}
+
+// print 打印已加载的版本和分流
+func (r *Registry) print() {
+	names := make([]string, 0, len(r.prompts))
+	for name := range r.prompts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		versions := make([]string, 0, len(r.prompts[name]))
+		for version := range r.prompts[name] {
+			versions = append(versions, version)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versionLess(versions[i], versions[j]) })
+		if weights := r.splits[name]; len(weights) > 0 {
+			parts := make([]string, len(weights))
+			for i, w := range weights {
+				parts[i] = fmt.Sprintf("%s=%d", w.version, w.weight)
+			}
+			fmt.Printf(">>> [Prompt] %s: 版本 %v，分流 %s\n", name, versions, strings.Join(parts, " "))
+		} else {
+			fmt.Printf(">>> [Prompt] %s: 版本 %v，使用 %s\n", name, versions, latest(r.prompts[name]))
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package retrieval
+
+import (
+	"context"
+	"eino-demo/types"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// metricAliases LLM 偶尔输出中文或近义词，统一为标准指标
+var metricAliases = map[string]string{
+	"数量": types.MetricCount, "份数": types.MetricCount, "个数": types.MetricCount,
+	"总额": types.MetricSum, "总金额": types.MetricSum, "合计": types.MetricSum, "total": types.MetricSum,
+	"平均": types.MetricAvg, "平均值": types.MetricAvg, "average": types.MetricAvg, "mean": types.MetricAvg,
+	"最大": types.MetricMax, "最高": types.MetricMax, "最小": types.MetricMin, "最低": types.MetricMin,
+}
+
+// groupByAliases 分组维度的中文/近义写法
+var groupByAliases = map[string]string{
+	"参与方": types.GroupByParty, "主体": types.GroupByParty, "客户": types.GroupByParty, "供应商": types.GroupByParty,
+	"counterparty": types.GroupByParty, "any_party": types.GroupByParty,
+	"类型": types.GroupByContractType, "合同类型": types.GroupByContractType, "type": types.GroupByContractType,
+	"年": types.GroupByYear, "年份": types.GroupByYear, "年度": types.GroupByYear,
+	"月": types.GroupByMonth, "月份": types.GroupByMonth,
+	"状态": types.GroupByStatus, "contract_status": types.GroupByStatus,
+}
+
+// normalizeAggregation 校验聚合需求：指标默认 count，无法识别的分组维度直接丢弃
+func normalizeAggregation(spec *types.AggregationSpec) *types.AggregationSpec {
+	if spec == nil {
+		return &types.AggregationSpec{Metric: types.MetricCount}
+	}
+	metric := strings.ToLower(strings.TrimSpace(spec.Metric))
+	if m, ok := metricAliases[metric]; ok {
+		metric = m
+	}
+	if !types.IsValidMetric(metric) {
+		metric = types.MetricCount
+	}
+
+	var groupBy []string
+	seen := make(map[string]bool)
+	for _, g := range spec.GroupBy {
+		g = strings.ToLower(strings.TrimSpace(g))
+		if alias, ok := groupByAliases[g]; ok {
+			g = alias
+		}
+		if !types.IsValidGroupBy(g) || seen[g] {
+			continue
+		}
+		seen[g] = true
+		groupBy = append(groupBy, g)
+	}
+	return &types.AggregationSpec{Metric: metric, GroupBy: groupBy}
+}
+
+// columnLabels 结果表的中文列名
+var columnLabels = map[string]string{
+	types.GroupByParty:        "参与方",
+	types.GroupByContractType: "合同类型",
+	types.GroupByYear:         "年份",
+	types.GroupByMonth:        "月份",
+	types.GroupByStatus:       "状态",
+	types.MetricCount:         "合同数",
+	types.MetricSum:           "总金额(元)",
+	types.MetricAvg:           "平均金额(元)",
+	types.MetricMax:           "最高金额(元)",
+	types.MetricMin:           "最低金额(元)",
+}
+
+// FormatAggregate 把聚合结果渲染为 Markdown 表格
+func FormatAggregate(result *types.AggregateResult) string {
+	if result == nil || len(result.Rows) == 0 {
+		return "没有符合条件的合同"
+	}
+	var sb strings.Builder
+	headers := make([]string, len(result.Columns))
+	for i, c := range result.Columns {
+		headers[i] = columnLabels[c]
+	}
+	sb.WriteString("| " + strings.Join(headers, " | ") + " |\n")
+	sb.WriteString("|" + strings.Repeat(" --- |", len(headers)) + "\n")
+	for _, row := range result.Rows {
+		cells := make([]string, len(row))
+		for i, v := range row {
+			cells[i] = formatCell(result.Columns[i], v)
+		}
+		sb.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+	return sb.String()
+}
+
+func formatCell(column string, v any) string {
+	switch column {
+	case types.GroupByStatus:
+		switch fmt.Sprint(v) {
+		case fmt.Sprint(types.StatusActive):
+			return "生效中"
+		case fmt.Sprint(types.StatusExpired):
+			return "已过期"
+		}
+	case types.MetricSum, types.MetricAvg, types.MetricMax, types.MetricMin:
+		if f, ok := v.(float64); ok {
+			return fmt.Sprintf("%.2f", f)
+		}
+	}
+	if v == nil {
+		return "未知"
+	}
+	return fmt.Sprint(v)
+}
+
+// SummarizeAggregate 用 LLM 把聚合结果总结为一两句自然语言 (失败时由调用方忽略)
+func SummarizeAggregate(ctx context.Context, query string, result *types.AggregateResult, chatModel model.ToolCallingChatModel) (string, error) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+	prompt := `你是合同数据分析助手。根据统计结果，用一到两句中文回答用户的问题。
+只能使用给出的数字，不要编造；金额单位为元，较大金额可换算为万元。`
+	resp, err := chatModel.Generate(ctx, []*schema.Message{
+		schema.SystemMessage(prompt),
+		schema.UserMessage(fmt.Sprintf("问题: %s\n统计结果(JSON): %s\n表格:\n%s", query, data, FormatAggregate(result))),
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(resp.Content), nil
+}
@@ -0,0 +1,40 @@
+package retrieval
+
+import (
+	"context"
+	"eino-demo/types"
+	"fmt"
+	"strings"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// maxAnswerChunkLen 拼进 Prompt 的单个片段最大长度 (字节)
+const maxAnswerChunkLen = 1500
+
+// AnswerFromChunks 根据检索到的合同片段回答问题，答案用 [n] 标注引用的片段
+func AnswerFromChunks(ctx context.Context, query string, chunks []types.ChunkHit, chatModel model.ToolCallingChatModel) (string, error) {
+	if len(chunks) == 0 {
+		return "没有检索到相关的合同内容，无法回答。", nil
+	}
+	var sb strings.Builder
+	for i, c := range chunks {
+		content := c.Content
+		if len(content) > maxAnswerChunkLen {
+			content = strings.ToValidUTF8(content[:maxAnswerChunkLen], "") + "..."
+		}
+		sb.WriteString(fmt.Sprintf("[%d] (合同 %s)\n%s\n\n", i+1, c.DocID, content))
+	}
+
+	prompt := `你是合同问答助手。只根据给出的合同片段回答用户的问题，用中文简洁作答。
+每个结论后用 [编号] 标注依据的片段；片段中没有答案时直接说明"合同中未找到相关约定"，不要编造。`
+	resp, err := chatModel.Generate(ctx, []*schema.Message{
+		schema.SystemMessage(prompt),
+		schema.UserMessage(fmt.Sprintf("问题: %s\n\n合同片段:\n%s", query, sb.String())),
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(resp.Content), nil
+}
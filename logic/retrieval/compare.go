@@ -0,0 +1,177 @@
+package retrieval
+
+import (
+	"context"
+	"eino-demo/logic/ingestion/clause"
+	"eino-demo/types"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// maxCompareEvidenceLen 拼进 Prompt 的单条依据最大长度 (rune)
+const maxCompareEvidenceLen = 600
+
+// contractRef 合同在 Prompt 中的代号 A、B、C...，比 doc_id 更不容易被 LLM 写错
+func contractRef(i int) string {
+	return string(rune('A' + i))
+}
+
+// compareRow LLM 输出的一行，values 以合同代号为键
+type compareRow struct {
+	ClauseType string            `json:"clause_type"`
+	Term       string            `json:"term"`
+	Status     string            `json:"status"`
+	Values     map[string]string `json:"values"`
+	Citations  []string          `json:"citations"`
+}
+
+// CompareContracts 由 LLM 根据对齐后的条款生成差异表
+// 输出中的合同代号换回 doc_id，状态和引用只保留合法取值
+func CompareContracts(ctx context.Context, query string, result *types.CompareResult, chatModel model.ToolCallingChatModel) ([]types.CompareRow, error) {
+	refToDoc := make(map[string]string, len(result.Contracts))
+	docToRef := make(map[string]string, len(result.Contracts))
+	var sb strings.Builder
+	sb.WriteString("合同:\n")
+	for i, c := range result.Contracts {
+		ref := contractRef(i)
+		refToDoc[ref], docToRef[c.DocID] = c.DocID, ref
+		sb.WriteString(fmt.Sprintf("%s: %s (甲方 %s，乙方 %s)\n", ref, c.FileName, c.PartyA, c.PartyB))
+	}
+	sb.WriteString("\n依据:\n")
+	evidenceRefs := make(map[string]bool, len(result.Evidence))
+	for _, e := range result.Evidence {
+		evidenceRefs[e.Ref] = true
+		content := []rune(e.Content)
+		if len(content) > maxCompareEvidenceLen {
+			content = append(content[:maxCompareEvidenceLen], []rune("...")...)
+		}
+		sb.WriteString(fmt.Sprintf("[%s] 合同%s %s: %s\n", e.Ref, docToRef[e.DocID], clause.Label(e.ClauseType), string(content)))
+	}
+	labels := make([]string, len(result.ClauseTypes))
+	for i, ct := range result.ClauseTypes {
+		labels[i] = fmt.Sprintf("%s(%s)", ct, clause.Label(ct))
+	}
+
+	prompt := `你是合同比对助手。根据给出的依据，逐项比较各合同在指定条款类型上的约定。
+要求：
+1. 每类条款拆成若干要点 (如付款期限、付款比例、违约金标准)，每个要点一行
+2. status 只能是 same(约定一致) / different(约定不同) / missing(有合同未约定)
+3. values 以合同代号为键，值为该合同的约定，简洁概括；未约定填 ""
+4. citations 填依据编号 (如 "E1")，只能引用给出的依据，不要编造
+只输出 JSON，不要 markdown：
+{"rows": [{"clause_type": "payment", "term": "付款期限", "status": "different", "values": {"A": "验收后30日内", "B": "签约后7日内"}, "citations": ["E1", "E3"]}]}`
+	resp, err := chatModel.Generate(ctx, []*schema.Message{
+		schema.SystemMessage(prompt),
+		schema.UserMessage(fmt.Sprintf("问题: %s\n比较的条款类型: %s\n\n%s", query, strings.Join(labels, ", "), sb.String())),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	raw := resp.Content
+	start, end := strings.Index(raw, "{"), strings.LastIndex(raw, "}")
+	if start == -1 || end <= start {
+		return nil, fmt.Errorf("比较结果不是 JSON: %s", raw)
+	}
+	var out struct {
+		Rows []compareRow `json:"rows"`
+	}
+	if err := json.Unmarshal([]byte(raw[start:end+1]), &out); err != nil {
+		return nil, fmt.Errorf("比较结果解析失败: %v", err)
+	}
+
+	rows := make([]types.CompareRow, 0, len(out.Rows))
+	for _, r := range out.Rows {
+		row := types.CompareRow{
+			ClauseType: clause.Normalize(r.ClauseType),
+			Term:       r.Term,
+			Status:     r.Status,
+			Values:     make(map[string]string, len(result.Contracts)),
+		}
+		if row.Status != types.CompareSame && row.Status != types.CompareDifferent && row.Status != types.CompareMissing {
+			row.Status = types.CompareUnknown
+		}
+		for ref, v := range r.Values {
+			if docID, ok := refToDoc[strings.TrimSpace(ref)]; ok {
+				row.Values[docID] = v
+			}
+		}
+		for _, c := range r.Citations {
+			if c = strings.Trim(strings.TrimSpace(c), "[]"); evidenceRefs[c] {
+				row.Citations = append(row.Citations, c)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// FallbackCompareRows LLM 不可用时按条款类型逐类列出原文，只判断是否缺失
+func FallbackCompareRows(result *types.CompareResult) []types.CompareRow {
+	rows := make([]types.CompareRow, 0, len(result.ClauseTypes))
+	for _, ct := range result.ClauseTypes {
+		row := types.CompareRow{ClauseType: ct, Term: clause.Label(ct), Status: types.CompareUnknown, Values: make(map[string]string)}
+		for _, e := range result.Evidence {
+			if e.ClauseType != ct {
+				continue
+			}
+			content := []rune(e.Content)
+			if len(content) > 100 {
+				content = append(content[:100], []rune("...")...)
+			}
+			if row.Values[e.DocID] == "" {
+				row.Values[e.DocID] = string(content)
+			}
+			row.Citations = append(row.Citations, e.Ref)
+		}
+		if len(row.Values) < len(result.Contracts) {
+			row.Status = types.CompareMissing
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// compareStatusLabels 比较结论的中文名
+var compareStatusLabels = map[string]string{
+	types.CompareSame:      "一致",
+	types.CompareDifferent: "不同",
+	types.CompareMissing:   "缺失",
+	types.CompareUnknown:   "待判断",
+}
+
+// FormatCompare 差异表转为 Markdown：每行一个要点，每个合同一列，最后是结论和引用
+func FormatCompare(result *types.CompareResult) string {
+	var sb strings.Builder
+	sb.WriteString("| 条款 | 要点 |")
+	for i, c := range result.Contracts {
+		sb.WriteString(fmt.Sprintf(" %s: %s |", contractRef(i), escapeCell(c.FileName)))
+	}
+	sb.WriteString(" 结论 | 引用 |\n|---|---|")
+	for range result.Contracts {
+		sb.WriteString("---|")
+	}
+	sb.WriteString("---|---|\n")
+	for _, r := range result.Rows {
+		sb.WriteString(fmt.Sprintf("| %s | %s |", clause.Label(r.ClauseType), escapeCell(r.Term)))
+		for _, c := range result.Contracts {
+			v := r.Values[c.DocID]
+			if v == "" {
+				v = "未约定"
+			}
+			sb.WriteString(fmt.Sprintf(" %s |", escapeCell(v)))
+		}
+		sb.WriteString(fmt.Sprintf(" %s | %s |\n", compareStatusLabels[r.Status], strings.Join(r.Citations, ", ")))
+	}
+	return sb.String()
+}
+
+// escapeCell Markdown 表格单元格中的 | 和换行会破坏表格
+func escapeCell(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	return strings.ReplaceAll(s, "\n", " ")
+}
@@ -0,0 +1,73 @@
+package retrieval
+
+import (
+	"eino-demo/types"
+	"encoding/json"
+	"math"
+	"sort"
+)
+
+// Example 意图解析的标注示例，渲染到提示词的 Examples 中
+type Example struct {
+	Query  string
+	Output string  // 正确的 SearchIntent JSON
+	Score  float64 // 与当前查询的相似度
+}
+
+// NewExample 标注的意图转为提示词示例，意图需先经过 Sanitize
+func NewExample(query string, intent *types.SearchIntent) (Example, error) {
+	out, err := json.MarshalIndent(intent, "", "  ")
+	if err != nil {
+		return Example{}, err
+	}
+	return Example{Query: query, Output: string(out)}, nil
+}
+
+// ExampleVector 带查询向量的示例
+type ExampleVector struct {
+	Example
+	Vector []float64
+}
+
+// SelectExamples 按余弦相似度选出与查询最相近的 k 个示例，低于 minScore 的不要
+// 小模型更容易照着相近的示例输出，不相关的示例反而会干扰
+func SelectExamples(query []float64, candidates []ExampleVector, k int, minScore float64) []Example {
+	if k <= 0 {
+		return nil
+	}
+	selected := make([]Example, 0, len(candidates))
+	for _, c := range candidates {
+		score := cosine(query, c.Vector)
+		if score < minScore {
+			continue
+		}
+		e := c.Example
+		e.Score = score
+		selected = append(selected, e)
+	}
+	sort.SliceStable(selected, func(i, j int) bool { return selected[i].Score > selected[j].Score })
+	if len(selected) > k {
+		selected = selected[:k]
+	}
+	// 最相近的放在最后，离用户查询最近
+	for i, j := 0, len(selected)-1; i < j; i, j = i+1, j-1 {
+		selected[i], selected[j] = selected[j], selected[i]
+	}
+	return selected
+}
+
+func cosine(a, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
@@ -0,0 +1,59 @@
+package retrieval
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCosine(t *testing.T) {
+	cases := []struct {
+		a, b []float64
+		want float64
+	}{
+		{[]float64{1, 0}, []float64{2, 0}, 1},
+		{[]float64{1, 0}, []float64{0, 3}, 0},
+		{[]float64{1, 1}, []float64{-1, -1}, -1},
+		{[]float64{1, 2, 3}, []float64{1, 2}, 0}, // 维度不同 (如换了向量模型)
+		{nil, nil, 0},
+		{[]float64{0, 0}, []float64{1, 1}, 0}, // 零向量
+	}
+	for _, c := range cases {
+		if got := cosine(c.a, c.b); math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("cosine(%v, %v) = %v, want %v", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestSelectExamples(t *testing.T) {
+	candidates := []ExampleVector{
+		{Example: Example{Query: "远"}, Vector: []float64{0, 1}},
+		{Example: Example{Query: "最近"}, Vector: []float64{1, 0}},
+		{Example: Example{Query: "较近"}, Vector: []float64{1, 0.5}},
+		{Example: Example{Query: "次近"}, Vector: []float64{1, 0.2}},
+	}
+	query := []float64{1, 0}
+
+	got := SelectExamples(query, candidates, 2, 0.5)
+	if len(got) != 2 || got[0].Query != "次近" || got[1].Query != "最近" {
+		t.Fatalf("应取最相近的 2 个且最相近的放最后: %+v", got)
+	}
+	if math.Abs(got[1].Score-1) > 1e-9 {
+		t.Errorf("score = %v, want 1", got[1].Score)
+	}
+
+	got = SelectExamples(query, candidates, 10, 0.5)
+	if len(got) != 3 {
+		t.Errorf("低于 min_score 的示例不选: %+v", got)
+	}
+	for _, e := range got {
+		if e.Query == "远" {
+			t.Errorf("不相关的示例不应选中")
+		}
+	}
+	if got := SelectExamples(query, candidates, 0, 0); got != nil {
+		t.Errorf("k=0 应返回空: %+v", got)
+	}
+	if got := SelectExamples(query, nil, 3, 0); len(got) != 0 {
+		t.Errorf("没有候选时应返回空: %+v", got)
+	}
+}
@@ -2,17 +2,28 @@ package main
 
 import (
 	"context"
+	"eino-demo/config"
 	"eino-demo/job"
 	"eino-demo/logic/chat"
+	"eino-demo/logic/ingestion/transform"
+	"eino-demo/logic/prompt"
+	"eino-demo/storage/cache"
 	"eino-demo/storage/es"
 	"eino-demo/storage/milvus"
-	"eino-demo/vars"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
 	"time"
 
 	"github.com/cloudwego/eino-ext/components/embedding/ollama"
+	"github.com/cloudwego/eino/components/embedding"
 	"github.com/milvus-io/milvus-sdk-go/v2/client"
+	"gorm.io/gorm"
 
 	"github.com/gin-gonic/gin"
 
@@ -26,60 +37,207 @@ import (
 
 func main() {
 	ctx := context.Background()
+	// 0. 加载配置：默认值 -> 配置文件 (CONFIG_FILE，默认 config.yaml) -> 环境变量，校验不通过直接退出
+	cfgPath := config.Path()
+	cfg, err := config.Load(cfgPath)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	source := cfgPath
+	if source == "" {
+		source = "未使用配置文件"
+	}
+	log.Printf("✅ 配置已加载 (%s):\n%s", source, cfg)
+	cfgStore := config.NewStore(cfgPath, cfg)
+
 	// 1. 初始化 DB
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
-		vars.PGHOST, vars.PGUSER, vars.PGPWD, vars.PGDB, vars.PGPORT)
-	db, err := postgres.InitDB(dsn)
+	db, err := postgres.InitDB(cfg.Postgres.DSN())
 	if err != nil {
 		panic(err)
 	}
 
+	if err := postgres.AutoMigrate(db); err != nil {
+		panic(err)
+	}
+
+	// 初始化缓存 (意图、查询向量、检索结果)
+	appCache := cache.New(newCacheBackend(cfg.Cache))
+	// 检索参数热更新后，按旧参数缓存的检索结果作废
+	cfgStore.OnChange(func(_, _ *config.Config) { appCache.Invalidate(context.Background()) })
+	watchCtx, stopWatch := context.WithCancel(ctx)
+	defer stopWatch()
+	go cfgStore.Watch(watchCtx, 5*time.Second)
+
+	// 提示词注册表：内置版本 + prompts.dir 目录，目录内容和分流权重都支持热更新
+	prompts, err := prompt.NewRegistry(cfg.Prompts.Dir, cfg.Prompts.Splits)
+	if err != nil {
+		log.Fatalf("❌ 提示词加载失败: %v", err)
+	}
+	go prompts.Watch(watchCtx, 5*time.Second)
+	cfgStore.OnChange(func(old, updated *config.Config) {
+		if reflect.DeepEqual(old.Prompts.Splits, updated.Prompts.Splits) {
+			return
+		}
+		if err := prompts.SetSplits(updated.Prompts.Splits); err != nil {
+			log.Printf("⚠️ 提示词分流未更新，继续使用原分流: %v", err)
+		}
+	})
+
 	// 2. 初始化 pg
 	pgRepo := postgres.NewContractRepo(db)
+	partyRepo := postgres.NewPartyRepo(db)
+	partySvc := service.NewPartyService(partyRepo, appCache)
+	if n, err := partySvc.Backfill(ctx); err != nil {
+		log.Printf("⚠️ 参与方主体回填失败: %v", err)
+	} else if n > 0 {
+		log.Printf("✅ 已为 %d 个历史参与方关联主体", n)
+	}
 
 	// 启动定时任务
-	job.StartCronJob(pgRepo)
-
-	// 3. 初始化 LLM Model
-	model := chat.CreateOllamaChatModel(ctx, vars.OLLAMA_PATH, vars.QWEN3B)
-	embedder, err := ollama.NewEmbedder(ctx, &ollama.EmbeddingConfig{
-		BaseURL: vars.OLLAMA_PATH,
-		Model:   vars.NOMIC,
-		Timeout: 60 * time.Second,
-	})
+	job.StartCronJob(pgRepo, appCache)
+
+	// 3. 初始化 LLM Model：提取、意图、问答等按用途分别配置
+	chatModels, err := chat.NewModels(ctx, cfg)
 	if err != nil {
 		panic(err)
 	}
 
 	// 创建全局 Milvus Client（复用）
 	milvusClient, err := client.NewClient(ctx, client.Config{
-		Address: vars.MILVUSADDR,
+		Address: cfg.Milvus.Addr,
 	})
 	if err != nil {
 		panic(fmt.Sprintf("Milvus 连接失败:%v", err))
 	}
 	log.Println("✅ Milvus 全局连接已创建")
 
-	indexer, err := milvus.NewMilvusIndexerWithClient(ctx, milvusClient, embedder, vars.COLLECTION)
+	// 当前在用的向量集合及其模型 (首次启动登记 milvus.collection)，检索别名指向它
+	versionRepo := postgres.NewEmbeddingVersionRepo(db)
+	activeVersion, err := service.BootstrapEmbeddingVersion(ctx, versionRepo, milvusClient, cfg.Milvus.Alias, cfg.Milvus.Collection, cfg.Models.Embedding)
+	if err != nil {
+		panic(fmt.Sprintf("向量集合版本初始化失败:%v", err))
+	}
+	log.Printf("✅ 当前向量集合: %s (%s)", activeVersion.Collection, activeVersion.Model)
+
+	ingestEmbedder, queryEmbedder, embeddingCache, err := newEmbedders(ctx, cfg, db, appCache, activeVersion.Model, activeVersion.Dim)
+	if err != nil {
+		panic(err)
+	}
+	// 集合 schema 迁移：已是最新版本时启动不做任何索引操作
+	migrator := milvus.NewMigrator(milvusClient, postgres.NewMilvusMigrationRepo(db))
+	indexer, schemaVersion, err := milvus.NewMilvusIndexerWithClient(ctx, milvusClient, ingestEmbedder, activeVersion.Collection, migrator)
 	if err != nil {
 		panic(fmt.Sprintf("Milvus 初始化失败:%v", err))
 	}
+	vectorIndex := service.NewVectorIndex(milvusClient, cfg.Milvus.Alias, activeVersion.Collection, activeVersion.Model, schemaVersion, queryEmbedder, indexer)
 
-	esIndexer, err := es.NewESIndexer([]string{vars.ESADDR}, "contract_chunks_v1")
+	esIndexer, err := es.NewESIndexer([]string{cfg.ES.Addr}, cfg.ES.Index)
 	if err != nil {
 		panic(err)
 	}
 
 	// 4. 初始化 Service (业务层)
-	contractSvc := service.NewContractService(pgRepo, model, embedder, indexer, esIndexer)
-	retrievalSvc := service.NewRetrievalService(pgRepo, model, embedder, milvusClient, esIndexer.GetClient())
+	contractSvc := service.NewContractService(pgRepo, partySvc, chatModels.Extraction, prompts, ingestEmbedder, vectorIndex, esIndexer, appCache, cfg)
+	searchLogRepo := postgres.NewSearchLogRepo(db)
+	exampleSvc := service.NewIntentExampleService(postgres.NewIntentExampleRepo(db), searchLogRepo, vectorIndex, cfgStore)
+	if err := exampleSvc.Load(ctx); err != nil {
+		log.Printf("⚠️ 意图示例加载失败，意图解析只用固定示例: %v", err)
+	}
+	retrievalSvc := service.NewRetrievalService(pgRepo, partySvc, chatModels, prompts, exampleSvc, searchLogRepo, vectorIndex, milvusClient, esIndexer.GetClient(), appCache, cfgStore)
+	embedderFactory := func(ctx context.Context, model string, dim int) (embedding.Embedder, embedding.Embedder, error) {
+		ingest, query, _, err := newEmbedders(ctx, cfg, db, appCache, model, dim)
+		return ingest, query, err
+	}
+	reembedSvc := service.NewReembedService(pgRepo, versionRepo, milvusClient, migrator, esIndexer.GetClient(), vectorIndex, embedderFactory, appCache, cfg)
+	analyticsSvc := service.NewAnalyticsService(pgRepo)
 	// 5. 初始化 Handler (API 层)
 	contractHandler := handler.NewContractHandler(contractSvc, retrievalSvc)
+	partyHandler := handler.NewPartyHandler(partySvc)
+	analyticsHandler := handler.NewAnalyticsHandler(analyticsSvc)
+	exportHandler := handler.NewExportHandler(retrievalSvc)
+	cacheHandler := handler.NewCacheHandler(embeddingCache)
+	embeddingHandler := handler.NewEmbeddingHandler(reembedSvc)
+	exampleHandler := handler.NewIntentExampleHandler(exampleSvc)
 
 	// 6. 启动 Web Server
 	r := gin.Default()
-	router.RegisterRoutes(r, contractHandler)
+	router.RegisterRoutes(r, contractHandler, partyHandler, analyticsHandler, exportHandler, cacheHandler, embeddingHandler, exampleHandler)
+
+	srv := &http.Server{Addr: cfg.Server.Addr, Handler: r}
+	go func() {
+		log.Printf("Server running on %s", cfg.Server.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			panic(err)
+		}
+	}()
+
+	// 7. 优雅退出：先停止接收请求，再等入库流水线把在途文档处理完
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	log.Println("正在关闭服务，等待在途任务完成...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("⚠️ HTTP 服务关闭失败: %v", err)
+	}
+	if err := contractSvc.Close(shutdownCtx); err != nil {
+		log.Printf("⚠️ %v", err)
+	}
+	log.Println("✅ 服务已退出")
+}
 
-	log.Println("Server running on :8081")
-	r.Run(":8081")
+// newCacheBackend 按 cache.backend 选择缓存后端
+func newCacheBackend(c config.Cache) cache.Backend {
+	if c.Backend == "redis" {
+		log.Printf("✅ 使用 Redis 缓存: %s/%d", c.Redis.Addr, c.Redis.DB)
+		return cache.NewRedis(c.Redis.Addr, c.Redis.Password, c.Redis.DB)
+	}
+	log.Printf("✅ 使用进程内 LRU 缓存，容量 %d", c.Size)
+	return cache.NewLRU(c.Size)
+}
+
+// newEmbedders 创建 model 的入库 embedder 和检索 embedder
+// 都带分批并发、重试、单次超时、维度校验 (dim 为集合的向量维度，0 时以第一次输出为准)、NaN/Inf 清理；
+// 入库的再包一层向量缓存 (语义切分和 Milvus 索引共用)，检索的包一层查询向量缓存
+func newEmbedders(ctx context.Context, cfg *config.Config, db *gorm.DB, appCache *cache.Cache, model string, dim int) (ingest, query embedding.Embedder, embeddingCache *cache.EmbeddingCache, err error) {
+	// 超时由中间件按单次请求控制，这里不再设置整体超时
+	ollamaEmbedder, err := ollama.NewEmbedder(ctx, &ollama.EmbeddingConfig{
+		BaseURL: cfg.Ollama.URL,
+		Model:   model,
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	embedder := transform.NewResilientEmbedder(ollamaEmbedder, transform.EmbedderOptions{
+		BatchSize:   cfg.Embedder.BatchSize,
+		Concurrency: cfg.Embedder.Concurrency,
+		MaxRetries:  cfg.Embedder.MaxRetries,
+		BaseBackoff: cfg.Embedder.BaseBackoff,
+		Timeout:     cfg.Embedder.Timeout,
+		Dimension:   dim,
+	})
+	ingest = embedder
+	if embeddingCache = newEmbeddingCache(cfg.Cache.Embedding, db, embedder, model); embeddingCache != nil {
+		ingest = embeddingCache
+	}
+	return ingest, cache.NewQueryEmbedder(embedder, appCache, model), embeddingCache, nil
+}
+
+// newEmbeddingCache 按 cache.embedding.mode 选择向量缓存的持久化方式，off 时返回 nil
+func newEmbeddingCache(c config.EmbeddingCache, db *gorm.DB, embedder embedding.Embedder, model string) *cache.EmbeddingCache {
+	switch c.Mode {
+	case "off":
+		log.Println("⚠️ 入库向量缓存未启用")
+		return nil
+	case "disk":
+		store, err := cache.NewDiskStore(c.Dir)
+		if err != nil {
+			panic(fmt.Sprintf("向量缓存目录初始化失败: %v", err))
+		}
+		log.Printf("✅ 入库向量缓存: 本地目录 %s", c.Dir)
+		return cache.NewEmbeddingCache(embedder, store, model)
+	}
+	log.Println("✅ 入库向量缓存: PG embedding_cache 表")
+	return cache.NewEmbeddingCache(embedder, postgres.NewEmbeddingCacheRepo(db), model)
 }
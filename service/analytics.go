@@ -0,0 +1,245 @@
+package service
+
+import (
+	"context"
+	"eino-demo/storage/filter"
+	"eino-demo/storage/postgres"
+	"eino-demo/types"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// AnalyticsService 合同组合分析报表 (按周期、类型、状态、参与方统计)
+type AnalyticsService struct {
+	pgRepo *postgres.ContractRepo
+}
+
+func NewAnalyticsService(pgRepo *postgres.ContractRepo) *AnalyticsService {
+	return &AnalyticsService{pgRepo: pgRepo}
+}
+
+const (
+	defaultTopCounterparties = 10
+	maxTopCounterparties     = 100
+)
+
+// analyticsRange 校验后的过滤条件
+type analyticsRange struct {
+	start, end *time.Time
+	period     string
+}
+
+// parseFilter 校验日期和周期，转换为 FilterConditions (日期作用于签署日期)
+func parseFilter(f types.AnalyticsFilter) (*types.FilterConditions, *analyticsRange, error) {
+	rng := &analyticsRange{period: f.Period}
+	if rng.period == "" {
+		rng.period = types.PeriodMonth
+	}
+	if rng.period != types.PeriodMonth && rng.period != types.PeriodYear {
+		return nil, nil, fmt.Errorf("period 只能是 %s 或 %s", types.PeriodMonth, types.PeriodYear)
+	}
+
+	conditions := &types.FilterConditions{ContractType: f.ContractType}
+	if f.Start != "" || f.End != "" {
+		conditions.DateRange = &types.DateRange{Start: f.Start, End: f.End}
+	}
+	if f.Start != "" {
+		t, err := time.Parse("2006-01-02", f.Start)
+		if err != nil {
+			return nil, nil, fmt.Errorf("start 格式应为 YYYY-MM-DD: %v", err)
+		}
+		rng.start = &t
+	}
+	if f.End != "" {
+		t, err := time.Parse("2006-01-02", f.End)
+		if err != nil {
+			return nil, nil, fmt.Errorf("end 格式应为 YYYY-MM-DD: %v", err)
+		}
+		rng.end = &t
+	}
+	if rng.start != nil && rng.end != nil && rng.end.Before(*rng.start) {
+		return nil, nil, fmt.Errorf("end 不能早于 start")
+	}
+	return conditions, rng, nil
+}
+
+// Trend 按签署月/年统计合同数和金额
+func (s *AnalyticsService) Trend(ctx context.Context, f types.AnalyticsFilter) (types.PeriodStats, error) {
+	conditions, rng, err := parseFilter(f)
+	if err != nil {
+		return nil, err
+	}
+	return s.pgRepo.StatsByPeriod(ctx, conditions, rng.period)
+}
+
+// ByType 按合同类型统计
+func (s *AnalyticsService) ByType(ctx context.Context, f types.AnalyticsFilter) (types.GroupStats, error) {
+	conditions, _, err := parseFilter(f)
+	if err != nil {
+		return nil, err
+	}
+	return s.pgRepo.StatsByGroup(ctx, conditions, types.GroupByContractType, 0)
+}
+
+// ByStatus 按合同状态统计
+func (s *AnalyticsService) ByStatus(ctx context.Context, f types.AnalyticsFilter) (types.GroupStats, error) {
+	conditions, _, err := parseFilter(f)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := s.pgRepo.StatsByGroup(ctx, conditions, types.GroupByStatus, 0)
+	if err != nil {
+		return nil, err
+	}
+	for i := range stats {
+		switch stats[i].Key {
+		case strconv.Itoa(types.StatusActive):
+			stats[i].Label = "生效中"
+		case strconv.Itoa(types.StatusExpired):
+			stats[i].Label = "已过期"
+		}
+	}
+	return stats, nil
+}
+
+// TopCounterparties 按金额排名的参与方主体
+func (s *AnalyticsService) TopCounterparties(ctx context.Context, f types.AnalyticsFilter) (types.GroupStats, error) {
+	conditions, _, err := parseFilter(f)
+	if err != nil {
+		return nil, err
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = defaultTopCounterparties
+	}
+	if limit > maxTopCounterparties {
+		limit = maxTopCounterparties
+	}
+	return s.pgRepo.StatsByGroup(ctx, conditions, types.GroupByParty, limit)
+}
+
+// SignedVsExpiring 每个周期签署的合同 vs 到期的合同
+// 日期范围对签署按 sign_date 过滤，对到期按 end_date 过滤
+func (s *AnalyticsService) SignedVsExpiring(ctx context.Context, f types.AnalyticsFilter) (types.FlowStats, error) {
+	conditions, rng, err := parseFilter(f)
+	if err != nil {
+		return nil, err
+	}
+	signed, err := s.pgRepo.StatsByPeriod(ctx, conditions, rng.period)
+	if err != nil {
+		return nil, err
+	}
+
+	var endMin, endMax any
+	if rng.start != nil {
+		endMin = *rng.start
+	}
+	if rng.end != nil {
+		endMax = rng.end.Add(24*time.Hour - time.Second)
+	}
+	typeOnly := &types.FilterConditions{ContractType: conditions.ContractType}
+	expiring, err := s.pgRepo.ExpiringByPeriod(ctx, typeOnly, filter.Range(filter.FieldEndDate, endMin, endMax), rng.period)
+	if err != nil {
+		return nil, err
+	}
+
+	byPeriod := make(map[string]*types.FlowStat)
+	get := func(period string) *types.FlowStat {
+		if byPeriod[period] == nil {
+			byPeriod[period] = &types.FlowStat{Period: period}
+		}
+		return byPeriod[period]
+	}
+	for _, st := range signed {
+		fs := get(st.Period)
+		fs.Signed, fs.SignedAmount = st.Count, st.Amount
+	}
+	for _, st := range expiring {
+		fs := get(st.Period)
+		fs.Expiring, fs.ExpiringAmount = st.Count, st.Amount
+	}
+
+	flows := make(types.FlowStats, 0, len(byPeriod))
+	for _, fs := range byPeriod {
+		flows = append(flows, *fs)
+	}
+	sort.Slice(flows, func(i, j int) bool {
+		return flows[i].Period < flows[j].Period
+	})
+	return flows, nil
+}
+
+// YoY 同比：每个周期与去年同期比较
+// 为了拿到第一个周期的去年同期数据，查询时把起始日期前移一年
+func (s *AnalyticsService) YoY(ctx context.Context, f types.AnalyticsFilter) (types.YoYStats, error) {
+	conditions, rng, err := parseFilter(f)
+	if err != nil {
+		return nil, err
+	}
+	if rng.start != nil {
+		conditions.DateRange.Start = rng.start.AddDate(-1, 0, 0).Format("2006-01-02")
+	}
+	stats, err := s.pgRepo.StatsByPeriod(ctx, conditions, rng.period)
+	if err != nil {
+		return nil, err
+	}
+
+	byPeriod := make(map[string]types.PeriodStat, len(stats))
+	for _, st := range stats {
+		byPeriod[st.Period] = st
+	}
+	firstPeriod := ""
+	if rng.start != nil {
+		firstPeriod = periodKey(*rng.start, rng.period)
+	}
+
+	result := make(types.YoYStats, 0, len(stats))
+	for _, st := range stats {
+		prevKey, ok := previousYear(st.Period)
+		if !ok || st.Period < firstPeriod {
+			continue
+		}
+		prev := byPeriod[prevKey]
+		result = append(result, types.YoYStat{
+			Period:       st.Period,
+			Count:        st.Count,
+			Amount:       st.Amount,
+			PrevCount:    prev.Count,
+			PrevAmount:   prev.Amount,
+			CountGrowth:  growth(float64(st.Count), float64(prev.Count)),
+			AmountGrowth: growth(st.Amount, prev.Amount),
+		})
+	}
+	return result, nil
+}
+
+// periodKey 日期对应的周期键，与 SQL 中 to_char 的格式一致
+func periodKey(t time.Time, period string) string {
+	if period == types.PeriodYear {
+		return t.Format("2006")
+	}
+	return t.Format("2006-01")
+}
+
+// previousYear "2024" -> "2023"，"2024-03" -> "2023-03"；"未知" 等无法解析的返回 false
+func previousYear(period string) (string, bool) {
+	if len(period) < 4 {
+		return "", false
+	}
+	year, err := strconv.Atoi(period[:4])
+	if err != nil {
+		return "", false
+	}
+	return fmt.Sprintf("%04d%s", year-1, period[4:]), true
+}
+
+// growth 增长率 (cur-prev)/prev，prev 为 0 时无意义返回 nil
+func growth(cur, prev float64) *float64 {
+	if prev == 0 {
+		return nil
+	}
+	g := (cur - prev) / prev
+	return &g
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"eino-demo/logic/ingestion/clause"
+	"eino-demo/logic/retrieval"
+	"eino-demo/storage/postgres"
+	"eino-demo/types"
+	"eino-demo/vars"
+	"fmt"
+	"time"
+)
+
+// Compare 比较两份以上合同：按 doc_ids 或问题定位合同，按条款类型对齐后由 LLM 生成差异表
+func (s *RetrievalService) Compare(ctx context.Context, req types.CompareRequest) (*types.CompareResult, error) {
+	maxContracts := s.options().CompareContracts
+	var contracts []postgres.Contract
+	var intent *types.SearchIntent
+	if len(req.DocIDs) > 0 {
+		seen := make(map[string]bool)
+		for _, id := range req.DocIDs {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			contract, err := s.pgRepo.GetByDocID(ctx, id)
+			if err != nil {
+				return nil, fmt.Errorf("合同 %s 不存在", id)
+			}
+			contracts = append(contracts, *contract)
+		}
+		intent = &types.SearchIntent{Intent: vars.CP, SemanticQuery: req.Query}
+	} else {
+		if req.Query == "" {
+			return nil, fmt.Errorf("query 和 doc_ids 不能同时为空")
+		}
+		var err error
+		if intent, err = s.analyze(ctx, req.Query, nil); err != nil {
+			return nil, err
+		}
+		if contracts, err = s.locateContracts(ctx, &intent.Filters, maxContracts+1); err != nil {
+			return nil, err
+		}
+	}
+	if len(contracts) < 2 {
+		return nil, fmt.Errorf("比较至少需要两份合同，当前定位到 %d 份", len(contracts))
+	}
+	if len(contracts) > maxContracts {
+		return nil, fmt.Errorf("一次最多比较 %d 份合同，请缩小范围或直接指定 doc_ids", maxContracts)
+	}
+	return s.compare(ctx, req.Query, intent, contracts, req.ClauseTypes)
+}
+
+// compare 按条款类型对齐各合同的条款，生成差异表
+// 依据优先取 contract_clauses 中的条款原文；某合同缺少该类条款时，在该合同内做混合检索补充片段
+func (s *RetrievalService) compare(ctx context.Context, query string, intent *types.SearchIntent, contracts []postgres.Contract, clauseTypes []string) (*types.CompareResult, error) {
+	compareStart := time.Now()
+	result := &types.CompareResult{Contracts: contractHits(contracts), Rows: []types.CompareRow{}, Evidence: []types.CompareEvidence{}}
+
+	// 1. 各合同已抽取的条款，按类型分组
+	byDoc := make(map[string]map[string][]postgres.ContractClause, len(contracts))
+	present := make(map[string]bool)
+	for _, contract := range contracts {
+		clauses, err := s.pgRepo.ListClauses(ctx, contract.DocID, "")
+		if err != nil {
+			return nil, fmt.Errorf("条款查询失败: %v", err)
+		}
+		byDoc[contract.DocID] = make(map[string][]postgres.ContractClause)
+		for _, c := range clauses {
+			byDoc[contract.DocID][c.ClauseType] = append(byDoc[contract.DocID][c.ClauseType], c)
+			present[c.ClauseType] = true
+		}
+	}
+
+	// 2. 要比较的条款类型：请求指定 > 问题中提到 > 各合同出现过的全部类型
+	result.ClauseTypes = compareClauseTypes(query, intent, clauseTypes, present)
+
+	// 3. 对齐：每个合同 × 每类条款收集依据
+	for _, ct := range result.ClauseTypes {
+		for _, contract := range contracts {
+			if clauses := byDoc[contract.DocID][ct]; len(clauses) > 0 {
+				for _, c := range clauses {
+					addEvidence(result, contract.DocID, ct, "clause", c.Content)
+				}
+				continue
+			}
+			chunks, err := s.scopedChunks(ctx, contract.DocID, ct, intent)
+			if err != nil {
+				return nil, err
+			}
+			for _, c := range chunks {
+				addEvidence(result, contract.DocID, ct, "chunk", c.Content)
+			}
+		}
+	}
+	fmt.Printf(">>> [Compare] %d 份合同, 条款类型 %v, 依据 %d 条, 耗时: %v\n", len(contracts), result.ClauseTypes, len(result.Evidence), time.Since(compareStart))
+
+	// 4. LLM 生成差异表，失败时按类型列出原文
+	if len(result.ClauseTypes) > 0 {
+		llmStart := time.Now()
+		rows, err := retrieval.CompareContracts(ctx, query, result, s.models.Answer)
+		if err != nil {
+			fmt.Printf(">>> [Compare] 生成差异表失败，仅列出原文: %v\n", err)
+			rows = retrieval.FallbackCompareRows(result)
+		}
+		result.Rows = rows
+		fmt.Printf(">>> [性能] 差异表生成耗时: %v\n", time.Since(llmStart))
+	}
+	result.Table = retrieval.FormatCompare(result)
+	return result, nil
+}
+
+// compareClauseTypes 确定要比较的条款类型，present 为各合同出现过的类型
+func compareClauseTypes(query string, intent *types.SearchIntent, requested []string, present map[string]bool) []string {
+	var candidates []string
+	for _, t := range requested {
+		if ct := clause.Normalize(t); ct != "" {
+			candidates = append(candidates, ct)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = clause.Mentioned(query)
+		if intent != nil && intent.Filters.ClauseType != "" {
+			candidates = append(candidates, intent.Filters.ClauseType)
+		}
+	}
+	if len(candidates) == 0 {
+		for _, ct := range clause.Types() {
+			if ct != clause.TypeOther && present[ct] {
+				candidates = append(candidates, ct)
+			}
+		}
+	}
+
+	seen := make(map[string]bool, len(candidates))
+	unique := make([]string, 0, len(candidates))
+	for _, ct := range candidates {
+		if !seen[ct] {
+			seen[ct] = true
+			unique = append(unique, ct)
+		}
+	}
+	return unique
+}
+
+// scopedChunks 在单个合同内检索某类条款的片段 (ES 按 es.Filter.DocIDs、Milvus 按 doc_id 过滤)
+func (s *RetrievalService) scopedChunks(ctx context.Context, docID, clauseType string, intent *types.SearchIntent) ([]types.ChunkHit, error) {
+	scoped := &types.SearchIntent{
+		Intent:        vars.HY,
+		SemanticQuery: clause.Label(clauseType),
+		Keywords:      []string{clause.Label(clauseType)},
+		DocIDs:        []string{docID},
+	}
+	if intent != nil && intent.SemanticQuery != "" {
+		scoped.SemanticQuery += " " + intent.SemanticQuery
+	}
+	req := types.SearchRequest{PageSize: s.options().CompareChunkTopK}
+	req.Normalize()
+	resp := &types.SearchResponse{}
+	if _, err := s.searchHybrid(ctx, req, nil, scoped, resp); err != nil {
+		return nil, err
+	}
+	return resp.Chunks, nil
+}
+
+// addEvidence 追加一条依据，编号 E1、E2...
+func addEvidence(result *types.CompareResult, docID, clauseType, source, content string) {
+	result.Evidence = append(result.Evidence, types.CompareEvidence{
+		Ref:        fmt.Sprintf("E%d", len(result.Evidence)+1),
+		DocID:      docID,
+		ClauseType: clauseType,
+		Source:     source,
+		Content:    content,
+	})
+}
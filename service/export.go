@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"eino-demo/storage/postgres"
+	"eino-demo/types"
+	"fmt"
+)
+
+// exportBatchSize 导出合同列表时每批从 PG 拉取的条数
+const exportBatchSize = 500
+
+// ExportContracts 导出问题命中的全部合同，按批 keyset 翻页，逐条交给 fn 写出
+func (s *RetrievalService) ExportContracts(ctx context.Context, req types.ExportRequest, fn func(c *postgres.Contract) error) error {
+	intent, err := s.analyze(ctx, req.Query, nil)
+	if err != nil {
+		return err
+	}
+	sr := types.SearchRequest{SortBy: req.SortBy, Order: req.Order}
+	sr.Normalize()
+
+	esDocIDs, ok, err := s.partyDocIDs(ctx, &intent.Filters)
+	if err != nil || !ok {
+		return err
+	}
+
+	page := postgres.ContractPage{SortBy: sr.SortBy, Desc: sr.Order == "desc", Limit: exportBatchSize}
+	total := 0
+	for {
+		contracts, err := s.pgRepo.ListContracts(ctx, &intent.Filters, esDocIDs, page)
+		if err != nil {
+			return fmt.Errorf("PG查询失败: %v", err)
+		}
+		for i := range contracts {
+			if err := fn(&contracts[i]); err != nil {
+				return err
+			}
+		}
+		total += len(contracts)
+		if len(contracts) < exportBatchSize {
+			fmt.Printf(">>> [Export] 合同列表导出 %d 条\n", total)
+			return nil
+		}
+		last := contracts[len(contracts)-1]
+		if page.After, err = postgres.ParseSortValue(sr.SortBy, last.SortValue(sr.SortBy)); err != nil {
+			return err
+		}
+		page.AfterDocID = last.DocID
+	}
+}
+
+// ExportAggregate 导出问题对应的统计表，问题不是统计类时报错
+func (s *RetrievalService) ExportAggregate(ctx context.Context, req types.ExportRequest) (*types.AggregateResult, error) {
+	intent, err := s.analyze(ctx, req.Query, nil)
+	if err != nil {
+		return nil, err
+	}
+	if intent.Aggregation == nil {
+		return nil, fmt.Errorf("问题不是统计类问题，无法导出统计表")
+	}
+	return s.pgRepo.Aggregate(ctx, &intent.Filters, *intent.Aggregation)
+}
+
+// ExportEvidence 导出混合检索命中的片段 (原文 + 融合分数)，逐页检索直到取完或达到条数上限
+func (s *RetrievalService) ExportEvidence(ctx context.Context, req types.ExportRequest, fn func(rank int, hit types.ChunkHit) error) error {
+	sr := types.SearchRequest{Query: req.Query, SortBy: req.SortBy, Order: req.Order, PageSize: types.MaxPageSize}
+	sr.Normalize()
+	// 调用 LLM 之前先校验排序
+	if err := checkHybridSort(sr.SortBy); err != nil {
+		return err
+	}
+	intent, err := s.analyze(ctx, req.Query, nil)
+	if err != nil {
+		return err
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = types.DefaultExportEvidence
+	}
+	if limit > types.MaxExportEvidence {
+		limit = types.MaxExportEvidence
+	}
+	rank := 0
+	var cursor *types.Cursor
+	for {
+		resp := &types.SearchResponse{}
+		cursor, err = s.searchHybrid(ctx, sr, cursor, intent, resp)
+		if err != nil {
+			return err
+		}
+		for _, hit := range resp.Chunks {
+			rank++
+			if err := fn(rank, hit); err != nil {
+				return err
+			}
+			if rank >= limit {
+				return nil
+			}
+		}
+		if cursor == nil {
+			return nil
+		}
+	}
+}
@@ -2,22 +2,26 @@ package service
 
 import (
 	"context"
+	"eino-demo/config"
+	"eino-demo/logic/ingestion/clause"
 	"eino-demo/logic/ingestion/extract"
+	"eino-demo/logic/party"
+	"eino-demo/logic/prompt"
+	"eino-demo/storage/cache"
 	"eino-demo/storage/es"
 	"eino-demo/types"
 	"fmt"
 	"mime/multipart"
 	"regexp"
-	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/cloudwego/eino-ext/components/document/loader/file"
 	"github.com/cloudwego/eino-ext/components/document/parser/pdf"
 	"github.com/cloudwego/eino-ext/components/document/transformer/splitter/semantic"
 	"github.com/cloudwego/eino/components/document/parser"
 	"github.com/cloudwego/eino/components/embedding"
-	"github.com/cloudwego/eino/components/indexer"
 	"github.com/cloudwego/eino/schema"
 	"github.com/google/uuid"
 
@@ -48,244 +52,402 @@ func cleanText(text string) string {
 
 type ContractService struct {
 	pgRepo    *postgres.ContractRepo
+	partySvc  *PartyService
 	chatModel model.ToolCallingChatModel
+	prompts   *prompt.Registry
 	embedder  embedding.Embedder
-	indexer   indexer.Indexer
+	index     *VectorIndex
 	esIndexer *es.ESIndexer
+	cache     *cache.Cache
+	splitter  config.Splitter
+	pipeline  *ingestPipeline
 }
 
-// 构造函数：依赖注入
-func NewContractService(pgRepo *postgres.ContractRepo, chatModel model.ToolCallingChatModel, embedder embedding.Embedder, idx indexer.Indexer, esIndexer *es.ESIndexer) *ContractService {
-	return &ContractService{
+// 构造函数：依赖注入，同时启动入库流水线
+func NewContractService(pgRepo *postgres.ContractRepo, partySvc *PartyService, chatModel model.ToolCallingChatModel, prompts *prompt.Registry, embedder embedding.Embedder, index *VectorIndex, esIndexer *es.ESIndexer, c *cache.Cache, cfg *config.Config) *ContractService {
+	s := &ContractService{
 		pgRepo:    pgRepo,
+		partySvc:  partySvc,
 		chatModel: chatModel,
+		prompts:   prompts,
 		embedder:  embedder,
-		indexer:   idx,
+		index:     index,
 		esIndexer: esIndexer,
+		cache:     c,
+		splitter:  cfg.Ingest.Splitter,
 	}
+	s.pipeline = newIngestPipeline(s, cfg.Ingest)
+	return s
 }
 
-func (s *ContractService) UploadAndProcess(ctx context.Context, fileHeader *multipart.FileHeader) ([]string, error) {
+// UploadAndProcess 把一批文件提交到入库流水线，等待全部处理完成后返回每个文件的结果
+// 已提交的文件即使请求中断也会处理完 (multipart 临时文件在 handler 返回后才删除，因此这里等到全部结束)
+func (s *ContractService) UploadAndProcess(ctx context.Context, files []*multipart.FileHeader) ([]types.IngestResult, error) {
 	startTime := time.Now()
-	fmt.Println(">>> [DEBUG] 4. 进入 Service")
+	trackers := make([]*fileTracker, 0, len(files))
+	var submitErr error
+	for _, fh := range files {
+		tracker := newFileTracker(fh.Filename)
+		if submitErr = s.pipeline.submit(ctx, fh, tracker); submitErr != nil {
+			break
+		}
+		trackers = append(trackers, tracker)
+	}
+
+	results := make([]types.IngestResult, len(trackers))
+	indexed := 0
+	for i, t := range trackers {
+		<-t.done
+		results[i] = t.result
+		indexed += len(t.result.DocIDs)
+	}
+	fmt.Printf("\n>>> [性能总览] 处理完成，%d 个文件共入库 %d 个文档，总耗时: %v\n", len(trackers), indexed, time.Since(startTime))
+	if submitErr != nil {
+		return results, fmt.Errorf("提交入库任务失败: %v", submitErr)
+	}
+	return results, nil
+}
+
+// PipelineStats 入库流水线各阶段的运行指标
+func (s *ContractService) PipelineStats() types.PipelineStats {
+	return s.pipeline.stats()
+}
+
+// Close 停止接收新文件并等待在途的入库任务完成
+func (s *ContractService) Close(ctx context.Context) error {
+	return s.pipeline.close(ctx)
+}
+
+// parseFile 解析 PDF，文件名写入 metadata (查重和存库要用)
+func (s *ContractService) parseFile(ctx context.Context, fileHeader *multipart.FileHeader) ([]*schema.Document, error) {
+	parseStart := time.Now()
 	srcFile, err := fileHeader.Open()
-	defer srcFile.Close()
 	if err != nil {
 		return nil, err
 	}
+	defer srcFile.Close()
 	// pdf解析器
 	p, err := pdf.NewPDFParser(ctx, &pdf.Config{ToPages: false})
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	docs, err := p.Parse(ctx, srcFile, parser.WithURI(fileHeader.Filename))
 	if err != nil {
-
 		return nil, fmt.Errorf("parse pdf failed: %v", err)
 	}
-	fmt.Printf(">>> [性能] PDF 解析耗时: %v\n", time.Since(startTime))
+	fmt.Printf(">>> [性能] PDF 解析耗时: %v (%s)\n", time.Since(parseStart), fileHeader.Filename)
 	for _, doc := range docs {
 		if doc.MetaData == nil {
 			doc.MetaData = make(map[string]any)
 		}
-		// 手动把文件名塞进去，因为后面查重和存库要用
 		doc.MetaData[file.MetaKeyFileName] = fileHeader.Filename
 	}
+	return docs, nil
+}
 
-	var docsID []string
-	for _, doc := range docs {
-		docStartTime := time.Now()
-		fileName := doc.MetaData[file.MetaKeyFileName]
-		//查重
-		one, err := s.pgRepo.GetByFileName(ctx, fileName.(string))
-		if one != nil {
-			fmt.Printf(">>> [DEBUG] 跳过: 文件已存在数据库中 (%s)\n", fileName)
-			continue
-		}
+// fileExists 同名文件是否已入库
+func (s *ContractService) fileExists(ctx context.Context, fileName string) bool {
+	one, _ := s.pgRepo.GetByFileName(ctx, fileName)
+	return one != nil
+}
 
-		// 结构化提取 存储postgresql
-		llmStart := time.Now()
-		entity, err := extract.ExtractAndClean(ctx, s.chatModel, doc)
-		if err != nil {
-			fmt.Println("结构化提取失败", err)
-			continue
-		}
-		fmt.Printf(">>> [性能] LLM 结构化提取耗时: %v\n", time.Since(llmStart))
+// extractDoc LLM 结构化提取，生成待写入 PG 的合同记录 (DocID 在 persist 阶段分配)
+func (s *ContractService) extractDoc(job *docJob) error {
+	llmStart := time.Now()
+	// 按文件名分流，同一文件重新入库时用同一个提示词版本
+	p, err := s.prompts.Pick(prompt.Extract, job.fileName)
+	if err != nil {
+		return err
+	}
+	entity, err := extract.ExtractAndClean(job.ctx, s.chatModel, p, job.doc)
+	if err != nil {
+		return err
+	}
+	fmt.Printf(">>> [性能] LLM 结构化提取耗时: %v (%s, %s)\n", time.Since(llmStart), job.fileName, p.ID())
 
-		// 1. 处理 SignDate (string -> time.Time)
-		var signDate *time.Time
-		if entity.SignDate != nil && *entity.SignDate != "" {
-			t, _ := time.Parse("2006-01-02", *entity.SignDate)
-			signDate = &t
-		}
+	// 1. 处理 SignDate (string -> time.Time)
+	var signDate *time.Time
+	if entity.SignDate != nil && *entity.SignDate != "" {
+		t, _ := time.Parse("2006-01-02", *entity.SignDate)
+		signDate = &t
+	}
 
-		// 2. 处理 EndDate (string -> time.Time)
-		var endDate *time.Time
-		if entity.EndDate != nil && *entity.EndDate != "" {
-			t, _ := time.Parse("2006-01-02", *entity.EndDate)
-			endDate = &t
-		}
+	// 2. 处理 EndDate (string -> time.Time)
+	var endDate *time.Time
+	if entity.EndDate != nil && *entity.EndDate != "" {
+		t, _ := time.Parse("2006-01-02", *entity.EndDate)
+		endDate = &t
+	}
 
-		// 3. 计算 Status (0 或 1) 默认1生效
-		status := types.StatusActive
-		// 如果有截止日期，且截止日期早于现在，则为已过期 (0)
-		if endDate != nil && endDate.Before(time.Now()) {
-			status = types.StatusExpired
+	// 3. 计算 Status (0 或 1) 默认1生效
+	status := types.StatusActive
+	// 如果有截止日期，且截止日期早于现在，则为已过期 (0)
+	if endDate != nil && endDate.Before(time.Now()) {
+		status = types.StatusExpired
+	}
+
+	var totalAmount float64
+	if entity.TotalAmount != nil {
+		totalAmount = extract.ParseAmount(entity.TotalAmount)
+	}
+	fmt.Printf(">>>>>>>>>>>>>>>>>>>>>>> 清洗金额: %v\n", totalAmount)
+
+	// 类型为空时从文件名兜底，保证 PG、ES、Milvus 上的 contract_type 一致 (三者都只按这个字段过滤)
+	if strings.TrimSpace(entity.ContractType) == "" {
+		if t := extract.ContractTypeFromFileName(job.fileName); t != "" {
+			fmt.Printf(">>> [Extract] %s 没有提取出合同类型，按文件名取: %s\n", job.fileName, t)
+			entity.ContractType = t
 		}
+	}
+
+	job.entity = entity
+	job.contract = &postgres.Contract{
+		FileName:       job.fileName,
+		PartyA:         entity.PartyA,
+		PartyB:         entity.PartyB,
+		SignDate:       signDate,
+		EndDate:        endDate,
+		ContractStatus: status,
+		ContractType:   entity.ContractType,
+		TotalAmount:    totalAmount,
+		Summary:        entity.Summary,
+		PromptVersion:  p.ID(),
+	}
+	return nil
+}
+
+// splitDoc 条款切分 + 语义切分，chunk 按原文偏移标注所属条款类型
+func (s *ContractService) splitDoc(job *docJob) error {
+	doc := job.doc
+	job.clauses = clause.Segment(doc.Content)
+
+	// 切分
+	//splitter, _ := recursive.NewSplitter(ctx, &recursive.Config{
+	//	ChunkSize:   200,
+	//	OverlapSize: 40,
+	//	Separators:  []string{"\n\n", "\n", "。", "！", ".", "?", "!"},
+	//})
+	splitter, err := semantic.NewSplitter(job.ctx, &semantic.Config{
+		Embedding:    s.embedder,
+		BufferSize:   s.splitter.BufferSize,
+		MinChunkSize: s.splitter.MinChunkSize,
+		Separators:   []string{"\n\n", "\n", "。", "！", "？", "，"},
+		LenFunc: func(s string) int {
+			// 使用 unicode 字符数而不是字节数
+			return len([]rune(s))
+		},
+		Percentile: s.splitter.Percentile,
+		//IDGenerator:  nil,
+	})
+	if err != nil {
+		return fmt.Errorf("创建切分器失败: %v", err)
+	}
 
-		var totalAmount float64
-
-		if entity.TotalAmount != nil {
-			fmt.Printf(">>>>>>>>>>>>>>>content: %v\n", doc.Content)
-			fmt.Printf(">>>>>>>>>>>>>>>>金额：%v\n", entity.TotalAmount)
-			switch v := entity.TotalAmount.(type) {
-			case float64:
-				// 情况1: LLM 返回了数字 (例如 0, 10000)
-				totalAmount = v
-			case string:
-				// 情况2: LLM 返回了字符串 (例如 "1000", "1,000", "100万")
-				if v != "" {
-					cleanAmount := strings.ReplaceAll(v, ",", "")
-					// 简单处理 "万" (根据 Prompt 情况，如果 Prompt 没强制纯数字的话)
-					multiplier := 1.0
-					if strings.Contains(cleanAmount, "万") {
-						cleanAmount = strings.ReplaceAll(cleanAmount, "万", "")
-						multiplier = 10000.0
-					}
-
-					if val, err := strconv.ParseFloat(cleanAmount, 64); err == nil {
-						totalAmount = val * multiplier
-					}
-				}
-			}
+	splitStart := time.Now()
+	chunks, err := splitter.Transform(job.ctx, []*schema.Document{doc})
+	if err != nil {
+		return fmt.Errorf("切分失败: %v", err)
+	}
+	fmt.Printf(">>> [性能] 语义切分耗时: %v, 切分出 %d 个 chunk (%s)\n", time.Since(splitStart), len(chunks), job.fileName)
+
+	var cleanChunks []*schema.Document
+	for _, chunk := range chunks {
+		// 清洗前在原文中定位 chunk，按偏移标注所属条款类型
+		if chunk.MetaData == nil {
+			chunk.MetaData = make(map[string]any)
 		}
-		fmt.Printf(">>>>>>>>>>>>>>>>>>>>>>> 清洗金额: %v\n", totalAmount)
+		if pos := strings.Index(doc.Content, chunk.Content); pos >= 0 {
+			start := utf8.RuneCountInString(doc.Content[:pos])
+			chunk.MetaData["clause_type"] = clause.TypeForSpan(job.clauses, start, start+utf8.RuneCountInString(chunk.Content))
+		}
+		chunk.Content = cleanText(chunk.Content)
+		if len(strings.TrimSpace(chunk.Content)) != 0 {
+			cleanChunks = append(cleanChunks, chunk)
+		}
+	}
+	if len(cleanChunks) == 0 {
+		return fmt.Errorf("没有有效的切片")
+	}
+	job.chunks = cleanChunks
+	return nil
+}
 
-		// 生成全局唯一的 DocID
-		docID := uuid.New().String()
-		now := time.Now()
-		err = s.pgRepo.Create(ctx, &postgres.Contract{
-			DocID:          docID,
-			FileName:       doc.MetaData[file.MetaKeyFileName].(string),
-			PartyA:         entity.PartyA,
-			PartyB:         entity.PartyB,
-			SignDate:       signDate,
-			EndDate:        endDate,
-			ContractStatus: status,
-			ContractType:   entity.ContractType,
-			TotalAmount:    totalAmount,
-			Summary:        entity.Summary,
-			CreatedAt:      now,
-			UpdatedAt:      now,
+// persistDoc 写 PG (合同、条款、参与方)，并把合同字段写入每个 chunk 的 metadata
+// 条款或参与方写入失败时回滚合同记录
+func (s *ContractService) persistDoc(job *docJob) error {
+	ctx := job.ctx
+	// 生成全局唯一的 DocID
+	docID := uuid.New().String()
+	now := time.Now()
+	c := job.contract
+	c.DocID, c.CreatedAt, c.UpdatedAt = docID, now, now
+	if err := s.pgRepo.Create(ctx, c); err != nil {
+		return fmt.Errorf("postgresql存储失败: %v", err)
+	}
+
+	// 条款切分结果，存储 contract_clauses
+	clauseRows := make([]*postgres.ContractClause, 0, len(job.clauses))
+	for _, cl := range job.clauses {
+		clauseRows = append(clauseRows, &postgres.ContractClause{
+			ID:          uuid.New().String(),
+			DocID:       docID,
+			Seq:         cl.Seq,
+			ClauseType:  cl.Type,
+			Title:       cl.Title,
+			Content:     cl.Content,
+			StartOffset: cl.Start,
+			EndOffset:   cl.End,
+			CreatedAt:   now,
 		})
+	}
+	if err := s.pgRepo.CreateClauses(ctx, clauseRows); err != nil {
+		_ = s.pgRepo.Delete(ctx, docID)
+		return fmt.Errorf("条款存储失败，已回滚PG记录: %v", err)
+	}
+	fmt.Printf(">>> [DEBUG] 切分出 %d 个条款\n", len(job.clauses))
+
+	// 参与方 (含丙方、担保人、签署代表)，存储 contract_parties
+	partyRows := buildPartyRows(docID, job.entity, now)
+	var partyIDs []string
+	for _, p := range partyRows {
+		// 归一到主体登记簿，失败不影响入库，后续可通过 Backfill 补齐
+		partyID, err := s.partySvc.Resolve(ctx, p.NormalizedName, p.EntityType)
 		if err != nil {
-			fmt.Println("postgresql存储失败", err)
+			fmt.Printf(">>> [Party] 主体解析失败 %s: %v\n", p.NormalizedName, err)
 			continue
 		}
-		fmt.Println(">>> [DEBUG] 8. 存入数据库成功:", fileName)
-
-		// 切分
-		//splitter, _ := recursive.NewSplitter(ctx, &recursive.Config{
-		//	ChunkSize:   200,
-		//	OverlapSize: 40,
-		//	Separators:  []string{"\n\n", "\n", "。", "！", ".", "?", "!"},
-		//})
-		splitter, err := semantic.NewSplitter(ctx, &semantic.Config{
-			Embedding:    s.embedder,
-			BufferSize:   5,  // ⬇️ 从 10 降到 5（减少 embedding 计算）
-			MinChunkSize: 200,
-			Separators:   []string{"\n\n", "\n", "。", "！", "？", "，"},
-			LenFunc: func(s string) int {
-				// 使用 unicode 字符数而不是字节数
-				return len([]rune(s))
-			},
-			Percentile: 0.85,  // ⬇️ 从 0.9 降到 0.85（更激进合并）
-			//IDGenerator:  nil,
+		p.PartyID = partyID
+		partyIDs = append(partyIDs, partyID)
+	}
+	if err := s.pgRepo.CreateParties(ctx, partyRows); err != nil {
+		_ = s.pgRepo.Delete(ctx, docID)
+		return fmt.Errorf("参与方存储失败，已回滚PG记录: %v", err)
+	}
+	partyNames := make([]string, 0, len(partyRows))
+	for _, p := range partyRows {
+		partyNames = append(partyNames, p.NormalizedName)
+	}
+	fmt.Println(">>> [DEBUG] 8. 存入数据库成功:", job.fileName)
+
+	// 切片原文存一份到 contract_chunks，换向量模型时从这里重新向量化
+	chunkRows := make([]*postgres.ContractChunk, 0, len(job.chunks))
+	for i, chunk := range job.chunks {
+		chunk.ID = uuid.New().String()
+		setChunkMetadata(chunk, c, partyNames, partyIDs)
+		clauseType, _ := chunk.MetaData["clause_type"].(string)
+		chunkRows = append(chunkRows, &postgres.ContractChunk{
+			ID:         chunk.ID,
+			DocID:      docID,
+			Seq:        i,
+			ClauseType: clauseType,
+			Content:    chunk.Content,
+			CreatedAt:  now,
 		})
+	}
+	if err := s.pgRepo.CreateChunks(ctx, chunkRows, false); err != nil {
+		_ = s.pgRepo.Delete(ctx, docID)
+		return fmt.Errorf("切片存储失败，已回滚PG记录: %v", err)
+	}
+	return nil
+}
 
-		splitStart := time.Now()
-		chunks, err := splitter.Transform(ctx, []*schema.Document{doc})
-		if err != nil {
-			_ = s.pgRepo.Delete(ctx, docID)
-			fmt.Printf("切分失败，已回滚PG记录：%v\n", err)
-			continue
-		}
-		fmt.Printf(">>> [性能] 语义切分耗时: %v, 切分出 %d 个 chunk\n", time.Since(splitStart), len(chunks))
-		//fmt.Printf(">>>>>>>>>>>>>>>>doc: %v", doc)
-
-		var cleanChunks []*schema.Document
-		for _, chunk := range chunks {
-			chunk.Content = cleanText(chunk.Content)
-			if len(strings.TrimSpace(chunk.Content)) != 0 {
-				cleanChunks = append(cleanChunks, chunk)
-			}
-		}
-		if len(cleanChunks) == 0 {
-			fmt.Printf(">>>>>>>>>>>>>空chunks原文档: %v\n", doc)
-			continue
-		}
+// setChunkMetadata 把合同字段写入 chunk 的 metadata (ES 和 Milvus 的标量字段都从这里取)
+func setChunkMetadata(chunk *schema.Document, c *postgres.Contract, partyNames, partyIDs []string) {
+	if chunk.MetaData == nil {
+		chunk.MetaData = make(map[string]any)
+	}
+	chunk.MetaData["doc_id"] = c.DocID
+	chunk.MetaData["party_a"] = c.PartyA
+	chunk.MetaData["party_b"] = c.PartyB
+	chunk.MetaData["parties"] = partyNames
+	chunk.MetaData["party_ids"] = partyIDs
+	chunk.MetaData["amount"] = c.TotalAmount
+	chunk.MetaData["contract_type"] = c.ContractType
+	chunk.MetaData["contract_status"] = c.ContractStatus
+	if c.SignDate != nil {
+		chunk.MetaData["sign_date"] = *c.SignDate
+	}
+	if c.EndDate != nil {
+		chunk.MetaData["end_date"] = *c.EndDate
+	}
+}
 
-		chunks = cleanChunks
-		for _, chunk := range chunks {
-			if len(strings.TrimSpace(chunk.Content)) == 0 {
-				fmt.Println(">>>>>>>>>>>>>>>>>>>>>>>>空chunk")
-				continue
-			}
-			chunk.ID = uuid.New().String()
-
-			if chunk.MetaData == nil {
-				chunk.MetaData = make(map[string]any)
-			}
-			chunk.MetaData["doc_id"] = docID
-			chunk.MetaData["party_a"] = entity.PartyA
-			chunk.MetaData["party_b"] = entity.PartyB
-			chunk.MetaData["amount"] = totalAmount
-			chunk.MetaData["contract_type"] = entity.ContractType
-			chunk.MetaData["contract_status"] = status
-			// ✅ 使用解析后的 *time.Time 变量，而不是 *string
-			if signDate != nil {
-				chunk.MetaData["sign_date"] = *signDate
-			} else {
-				fmt.Println("sign_date = nil")
-			}
-			if endDate != nil {
-				chunk.MetaData["end_date"] = *endDate
-			} else {
-				fmt.Println("end_date = nil")
-			}
-		}
+// storeChunks 多个文档的切片一次写入 ES 和 Milvus；Milvus 失败时删除本批已写入的 ES 记录
+func (s *ContractService) storeChunks(batch []*docJob) error {
+	ctx := context.Background()
+	docs := make([]es.IndexDoc, 0, len(batch))
+	var chunks []*schema.Document
+	for _, job := range batch {
+		docs = append(docs, es.IndexDoc{DocID: job.contract.DocID, Chunks: job.chunks, Keywords: job.entity.Keywords})
+		chunks = append(chunks, job.chunks...)
+	}
 
-		// es存储
-		esStart := time.Now()
-		err = s.esIndexer.Store(ctx, docID, chunks, entity.Keywords)
-		if err != nil {
-			_ = s.pgRepo.Delete(ctx, docID)
-			fmt.Printf("es存储失败，已回滚PG记录：%v\n", err)
-			return nil, err
+	// es存储
+	esStart := time.Now()
+	if err := s.esIndexer.StoreDocs(ctx, docs); err != nil {
+		s.deleteESDocs(ctx, docs)
+		return fmt.Errorf("es存储失败: %v", err)
+	}
+	fmt.Printf(">>> [性能] ES 存储耗时: %v (%d 个文档，%d 个切片)\n", time.Since(esStart), len(docs), len(chunks))
+
+	// 向量化存储
+	milvusStart := time.Now()
+	if err := s.index.Store(ctx, chunks); err != nil {
+		fmt.Printf("❌ Milvus 存储失败! 错误详情: %v\n", err)
+		s.deleteESDocs(ctx, docs)
+		return fmt.Errorf("Milvus 存储失败: %v", err)
+	}
+	fmt.Printf(">>> [性能] Milvus 存储耗时: %v (%d 个切片)\n", time.Since(milvusStart), len(chunks))
+	return nil
+}
+
+// rollbackDoc 索引写入失败时删除 persist 阶段写入的 PG 记录
+func (s *ContractService) rollbackDoc(job *docJob) {
+	_ = s.pgRepo.Delete(context.Background(), job.contract.DocID)
+}
+
+// invalidateResults 有新合同入库，检索结果缓存作废
+func (s *ContractService) invalidateResults() {
+	s.cache.Invalidate(context.Background())
+}
+
+func (s *ContractService) deleteESDocs(ctx context.Context, docs []es.IndexDoc) {
+	for _, d := range docs {
+		if err := s.esIndexer.DeleteByDocID(ctx, d.DocID); err != nil {
+			fmt.Printf(">>> [ES] 回滚 %s 失败: %v\n", d.DocID, err)
 		}
-		fmt.Printf(">>> [性能] ES 存储耗时: %v\n", time.Since(esStart))
+	}
+}
 
-		// 向量化存储
-		milvusStart := time.Now()
-		_, err = s.indexer.Store(ctx, chunks)
-		if err != nil {
-			fmt.Printf("❌ Milvus 存储失败! 错误详情: %v\n", err)
-			fmt.Printf("存储失败原文档：%v\n", doc)
-			fmt.Println("存储失败切片")
-			for i, chunk := range chunks {
-				fmt.Printf("编号%d content: %v\n", i, chunk.Content)
-				fmt.Printf("metadata: %v\n", chunk.MetaData)
-			}
-			_ = s.pgRepo.Delete(ctx, docID)
-			_ = s.esIndexer.DeleteByDocID(ctx, docID)
-			fmt.Printf("Milvus 存储失败，已回滚PG记录和ES记录：%v\n", err)
+// buildPartyRows 合并 LLM 提取的参与方和 party_a/party_b，按 (规范名, 角色) 去重
+func buildPartyRows(docID string, entity *types.ContractRawData, now time.Time) []*postgres.ContractParty {
+	raws := append([]types.PartyRaw{
+		{Name: entity.PartyA, Role: party.RolePartyA},
+		{Name: entity.PartyB, Role: party.RolePartyB},
+	}, entity.Parties...)
+
+	seen := make(map[string]bool)
+	var rows []*postgres.ContractParty
+	for _, raw := range raws {
+		name := party.NormalizeName(raw.Name)
+		if name == "" {
 			continue
 		}
-		fmt.Printf(">>> [性能] Milvus 存储耗时: %v\n", time.Since(milvusStart))
-		fmt.Printf(">>> [性能] 单个文档总耗时: %v\n\n", time.Since(docStartTime))
-		docsID = append(docsID, docID)
+		role := party.NormalizeRole(raw.Role)
+		key := name + "|" + role
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		rows = append(rows, &postgres.ContractParty{
+			ID:             uuid.New().String(),
+			DocID:          docID,
+			Role:           role,
+			Name:           strings.TrimSpace(raw.Name),
+			NormalizedName: name,
+			EntityType:     party.NormalizeEntityType(raw.EntityType, name),
+			CreatedAt:      now,
+		})
 	}
-
-	fmt.Printf("\n>>> [性能总览] 处理完成，共 %d 个文档，总耗时: %v\n", len(docsID), time.Since(startTime))
-	return docsID, err
+	return rows
 }
@@ -0,0 +1,310 @@
+package service
+
+import (
+	"context"
+	"eino-demo/config"
+	"eino-demo/logic/retrieval"
+	"eino-demo/storage/cache"
+	"eino-demo/storage/postgres"
+	"eino-demo/types"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IntentExampleService 意图解析的动态 few-shot 示例
+// 示例持久化在 intent_examples 表，内存中按查询向量相似度选出最相近的 k 个放进提示词
+// 示例查询用当前向量集合的检索 embedder 向量化，换向量模型后自动重新计算
+type IntentExampleService struct {
+	repo       *postgres.IntentExampleRepo
+	searchLogs *postgres.SearchLogRepo
+	index      *VectorIndex
+	cfg        *config.Store // few_shot_k、few_shot_min_score 支持热更新
+
+	mu       sync.Mutex
+	examples []*intentExample
+	model    string // 示例向量所用的模型
+	revision int64  // 示例最近一次变更的时间，写进意图缓存键，示例变更后旧的解析结果不再命中
+}
+
+// intentExample 内存中的示例，vector 为空表示还没向量化
+type intentExample struct {
+	id      string
+	example retrieval.Example
+	vector  []float64
+}
+
+func NewIntentExampleService(repo *postgres.IntentExampleRepo, searchLogs *postgres.SearchLogRepo, index *VectorIndex, cfg *config.Store) *IntentExampleService {
+	return &IntentExampleService{repo: repo, searchLogs: searchLogs, index: index, cfg: cfg}
+}
+
+// Load 启动时加载全部示例，向量在第一次选择时计算
+func (s *IntentExampleService) Load(ctx context.Context) error {
+	rows, err := s.repo.ListAll(ctx)
+	if err != nil {
+		return err
+	}
+	examples := make([]*intentExample, 0, len(rows))
+	var revision int64
+	for _, row := range rows {
+		e, err := newIntentExample(row)
+		if err != nil {
+			fmt.Printf(">>> [FewShot] 示例 %s 格式错误，跳过: %v\n", row.ID, err)
+			continue
+		}
+		examples = append(examples, e)
+		revision = max(revision, row.UpdatedAt.UnixNano())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.examples, s.revision = examples, revision
+	fmt.Printf(">>> [FewShot] 已加载 %d 条意图示例\n", len(examples))
+	return nil
+}
+
+func newIntentExample(row postgres.IntentExample) (*intentExample, error) {
+	var intent types.SearchIntent
+	if err := json.Unmarshal([]byte(row.Intent), &intent); err != nil {
+		return nil, err
+	}
+	example, err := retrieval.NewExample(row.Query, &intent)
+	if err != nil {
+		return nil, err
+	}
+	return &intentExample{id: row.ID, example: example}, nil
+}
+
+// Revision 示例版本，示例增删改后变化
+func (s *IntentExampleService) Revision() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.revision
+}
+
+// Select 与查询最相近的示例；出错时返回空 (提示词退回固定示例)，不影响检索
+func (s *IntentExampleService) Select(ctx context.Context, query string) []retrieval.Example {
+	opts := s.cfg.Get().Retrieval
+	if opts.FewShotK <= 0 {
+		return nil
+	}
+	candidates, err := s.vectors(ctx)
+	if err != nil {
+		fmt.Printf(">>> [FewShot] %v，使用固定示例\n", err)
+		return nil
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	vectors, err := s.index.QueryEmbedder().EmbedStrings(ctx, []string{query})
+	if err != nil || len(vectors) != 1 {
+		fmt.Printf(">>> [FewShot] 查询向量化失败，使用固定示例: %v\n", err)
+		return nil
+	}
+	selected := retrieval.SelectExamples(vectors[0], candidates, opts.FewShotK, opts.FewShotMinScore)
+	fmt.Printf(">>> [FewShot] %d 条示例中选出 %d 条相似示例\n", len(candidates), len(selected))
+	return selected
+}
+
+// vectors 带向量的全部示例，缺向量或向量模型已切换时先补算
+// 向量化是带重试的网络调用，不持锁执行，避免阻塞 Revision (每次检索都会调用)
+func (s *IntentExampleService) vectors(ctx context.Context) ([]retrieval.ExampleVector, error) {
+	_, model := s.index.Current()
+	s.mu.Lock()
+	if s.model != model {
+		for _, e := range s.examples {
+			e.vector = nil
+		}
+		s.model = model
+	}
+	var pending []*intentExample
+	var queries []string
+	for _, e := range s.examples {
+		if e.vector == nil {
+			pending = append(pending, e)
+			queries = append(queries, e.example.Query)
+		}
+	}
+	s.mu.Unlock()
+
+	if len(pending) > 0 {
+		vectors, err := s.index.QueryEmbedder().EmbedStrings(ctx, queries)
+		if err != nil {
+			return nil, fmt.Errorf("示例向量化失败: %w", err)
+		}
+		if len(vectors) != len(pending) {
+			return nil, fmt.Errorf("示例向量化返回 %d 个向量，期望 %d 个", len(vectors), len(pending))
+		}
+		s.mu.Lock()
+		// 向量化期间又换了模型时，这批向量作废，由下一次选择重新计算
+		if s.model == model {
+			for i, e := range pending {
+				e.vector = vectors[i]
+			}
+		}
+		s.mu.Unlock()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	candidates := make([]retrieval.ExampleVector, 0, len(s.examples))
+	for _, e := range s.examples {
+		// 向量化期间新增的示例下一次再参与选择
+		if e.vector != nil {
+			candidates = append(candidates, retrieval.ExampleVector{Example: e.example, Vector: e.vector})
+		}
+	}
+	return candidates, nil
+}
+
+// List 全部示例
+func (s *IntentExampleService) List(ctx context.Context) ([]types.IntentExample, error) {
+	rows, err := s.repo.ListAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	examples := make([]types.IntentExample, 0, len(rows))
+	for _, row := range rows {
+		e, err := toIntentExample(row)
+		if err != nil {
+			return nil, err
+		}
+		examples = append(examples, *e)
+	}
+	return examples, nil
+}
+
+// Create 添加示例；同一查询 (归一化后相同) 已有示例时覆盖，视为纠正
+// 带 search_log_id 时从检索日志取查询原文，用于把解析错误的线上查询纠正后加入示例
+func (s *IntentExampleService) Create(ctx context.Context, req types.IntentExampleRequest) (*types.IntentExample, error) {
+	query, source := strings.TrimSpace(req.Query), postgres.ExampleSourceManual
+	if req.SearchLogID != "" {
+		l, err := s.searchLogs.Get(ctx, req.SearchLogID)
+		if err != nil {
+			return nil, fmt.Errorf("检索日志 %s 不存在: %v", req.SearchLogID, err)
+		}
+		if query == "" {
+			query = l.Query
+		}
+		source = postgres.ExampleSourceSearchLog
+	}
+	if query == "" {
+		return nil, fmt.Errorf("query 不能为空")
+	}
+	intent, err := checkIntent(req.Intent)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.repo.ListAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	row := &postgres.IntentExample{ID: uuid.New().String(), CreatedAt: now}
+	for _, existing := range rows {
+		if cache.NormalizeQuery(existing.Query) == cache.NormalizeQuery(query) {
+			row = &existing
+			break
+		}
+	}
+	row.Query, row.Intent, row.Source, row.SearchLogID, row.UpdatedAt = query, intent, source, req.SearchLogID, now
+	return s.save(ctx, row)
+}
+
+// Update 纠正示例的意图，query 非空时同时修改查询
+func (s *IntentExampleService) Update(ctx context.Context, id string, req types.IntentExampleRequest) (*types.IntentExample, error) {
+	row, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("示例 %s 不存在: %v", id, err)
+	}
+	intent, err := checkIntent(req.Intent)
+	if err != nil {
+		return nil, err
+	}
+	if query := strings.TrimSpace(req.Query); query != "" {
+		row.Query = query
+	}
+	row.Intent, row.UpdatedAt = intent, time.Now()
+	return s.save(ctx, row)
+}
+
+// Delete 删除示例
+func (s *IntentExampleService) Delete(ctx context.Context, id string) error {
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, e := range s.examples {
+		if e.id == id {
+			s.examples = append(s.examples[:i], s.examples[i+1:]...)
+			break
+		}
+	}
+	s.revision = time.Now().UnixNano()
+	return nil
+}
+
+// save 写 PG 并更新内存中的示例
+func (s *IntentExampleService) save(ctx context.Context, row *postgres.IntentExample) (*types.IntentExample, error) {
+	e, err := newIntentExample(*row)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.Save(ctx, row); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	replaced := false
+	for i, existing := range s.examples {
+		if existing.id == row.ID {
+			s.examples[i], replaced = e, true
+			break
+		}
+	}
+	if !replaced {
+		s.examples = append(s.examples, e)
+	}
+	s.revision = row.UpdatedAt.UnixNano()
+	s.mu.Unlock()
+	return toIntentExample(*row)
+}
+
+// checkIntent 校验标注的意图并按解析结果的规则归一，返回 JSON
+func checkIntent(intent *types.SearchIntent) (string, error) {
+	if intent == nil {
+		return "", fmt.Errorf("intent 不能为空")
+	}
+	normalized := *intent
+	normalized.Intent = strings.ToLower(strings.TrimSpace(normalized.Intent))
+	if !retrieval.ValidIntent(normalized.Intent) {
+		return "", fmt.Errorf("不支持的 intent: %q", intent.Intent)
+	}
+	retrieval.Sanitize(&normalized)
+	data, err := json.Marshal(&normalized)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func toIntentExample(row postgres.IntentExample) (*types.IntentExample, error) {
+	e := &types.IntentExample{
+		ID:          row.ID,
+		Query:       row.Query,
+		Source:      row.Source,
+		SearchLogID: row.SearchLogID,
+		CreatedAt:   row.CreatedAt,
+		UpdatedAt:   row.UpdatedAt,
+	}
+	if err := json.Unmarshal([]byte(row.Intent), &e.Intent); err != nil {
+		return nil, fmt.Errorf("示例 %s 格式错误: %v", row.ID, err)
+	}
+	return e, nil
+}
@@ -0,0 +1,415 @@
+package service
+
+import (
+	"context"
+	"eino-demo/logic/party"
+	"eino-demo/storage/cache"
+	"eino-demo/storage/postgres"
+	"eino-demo/types"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PartyService 参与方主体归一：入库时自动挂载别名，查询时把 any_party 解析为主体 ID
+type PartyService struct {
+	partyRepo *postgres.PartyRepo
+	// 串行化入库时的解析，避免并发上传时同一主体被重复创建
+	mu sync.Mutex
+	// 人工维护主体/别名后检索口径变化，需要让结果缓存失效
+	cache *cache.Cache
+
+	// 模糊匹配用的登记簿快照，避免每个未命中的名称都全表加载；登记簿变更时作废
+	snapshotMu sync.RWMutex
+	snapshot   []postgres.Party
+	snapshotAt time.Time
+	generation uint64 // 每次作废加一，作废前开始的加载结果不再写回
+}
+
+// snapshotTTL 快照最长使用时间，多实例部署时其他实例的变更最多延迟这么久生效
+const snapshotTTL = time.Minute
+
+func NewPartyService(partyRepo *postgres.PartyRepo, c *cache.Cache) *PartyService {
+	return &PartyService{partyRepo: partyRepo, cache: c}
+}
+
+// invalidate 变更成功后使结果缓存和登记簿快照失效，原样返回 err
+func (s *PartyService) invalidate(ctx context.Context, err error) error {
+	if err == nil {
+		s.dropSnapshot()
+		s.cache.Invalidate(ctx)
+	}
+	return err
+}
+
+// parties 登记簿快照，过期或作废后重新加载 (加载时不持锁)
+func (s *PartyService) parties(ctx context.Context) ([]postgres.Party, error) {
+	s.snapshotMu.RLock()
+	parties, generation := s.snapshot, s.generation
+	fresh := parties != nil && time.Since(s.snapshotAt) < snapshotTTL
+	s.snapshotMu.RUnlock()
+	if fresh {
+		return parties, nil
+	}
+	loadedAt := time.Now()
+	parties, err := s.partyRepo.ListAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if parties == nil {
+		parties = []postgres.Party{}
+	}
+	s.snapshotMu.Lock()
+	if s.generation == generation {
+		s.snapshot, s.snapshotAt = parties, loadedAt
+	}
+	s.snapshotMu.Unlock()
+	return parties, nil
+}
+
+func (s *PartyService) dropSnapshot() {
+	s.snapshotMu.Lock()
+	s.snapshot = nil
+	s.generation++
+	s.snapshotMu.Unlock()
+}
+
+// partyMatch 模糊匹配命中的主体
+type partyMatch struct {
+	party *postgres.Party
+	score float64
+}
+
+// Resolve 入库时把参与方名称解析为主体 ID
+// 1. 别名精确命中 -> 直接返回
+// 2. 否则新建主体；模糊匹配相似度 >= AutoLinkThreshold 时记下建议合并的主体，由人工确认后合并
+// ("腾讯" 和 "腾讯音乐娱乐" 前缀相似但不是同一主体，不能自动归并)
+func (s *PartyService) Resolve(ctx context.Context, name, entityType string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	alias := party.NormalizeName(name)
+	if alias == "" {
+		return "", fmt.Errorf("empty party name")
+	}
+	a, err := s.partyRepo.GetAlias(ctx, alias)
+	if err == nil {
+		return a.PartyID, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+
+	matches, err := s.match(ctx, alias, entityType == party.EntityPerson, party.AutoLinkThreshold)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	p := &postgres.Party{
+		ID:            uuid.New().String(),
+		CanonicalName: alias,
+		EntityType:    entityType,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if len(matches) > 0 {
+		p.SuggestedPartyID, p.SuggestionScore = matches[0].party.ID, matches[0].score
+	}
+	err = s.partyRepo.CreateParty(ctx, p, &postgres.PartyAlias{
+		ID:        uuid.New().String(),
+		PartyID:   p.ID,
+		Alias:     alias,
+		Source:    postgres.AliasSourceCanonical,
+		Score:     1,
+		Confirmed: true,
+		CreatedAt: now,
+	})
+	if err != nil {
+		return "", err
+	}
+	s.dropSnapshot()
+	if p.SuggestedPartyID != "" {
+		fmt.Printf(">>> [Party] 新建主体: %s (%s)，与 %s 相似 (%.2f)，待确认是否合并\n", alias, entityType, matches[0].party.CanonicalName, p.SuggestionScore)
+	} else {
+		fmt.Printf(">>> [Party] 新建主体: %s (%s)\n", alias, entityType)
+	}
+	return p.ID, nil
+}
+
+// ResolveFilters 查询前把 any_party 解析为主体 ID 及其全部别名
+// 解析成功的名称从 AnyParty 移到 PartyIDs/PartyAliases，三个存储都按 ID/别名精确匹配；
+// 解析不了的名称保留在 AnyParty 里，沿用各存储原来的模糊匹配，相似的主体写入 PartyCandidates 供用户选择，
+// chosen 为用户从候选中选定的主体
+func (s *PartyService) ResolveFilters(ctx context.Context, filters *types.FilterConditions, chosen []string) error {
+	if filters == nil || len(filters.AnyParty) == 0 {
+		return nil
+	}
+
+	var unresolved []string
+	var candidates []types.PartyCandidate
+	idSet := make(map[string]bool)
+	var ids []string
+	for _, name := range filters.AnyParty {
+		matched, similar, err := s.lookup(ctx, name, chosen)
+		if err != nil {
+			return err
+		}
+		if len(matched) == 0 {
+			unresolved = append(unresolved, name)
+			candidates = append(candidates, similar...)
+			continue
+		}
+		for _, id := range matched {
+			if !idSet[id] {
+				idSet[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	filters.PartyCandidates = candidates
+	if len(candidates) > 0 {
+		fmt.Printf(">>> [Party] any_party %v 不能唯一确定主体，返回 %d 个候选\n", unresolved, len(candidates))
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	aliases, err := s.partyRepo.AliasesByPartyIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	fmt.Printf(">>> [Party] any_party %v 解析为 %d 个主体, 别名 %v, 未解析 %v\n", filters.AnyParty, len(ids), aliases, unresolved)
+	filters.AnyParty = unresolved
+	filters.PartyIDs = ids
+	filters.PartyAliases = aliases
+	return nil
+}
+
+// lookup 查询侧解析单个名称：确认过的别名精确命中取该主体，否则在登记簿快照中挑选；
+// 不能确定时返回候选主体
+func (s *PartyService) lookup(ctx context.Context, name string, chosen []string) ([]string, []types.PartyCandidate, error) {
+	alias := party.NormalizeName(name)
+	if alias == "" {
+		return nil, nil, nil
+	}
+	a, err := s.partyRepo.GetAlias(ctx, alias)
+	if err == nil && a.Confirmed {
+		return []string{a.PartyID}, nil, nil
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, err
+	}
+	parties, err := s.parties(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	ids, candidates := pickParties(name, parties, chosen)
+	return ids, candidates, nil
+}
+
+// pickParties 按相似度为查询中的名称挑选主体
+// 只有一个主体的已确认别名与名称主干一致 (相似度高于 PrefixScore) 时直接采用；
+// 前缀或字面相近的主体可能是不同主体 ("腾讯" 和 "腾讯音乐娱乐")，不合并检索，
+// 相似度达到 QueryThreshold 的都作为候选返回，用户已从中选定的主体直接采用
+func pickParties(name string, parties []postgres.Party, chosen []string) ([]string, []types.PartyCandidate) {
+	var confident []string
+	var candidates []types.PartyCandidate
+	for i := range parties {
+		p := &parties[i]
+		best, confirmed := 0.0, 0.0
+		for _, a := range p.Aliases {
+			score := party.Similarity(name, a.Alias, p.EntityType == party.EntityPerson)
+			best = max(best, score)
+			if a.Confirmed {
+				confirmed = max(confirmed, score)
+			}
+		}
+		if confirmed > party.PrefixScore {
+			confident = append(confident, p.ID)
+		}
+		if best >= party.QueryThreshold {
+			candidates = append(candidates, types.PartyCandidate{Name: name, PartyID: p.ID, CanonicalName: p.CanonicalName, Score: best})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+
+	var picked []string
+	for _, c := range candidates {
+		if slices.Contains(chosen, c.PartyID) {
+			picked = append(picked, c.PartyID)
+		}
+	}
+	if len(picked) > 0 {
+		return picked, nil
+	}
+	if len(confident) == 1 {
+		return confident, nil
+	}
+	return nil, candidates
+}
+
+// match 与登记簿快照中全部别名做模糊匹配，返回相似度 >= threshold 的主体 (按相似度降序)
+func (s *PartyService) match(ctx context.Context, name string, personLike bool, threshold float64) ([]partyMatch, error) {
+	parties, err := s.parties(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var matches []partyMatch
+	for i := range parties {
+		p := &parties[i]
+		best := 0.0
+		for _, a := range p.Aliases {
+			if score := party.Similarity(name, a.Alias, personLike || p.EntityType == party.EntityPerson); score > best {
+				best = score
+			}
+		}
+		if best >= threshold {
+			matches = append(matches, partyMatch{party: p, score: best})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+	return matches, nil
+}
+
+// Backfill 为历史合同参与方补齐主体 ID
+func (s *PartyService) Backfill(ctx context.Context) (int, error) {
+	rows, err := s.partyRepo.ListUnresolvedContractParties(ctx)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, row := range rows {
+		partyID, err := s.Resolve(ctx, row.NormalizedName, row.EntityType)
+		if err != nil {
+			fmt.Printf(">>> [Party] 回填 %s 失败: %v\n", row.NormalizedName, err)
+			continue
+		}
+		if err := s.partyRepo.SetContractPartyID(ctx, row.ID, partyID); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// ==================== 登记簿维护 (API) ====================
+
+// ListParties 分页查询主体
+func (s *PartyService) ListParties(ctx context.Context, keyword string, limit, offset int) ([]postgres.Party, int64, error) {
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	return s.partyRepo.ListParties(ctx, keyword, limit, offset)
+}
+
+// GetParty 查询主体详情
+func (s *PartyService) GetParty(ctx context.Context, id string) (*postgres.Party, error) {
+	return s.partyRepo.GetParty(ctx, id)
+}
+
+// UpdateParty 修改规范名/主体类型，新规范名同时登记为别名
+func (s *PartyService) UpdateParty(ctx context.Context, id, canonicalName, entityType string) error {
+	canonicalName = party.NormalizeName(canonicalName)
+	if entityType != "" && entityType != party.EntityPerson && entityType != party.EntityOrganization {
+		return fmt.Errorf("entity_type 只能是 %s 或 %s", party.EntityPerson, party.EntityOrganization)
+	}
+	if err := s.invalidate(ctx, s.partyRepo.UpdateParty(ctx, id, canonicalName, entityType)); err != nil {
+		return err
+	}
+	if canonicalName == "" {
+		return nil
+	}
+	if _, err := s.partyRepo.GetAlias(ctx, canonicalName); err == nil {
+		return nil
+	}
+	return s.AddAlias(ctx, id, canonicalName)
+}
+
+// AddAlias 人工给主体添加别名
+func (s *PartyService) AddAlias(ctx context.Context, partyID, alias string) error {
+	alias = party.NormalizeName(alias)
+	if alias == "" {
+		return fmt.Errorf("alias 不能为空")
+	}
+	if existing, err := s.partyRepo.GetAlias(ctx, alias); err == nil {
+		if existing.PartyID == partyID {
+			return nil
+		}
+		return fmt.Errorf("别名 %s 已属于主体 %s，请使用 move 或 merge", alias, existing.PartyID)
+	}
+	return s.invalidate(ctx, s.partyRepo.AddAlias(ctx, &postgres.PartyAlias{
+		ID:        uuid.New().String(),
+		PartyID:   partyID,
+		Alias:     alias,
+		Source:    postgres.AliasSourceManual,
+		Score:     1,
+		Confirmed: true,
+		CreatedAt: time.Now(),
+	}))
+}
+
+// DeleteAlias 删除别名
+func (s *PartyService) DeleteAlias(ctx context.Context, partyID, aliasID string) error {
+	return s.invalidate(ctx, s.partyRepo.DeleteAlias(ctx, partyID, aliasID))
+}
+
+// ConfirmAlias 确认自动挂载的别名
+func (s *PartyService) ConfirmAlias(ctx context.Context, partyID, aliasID string) error {
+	return s.invalidate(ctx, s.partyRepo.ConfirmAlias(ctx, partyID, aliasID))
+}
+
+// MoveAlias 把别名改挂到另一个主体
+func (s *PartyService) MoveAlias(ctx context.Context, aliasID, toPartyID string) error {
+	if _, err := s.partyRepo.GetParty(ctx, toPartyID); err != nil {
+		return fmt.Errorf("目标主体不存在: %v", err)
+	}
+	return s.invalidate(ctx, s.partyRepo.MoveAlias(ctx, aliasID, toPartyID))
+}
+
+// MergeParties 合并两个主体
+func (s *PartyService) MergeParties(ctx context.Context, targetID, sourceID string) error {
+	if targetID == sourceID {
+		return fmt.Errorf("不能与自身合并")
+	}
+	if _, err := s.partyRepo.GetParty(ctx, sourceID); err != nil {
+		return fmt.Errorf("被合并主体不存在: %v", err)
+	}
+	return s.invalidate(ctx, s.partyRepo.MergeParties(ctx, targetID, sourceID))
+}
+
+// PartySuggestions 待人工处理的归一建议
+type PartySuggestions struct {
+	// Merges 与已有主体相似的主体，确认后 POST /party/:suggested_party_id/merge {"source_id": id}，
+	// 不是同一主体时 DELETE /party/:id/suggestion 忽略
+	Merges []postgres.Party `json:"merges"`
+	// Aliases 早期版本自动挂载、尚未确认的别名
+	Aliases []postgres.PartyAlias `json:"aliases"`
+}
+
+// ListSuggestions 查询待确认的合并建议和自动别名
+func (s *PartyService) ListSuggestions(ctx context.Context) (*PartySuggestions, error) {
+	merges, err := s.partyRepo.ListMergeSuggestions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	aliases, err := s.partyRepo.ListSuggestions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &PartySuggestions{Merges: merges, Aliases: aliases}, nil
+}
+
+// DismissSuggestion 忽略合并建议，两个主体保持独立
+func (s *PartyService) DismissSuggestion(ctx context.Context, id string) error {
+	return s.partyRepo.ClearSuggestion(ctx, id)
+}
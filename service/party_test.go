@@ -0,0 +1,55 @@
+package service
+
+import (
+	"slices"
+	"testing"
+
+	"eino-demo/logic/party"
+	"eino-demo/storage/postgres"
+)
+
+func testParty(id, canonical string, aliases ...postgres.PartyAlias) postgres.Party {
+	p := postgres.Party{ID: id, CanonicalName: canonical, EntityType: party.EntityOrganization}
+	p.Aliases = append([]postgres.PartyAlias{{PartyID: id, Alias: canonical, Confirmed: true}}, aliases...)
+	return p
+}
+
+// 前缀相似的不同主体不能合并检索，只作为候选返回
+func TestPickParties(t *testing.T) {
+	parties := []postgres.Party{
+		testParty("tencent", "深圳市腾讯计算机系统有限公司"),
+		testParty("tme", "腾讯音乐娱乐科技(深圳)有限公司"),
+		testParty("alibaba", "阿里巴巴(中国)有限公司", postgres.PartyAlias{PartyID: "alibaba", Alias: "阿里云计算有限公司", Confirmed: false}),
+	}
+	cases := []struct {
+		name       string
+		query      string
+		chosen     []string
+		want       []string
+		candidates []string
+	}{
+		{name: "主干一致直接采用", query: "腾讯计算机系统公司", want: []string{"tencent"}},
+		{name: "简称前缀命中两个主体", query: "腾讯", candidates: []string{"tencent", "tme"}},
+		{name: "用户选定候选", query: "腾讯", chosen: []string{"tme"}, want: []string{"tme"}},
+		{name: "选定的主体不在候选里", query: "腾讯", chosen: []string{"alibaba"}, candidates: []string{"tencent", "tme"}},
+		{name: "未确认的别名只作为候选", query: "阿里云计算公司", candidates: []string{"alibaba"}},
+		{name: "没有相似主体", query: "字节跳动", want: nil},
+	}
+	for _, c := range cases {
+		ids, candidates := pickParties(c.query, parties, c.chosen)
+		if !slices.Equal(ids, c.want) {
+			t.Errorf("%s: ids = %v, want %v", c.name, ids, c.want)
+		}
+		var got []string
+		for _, cand := range candidates {
+			if cand.Name != c.query {
+				t.Errorf("%s: 候选名称 %q", c.name, cand.Name)
+			}
+			got = append(got, cand.PartyID)
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, c.candidates) {
+			t.Errorf("%s: candidates = %v, want %v", c.name, got, c.candidates)
+		}
+	}
+}
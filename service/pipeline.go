@@ -0,0 +1,498 @@
+package service
+
+import (
+	"context"
+	"eino-demo/config"
+	"eino-demo/logic/ingestion/clause"
+	"eino-demo/storage/postgres"
+	"eino-demo/types"
+	"fmt"
+	"mime/multipart"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudwego/eino-ext/components/document/loader/file"
+	"github.com/cloudwego/eino/schema"
+)
+
+// 阶段名
+const (
+	stageParse   = "parse"
+	stageExtract = "extract"
+	stageSplit   = "split"
+	stagePersist = "persist"
+	stageWrite   = "write"
+)
+
+// ingestSteps 流水线各阶段的具体处理，由 ContractService 实现
+type ingestSteps interface {
+	parseFile(ctx context.Context, header *multipart.FileHeader) ([]*schema.Document, error)
+	fileExists(ctx context.Context, fileName string) bool
+	extractDoc(job *docJob) error
+	splitDoc(job *docJob) error
+	persistDoc(job *docJob) error
+	storeChunks(batch []*docJob) error
+	rollbackDoc(job *docJob)
+	invalidateResults()
+}
+
+// ingestPipeline 入库流水线
+//
+//	parse ─┬─> extract ─┬─> persist -> write (跨文档批量)
+//	       └─> split  ──┘
+//
+// LLM 提取和语义切分互不依赖，同一文档的两步并行执行，都完成后才写 PG
+// 每个阶段固定数量的 worker，阶段之间用有界 channel 连接
+type ingestPipeline struct {
+	svc ingestSteps
+	cfg config.Ingest
+
+	parseQ   chan *fileJob
+	extractQ chan *docJob
+	splitQ   chan *docJob
+	persistQ chan *docJob
+	writeQ   chan *docJob
+
+	stages       []*stageMetrics
+	metrics      map[string]*stageMetrics
+	writeBatches atomic.Int64
+	writeChunks  atomic.Int64
+
+	// inflight 正在处理的文件名，同名文件并发上传时只处理一份
+	inflightMu sync.Mutex
+	inflight   map[string]bool
+
+	closeMu sync.RWMutex
+	closed  bool
+	done    chan struct{}
+}
+
+// fileJob 一个上传文件
+type fileJob struct {
+	ctx     context.Context
+	header  *multipart.FileHeader
+	tracker *fileTracker
+}
+
+// docJob 一个文档在流水线中的状态
+type docJob struct {
+	ctx      context.Context
+	fileName string
+	doc      *schema.Document
+	tracker  *fileTracker
+	start    time.Time
+
+	// extract 阶段产出
+	entity   *types.ContractRawData
+	contract *postgres.Contract
+	// split 阶段产出
+	clauses []clause.Clause
+	chunks  []*schema.Document
+
+	// pending extract 和 split 还剩几步未完成，归零的一方把任务送往 persist
+	pending atomic.Int32
+	mu      sync.Mutex
+	err     error
+}
+
+func (j *docJob) fail(err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.err == nil {
+		j.err = err
+	}
+}
+
+func (j *docJob) failed() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.err
+}
+
+// fileTracker 汇总一个文件拆出的全部文档的结果；pending 初始为 1 代表文件本身 (parse 完成时释放)
+type fileTracker struct {
+	mu      sync.Mutex
+	pending int
+	result  types.IngestResult
+	done    chan struct{}
+}
+
+func newFileTracker(fileName string) *fileTracker {
+	return &fileTracker{pending: 1, result: types.IngestResult{FileName: fileName, DocIDs: []string{}}, done: make(chan struct{})}
+}
+
+func (t *fileTracker) add() {
+	t.mu.Lock()
+	t.pending++
+	t.mu.Unlock()
+}
+
+// finish 记录一个文档 (或文件本身) 的结果，全部完成时关闭 done
+func (t *fileTracker) finish(docID string, skipped bool, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch {
+	case err != nil:
+		t.result.Errors = append(t.result.Errors, err.Error())
+	case skipped:
+		t.result.Skipped++
+	case docID != "":
+		t.result.DocIDs = append(t.result.DocIDs, docID)
+	}
+	t.pending--
+	if t.pending == 0 {
+		close(t.done)
+	}
+}
+
+// stageMetrics 单个阶段的计数器
+type stageMetrics struct {
+	name      string
+	workers   int
+	queue     func() (int, int)
+	inFlight  atomic.Int64
+	processed atomic.Int64
+	failed    atomic.Int64
+	busyNanos atomic.Int64
+}
+
+// track 执行一个任务并计数
+func (m *stageMetrics) track(fn func() error) {
+	m.inFlight.Add(1)
+	start := time.Now()
+	err := fn()
+	m.busyNanos.Add(int64(time.Since(start)))
+	m.inFlight.Add(-1)
+	if err != nil {
+		m.failed.Add(1)
+	} else {
+		m.processed.Add(1)
+	}
+}
+
+func newIngestPipeline(svc ingestSteps, cfg config.Ingest) *ingestPipeline {
+	def := config.Default().Ingest
+	positive := func(v, fallback int) int {
+		if v > 0 {
+			return v
+		}
+		return fallback
+	}
+	cfg.ParseWorkers = positive(cfg.ParseWorkers, def.ParseWorkers)
+	cfg.ExtractWorkers = positive(cfg.ExtractWorkers, def.ExtractWorkers)
+	cfg.SplitWorkers = positive(cfg.SplitWorkers, def.SplitWorkers)
+	cfg.PersistWorkers = positive(cfg.PersistWorkers, def.PersistWorkers)
+	cfg.QueueSize = positive(cfg.QueueSize, def.QueueSize)
+	cfg.WriteBatchSize = positive(cfg.WriteBatchSize, def.WriteBatchSize)
+	if cfg.WriteBatchWait <= 0 {
+		cfg.WriteBatchWait = def.WriteBatchWait
+	}
+
+	p := &ingestPipeline{
+		svc:      svc,
+		cfg:      cfg,
+		parseQ:   make(chan *fileJob, cfg.QueueSize),
+		extractQ: make(chan *docJob, cfg.QueueSize),
+		splitQ:   make(chan *docJob, cfg.QueueSize),
+		persistQ: make(chan *docJob, cfg.QueueSize),
+		writeQ:   make(chan *docJob, cfg.QueueSize),
+		metrics:  make(map[string]*stageMetrics),
+		inflight: make(map[string]bool),
+		done:     make(chan struct{}),
+	}
+	docQueue := func(ch chan *docJob) func() (int, int) {
+		return func() (int, int) { return len(ch), cap(ch) }
+	}
+	for _, m := range []*stageMetrics{
+		{name: stageParse, workers: cfg.ParseWorkers, queue: func() (int, int) { return len(p.parseQ), cap(p.parseQ) }},
+		{name: stageExtract, workers: cfg.ExtractWorkers, queue: docQueue(p.extractQ)},
+		{name: stageSplit, workers: cfg.SplitWorkers, queue: docQueue(p.splitQ)},
+		{name: stagePersist, workers: cfg.PersistWorkers, queue: docQueue(p.persistQ)},
+		{name: stageWrite, workers: 1, queue: docQueue(p.writeQ)},
+	} {
+		p.stages = append(p.stages, m)
+		p.metrics[m.name] = m
+	}
+	p.start()
+	return p
+}
+
+// start 启动各阶段 worker；上游阶段全部退出后才关闭下游队列，保证关闭时在途任务全部处理完
+func (p *ingestPipeline) start() {
+	var parseWG, extractWG, splitWG, persistWG, writeWG sync.WaitGroup
+	runWorkers(p.cfg.ParseWorkers, &parseWG, p.parseQ, p.parse)
+	runWorkers(p.cfg.ExtractWorkers, &extractWG, p.extractQ, p.extract)
+	runWorkers(p.cfg.SplitWorkers, &splitWG, p.splitQ, p.split)
+	runWorkers(p.cfg.PersistWorkers, &persistWG, p.persistQ, p.persist)
+	writeWG.Add(1)
+	go func() {
+		defer writeWG.Done()
+		p.writeLoop()
+	}()
+
+	go func() {
+		parseWG.Wait()
+		close(p.extractQ)
+		close(p.splitQ)
+		extractWG.Wait()
+		splitWG.Wait()
+		close(p.persistQ)
+		persistWG.Wait()
+		close(p.writeQ)
+		writeWG.Wait()
+		close(p.done)
+	}()
+}
+
+func runWorkers[T any](n int, wg *sync.WaitGroup, in <-chan T, fn func(T)) {
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range in {
+				fn(job)
+			}
+		}()
+	}
+}
+
+// submit 提交一个文件，队列满时阻塞直到有空位或 ctx 结束
+func (p *ingestPipeline) submit(ctx context.Context, header *multipart.FileHeader, tracker *fileTracker) error {
+	p.closeMu.RLock()
+	defer p.closeMu.RUnlock()
+	if p.closed {
+		return fmt.Errorf("入库流水线已关闭")
+	}
+	// 处理过程与请求解耦：客户端断开后已提交的文件继续处理完，避免留下写了一半的数据
+	job := &fileJob{ctx: context.WithoutCancel(ctx), header: header, tracker: tracker}
+	select {
+	case p.parseQ <- job:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// close 不再接收新文件，等待在途任务全部处理完 (含最后一个写入批次)
+func (p *ingestPipeline) close(ctx context.Context) error {
+	p.closeMu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.parseQ)
+	}
+	p.closeMu.Unlock()
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("等待入库任务完成超时: %w", ctx.Err())
+	}
+}
+
+// parse 解析文件，按文件名查重后把每个文档同时送往 extract 和 split
+func (p *ingestPipeline) parse(job *fileJob) {
+	var docs []*schema.Document
+	var err error
+	p.metrics[stageParse].track(func() error {
+		docs, err = p.svc.parseFile(job.ctx, job.header)
+		return err
+	})
+	if err != nil {
+		job.tracker.finish("", false, fmt.Errorf("%s: %v", job.header.Filename, err))
+		return
+	}
+	for _, doc := range docs {
+		fileName := doc.MetaData[file.MetaKeyFileName].(string)
+		if !p.acquire(fileName) {
+			fmt.Printf(">>> [Pipeline] 跳过: 同名文件正在处理中 (%s)\n", fileName)
+			job.tracker.add()
+			job.tracker.finish("", true, nil)
+			continue
+		}
+		if p.svc.fileExists(job.ctx, fileName) {
+			fmt.Printf(">>> [DEBUG] 跳过: 文件已存在数据库中 (%s)\n", fileName)
+			p.release(fileName)
+			job.tracker.add()
+			job.tracker.finish("", true, nil)
+			continue
+		}
+		d := &docJob{ctx: job.ctx, fileName: fileName, doc: doc, tracker: job.tracker, start: time.Now()}
+		d.pending.Store(2)
+		job.tracker.add()
+		p.extractQ <- d
+		p.splitQ <- d
+	}
+	job.tracker.finish("", false, nil)
+}
+
+func (p *ingestPipeline) extract(job *docJob) {
+	p.metrics[stageExtract].track(func() error {
+		err := p.svc.extractDoc(job)
+		if err != nil {
+			job.fail(fmt.Errorf("结构化提取失败: %v", err))
+		}
+		return err
+	})
+	p.join(job)
+}
+
+func (p *ingestPipeline) split(job *docJob) {
+	p.metrics[stageSplit].track(func() error {
+		err := p.svc.splitDoc(job)
+		if err != nil {
+			job.fail(err)
+		}
+		return err
+	})
+	p.join(job)
+}
+
+// join extract 和 split 中后完成的一方把任务送往 persist
+func (p *ingestPipeline) join(job *docJob) {
+	if job.pending.Add(-1) == 0 {
+		p.persistQ <- job
+	}
+}
+
+func (p *ingestPipeline) persist(job *docJob) {
+	if err := job.failed(); err != nil {
+		p.finish(job, err)
+		return
+	}
+	var err error
+	p.metrics[stagePersist].track(func() error {
+		err = p.svc.persistDoc(job)
+		return err
+	})
+	if err != nil {
+		p.finish(job, err)
+		return
+	}
+	p.writeQ <- job
+}
+
+// writeLoop 跨文档攒批：切片数达到 WriteBatchSize 或第一个文档等待超过 WriteBatchWait 时写入
+func (p *ingestPipeline) writeLoop() {
+	var batch []*docJob
+	var timeout <-chan time.Time
+	chunks := 0
+	flush := func() {
+		if len(batch) > 0 {
+			p.writeBatches.Add(1)
+			p.writeChunks.Add(int64(chunks))
+			p.writeBatch(batch)
+		}
+		batch, chunks, timeout = nil, 0, nil
+	}
+	for {
+		select {
+		case job, ok := <-p.writeQ:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, job)
+			chunks += len(job.chunks)
+			if timeout == nil {
+				timeout = time.After(p.cfg.WriteBatchWait)
+			}
+			if chunks >= p.cfg.WriteBatchSize {
+				flush()
+			}
+		case <-timeout:
+			flush()
+		}
+	}
+}
+
+// writeBatch 批量写 ES/Milvus；整批失败时逐个文档重试，只回滚真正写不进去的文档
+func (p *ingestPipeline) writeBatch(batch []*docJob) {
+	m := p.metrics[stageWrite]
+	var err error
+	m.track(func() error {
+		err = p.svc.storeChunks(batch)
+		return err
+	})
+	if err == nil {
+		for _, job := range batch {
+			p.finish(job, nil)
+		}
+		p.svc.invalidateResults()
+		return
+	}
+	if len(batch) > 1 {
+		fmt.Printf(">>> [Pipeline] %d 个文档批量写入失败，逐个重试: %v\n", len(batch), err)
+	}
+
+	written := 0
+	for _, job := range batch {
+		if len(batch) > 1 {
+			m.track(func() error {
+				err = p.svc.storeChunks([]*docJob{job})
+				return err
+			})
+		}
+		if err != nil {
+			p.svc.rollbackDoc(job)
+			fmt.Printf(">>> [Pipeline] %s 写入 ES/Milvus 失败，已回滚PG记录：%v\n", job.fileName, err)
+			p.finish(job, fmt.Errorf("索引写入失败: %v", err))
+			continue
+		}
+		written++
+		p.finish(job, nil)
+	}
+	if written > 0 {
+		p.svc.invalidateResults()
+	}
+}
+
+// finish 文档处理结束 (成功 err 为 nil)
+func (p *ingestPipeline) finish(job *docJob, err error) {
+	p.release(job.fileName)
+	if err != nil {
+		fmt.Printf(">>> [Pipeline] %s 入库失败: %v\n", job.fileName, err)
+		job.tracker.finish("", false, fmt.Errorf("%s: %v", job.fileName, err))
+		return
+	}
+	fmt.Printf(">>> [性能] %s 入库完成，耗时: %v\n", job.fileName, time.Since(job.start))
+	job.tracker.finish(job.contract.DocID, false, nil)
+}
+
+func (p *ingestPipeline) acquire(fileName string) bool {
+	p.inflightMu.Lock()
+	defer p.inflightMu.Unlock()
+	if p.inflight[fileName] {
+		return false
+	}
+	p.inflight[fileName] = true
+	return true
+}
+
+func (p *ingestPipeline) release(fileName string) {
+	p.inflightMu.Lock()
+	delete(p.inflight, fileName)
+	p.inflightMu.Unlock()
+}
+
+// stats 各阶段指标快照
+func (p *ingestPipeline) stats() types.PipelineStats {
+	stats := types.PipelineStats{WriteBatches: p.writeBatches.Load(), WriteChunks: p.writeChunks.Load()}
+	for _, m := range p.stages {
+		queued, queueCap := m.queue()
+		st := types.StageStats{
+			Stage:     m.name,
+			Workers:   m.workers,
+			Queued:    queued,
+			QueueCap:  queueCap,
+			InFlight:  m.inFlight.Load(),
+			Processed: m.processed.Load(),
+			Failed:    m.failed.Load(),
+		}
+		if done := st.Processed + st.Failed; done > 0 {
+			st.AvgMillis = float64(m.busyNanos.Load()) / float64(done) / float64(time.Millisecond)
+		}
+		stats.Stages = append(stats.Stages, st)
+	}
+	return stats
+}
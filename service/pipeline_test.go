@@ -0,0 +1,249 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"mime/multipart"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"eino-demo/config"
+	"eino-demo/storage/postgres"
+	"eino-demo/types"
+
+	"github.com/cloudwego/eino-ext/components/document/loader/file"
+	"github.com/cloudwego/eino/schema"
+)
+
+// fakeSteps 不依赖 PDF/LLM/存储的流水线阶段：每个文件解析为一个文档
+type fakeSteps struct {
+	gate     chan struct{}   // 非空时 extract 阻塞到 gate 关闭
+	badFiles map[string]bool // 写入 ES/Milvus 时失败的文件
+
+	mu          sync.Mutex
+	persisted   map[string]bool
+	batches     [][]string
+	rolledBack  []string
+	invalidated int
+}
+
+func newFakeSteps() *fakeSteps {
+	return &fakeSteps{badFiles: make(map[string]bool), persisted: make(map[string]bool)}
+}
+
+func (f *fakeSteps) parseFile(_ context.Context, header *multipart.FileHeader) ([]*schema.Document, error) {
+	return []*schema.Document{{Content: header.Filename, MetaData: map[string]any{file.MetaKeyFileName: header.Filename}}}, nil
+}
+
+func (f *fakeSteps) fileExists(_ context.Context, fileName string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.persisted[fileName]
+}
+
+func (f *fakeSteps) extractDoc(job *docJob) error {
+	if f.gate != nil {
+		<-f.gate
+	}
+	job.entity = &types.ContractRawData{}
+	job.contract = &postgres.Contract{FileName: job.fileName}
+	return nil
+}
+
+func (f *fakeSteps) splitDoc(job *docJob) error {
+	job.chunks = []*schema.Document{{Content: job.doc.Content}}
+	return nil
+}
+
+func (f *fakeSteps) persistDoc(job *docJob) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	job.contract.DocID = "doc-" + job.fileName
+	f.persisted[job.fileName] = true
+	return nil
+}
+
+func (f *fakeSteps) storeChunks(batch []*docJob) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var names []string
+	var err error
+	for _, job := range batch {
+		names = append(names, job.fileName)
+		if f.badFiles[job.fileName] {
+			err = errors.New("Milvus 存储失败")
+		}
+	}
+	f.batches = append(f.batches, names)
+	return err
+}
+
+func (f *fakeSteps) rollbackDoc(job *docJob) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rolledBack = append(f.rolledBack, job.fileName)
+	delete(f.persisted, job.fileName)
+}
+
+func (f *fakeSteps) invalidateResults() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.invalidated++
+}
+
+// testIngestConfig 批次等待足够长，测试中提交的文档都进同一个写入批次
+func testIngestConfig() config.Ingest {
+	cfg := config.Default().Ingest
+	cfg.WriteBatchWait = 200 * time.Millisecond
+	return cfg
+}
+
+func submitFiles(t *testing.T, p *ingestPipeline, names ...string) []*fileTracker {
+	t.Helper()
+	trackers := make([]*fileTracker, len(names))
+	for i, name := range names {
+		trackers[i] = newFileTracker(name)
+		if err := p.submit(context.Background(), &multipart.FileHeader{Filename: name}, trackers[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return trackers
+}
+
+func wait(t *testing.T, trackers ...*fileTracker) {
+	t.Helper()
+	for _, tr := range trackers {
+		select {
+		case <-tr.done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s 没有处理完", tr.result.FileName)
+		}
+	}
+}
+
+// 整批写入失败时逐个文档重试，只回滚真正写不进去的文档
+func TestPipelineBatchFallback(t *testing.T) {
+	steps := newFakeSteps()
+	steps.badFiles["c.pdf"] = true
+	p := newIngestPipeline(steps, testIngestConfig())
+	defer p.close(context.Background())
+
+	trackers := submitFiles(t, p, "a.pdf", "b.pdf", "c.pdf")
+	wait(t, trackers...)
+
+	for _, tr := range trackers[:2] {
+		if len(tr.result.DocIDs) != 1 || len(tr.result.Errors) != 0 {
+			t.Errorf("%s 应入库成功: %+v", tr.result.FileName, tr.result)
+		}
+	}
+	if bad := trackers[2].result; len(bad.DocIDs) != 0 || len(bad.Errors) != 1 || !strings.Contains(bad.Errors[0], "索引写入失败") {
+		t.Errorf("c.pdf 应报索引写入失败: %+v", bad)
+	}
+
+	steps.mu.Lock()
+	defer steps.mu.Unlock()
+	if len(steps.batches) != 4 || len(steps.batches[0]) != 3 {
+		t.Fatalf("应先整批写入一次，再逐个重试 3 次: %v", steps.batches)
+	}
+	for _, retry := range steps.batches[1:] {
+		if len(retry) != 1 {
+			t.Errorf("重试应逐个文档写入: %v", retry)
+		}
+	}
+	if !slices.Equal(steps.rolledBack, []string{"c.pdf"}) {
+		t.Errorf("只应回滚 c.pdf: %v", steps.rolledBack)
+	}
+	if steps.invalidated != 1 {
+		t.Errorf("有文档写入成功，结果缓存应作废一次: %d", steps.invalidated)
+	}
+	if write := p.metrics[stageWrite]; write.failed.Load() != 2 || write.processed.Load() != 2 {
+		t.Errorf("写入阶段计数 failed=%d processed=%d", write.failed.Load(), write.processed.Load())
+	}
+}
+
+// 同名文件并发提交只处理一份；处理完之后再提交按已入库跳过
+func TestPipelineDuplicateFileNames(t *testing.T) {
+	steps := newFakeSteps()
+	steps.gate = make(chan struct{})
+	p := newIngestPipeline(steps, testIngestConfig())
+	defer p.close(context.Background())
+
+	trackers := submitFiles(t, p, "dup.pdf", "dup.pdf")
+	// 其中一份阻塞在 extract，另一份应立即被跳过
+	select {
+	case <-trackers[0].done:
+	case <-trackers[1].done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("同名文件没有被跳过")
+	}
+	close(steps.gate)
+	wait(t, trackers...)
+
+	docs, skipped := 0, 0
+	for _, tr := range trackers {
+		docs += len(tr.result.DocIDs)
+		skipped += tr.result.Skipped
+	}
+	if docs != 1 || skipped != 1 {
+		t.Errorf("应入库 1 份、跳过 1 份: docs=%d skipped=%d", docs, skipped)
+	}
+
+	again := submitFiles(t, p, "dup.pdf")
+	wait(t, again...)
+	if again[0].result.Skipped != 1 {
+		t.Errorf("已入库的同名文件应跳过: %+v", again[0].result)
+	}
+	if !p.acquire("dup.pdf") {
+		t.Error("处理完成后应释放文件名")
+	}
+}
+
+// close 不再接收新文件，等在途任务 (含最后一个写入批次) 全部处理完才返回
+func TestPipelineCloseDrains(t *testing.T) {
+	steps := newFakeSteps()
+	steps.gate = make(chan struct{})
+	p := newIngestPipeline(steps, testIngestConfig())
+
+	trackers := submitFiles(t, p, "a.pdf", "b.pdf", "c.pdf")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := p.close(ctx); err == nil {
+		t.Fatal("在途任务未完成时 close 应等待到超时")
+	}
+	if err := p.submit(context.Background(), &multipart.FileHeader{Filename: "d.pdf"}, newFileTracker("d.pdf")); err == nil {
+		t.Error("关闭后不应再接收新文件")
+	}
+
+	closed := make(chan error, 1)
+	go func() { closed <- p.close(context.Background()) }()
+	close(steps.gate)
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("close 没有返回")
+	}
+
+	// close 返回时全部文件已处理完并写入
+	for _, tr := range trackers {
+		select {
+		case <-tr.done:
+		default:
+			t.Fatalf("%s 在 close 返回时还没处理完", tr.result.FileName)
+		}
+		if len(tr.result.DocIDs) != 1 {
+			t.Errorf("%s 应入库成功: %+v", tr.result.FileName, tr.result)
+		}
+	}
+	steps.mu.Lock()
+	defer steps.mu.Unlock()
+	if len(steps.batches) != 1 || len(steps.batches[0]) != 3 {
+		t.Errorf("最后一个批次应在 close 时写入: %v", steps.batches)
+	}
+}
@@ -0,0 +1,320 @@
+package service
+
+import (
+	"context"
+	"eino-demo/logic/ingestion/clause"
+	"eino-demo/logic/ingestion/transform/score"
+	"eino-demo/logic/retrieval"
+	"eino-demo/storage/es"
+	"eino-demo/storage/milvus"
+	"eino-demo/storage/postgres"
+	"eino-demo/types"
+	"eino-demo/vars"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// planFunc 执行计划：按意图检索并填充响应
+type planFunc func(ctx context.Context, req types.SearchRequest, cursor *types.Cursor, intent *types.SearchIntent, resp *types.SearchResponse) error
+
+// planSteps 各意图执行计划的步骤，随响应返回
+var planSteps = map[string][]string{
+	vars.PG: {"es_party_search", "pg_filter", "pg_keyset_page"},
+	vars.ML: {"milvus_vector"},
+	vars.HY: {"milvus_vector", "es_bm25", "hybrid_rerank"},
+	vars.AG: {"pg_aggregate", "llm_summary"},
+	vars.CL: {"pg_locate_contracts", "pg_clauses"},
+	vars.QA: {"pg_resolve_contract", "disambiguate", "milvus_vector(doc_id, all_chunks)", "es_bm25(doc_id, all_chunks)", "hybrid_rerank", "llm_answer"},
+	vars.CP: {"pg_locate_contracts", "pg_clauses", "align_by_clause_type", "hybrid_search(doc_id)", "llm_compare"},
+}
+
+// plan 意图对应的执行计划，未知意图按 hybrid 执行
+func (s *RetrievalService) plan(intent string) planFunc {
+	switch intent {
+	case vars.PG:
+		return s.searchStructured
+	case vars.AG:
+		return s.planAggregate
+	case vars.CL:
+		return s.planClauseLookup
+	case vars.QA:
+		return s.planContractQA
+	case vars.CP:
+		return s.planCompare
+	}
+	// hybrid 和 semantic_only 共用混合检索，semantic_only 只走向量一路
+	return s.planHybrid
+}
+
+// usePlan 记录将要执行的计划；reason 非空表示从原计划降级而来
+// 同时改写 intent.Intent，使游标翻页时直接执行降级后的计划
+func usePlan(resp *types.SearchResponse, intent *types.SearchIntent, name, reason string) {
+	intent.Intent = name
+	resp.Plan = &types.ExecutionPlan{Intent: name, Steps: planSteps[name], Fallback: reason}
+	if reason != "" {
+		fmt.Printf(">>> [Plan] 降级到 %s: %s\n", name, reason)
+	}
+}
+
+// fallback 降级到其他计划执行
+func (s *RetrievalService) fallback(ctx context.Context, req types.SearchRequest, intent *types.SearchIntent, resp *types.SearchResponse, name, reason string) error {
+	usePlan(resp, intent, name, reason)
+	return s.plan(name)(ctx, req, nil, intent, resp)
+}
+
+// planHybrid hybrid / semantic_only：混合检索，支持翻页
+func (s *RetrievalService) planHybrid(ctx context.Context, req types.SearchRequest, cursor *types.Cursor, intent *types.SearchIntent, resp *types.SearchResponse) error {
+	next, err := s.searchHybrid(ctx, req, cursor, intent, resp)
+	if err != nil {
+		return err
+	}
+	resp.NextCursor = types.EncodeCursor(next, s.cursorKey)
+	return nil
+}
+
+// planAggregate aggregate：聚合统计，直接在 PG 上执行 (分组数有上限，不分页)
+func (s *RetrievalService) planAggregate(ctx context.Context, req types.SearchRequest, _ *types.Cursor, intent *types.SearchIntent, resp *types.SearchResponse) error {
+	answer, result, err := s.aggregate(ctx, req.Query, intent)
+	if err != nil {
+		return fmt.Errorf("统计失败: %v", err)
+	}
+	resp.Answer, resp.Aggregate = answer, result
+	return nil
+}
+
+// hasContractFilters 是否有能定位具体合同的过滤条件（条款类型本身不算）
+func hasContractFilters(filters *types.FilterConditions) bool {
+	return filters.HasPartyFilter() || filters.PartyA != "" || filters.PartyB != "" ||
+		filters.ContractType != "" || filters.DateRange != nil || filters.AmountRange != nil
+}
+
+// locateContracts 按结构化条件 (不含条款类型) 定位合同，最近签署的在前，最多返回 limit 份
+func (s *RetrievalService) locateContracts(ctx context.Context, filters *types.FilterConditions, limit int) ([]postgres.Contract, error) {
+	esDocIDs, ok, err := s.partyDocIDs(ctx, filters)
+	if err != nil || !ok {
+		return nil, err
+	}
+	conditions := *filters
+	conditions.ClauseType = ""
+	contracts, err := s.pgRepo.ListContracts(ctx, &conditions, esDocIDs, postgres.ContractPage{SortBy: types.SortSignDate, Desc: true, Limit: limit})
+	if err != nil {
+		return nil, fmt.Errorf("PG查询失败: %v", err)
+	}
+	fmt.Printf(">>> [Locate] 条件命中 %d 份合同\n", len(contracts))
+	return contracts, nil
+}
+
+// planClauseLookup clause_lookup：定位到少量合同时，直接从 contract_clauses 返回条款原文
+// 没定位到合同或命中过多时降级为 hybrid
+func (s *RetrievalService) planClauseLookup(ctx context.Context, req types.SearchRequest, _ *types.Cursor, intent *types.SearchIntent, resp *types.SearchResponse) error {
+	if !hasContractFilters(&intent.Filters) {
+		return s.fallback(ctx, req, intent, resp, vars.HY, "没有能定位合同的条件")
+	}
+	// 超过 DirectClauseDocs 份则认为没定位到具体合同，走混合检索
+	maxDocs := s.options().DirectClauseDocs
+	contracts, err := s.locateContracts(ctx, &intent.Filters, maxDocs+1)
+	if err != nil {
+		return err
+	}
+	if len(contracts) == 0 {
+		return s.fallback(ctx, req, intent, resp, vars.HY, "条件没有命中合同")
+	}
+	if len(contracts) > maxDocs {
+		return s.fallback(ctx, req, intent, resp, vars.HY, fmt.Sprintf("条件命中超过 %d 份合同", maxDocs))
+	}
+
+	var sb strings.Builder
+	for _, contract := range contracts {
+		clauses, err := s.pgRepo.ListClauses(ctx, contract.DocID, intent.Filters.ClauseType)
+		if err != nil {
+			return fmt.Errorf("条款查询失败: %v", err)
+		}
+		sb.WriteString(fmt.Sprintf("【%s】%s：\n", contract.FileName, clause.Label(intent.Filters.ClauseType)))
+		if len(clauses) == 0 {
+			sb.WriteString("  未找到该类条款\n")
+			continue
+		}
+		for _, c := range clauses {
+			sb.WriteString(fmt.Sprintf("  %s\n", c.Content))
+		}
+	}
+	resp.Answer = sb.String()
+	resp.Contracts = contractHits(contracts)
+	return nil
+}
+
+// maxChunksPerDoc 单合同问答时单份合同参与排序的最大切片数
+const maxChunksPerDoc = 2000
+
+// planContractQA contract_qa：先定位唯一一份合同，再在这份合同的全部切片中检索并由 LLM 作答 (不分页)
+// 命中多份合同时返回候选列表，由用户选定后带 doc_id 重新提问
+func (s *RetrievalService) planContractQA(ctx context.Context, req types.SearchRequest, _ *types.Cursor, intent *types.SearchIntent, resp *types.SearchResponse) error {
+	opts := s.options()
+	var contract *postgres.Contract
+	if req.DocID != "" {
+		c, err := s.pgRepo.GetByDocID(ctx, req.DocID)
+		if err != nil {
+			return fmt.Errorf("合同 %s 不存在", req.DocID)
+		}
+		contract = c
+	} else {
+		if !hasContractFilters(&intent.Filters) && intent.Contract == "" {
+			return s.fallback(ctx, req, intent, resp, vars.HY, "没有能定位合同的条件")
+		}
+		candidates, err := s.resolveContracts(ctx, intent, opts.QACandidates+1)
+		if err != nil {
+			return err
+		}
+		switch {
+		case len(candidates) == 0:
+			return s.fallback(ctx, req, intent, resp, vars.HY, "条件没有命中合同")
+		case len(candidates) > 1:
+			more := ""
+			if len(candidates) > opts.QACandidates {
+				candidates = candidates[:opts.QACandidates]
+				more = fmt.Sprintf("，仅列出最近签署的 %d 份", opts.QACandidates)
+			}
+			resp.Candidates = contractHits(candidates)
+			resp.Answer = fmt.Sprintf("找到多份符合条件的合同%s，请选择其中一份 (带上 doc_id 重新提问)：\n%s", more, formatCandidates(candidates))
+			return nil
+		}
+		contract = &candidates[0]
+	}
+	fmt.Printf(">>> [Contract QA] 定位到合同: %s (%s)\n", contract.FileName, contract.DocID)
+	if intent.SemanticQuery == "" {
+		intent.SemanticQuery = req.Query
+	}
+
+	chunks, err := s.rankContractChunks(ctx, contract.DocID, intent)
+	if err != nil {
+		return err
+	}
+	if len(chunks) > opts.QAContextChunks {
+		chunks = chunks[:opts.QAContextChunks]
+	}
+	resp.Contracts = contractHits([]postgres.Contract{*contract})
+	resp.Chunks = chunks
+
+	answerStart := time.Now()
+	answer, err := retrieval.AnswerFromChunks(ctx, req.Query, chunks, s.models.Answer)
+	if err != nil {
+		return fmt.Errorf("生成回答失败: %w", err)
+	}
+	fmt.Printf(">>> [性能] 生成回答耗时: %v\n", time.Since(answerStart))
+	resp.Answer = answer
+	return nil
+}
+
+// resolveContracts 按结构化条件和合同名称定位合同
+// 名称匹配不到时退回只用结构化条件，避免 LLM 提取的名称与文件名不一致导致找不到
+func (s *RetrievalService) resolveContracts(ctx context.Context, intent *types.SearchIntent, limit int) ([]postgres.Contract, error) {
+	esDocIDs, ok, err := s.partyDocIDs(ctx, &intent.Filters)
+	if err != nil || !ok {
+		return nil, err
+	}
+	conditions := intent.Filters
+	conditions.ClauseType = ""
+	contracts, err := s.pgRepo.ResolveContracts(ctx, &conditions, esDocIDs, intent.Contract, limit)
+	if err != nil {
+		return nil, fmt.Errorf("PG查询失败: %v", err)
+	}
+	if len(contracts) == 0 && intent.Contract != "" && hasContractFilters(&conditions) {
+		contracts, err = s.pgRepo.ResolveContracts(ctx, &conditions, esDocIDs, "", limit)
+		if err != nil {
+			return nil, fmt.Errorf("PG查询失败: %v", err)
+		}
+	}
+	fmt.Printf(">>> [Resolve] 名称 %q 和条件命中 %d 份合同\n", intent.Contract, len(contracts))
+	return contracts, nil
+}
+
+// rankContractChunks 对一份合同的全部切片做混合排序 (不是全局 top 10)
+// Milvus 和 ES 都按 doc_id 过滤，topK 取该合同的切片总数
+func (s *RetrievalService) rankContractChunks(ctx context.Context, docID string, intent *types.SearchIntent) ([]types.ChunkHit, error) {
+	total, err := es.CountByDoc(ctx, s.esClient, s.esIndex, docID)
+	if err != nil {
+		return nil, fmt.Errorf("ES 统计切片失败: %v", err)
+	}
+	if total == 0 {
+		return nil, nil
+	}
+	if total > maxChunksPerDoc {
+		total = maxChunksPerDoc
+	}
+
+	// 合同已经定位，不再按其他结构化条件过滤
+	scoped := &types.SearchIntent{
+		Intent:        vars.HY,
+		SemanticQuery: intent.SemanticQuery,
+		Keywords:      intent.Keywords,
+		DocIDs:        []string{docID},
+	}
+	milvusDocs, err := milvus.Retriever(ctx, s.milvusClient, s.index.Alias(), scoped.SemanticQuery, &milvus.Filter{DocIDs: scoped.DocIDs, SchemaVersion: s.index.SchemaVersion()}, s.index.QueryEmbedder(), total, 0)
+	if err != nil {
+		return nil, fmt.Errorf("Milvus 检索失败: %v", err)
+	}
+	esQuery := fmt.Sprintf("%s %s", scoped.SemanticQuery, strings.Join(scoped.Keywords, " "))
+	esDocs, _, err := es.Retriever(ctx, s.esClient, s.esIndex, esQuery, &es.Filter{DocIDs: scoped.DocIDs}, es.Page{Size: total})
+	if err != nil {
+		return nil, fmt.Errorf("ES 检索失败: %v", err)
+	}
+	fmt.Printf(">>> [Contract QA] 合同共 %d 个切片，Milvus %d 个，ES %d 个\n", total, len(milvusDocs), len(esDocs))
+
+	return chunkHits(score.HybridReranker(milvusDocs, esDocs, s.hybridRerankerConfig(milvusDocs, esDocs))), nil
+}
+
+// formatCandidates 候选合同列表，供用户选择
+func formatCandidates(contracts []postgres.Contract) string {
+	var sb strings.Builder
+	for i, c := range contracts {
+		signDate := "未知"
+		if c.SignDate != nil {
+			signDate = c.SignDate.Format("2006-01-02")
+		}
+		sb.WriteString(fmt.Sprintf("%d. %s (甲方 %s，乙方 %s，签署 %s，金额 %.2f) doc_id=%s\n", i+1, c.FileName, c.PartyA, c.PartyB, signDate, c.TotalAmount, c.DocID))
+	}
+	return sb.String()
+}
+
+// planCompare compare：定位两份以上合同，按条款类型对齐后生成差异表
+func (s *RetrievalService) planCompare(ctx context.Context, req types.SearchRequest, _ *types.Cursor, intent *types.SearchIntent, resp *types.SearchResponse) error {
+	maxContracts := s.options().CompareContracts
+	contracts, err := s.locateContracts(ctx, &intent.Filters, maxContracts+1)
+	if err != nil {
+		return err
+	}
+	if len(contracts) < 2 {
+		return s.fallback(ctx, req, intent, resp, vars.HY, fmt.Sprintf("比较至少需要两份合同，条件命中 %d 份", len(contracts)))
+	}
+	if len(contracts) > maxContracts {
+		return s.fallback(ctx, req, intent, resp, vars.HY, fmt.Sprintf("条件命中超过 %d 份合同", maxContracts))
+	}
+	result, err := s.compare(ctx, req.Query, intent, contracts, nil)
+	if err != nil {
+		return err
+	}
+	resp.Compare = result
+	resp.Contracts = result.Contracts
+	resp.Answer = result.Table
+	return nil
+}
+
+// contractHits 转换为响应中的合同列表
+func contractHits(contracts []postgres.Contract) []types.ContractHit {
+	hits := make([]types.ContractHit, len(contracts))
+	for i, c := range contracts {
+		hits[i] = types.ContractHit{
+			DocID:        c.DocID,
+			FileName:     c.FileName,
+			PartyA:       c.PartyA,
+			PartyB:       c.PartyB,
+			ContractType: c.ContractType,
+			Status:       c.ContractStatus,
+			SignDate:     c.SignDate,
+			EndDate:      c.EndDate,
+			TotalAmount:  c.TotalAmount,
+		}
+	}
+	return hits
+}
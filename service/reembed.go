@@ -0,0 +1,484 @@
+package service
+
+import (
+	"context"
+	"eino-demo/config"
+	"eino-demo/storage/cache"
+	"eino-demo/storage/es"
+	"eino-demo/storage/milvus"
+	"eino-demo/storage/postgres"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/components/embedding"
+	"github.com/cloudwego/eino/components/indexer"
+	"github.com/cloudwego/eino/schema"
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/milvus-io/milvus-sdk-go/v2/client"
+)
+
+// recallQueryRunes 召回自检时取切片开头多少字作为查询
+const recallQueryRunes = 200
+
+// builtIndex 构建完成的集合对应的 embedder 和 indexer，切换时直接复用
+type builtIndex struct {
+	query         embedding.Embedder
+	indexer       indexer.Indexer
+	schemaVersion int
+}
+
+// ReembedService 换向量模型时的蓝绿切换：
+//
+//	新建带版本的集合 -> 从 PG 切片原文重新向量化 -> 追平构建期间新入库的切片 -> 抽样校验召回 -> 切换别名
+//
+// 旧集合保留，切换后发现问题可回滚到上一个版本
+type ReembedService struct {
+	pgRepo       *postgres.ContractRepo
+	versionRepo  *postgres.EmbeddingVersionRepo
+	milvusClient client.Client
+	migrator     *milvus.Migrator
+	esClient     *elasticsearch.Client
+	index        *VectorIndex
+	factory      EmbedderFactory
+	cache        *cache.Cache
+	cfg          config.Reembed
+	esIndex      string
+
+	mu       sync.Mutex
+	building string // 正在构建的集合，同一时间只允许一个
+	built    map[string]*builtIndex
+}
+
+func NewReembedService(pgRepo *postgres.ContractRepo, versionRepo *postgres.EmbeddingVersionRepo, milvusClient client.Client, migrator *milvus.Migrator, esClient *elasticsearch.Client, index *VectorIndex, factory EmbedderFactory, c *cache.Cache, cfg *config.Config) *ReembedService {
+	return &ReembedService{
+		pgRepo:       pgRepo,
+		versionRepo:  versionRepo,
+		milvusClient: milvusClient,
+		migrator:     migrator,
+		esClient:     esClient,
+		index:        index,
+		factory:      factory,
+		cache:        c,
+		cfg:          cfg.Reembed,
+		esIndex:      cfg.ES.Index,
+		built:        make(map[string]*builtIndex),
+	}
+}
+
+// BootstrapEmbeddingVersion 启动时确定在用的集合：
+// 还没有版本记录时把 legacy 集合登记为在用版本；别名总是重新指向在用集合；上次没跑完的构建标记为失败
+func BootstrapEmbeddingVersion(ctx context.Context, versionRepo *postgres.EmbeddingVersionRepo, cli client.Client, alias, legacy, model string) (*postgres.EmbeddingVersion, error) {
+	versions, err := versionRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range versions {
+		if v.Status == postgres.EmbeddingStatusBuilding {
+			_ = versionRepo.Update(ctx, v.Collection, map[string]any{"status": postgres.EmbeddingStatusFailed, "error": "服务重启，构建中断"})
+		}
+	}
+
+	active, err := versionRepo.GetActive(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if active == nil {
+		now := time.Now()
+		active = &postgres.EmbeddingVersion{
+			Collection:  legacy,
+			Model:       model,
+			Status:      postgres.EmbeddingStatusActive,
+			ActivatedAt: &now,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		if err := versionRepo.Create(ctx, active); err != nil {
+			return nil, err
+		}
+		fmt.Printf(">>> [Reembed] 已登记初始向量集合 %s (%s)\n", legacy, model)
+	}
+	if err := ensureDim(ctx, versionRepo, cli, active); err != nil {
+		return nil, err
+	}
+	if err := milvus.PointAlias(ctx, cli, alias, active.Collection); err != nil {
+		return nil, err
+	}
+	return active, nil
+}
+
+// ensureDim 早于维度登记的版本从集合 schema 读取维度并补登记；集合还不存在时保持 0，由模型第一次输出决定
+func ensureDim(ctx context.Context, versionRepo *postgres.EmbeddingVersionRepo, cli client.Client, v *postgres.EmbeddingVersion) error {
+	if v.Dim > 0 {
+		return nil
+	}
+	dim, err := milvus.CollectionDim(ctx, cli, v.Collection)
+	if err != nil || dim == 0 {
+		return err
+	}
+	v.Dim = dim
+	return versionRepo.Update(ctx, v.Collection, map[string]any{"dim": dim})
+}
+
+// Start 用 model 新建集合并在后台重新向量化，返回新版本记录 (状态 building)
+func (s *ReembedService) Start(ctx context.Context, model string) (*postgres.EmbeddingVersion, error) {
+	if model == "" {
+		return nil, errors.New("模型名不能为空")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.building != "" {
+		return nil, fmt.Errorf("集合 %s 正在构建，请等待完成后再试", s.building)
+	}
+
+	now := time.Now()
+	v := &postgres.EmbeddingVersion{
+		Collection: versionedCollection(s.index.Alias(), model, now),
+		Model:      model,
+		Status:     postgres.EmbeddingStatusBuilding,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := s.versionRepo.Create(ctx, v); err != nil {
+		return nil, err
+	}
+	s.building = v.Collection
+	go s.build(context.WithoutCancel(ctx), v)
+	return v, nil
+}
+
+// collectionUnsafe Milvus 集合名只允许字母、数字和下划线
+var collectionUnsafe = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// versionedCollection 别名_模型名_时间戳，如 contract_collection_bge_m3_20250101120000
+func versionedCollection(alias, model string, now time.Time) string {
+	return fmt.Sprintf("%s_%s_%s", alias, collectionUnsafe.ReplaceAllString(model, "_"), now.Format("20060102150405"))
+}
+
+// build 后台构建，失败时记录错误并把版本标记为 failed
+func (s *ReembedService) build(ctx context.Context, v *postgres.EmbeddingVersion) {
+	defer func() {
+		s.mu.Lock()
+		s.building = ""
+		s.mu.Unlock()
+	}()
+	start := time.Now()
+	recall, err := s.buildCollection(ctx, v)
+	if err != nil {
+		fmt.Printf(">>> [Reembed] %s 构建失败: %v\n", v.Collection, err)
+		_ = s.versionRepo.Update(ctx, v.Collection, map[string]any{"status": postgres.EmbeddingStatusFailed, "error": err.Error()})
+		return
+	}
+	status, msg := postgres.EmbeddingStatusReady, ""
+	if recall < s.cfg.MinRecall {
+		status = postgres.EmbeddingStatusFailed
+		msg = fmt.Sprintf("召回率 %.2f 低于阈值 %.2f", recall, s.cfg.MinRecall)
+	}
+	_ = s.versionRepo.Update(ctx, v.Collection, map[string]any{"status": status, "error": msg})
+	fmt.Printf(">>> [Reembed] %s 构建完成，召回率 %.2f，状态 %s，耗时 %v\n", v.Collection, recall, status, time.Since(start))
+}
+
+// buildCollection 建集合、全量向量化、追平、抽样校验，返回召回率
+func (s *ReembedService) buildCollection(ctx context.Context, v *postgres.EmbeddingVersion) (float64, error) {
+	// 1. 早于切片表上线的合同只有 ES 里有切片原文，先回填
+	if n, err := s.backfillChunks(ctx); err != nil {
+		return 0, fmt.Errorf("从 ES 回填切片失败: %v", err)
+	} else if n > 0 {
+		fmt.Printf(">>> [Reembed] 从 ES 回填 %d 个切片\n", n)
+	}
+
+	// 2. 新模型的 embedder，建集合 (维度以新模型实际输出为准)
+	ingest, query, err := s.factory(ctx, v.Model, 0)
+	if err != nil {
+		return 0, fmt.Errorf("创建 embedder 失败: %v", err)
+	}
+	probe, err := ingest.EmbedStrings(ctx, []string{"test"})
+	if err != nil || len(probe) == 0 {
+		return 0, fmt.Errorf("模型 %s 不可用: %v", v.Model, err)
+	}
+	idx, schemaVersion, err := milvus.NewMilvusIndexerWithClient(ctx, s.milvusClient, ingest, v.Collection, s.migrator)
+	if err != nil {
+		return 0, err
+	}
+
+	// 3. 全量：快照时间之前入库的切片
+	snapshot := time.Now()
+	total, err := s.pgRepo.CountChunks(ctx, postgres.ChunkRange{Before: snapshot})
+	if err != nil {
+		return 0, err
+	}
+	if err := s.versionRepo.Update(ctx, v.Collection, map[string]any{"dim": len(probe[0]), "total_chunks": total}); err != nil {
+		return 0, err
+	}
+	done, err := s.copyChunks(ctx, v.Collection, idx, postgres.ChunkRange{Before: snapshot}, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	// 4. 追平构建期间新入库的切片
+	syncedAt := time.Now()
+	if _, err := s.copyChunks(ctx, v.Collection, idx, postgres.ChunkRange{Since: snapshot, Before: syncedAt}, done); err != nil {
+		return 0, err
+	}
+	if err := s.versionRepo.Update(ctx, v.Collection, map[string]any{"synced_at": syncedAt}); err != nil {
+		return 0, err
+	}
+
+	// 5. 抽样校验召回
+	recall, sampled, err := s.validateRecall(ctx, v.Collection, query, syncedAt)
+	if err != nil {
+		return 0, fmt.Errorf("召回校验失败: %v", err)
+	}
+	if err := s.versionRepo.Update(ctx, v.Collection, map[string]any{"recall": recall, "sample_size": sampled}); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	s.built[v.Collection] = &builtIndex{query: query, indexer: idx, schemaVersion: schemaVersion}
+	s.mu.Unlock()
+	return recall, nil
+}
+
+// backfillChunks 把 ES 里有、PG 切片表里没有的切片补进 PG (已存在的跳过)
+func (s *ReembedService) backfillChunks(ctx context.Context) (int, error) {
+	total := 0
+	var after []any
+	for {
+		batch, next, err := es.ScanChunks(ctx, s.esClient, s.esIndex, after, s.cfg.BatchSize)
+		if err != nil {
+			return total, err
+		}
+		rows := make([]*postgres.ContractChunk, 0, len(batch))
+		now := time.Now()
+		for _, c := range batch {
+			if c.ChunkID == "" || c.DocID == "" {
+				continue
+			}
+			rows = append(rows, &postgres.ContractChunk{
+				ID:         c.ChunkID,
+				DocID:      c.DocID,
+				ClauseType: c.ClauseType,
+				Content:    c.Content,
+				CreatedAt:  now,
+			})
+		}
+		if err := s.pgRepo.CreateChunks(ctx, rows, true); err != nil {
+			return total, err
+		}
+		total += len(rows)
+		if len(batch) < s.cfg.BatchSize || next == nil {
+			return total, nil
+		}
+		after = next
+	}
+}
+
+// copyChunks 分批读取范围内的切片，按合同当前的字段重建 metadata 后写入集合，done 为之前已完成的数量
+func (s *ReembedService) copyChunks(ctx context.Context, collection string, idx indexer.Indexer, rng postgres.ChunkRange, done int64) (int64, error) {
+	afterID := ""
+	for {
+		rows, err := s.pgRepo.ListChunks(ctx, rng, afterID, s.cfg.BatchSize)
+		if err != nil {
+			return done, err
+		}
+		if len(rows) == 0 {
+			return done, nil
+		}
+		chunks, err := s.buildChunkDocs(ctx, rows)
+		if err != nil {
+			return done, err
+		}
+		if len(chunks) > 0 {
+			if err := upsertChunks(ctx, s.milvusClient, collection, idx, chunks); err != nil {
+				return done, fmt.Errorf("写入 %s 失败: %v", collection, err)
+			}
+		}
+		done += int64(len(rows))
+		afterID = rows[len(rows)-1].ID
+		if err := s.versionRepo.Update(ctx, collection, map[string]any{"done_chunks": done}); err != nil {
+			return done, err
+		}
+		fmt.Printf(">>> [Reembed] %s 已完成 %d 个切片\n", collection, done)
+	}
+}
+
+// buildChunkDocs 切片原文 + 合同字段，组装成与入库时一致的 Document；合同已删除的切片跳过
+func (s *ReembedService) buildChunkDocs(ctx context.Context, rows []postgres.ContractChunk) ([]*schema.Document, error) {
+	docIDs := make([]string, 0, len(rows))
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		if !seen[row.DocID] {
+			seen[row.DocID] = true
+			docIDs = append(docIDs, row.DocID)
+		}
+	}
+	contracts, err := s.pgRepo.GetByDocIDs(ctx, docIDs)
+	if err != nil {
+		return nil, err
+	}
+	byDoc := make(map[string]*postgres.Contract, len(contracts))
+	for i := range contracts {
+		byDoc[contracts[i].DocID] = &contracts[i]
+	}
+	parties, err := s.pgRepo.ListPartiesByDocIDs(ctx, docIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := make([]*schema.Document, 0, len(rows))
+	for _, row := range rows {
+		c, ok := byDoc[row.DocID]
+		if !ok {
+			continue
+		}
+		var partyNames, partyIDs []string
+		for _, p := range parties[row.DocID] {
+			partyNames = append(partyNames, p.NormalizedName)
+			if p.PartyID != "" {
+				partyIDs = append(partyIDs, p.PartyID)
+			}
+		}
+		chunk := &schema.Document{ID: row.ID, Content: row.Content, MetaData: map[string]any{"clause_type": row.ClauseType}}
+		setChunkMetadata(chunk, c, partyNames, partyIDs)
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
+
+// validateRecall 抽样切片，用切片开头的原文在集合里检索，前 RecallTopK 条内出现该切片算命中
+func (s *ReembedService) validateRecall(ctx context.Context, collection string, query embedding.Embedder, before time.Time) (float64, int, error) {
+	samples, err := s.pgRepo.SampleChunks(ctx, postgres.ChunkRange{Before: before}, s.cfg.SampleSize)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(samples) == 0 {
+		return 1, 0, nil
+	}
+	hits := 0
+	for _, sample := range samples {
+		text := []rune(sample.Content)
+		if len(text) > recallQueryRunes {
+			text = text[:recallQueryRunes]
+		}
+		docs, err := milvus.SearchCollection(ctx, s.milvusClient, collection, string(text), query, s.cfg.RecallTopK)
+		if err != nil {
+			return 0, 0, err
+		}
+		for _, doc := range docs {
+			if doc.ID == sample.ID {
+				hits++
+				break
+			}
+		}
+	}
+	return float64(hits) / float64(len(samples)), len(samples), nil
+}
+
+// Activate 把检索别名切到 collection (状态须为 ready，或 retired 即回滚)
+// 持有 VectorIndex 写锁：先追平上次同步之后入库的切片，再切别名、换 embedder/indexer，期间入库批次等待
+func (s *ReembedService) Activate(ctx context.Context, collection string) (*postgres.EmbeddingVersion, error) {
+	v, err := s.versionRepo.Get(ctx, collection)
+	if err != nil {
+		return nil, fmt.Errorf("集合 %s 不存在: %v", collection, err)
+	}
+	if v.Status == postgres.EmbeddingStatusActive {
+		return v, nil
+	}
+	if v.Status != postgres.EmbeddingStatusReady && v.Status != postgres.EmbeddingStatusRetired {
+		return nil, fmt.Errorf("集合 %s 状态为 %s，不能切换", collection, v.Status)
+	}
+	built, err := s.open(ctx, v)
+	if err != nil {
+		return nil, err
+	}
+
+	// 上次同步之后 (构建完成后、或者下线期间) 入库的切片：大部分在加锁前追平，锁内只补最后一小段
+	var since time.Time
+	if v.SyncedAt != nil {
+		since = *v.SyncedAt
+	}
+	unlocked := time.Now()
+	done, err := s.copyChunks(ctx, collection, built.indexer, postgres.ChunkRange{Since: since, Before: unlocked}, v.DoneChunks)
+	if err != nil {
+		return nil, fmt.Errorf("追平切片失败: %v", err)
+	}
+
+	s.index.mu.Lock()
+	defer s.index.mu.Unlock()
+	prev := s.index.collection
+	now := time.Now()
+	if _, err := s.copyChunks(ctx, collection, built.indexer, postgres.ChunkRange{Since: unlocked, Before: now}, done); err != nil {
+		return nil, fmt.Errorf("追平切片失败: %v", err)
+	}
+	if err := s.milvusClient.LoadCollection(ctx, collection, false); err != nil {
+		return nil, fmt.Errorf("加载集合 %s 失败: %v", collection, err)
+	}
+	if err := milvus.PointAlias(ctx, s.milvusClient, s.index.Alias(), collection); err != nil {
+		return nil, err
+	}
+	if err := s.versionRepo.Activate(ctx, collection, now); err != nil {
+		// PG 状态没更新，别名切回去，保持两边一致
+		if rollbackErr := milvus.PointAlias(ctx, s.milvusClient, s.index.Alias(), prev); rollbackErr != nil {
+			fmt.Printf(">>> [Reembed] 别名回退到 %s 失败: %v\n", prev, rollbackErr)
+		}
+		return nil, err
+	}
+	_ = s.versionRepo.Update(ctx, collection, map[string]any{"synced_at": now})
+	// 旧集合从现在起不再接收新切片，回滚时从这个时间点开始追平
+	_ = s.versionRepo.Update(ctx, prev, map[string]any{"synced_at": now})
+	s.index.swap(collection, v.Model, built)
+	// 向量变了，检索结果缓存作废
+	s.cache.Invalidate(ctx)
+	fmt.Printf(">>> [Reembed] 检索别名已从 %s 切换到 %s (%s)\n", prev, collection, v.Model)
+	return s.versionRepo.Get(ctx, collection)
+}
+
+// Rollback 切回最近一次下线的版本
+func (s *ReembedService) Rollback(ctx context.Context) (*postgres.EmbeddingVersion, error) {
+	prev, err := s.versionRepo.LatestRetired(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if prev == nil {
+		return nil, errors.New("没有可回滚的版本")
+	}
+	return s.Activate(ctx, prev.Collection)
+}
+
+// open 取集合对应的 embedder 和 indexer：本进程构建的直接复用，否则 (重启后、回滚到旧集合) 按模型重新创建
+func (s *ReembedService) open(ctx context.Context, v *postgres.EmbeddingVersion) (*builtIndex, error) {
+	s.mu.Lock()
+	built, ok := s.built[v.Collection]
+	s.mu.Unlock()
+	if ok {
+		return built, nil
+	}
+	if err := ensureDim(ctx, s.versionRepo, s.milvusClient, v); err != nil {
+		return nil, err
+	}
+	ingest, query, err := s.factory(ctx, v.Model, v.Dim)
+	if err != nil {
+		return nil, fmt.Errorf("创建 embedder 失败: %v", err)
+	}
+	idx, schemaVersion, err := milvus.NewMilvusIndexerWithClient(ctx, s.milvusClient, ingest, v.Collection, s.migrator)
+	if err != nil {
+		return nil, err
+	}
+	built = &builtIndex{query: query, indexer: idx, schemaVersion: schemaVersion}
+	s.mu.Lock()
+	s.built[v.Collection] = built
+	s.mu.Unlock()
+	return built, nil
+}
+
+// Get 查询版本 (含构建进度)
+func (s *ReembedService) Get(ctx context.Context, collection string) (*postgres.EmbeddingVersion, error) {
+	return s.versionRepo.Get(ctx, collection)
+}
+
+// List 全部版本
+func (s *ReembedService) List(ctx context.Context) ([]postgres.EmbeddingVersion, error) {
+	return s.versionRepo.List(ctx)
+}
@@ -2,179 +2,459 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"eino-demo/config"
+	"eino-demo/logic/chat"
+	"eino-demo/logic/ingestion/clause"
 	"eino-demo/logic/ingestion/transform/score"
+	"eino-demo/logic/prompt"
 	"eino-demo/logic/retrieval"
+	"eino-demo/storage/cache"
 	"eino-demo/storage/es"
+	"eino-demo/storage/filter"
 	"eino-demo/storage/milvus"
 	"eino-demo/storage/postgres"
 	"eino-demo/types"
 	"eino-demo/vars"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
 
-	"github.com/cloudwego/eino/components/embedding"
-	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
 	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/google/uuid"
 	"github.com/milvus-io/milvus-sdk-go/v2/client"
 )
 
 type RetrievalService struct {
 	pgRepo       *postgres.ContractRepo
-	chatModel    model.ToolCallingChatModel
-	embedder     embedding.Embedder
+	partySvc     *PartyService
+	models       *chat.Models // 意图解析用 Intent，问答、总结、比较用 Answer
+	prompts      *prompt.Registry
+	examples     *IntentExampleService // 意图解析的相似示例
+	searchLogs   *postgres.SearchLogRepo
+	index        *VectorIndex // 检索 embedder 跟随当前向量集合的模型
 	milvusClient client.Client
 	esClient     *elasticsearch.Client
+	esIndex      string
+	cache        *cache.Cache
+	cfg          *config.Store // 检索参数支持热更新，每次请求读取最新值
+	cursorKey    []byte        // 分页游标的签名密钥
 }
 
-func NewRetrievalService(pgRepo *postgres.ContractRepo, chatModel model.ToolCallingChatModel, embedder embedding.Embedder, milvusClient client.Client, esClient *elasticsearch.Client) *RetrievalService {
+func NewRetrievalService(pgRepo *postgres.ContractRepo, partySvc *PartyService, models *chat.Models, prompts *prompt.Registry, examples *IntentExampleService, searchLogs *postgres.SearchLogRepo, index *VectorIndex, milvusClient client.Client, esClient *elasticsearch.Client, c *cache.Cache, cfg *config.Store) *RetrievalService {
 	return &RetrievalService{
 		pgRepo:       pgRepo,
-		chatModel:    chatModel,
-		embedder:     embedder,
+		partySvc:     partySvc,
+		models:       models,
+		prompts:      prompts,
+		examples:     examples,
+		searchLogs:   searchLogs,
+		index:        index,
 		milvusClient: milvusClient,
 		esClient:     esClient,
+		esIndex:      cfg.Get().ES.Index,
+		cache:        c,
+		cfg:          cfg,
+		cursorKey:    cursorKey(cfg.Get().Server.CursorKey),
 	}
 }
 
-// Search 意图识别 + 检索实现
-func (s *RetrievalService) Search(ctx context.Context, query string) (string, error) {
-	searchStart := time.Now()
+// cursorKey 没有配置密钥时随机生成，只在本进程内有效
+func cursorKey(configured string) []byte {
+	if configured != "" {
+		return []byte(configured)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("生成游标签名密钥失败: %v", err))
+	}
+	fmt.Println(">>> [Search] ⚠️ 未配置 server.cursor_key，游标签名密钥随机生成，重启或切换实例后翻页需从第一页重新查询")
+	return key
+}
 
-	analyzeQuery, err := retrieval.AnalyzeQuery(ctx, query, s.chatModel)
-	if err != nil {
-		return "无法分析用户输入", err
+// options 当前的检索参数
+func (s *RetrievalService) options() config.Retrieval {
+	return s.cfg.Get().Retrieval
+}
+
+// hybridRerankerConfig 按当前融合权重创建重排配置，本页结果全部保留
+func (s *RetrievalService) hybridRerankerConfig(milvusDocs, esDocs []*schema.Document) *score.HybridRerankerConfig {
+	opts := s.options()
+	return &score.HybridRerankerConfig{
+		MilvusWeight: opts.MilvusWeight,
+		ESWeight:     opts.ESWeight,
+		TopK:         len(milvusDocs) + len(esDocs),
 	}
-	fmt.Printf(">>> [Intent] %+v\n", analyzeQuery)
-	fmt.Printf(">>> [性能] 意图识别耗时: %v\n", time.Since(searchStart))
+}
 
-	// 根据意图分发
-	if analyzeQuery.Intent == vars.PG {
-		// structured_only: 结构化检索
-		var esDocIDs []string
-		var err error
+// Search 意图识别 + 检索实现，支持排序和游标分页
+// 首页调用 LLM 解析意图；翻页时沿用游标里的意图，只做检索。每次请求记录一条检索日志
+func (s *RetrievalService) Search(ctx context.Context, req types.SearchRequest) (*types.SearchResponse, error) {
+	entry := &postgres.SearchLog{ID: uuid.New().String(), Query: req.Query, CreatedAt: time.Now()}
+	resp, err := s.search(ctx, req, entry)
+	s.logSearch(entry, resp, err)
+	return resp, err
+}
 
-		// 1. 如果有公司名过滤，先用 ES 模糊匹配
-		if len(analyzeQuery.Filters.AnyParty) > 0 {
-			esStart := time.Now()
-			esDocIDs, err = es.SearchByParties(ctx, s.esClient, "contract_chunks_v1", analyzeQuery.Filters.AnyParty)
-			if err != nil {
-				return fmt.Sprintf("ES 查询失败: %v", err), err
-			}
-			fmt.Printf(">>> [ES Party Search] 找到 %d 个唯一文档, 耗时: %v\n", len(esDocIDs), time.Since(esStart))
-
-			// 如果 ES 没找到任何结果，直接返回
-			if len(esDocIDs) == 0 {
-				return "抱歉，没有找到符合条件的合同", nil
-			}
+// logSearch 异步写检索日志，写入失败不影响检索结果
+func (s *RetrievalService) logSearch(entry *postgres.SearchLog, resp *types.SearchResponse, err error) {
+	entry.LatencyMs = time.Since(entry.CreatedAt).Milliseconds()
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if resp != nil {
+		entry.Intent = resp.Intent
+		entry.Hits = len(resp.Contracts) + len(resp.Chunks)
+		if resp.Plan != nil {
+			entry.Fallback = resp.Plan.Fallback
 		}
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := s.searchLogs.Create(ctx, entry); err != nil {
+			fmt.Printf(">>> [SearchLog] 写入失败: %v\n", err)
+		}
+	}()
+}
+
+// search 检索主流程，entry 记录提示词版本、是否翻页和是否命中缓存
+func (s *RetrievalService) search(ctx context.Context, req types.SearchRequest, entry *postgres.SearchLog) (*types.SearchResponse, error) {
+	searchStart := time.Now()
+	req.Normalize()
+
+	cursor, err := types.DecodeCursor(req.Cursor, s.cursorKey, req.SortBy, req.Order)
+	if err != nil {
+		return nil, err
+	}
 
-		// 2. 用 PG 应用其他过滤条件（日期、金额、类型等）
-		pgStart := time.Now()
-		var docIDs []string
-		docIDs, err = s.pgRepo.SearchContracts(ctx, &analyzeQuery.Filters, esDocIDs)
-		fmt.Printf(">>> [PG Filter] 从 ES 结果中用其他条件筛选，找到 %d 份合同, 耗时: %v\n", len(docIDs), time.Since(pgStart))
+	var analyzeQuery *types.SearchIntent
+	if cursor != nil {
+		analyzeQuery = cursor.Intent
+		entry.Page = true
+		fmt.Printf(">>> [Intent] 沿用游标中的意图: %+v\n", analyzeQuery)
+	} else {
+		analyzeQuery, err = s.analyze(ctx, req.Query, req.PartyIDs)
 		if err != nil {
-			return "PG查询失败", err
-		}
-		if len(docIDs) == 0 {
-			return "抱歉，没有找到符合条件的合同", nil
+			return nil, err
 		}
+		fmt.Printf(">>> [性能] 意图识别耗时: %v\n", time.Since(searchStart))
+	}
+	entry.PromptVersion = analyzeQuery.PromptVersion
+	if parsed, err := json.Marshal(analyzeQuery); err == nil {
+		entry.Parsed = string(parsed)
+	}
+	// 用户从候选中选定了合同，按单合同问答执行
+	if req.DocID != "" {
+		analyzeQuery.Intent = vars.QA
+	}
+
+	// 意图和参与方已解析，相同过滤条件 + 查询 + 分页直接返回缓存结果
+	resultKey := resultCacheKey(req, analyzeQuery)
+	resp := &types.SearchResponse{}
+	if s.cache.GetJSON(ctx, cache.NSResult, resultKey, resp) {
+		entry.Cached = true
+		fmt.Printf(">>> [Cache] 命中结果缓存，总耗时: %v\n", time.Since(searchStart))
+		return resp, nil
+	}
+	resp.Intent = analyzeQuery.Intent
+	resp.Filters = &analyzeQuery.Filters
+	resp.PromptVersion = analyzeQuery.PromptVersion
+
+	// 根据意图选择执行计划
+	usePlan(resp, analyzeQuery, analyzeQuery.Intent, "")
+	if err := s.plan(analyzeQuery.Intent)(ctx, req, cursor, analyzeQuery, resp); err != nil {
+		return nil, err
+	}
+	s.cache.SetJSON(ctx, cache.NSResult, resultKey, resp, s.options().ResultCacheTTL)
+	fmt.Printf(">>> [性能总览] 执行计划 %s 总耗时: %v\n", resp.Plan.Intent, time.Since(searchStart))
+	return resp, nil
+}
+
+// resultCacheKey 结果缓存键：编译后的过滤条件 + 意图 + 查询与分页参数
+// 过滤条件用参与方归一之后的编译结果，别名不同但指向同一主体的查询共享缓存
+func resultCacheKey(req types.SearchRequest, intent *types.SearchIntent) string {
+	intentJSON, _ := json.Marshal(intent)
+	reqJSON, _ := json.Marshal(req)
+	return filter.FromConditions(&intent.Filters).String() + "\x00" + string(intentJSON) + "\x00" + string(reqJSON)
+}
+
+// analyze LLM 解析意图并归一参与方，partyIDs 为用户从候选中选定的主体
+func (s *RetrievalService) analyze(ctx context.Context, query string, partyIDs []string) (*types.SearchIntent, error) {
+	intent, err := s.analyzeCached(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("无法分析用户输入: %w", err)
+	}
+	fmt.Printf(">>> [Intent] %+v\n", intent)
+
+	// 参与方归一：any_party 解析为主体 ID 和全部别名，保证 PG/ES/Milvus 匹配口径一致
+	if err := s.partySvc.ResolveFilters(ctx, &intent.Filters, partyIDs); err != nil {
+		return nil, fmt.Errorf("参与方解析失败: %v", err)
+	}
+	// 兼容：能定位到合同的条款问题被识别为 hybrid 时，按条款直查执行
+	if intent.Intent == vars.HY && intent.Filters.ClauseType != "" && hasContractFilters(&intent.Filters) {
+		intent.Intent = vars.CL
+	}
+	return intent, nil
+}
+
+// analyzeCached 先按原文、再按归一化后的查询查意图缓存，都未命中才调用 LLM
+// 缓存的是 LLM 的原始输出，参与方归一每次重新执行，别名变更后立即生效
+// 提示词版本按归一化后的查询分流，和意图示例的版本一起写进缓存键，调整分流或纠正示例后不会拿到旧的结果
+func (s *RetrievalService) analyzeCached(ctx context.Context, query string) (*types.SearchIntent, error) {
+	normalized := cache.NormalizeQuery(query)
+	p, err := s.prompts.Pick(prompt.Intent, normalized)
+	if err != nil {
+		return nil, err
+	}
+	prefix := fmt.Sprintf("%s\x00%s\x00%d\x00", time.Now().Format("2006-01-02"), p.ID(), s.examples.Revision())
+	exactKey := prefix + query
+	normalizedKey := prefix + "n\x00" + normalized
+
+	intent := &types.SearchIntent{}
+	if s.cache.GetJSON(ctx, cache.NSIntent, exactKey, intent) {
+		fmt.Println(">>> [Cache] 命中意图缓存")
+		return intent, nil
+	}
+	if s.cache.GetJSON(ctx, cache.NSIntent, normalizedKey, intent) {
+		fmt.Println(">>> [Cache] 命中意图缓存 (归一化查询)")
+		s.cache.SetJSON(ctx, cache.NSIntent, exactKey, intent, s.options().IntentCacheTTL)
+		return intent, nil
+	}
+
+	intent, err = retrieval.AnalyzeQuery(ctx, query, s.models.Intent, p, s.examples.Select(ctx, query))
+	if err != nil {
+		return nil, err
+	}
+	// 兜底意图不缓存，下次同样的查询重新调用 LLM
+	if intent.Degraded {
+		fmt.Println(">>> [Cache] 意图解析降级为 hybrid，不写入缓存")
+		return intent, nil
+	}
+	s.cache.SetJSON(ctx, cache.NSIntent, exactKey, intent, s.options().IntentCacheTTL)
+	s.cache.SetJSON(ctx, cache.NSIntent, normalizedKey, intent, s.options().IntentCacheTTL)
+	return intent, nil
+}
+
+// partyDocIDs 有未能归一的公司名时，先用 ES 模糊匹配出全部 doc_id (已归一的主体直接由 PG 按 ID 过滤)
+// ok=false 表示有参与方条件但没有命中任何合同
+func (s *RetrievalService) partyDocIDs(ctx context.Context, filters *types.FilterConditions) ([]string, bool, error) {
+	if len(filters.AnyParty) == 0 {
+		return nil, true, nil
+	}
+	esStart := time.Now()
+	docIDs, err := es.SearchByParties(ctx, s.esClient, s.esIndex, filters)
+	if err != nil {
+		return nil, false, fmt.Errorf("ES 查询失败: %v", err)
+	}
+	fmt.Printf(">>> [ES Party Search] 找到 %d 个唯一文档, 耗时: %v\n", len(docIDs), time.Since(esStart))
+	return docIDs, len(docIDs) > 0, nil
+}
+
+// searchStructured 结构化检索：ES 按参与方取全部 doc_id，PG 按其他条件过滤并 keyset 分页
+func (s *RetrievalService) searchStructured(ctx context.Context, req types.SearchRequest, cursor *types.Cursor, intent *types.SearchIntent, resp *types.SearchResponse) error {
+	// 1. 如果有未能归一的公司名，先用 ES 模糊匹配
+	esDocIDs, ok, err := s.partyDocIDs(ctx, &intent.Filters)
+	if err != nil {
+		return err
+	}
+	// 如果 ES 没找到任何结果，直接返回
+	if !ok {
+		resp.Answer = "抱歉，没有找到符合条件的合同"
+		return nil
+	}
 
-		// 3. 批量查询 PG 获取完整合同信息
-		for _, id := range docIDs {
-			contract, err := s.pgRepo.GetByDocID(ctx, id)
-			if err != nil {
-				continue
-			}
-			fmt.Printf("  - %s (金额: %.2f)\n", contract.FileName, contract.TotalAmount)
+	// 2. 用 PG 应用其他过滤条件（日期、金额、类型等），多取一条判断是否还有下一页
+	page := postgres.ContractPage{SortBy: req.SortBy, Desc: req.Order == "desc", Limit: req.PageSize + 1}
+	if cursor != nil {
+		after, err := postgres.ParseSortValue(req.SortBy, cursor.After)
+		if err != nil {
+			return fmt.Errorf("cursor 无效: %v", err)
 		}
-		return fmt.Sprintf("根据条件，共找到 %d 份合同。", len(docIDs)), nil
+		page.After, page.AfterDocID = after, cursor.DocID
+	}
+	pgStart := time.Now()
+	contracts, err := s.pgRepo.ListContracts(ctx, &intent.Filters, esDocIDs, page)
+	if err != nil {
+		return fmt.Errorf("PG查询失败: %v", err)
+	}
+	fmt.Printf(">>> [PG Filter] 本页 %d 份合同, 耗时: %v\n", len(contracts), time.Since(pgStart))
+
+	hasMore := len(contracts) > req.PageSize
+	if hasMore {
+		contracts = contracts[:req.PageSize]
+	}
+	if len(contracts) == 0 {
+		resp.Answer = "抱歉，没有找到符合条件的合同"
+		return nil
+	}
 
+	// 3. 组装结果和下一页游标
+	resp.Contracts = contractHits(contracts)
+	if hasMore {
+		last := contracts[len(contracts)-1]
+		resp.NextCursor = types.EncodeCursor(&types.Cursor{
+			SortBy: req.SortBy,
+			Order:  req.Order,
+			Intent: intent,
+			After:  last.SortValue(req.SortBy),
+			DocID:  last.DocID,
+		}, s.cursorKey)
+		resp.Answer = fmt.Sprintf("根据条件，本页返回 %d 份合同，还有更多结果。", len(contracts))
 	} else {
-		// hybrid: Milvus + ES 混合检索
-		fmt.Println(">>> [Hybrid Search] 开始混合检索...")
+		resp.Answer = fmt.Sprintf("根据条件，本页返回 %d 份合同。", len(contracts))
+	}
+	return nil
+}
+
+// searchHybrid 混合检索：Milvus 按 offset、ES 按 search_after 各自翻页，融合后返回
+// intent.DocIDs 非空时只在这些合同内检索；semantic_only 只走 Milvus
+// 返回下一页游标，两路都取完时为 nil
+// 每页的 page_size 在两路之间平分，一路取完后另一路独占；同一片段可能在不同页被两路分别召回
+// 只支持按相关度排序，见 checkHybridSort
+func (s *RetrievalService) searchHybrid(ctx context.Context, req types.SearchRequest, cursor *types.Cursor, intent *types.SearchIntent, resp *types.SearchResponse) (*types.Cursor, error) {
+	if err := checkHybridSort(req.SortBy); err != nil {
+		return nil, err
+	}
+	fmt.Println(">>> [Hybrid Search] 开始混合检索...")
+	next := types.Cursor{SortBy: req.SortBy, Order: req.Order, Intent: intent}
+	if cursor != nil {
+		next.MilvusOffset, next.MilvusDone = cursor.MilvusOffset, cursor.MilvusDone
+		next.ESAfter, next.ESDone = cursor.ESAfter, cursor.ESDone
+	} else if intent.Intent == vars.ML {
+		// semantic_only 只走向量检索
+		next.ESDone = true
+	}
+
+	esSize := req.PageSize / 2
+	if esSize == 0 {
+		esSize = 1
+	}
+	milvusSize := req.PageSize - esSize
+	if next.MilvusDone {
+		esSize = req.PageSize
+	}
+	if next.ESDone {
+		milvusSize = req.PageSize
+	}
 
-		// 1. Milvus 向量检索
+	// 1. Milvus 向量检索
+	var milvusDocs []*schema.Document
+	if !next.MilvusDone && milvusSize > 0 {
 		milvusStart := time.Now()
-		milvusDocs, err := milvus.Retriever(ctx, s.milvusClient, analyzeQuery.SemanticQuery, &analyzeQuery.Filters, s.embedder)
+		var err error
+		milvusDocs, err = milvus.Retriever(ctx, s.milvusClient, s.index.Alias(), intent.SemanticQuery, &milvus.Filter{Conditions: &intent.Filters, DocIDs: intent.DocIDs, SchemaVersion: s.index.SchemaVersion()}, s.index.QueryEmbedder(), milvusSize, next.MilvusOffset)
 		if err != nil {
-			return "", fmt.Errorf("Milvus 检索失败: %v", err)
+			return nil, fmt.Errorf("Milvus 检索失败: %v", err)
 		}
+		next.MilvusOffset += len(milvusDocs)
+		next.MilvusDone = len(milvusDocs) < milvusSize
 		fmt.Printf(">>> [Milvus] 找到 %d 个结果, 耗时: %v\n", len(milvusDocs), time.Since(milvusStart))
+	}
 
-		// 2. ES 关键词检索
+	// 2. ES 关键词检索
+	var esDocs []*schema.Document
+	if !next.ESDone && esSize > 0 {
 		esStart := time.Now()
-		esFilters := s.convertFiltersToES(&analyzeQuery.Filters)
-		esQuery := fmt.Sprintf("%s %s", analyzeQuery.SemanticQuery, strings.Join(analyzeQuery.Keywords, " "))
-		esDocs, err := es.Retriever(ctx, s.esClient, "contract_chunks_v1", esQuery, esFilters, 10)
+		esFilters := &es.Filter{Conditions: &intent.Filters, DocIDs: intent.DocIDs}
+		esQuery := fmt.Sprintf("%s %s", intent.SemanticQuery, strings.Join(intent.Keywords, " "))
+		page := es.Page{Size: esSize, SortBy: req.SortBy, Desc: req.Order == "desc", After: next.ESAfter}
+		var after []any
+		var err error
+		esDocs, after, err = es.Retriever(ctx, s.esClient, s.esIndex, esQuery, esFilters, page)
 		if err != nil {
-			return "", fmt.Errorf("ES 检索失败: %v", err)
+			return nil, fmt.Errorf("ES 检索失败: %v", err)
+		}
+		if after != nil {
+			next.ESAfter = after
 		}
+		next.ESDone = len(esDocs) < esSize
 		fmt.Printf(">>> [ES] 找到 %d 个结果, 耗时: %v\n", len(esDocs), time.Since(esStart))
+	}
 
-		// 3. Reranker 合并两个结果集（归一化、去重、加权融合）
-		rerankStart := time.Now()
-		rerankedDocs := score.HybridReranker(milvusDocs, esDocs, nil)
-		fmt.Printf(">>> [性能] Reranker 融合耗时: %v\n", time.Since(rerankStart))
-
-		// 4. 打印最终结果
-		score.PrintRerankedResults(rerankedDocs)
+	// 3. Reranker 合并两个结果集（归一化、去重、加权融合），本页结果全部保留
+	rerankStart := time.Now()
+	rerankedDocs := score.HybridReranker(milvusDocs, esDocs, s.hybridRerankerConfig(milvusDocs, esDocs))
+	fmt.Printf(">>> [性能] Reranker 融合耗时: %v\n", time.Since(rerankStart))
 
-		// 5. 返回融合后的结果
-		totalTime := time.Since(searchStart)
-		fmt.Printf(">>> [性能总览] 检索总耗时: %v (意图识别: %.2f%%, Milvus: %.2f%%, ES: %.2f%%, Reranker: %.2f%%)\n",
-			totalTime,
-			float64(time.Since(searchStart)-totalTime)/float64(totalTime)*100, // 占位符，实际需要记录各阶段时间
-			0, 0, 0) // 简化显示
+	// 4. 打印最终结果
+	score.PrintRerankedResults(rerankedDocs)
 
-		return fmt.Sprintf("混合检索完成：融合后 %d 条结果，总耗时 %v", len(rerankedDocs), totalTime), nil
+	// 5. 组装结果和下一页游标
+	resp.Chunks = chunkHits(rerankedDocs)
+	resp.Answer = fmt.Sprintf("混合检索完成：本页融合后 %d 条结果", len(rerankedDocs))
+	if next.MilvusDone && next.ESDone {
+		return nil, nil
 	}
+	return &next, nil
 }
 
-// convertFiltersToES 将 types.FilterConditions 转换为 es.Filter
-func (s *RetrievalService) convertFiltersToES(filters *types.FilterConditions) *es.Filter {
-	if filters == nil {
-		return nil
+// chunkHits 转换为响应中的片段列表
+func chunkHits(docs []*score.RerankedDocument) []types.ChunkHit {
+	hits := make([]types.ChunkHit, len(docs))
+	for i, doc := range docs {
+		docID, _ := doc.MetaData["doc_id"].(string)
+		hits[i] = types.ChunkHit{
+			ID:       doc.ID,
+			DocID:    docID,
+			Content:  doc.Content,
+			Score:    doc.FinalScore,
+			Sources:  doc.Sources,
+			Metadata: doc.MetaData,
+		}
 	}
+	return hits
+}
 
-	esFilter := &es.Filter{
-		AnyParty:     filters.AnyParty, // 传递数组
-		PartyA:       filters.PartyA,
-		PartyB:       filters.PartyB,
-		ContractType: filters.ContractType,
+// checkHybridSort 混合检索只能按融合分数排序：Milvus 只能按向量相似度翻页，
+// 按合同字段排序时后一页可能出现应排在前一页的结果，导出的多页结果也不再有序，因此直接拒绝
+func checkHybridSort(sortBy string) error {
+	if sortBy != "" && sortBy != types.SortRelevance {
+		return fmt.Errorf("混合检索只支持按相关度排序，不支持 sort_by=%s；需要按签署日期、金额或到期日排序时请给出明确的筛选条件", sortBy)
 	}
+	return nil
+}
 
-	// 处理 contract_status
-	if filters.Status != "" {
-		status := 1 // 默认生效中
-		if filters.Status == "已过期" || filters.Status == "过期" {
-			status = 0
-		}
-		esFilter.ContractStatus = &status
+// aggregate 执行聚合统计，返回表格和 (可选的) 自然语言总结
+// 总结由 LLM 生成，失败时只返回表格
+func (s *RetrievalService) aggregate(ctx context.Context, query string, intent *types.SearchIntent) (string, *types.AggregateResult, error) {
+	spec := intent.Aggregation
+	if spec == nil {
+		spec = &types.AggregationSpec{Metric: types.MetricCount}
+	}
+	aggStart := time.Now()
+	result, err := s.pgRepo.Aggregate(ctx, &intent.Filters, *spec)
+	if err != nil {
+		return "", nil, err
 	}
+	fmt.Printf(">>> [Aggregate] metric=%s group_by=%v, %d 行, 耗时: %v\n", spec.Metric, spec.GroupBy, len(result.Rows), time.Since(aggStart))
 
-	// 处理日期范围
-	if filters.DateRange != nil {
-		if filters.DateRange.Start != "" {
-			if t, err := time.Parse("2006-01-02", filters.DateRange.Start); err == nil {
-				esFilter.SignDateStart = &t
-			}
-		}
-		if filters.DateRange.End != "" {
-			if t, err := time.Parse("2006-01-02", filters.DateRange.End); err == nil {
-				esFilter.SignDateEnd = &t
-			}
-		}
+	table := retrieval.FormatAggregate(result)
+	if len(result.Rows) == 0 {
+		return table, result, nil
+	}
+	summary, err := retrieval.SummarizeAggregate(ctx, query, result, s.models.Answer)
+	if err != nil {
+		fmt.Printf(">>> [Aggregate] 生成总结失败，仅返回表格: %v\n", err)
+		return table, result, nil
 	}
+	return fmt.Sprintf("%s\n\n%s", summary, table), result, nil
+}
 
-	// 处理金额范围
-	if filters.AmountRange != nil {
-		esFilter.AmountMin = filters.AmountRange.Min
-		esFilter.AmountMax = filters.AmountRange.Max
+// ListClauses 查询指定合同的条款，clauseType 为空返回全部；无法识别的类型报错，不能当作不限类型
+func (s *RetrievalService) ListClauses(ctx context.Context, docID string, clauseType string) ([]postgres.ContractClause, error) {
+	normalized := clause.Normalize(clauseType)
+	if normalized == "" && strings.TrimSpace(clauseType) != "" {
+		return nil, fmt.Errorf("无法识别的条款类型 %q，可选: %s", clauseType, strings.Join(clause.Types(), ", "))
 	}
+	return s.pgRepo.ListClauses(ctx, docID, normalized)
+}
 
-	return esFilter
+// ListParties 查询指定合同的全部参与方
+func (s *RetrievalService) ListParties(ctx context.Context, docID string) ([]postgres.ContractParty, error) {
+	return s.pgRepo.ListParties(ctx, docID)
 }
 
 // truncate 截断字符串
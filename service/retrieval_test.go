@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"eino-demo/config"
+	"eino-demo/logic/chat"
+	"eino-demo/logic/prompt"
+	"eino-demo/storage/cache"
+	"eino-demo/types"
+	"eino-demo/vars"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// scriptedModel 按顺序返回预设的回复，用完后重复最后一条
+type scriptedModel struct {
+	replies []string
+	calls   int
+}
+
+func (m *scriptedModel) Generate(context.Context, []*schema.Message, ...model.Option) (*schema.Message, error) {
+	reply := m.replies[min(m.calls, len(m.replies)-1)]
+	m.calls++
+	return schema.AssistantMessage(reply, nil), nil
+}
+
+func (m *scriptedModel) Stream(ctx context.Context, in []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	msg, err := m.Generate(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return schema.StreamReaderFromArray([]*schema.Message{msg}), nil
+}
+
+func (m *scriptedModel) WithTools([]*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+// LLM 返回无法解析的内容时降级为 hybrid，但不能写进意图缓存，否则同一查询在缓存有效期内一直用兜底意图
+func TestAnalyzeCachedSkipsDegradedIntent(t *testing.T) {
+	cfg := config.Default()
+	cfg.Retrieval.FewShotK = 0
+	store := config.NewStore("", cfg)
+	prompts, err := prompt.NewRegistry("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	llm := &scriptedModel{replies: []string{"抱歉，我无法理解", `{"intent": "structured_only", "filters": {"contract_type": "借款合同"}}`}}
+	s := &RetrievalService{
+		models:   &chat.Models{Intent: llm},
+		prompts:  prompts,
+		examples: NewIntentExampleService(nil, nil, nil, store),
+		cache:    cache.New(cache.NewLRU(100)),
+		cfg:      store,
+	}
+	ctx := context.Background()
+
+	intent, err := s.analyzeCached(ctx, "借款合同有哪些")
+	if err != nil || intent.Intent != vars.HY || !intent.Degraded {
+		t.Fatalf("第一次应降级为 hybrid: %+v, %v", intent, err)
+	}
+	// 原文和归一化查询都不能命中兜底意图
+	for _, query := range []string{"借款合同有哪些", "借款合同有哪些？"} {
+		intent, err = s.analyzeCached(ctx, query)
+		if err != nil || intent.Intent != vars.PG || intent.Filters.ContractType != "借款合同" {
+			t.Fatalf("%q 应重新调用 LLM: %+v, %v", query, intent, err)
+		}
+	}
+	if llm.calls != 2 {
+		t.Errorf("LLM 调用 %d 次，解析成功后的结果应命中缓存", llm.calls)
+	}
+}
+
+// 混合检索按字段排序时，Milvus 仍按向量相似度翻页，第二页可能出现应排在第一页的结果，必须在访问存储之前拒绝
+func TestHybridRejectsFieldSort(t *testing.T) {
+	s := &RetrievalService{cursorKey: []byte("test-key")}
+	intent := &types.SearchIntent{Intent: vars.HY, SemanticQuery: "付款条款"}
+	for _, sortBy := range []string{types.SortSignDate, types.SortAmount, types.SortEndDate} {
+		req := types.SearchRequest{Query: "付款条款", SortBy: sortBy}
+		req.Normalize()
+
+		// 第一页
+		if _, err := s.searchHybrid(context.Background(), req, nil, intent, &types.SearchResponse{}); err == nil || !strings.Contains(err.Error(), sortBy) {
+			t.Errorf("sort_by=%s 第一页应报错: %v", sortBy, err)
+		}
+
+		// 第二页：按相关度翻页得到的游标不能换成按字段排序继续翻
+		relevance := types.SearchRequest{Query: "付款条款"}
+		relevance.Normalize()
+		next := types.EncodeCursor(&types.Cursor{SortBy: relevance.SortBy, Order: relevance.Order, Intent: intent, MilvusOffset: 10}, s.cursorKey)
+		if _, err := types.DecodeCursor(next, s.cursorKey, req.SortBy, req.Order); err == nil {
+			t.Errorf("sort_by=%s 第二页不能沿用相关度排序的游标", sortBy)
+		}
+		// 即使游标里的排序字段一致，也在检索前拒绝
+		cursor := &types.Cursor{SortBy: req.SortBy, Order: req.Order, Intent: intent, MilvusOffset: 10}
+		if _, err := s.searchHybrid(context.Background(), req, cursor, intent, &types.SearchResponse{}); err == nil {
+			t.Errorf("sort_by=%s 第二页应报错", sortBy)
+		}
+
+		// 证据导出在调用 LLM 之前报错
+		err := s.ExportEvidence(context.Background(), types.ExportRequest{Query: "付款条款", SortBy: sortBy}, func(int, types.ChunkHit) error {
+			t.Fatal("不应导出任何片段")
+			return nil
+		})
+		if err == nil {
+			t.Errorf("sort_by=%s 证据导出应报错", sortBy)
+		}
+	}
+}
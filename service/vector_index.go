@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"eino-demo/storage/milvus"
+	"fmt"
+	"sync"
+
+	"github.com/cloudwego/eino/components/embedding"
+	"github.com/cloudwego/eino/components/indexer"
+	"github.com/cloudwego/eino/schema"
+	"github.com/milvus-io/milvus-sdk-go/v2/client"
+)
+
+// EmbedderFactory 按模型名创建向量化组件：ingest 用于入库 (含向量缓存)，query 用于检索 (含查询向量缓存)
+// dim 为集合的向量维度，返回的向量维度不一致时报错；0 表示以模型第一次输出为准
+type EmbedderFactory func(ctx context.Context, model string, dim int) (ingest, query embedding.Embedder, err error)
+
+// VectorIndex 当前在用的 Milvus 集合，以及与之配套的检索 embedder 和入库 indexer
+// 检索走别名 alias，查询向量必须与集合使用同一个模型，所以切换别名时三者一起换
+// 切换时持写锁：切换期间的入库批次等切换完成后直接写新集合
+type VectorIndex struct {
+	cli   client.Client
+	alias string
+
+	mu            sync.RWMutex
+	collection    string
+	model         string
+	schemaVersion int
+	query         embedding.Embedder
+	indexer       indexer.Indexer
+}
+
+func NewVectorIndex(cli client.Client, alias, collection, model string, schemaVersion int, query embedding.Embedder, idx indexer.Indexer) *VectorIndex {
+	return &VectorIndex{cli: cli, alias: alias, collection: collection, model: model, schemaVersion: schemaVersion, query: query, indexer: idx}
+}
+
+// Alias 检索使用的别名，始终指向当前集合
+func (v *VectorIndex) Alias() string {
+	return v.alias
+}
+
+// QueryEmbedder 与当前集合同一模型的检索 embedder
+func (v *VectorIndex) QueryEmbedder() embedding.Embedder {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.query
+}
+
+// Current 当前集合名和模型名
+func (v *VectorIndex) Current() (collection, model string) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.collection, v.model
+}
+
+// SchemaVersion 当前集合的 schema 版本，检索过滤按它决定能用哪些字段
+func (v *VectorIndex) SchemaVersion() int {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.schemaVersion
+}
+
+// Store 切片写入当前集合
+func (v *VectorIndex) Store(ctx context.Context, chunks []*schema.Document) error {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return upsertChunks(ctx, v.cli, v.collection, v.indexer, chunks)
+}
+
+// swap 切换到新集合，调用方持有写锁
+func (v *VectorIndex) swap(collection, model string, built *builtIndex) {
+	v.collection, v.model, v.schemaVersion, v.query, v.indexer = collection, model, built.schemaVersion, built.query, built.indexer
+}
+
+// upsertChunks 先按主键删除再写入，重复写同一切片 (重新向量化追平时可能发生) 不会产生重复记录
+func upsertChunks(ctx context.Context, cli client.Client, collection string, idx indexer.Indexer, chunks []*schema.Document) error {
+	ids := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		ids[i] = chunk.ID
+	}
+	if err := milvus.DeleteByIDs(ctx, cli, collection, ids); err != nil {
+		return fmt.Errorf("清理旧向量失败: %v", err)
+	}
+	_, err := idx.Store(ctx, chunks)
+	return err
+}
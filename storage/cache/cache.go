@@ -0,0 +1,157 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Backend 缓存存储，默认进程内 LRU，也可以换成 Redis 兼容的服务 (多实例部署时共享)
+type Backend interface {
+	// Get 未命中或已过期返回 ok=false
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set ttl<=0 表示不过期
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Incr 计数器自增，不存在时从 0 开始；计数器不参与淘汰
+	Incr(ctx context.Context, key string) (int64, error)
+}
+
+// 命名空间
+const (
+	// NSIntent LLM 解析出的意图，与库内数据无关，只按 TTL 过期
+	NSIntent = "intent"
+	// NSEmbedding 查询向量，与库内数据无关，只按 TTL 过期
+	NSEmbedding = "emb"
+	// NSResult 检索结果，合同入库/变更后整体失效
+	NSResult = "result"
+)
+
+// generationKey 数据版本号，NSResult 的键带上版本号，版本号自增即让旧结果全部失效
+const generationKey = "cache:generation"
+
+// Cache 在 Backend 上按命名空间存取，键统一做 sha256，避免超长查询和特殊字符
+// nil *Cache 的所有方法都是空操作，未启用缓存时调用方不需要判断
+type Cache struct {
+	backend Backend
+}
+
+func New(backend Backend) *Cache {
+	return &Cache{backend: backend}
+}
+
+// Get 读取原始字节；后端出错按未命中处理，缓存不影响主流程
+func (c *Cache) Get(ctx context.Context, ns, key string) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	fullKey, err := c.key(ctx, ns, key)
+	if err != nil {
+		fmt.Printf(">>> [Cache] 读取版本号失败: %v\n", err)
+		return nil, false
+	}
+	value, ok, err := c.backend.Get(ctx, fullKey)
+	if err != nil {
+		fmt.Printf(">>> [Cache] 读取 %s 失败: %v\n", ns, err)
+		return nil, false
+	}
+	return value, ok
+}
+
+// Set 写入原始字节，失败只打日志
+func (c *Cache) Set(ctx context.Context, ns, key string, value []byte, ttl time.Duration) {
+	if c == nil {
+		return
+	}
+	fullKey, err := c.key(ctx, ns, key)
+	if err != nil {
+		fmt.Printf(">>> [Cache] 读取版本号失败: %v\n", err)
+		return
+	}
+	if err := c.backend.Set(ctx, fullKey, value, ttl); err != nil {
+		fmt.Printf(">>> [Cache] 写入 %s 失败: %v\n", ns, err)
+	}
+}
+
+// GetJSON 读取并反序列化到 v，每次得到的都是新对象，调用方可以放心修改
+func (c *Cache) GetJSON(ctx context.Context, ns, key string, v any) bool {
+	value, ok := c.Get(ctx, ns, key)
+	if !ok {
+		return false
+	}
+	if err := json.Unmarshal(value, v); err != nil {
+		fmt.Printf(">>> [Cache] %s 反序列化失败: %v\n", ns, err)
+		return false
+	}
+	return true
+}
+
+// SetJSON 序列化后写入
+func (c *Cache) SetJSON(ctx context.Context, ns, key string, v any, ttl time.Duration) {
+	if c == nil {
+		return
+	}
+	value, err := json.Marshal(v)
+	if err != nil {
+		fmt.Printf(">>> [Cache] %s 序列化失败: %v\n", ns, err)
+		return
+	}
+	c.Set(ctx, ns, key, value, ttl)
+}
+
+// Invalidate 合同入库、参与方变更、合同过期后调用，使所有检索结果缓存失效
+// 旧条目不主动删除，由 LRU 淘汰或 TTL 过期
+func (c *Cache) Invalidate(ctx context.Context) {
+	if c == nil {
+		return
+	}
+	gen, err := c.backend.Incr(ctx, generationKey)
+	if err != nil {
+		fmt.Printf(">>> [Cache] 结果缓存失效失败: %v\n", err)
+		return
+	}
+	fmt.Printf(">>> [Cache] 结果缓存已失效，当前数据版本 %d\n", gen)
+}
+
+// key ns:[版本号:]sha256(key)
+func (c *Cache) key(ctx context.Context, ns, key string) (string, error) {
+	sum := sha256.Sum256([]byte(key))
+	hash := hex.EncodeToString(sum[:])
+	if ns != NSResult {
+		return ns + ":" + hash, nil
+	}
+	gen, ok, err := c.backend.Get(ctx, generationKey)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		gen = []byte("0")
+	}
+	return ns + ":" + string(gen) + ":" + hash, nil
+}
+
+// NormalizeQuery 归一查询文本，让只差大小写、空白、全半角和句末标点的问题命中同一条缓存
+// "  查询 ABC公司 的合同？" 与 "查询abc公司的合同" 归一后相同
+func NormalizeQuery(query string) string {
+	var sb strings.Builder
+	for _, r := range strings.TrimSpace(query) {
+		// 全角字符转半角
+		switch {
+		case r == '　':
+			r = ' '
+		case r >= '！' && r <= '～':
+			r -= 0xFEE0
+		}
+		if unicode.IsSpace(r) {
+			continue
+		}
+		sb.WriteRune(unicode.ToLower(r))
+	}
+	return strings.TrimRightFunc(sb.String(), func(r rune) bool {
+		return unicode.IsPunct(r)
+	})
+}
@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DiskStore 向量存到本地目录：<dir>/<模型名>/<hash 前两位>/<hash>
+// 适合单机部署，不依赖数据库；写入先写临时文件再 rename，避免读到半个文件
+type DiskStore struct {
+	dir string
+}
+
+func NewDiskStore(dir string) (*DiskStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &DiskStore{dir: dir}, nil
+}
+
+func (d *DiskStore) GetVectors(_ context.Context, model string, hashes []string) (map[string][]byte, error) {
+	vectors := make(map[string][]byte, len(hashes))
+	for _, hash := range hashes {
+		value, err := os.ReadFile(d.path(model, hash))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return vectors, err
+		}
+		vectors[hash] = value
+	}
+	return vectors, nil
+}
+
+func (d *DiskStore) SaveVectors(_ context.Context, model string, vectors map[string][]byte) error {
+	for hash, value := range vectors {
+		path := d.path(model, hash)
+		if _, err := os.Stat(path); err == nil {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		tmp, err := os.CreateTemp(filepath.Dir(path), hash+".*.tmp")
+		if err != nil {
+			return err
+		}
+		_, err = tmp.Write(value)
+		if closeErr := tmp.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(tmp.Name(), path)
+		}
+		if err != nil {
+			_ = os.Remove(tmp.Name())
+			return err
+		}
+	}
+	return nil
+}
+
+// path 模型名里的 ":" "/" (如 qwen2.5:7b) 替换掉，避免生成多级或非法目录
+func (d *DiskStore) path(model, hash string) string {
+	safeModel := strings.NewReplacer("/", "_", ":", "_", "\\", "_").Replace(model)
+	return filepath.Join(d.dir, safeModel, hash[:2], hash)
+}
@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"time"
+
+	"github.com/cloudwego/eino/components/embedding"
+)
+
+// EmbeddingTTL 查询向量的缓存时长；模型不变时向量不会变，过期只是为了回收空间
+const EmbeddingTTL = 7 * 24 * time.Hour
+
+// QueryEmbedder 包装检索用的 embedder，相同查询文本不再重复向量化
+// 键包含模型名，换模型后不会读到旧维度的向量
+type QueryEmbedder struct {
+	inner embedding.Embedder
+	cache *Cache
+	model string
+}
+
+func NewQueryEmbedder(inner embedding.Embedder, cache *Cache, model string) *QueryEmbedder {
+	return &QueryEmbedder{inner: inner, cache: cache, model: model}
+}
+
+// EmbedStrings 先查缓存，只把未命中的文本交给原始 embedder
+func (e *QueryEmbedder) EmbedStrings(ctx context.Context, texts []string, opts ...embedding.Option) ([][]float64, error) {
+	vectors := make([][]float64, len(texts))
+	var missIdx []int
+	var missTexts []string
+	for i, text := range texts {
+		if value, ok := e.cache.Get(ctx, NSEmbedding, e.model+"\x00"+text); ok && len(value)%8 == 0 {
+			vectors[i] = decodeVector(value)
+			continue
+		}
+		missIdx = append(missIdx, i)
+		missTexts = append(missTexts, text)
+	}
+	if len(missTexts) == 0 {
+		return vectors, nil
+	}
+
+	embedded, err := e.inner.EmbedStrings(ctx, missTexts, opts...)
+	if err != nil {
+		return nil, err
+	}
+	for j, vec := range embedded {
+		if j >= len(missIdx) {
+			break
+		}
+		vectors[missIdx[j]] = vec
+		e.cache.Set(ctx, NSEmbedding, e.model+"\x00"+missTexts[j], encodeVector(vec), EmbeddingTTL)
+	}
+	return vectors, nil
+}
+
+// encodeVector 向量按 float64 小端序存储，比 JSON 紧凑且无精度损失
+func encodeVector(vec []float64) []byte {
+	buf := make([]byte, 8*len(vec))
+	for i, v := range vec {
+		binary.LittleEndian.PutUint64(buf[8*i:], math.Float64bits(v))
+	}
+	return buf
+}
+
+func decodeVector(buf []byte) []float64 {
+	vec := make([]float64, len(buf)/8)
+	for i := range vec {
+		vec[i] = math.Float64frombits(binary.LittleEndian.Uint64(buf[8*i:]))
+	}
+	return vec
+}
@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/cloudwego/eino/components/embedding"
+)
+
+// VectorStore 向量的持久化存储，键为 (模型名, 文本 sha256)，值为 encodeVector 编码后的字节
+// 实现：postgres.EmbeddingCacheRepo (PG)、DiskStore (本地磁盘)
+type VectorStore interface {
+	// GetVectors 批量查询，未命中的 hash 不出现在结果中
+	GetVectors(ctx context.Context, model string, hashes []string) (map[string][]byte, error)
+	// SaveVectors 批量写入，已存在的键保持不变
+	SaveVectors(ctx context.Context, model string, vectors map[string][]byte) error
+}
+
+// EmbeddingStats 向量缓存命中统计 (进程启动以来，按文本条数计)
+type EmbeddingStats struct {
+	Model   string  `json:"model"`
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	HitRate float64 `json:"hit_rate"`
+}
+
+// EmbeddingCache 内容寻址的向量缓存，可包装任意 embedding.Embedder
+// 入库时语义切分器和 Milvus 索引器共用同一个实例：重新入库、重新切分时相同文本不再重复向量化
+type EmbeddingCache struct {
+	inner  embedding.Embedder
+	store  VectorStore
+	model  string
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+func NewEmbeddingCache(inner embedding.Embedder, store VectorStore, model string) *EmbeddingCache {
+	return &EmbeddingCache{inner: inner, store: store, model: model}
+}
+
+// TextHash 文本的 sha256，作为缓存键
+func TextHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// EmbedStrings 批量查缓存，只把未命中的文本交给原始 embedder，结果回写存储
+// 存储读写失败不影响向量化，只按未命中处理
+func (e *EmbeddingCache) EmbedStrings(ctx context.Context, texts []string, opts ...embedding.Option) ([][]float64, error) {
+	hashes := make([]string, len(texts))
+	for i, text := range texts {
+		hashes[i] = TextHash(text)
+	}
+	cached, err := e.store.GetVectors(ctx, e.model, hashes)
+	if err != nil {
+		fmt.Printf(">>> [EmbeddingCache] 读取缓存失败: %v\n", err)
+		cached = nil
+	}
+
+	vectors := make([][]float64, len(texts))
+	// 同一批里的重复文本只向量化一次
+	missByHash := make(map[string][]int)
+	var missTexts, missHashes []string
+	var hits int64
+	for i, hash := range hashes {
+		if value, ok := cached[hash]; ok && len(value) > 0 && len(value)%8 == 0 {
+			vectors[i] = decodeVector(value)
+			hits++
+			continue
+		}
+		if _, ok := missByHash[hash]; !ok {
+			missTexts = append(missTexts, texts[i])
+			missHashes = append(missHashes, hash)
+		}
+		missByHash[hash] = append(missByHash[hash], i)
+	}
+	e.hits.Add(hits)
+	e.misses.Add(int64(len(texts)) - hits)
+	fmt.Printf(">>> [EmbeddingCache] %d 条文本，命中 %d，需向量化 %d\n", len(texts), hits, len(missTexts))
+	if len(missTexts) == 0 {
+		return vectors, nil
+	}
+
+	embedded, err := e.inner.EmbedStrings(ctx, missTexts, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if len(embedded) != len(missTexts) {
+		return nil, fmt.Errorf("embedder 返回 %d 个向量，期望 %d 个", len(embedded), len(missTexts))
+	}
+	toSave := make(map[string][]byte, len(embedded))
+	for j, vec := range embedded {
+		for _, i := range missByHash[missHashes[j]] {
+			vectors[i] = vec
+		}
+		toSave[missHashes[j]] = encodeVector(vec)
+	}
+	if err := e.store.SaveVectors(ctx, e.model, toSave); err != nil {
+		fmt.Printf(">>> [EmbeddingCache] 写入缓存失败: %v\n", err)
+	}
+	return vectors, nil
+}
+
+// Stats 当前命中统计；未启用缓存 (nil) 时返回零值
+func (e *EmbeddingCache) Stats() EmbeddingStats {
+	if e == nil {
+		return EmbeddingStats{}
+	}
+	stats := EmbeddingStats{Model: e.model, Hits: e.hits.Load(), Misses: e.misses.Load()}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(total)
+	}
+	return stats
+}
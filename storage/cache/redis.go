@@ -0,0 +1,209 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Redis Redis 兼容服务 (Redis/KeyDB/Dragonfly 等) 的最小 RESP 客户端，只实现缓存用到的 GET/SET/INCR
+// 连接放在固定大小的池里复用，出错的连接直接丢弃
+type Redis struct {
+	addr     string
+	password string
+	db       int
+	timeout  time.Duration
+	pool     chan *redisConn
+}
+
+type redisConn struct {
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+const (
+	redisPoolSize = 16
+	redisTimeout  = 3 * time.Second
+)
+
+func NewRedis(addr, password string, db int) *Redis {
+	return &Redis{
+		addr:     addr,
+		password: password,
+		db:       db,
+		timeout:  redisTimeout,
+		pool:     make(chan *redisConn, redisPoolSize),
+	}
+}
+
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reply, err := r.do(ctx, "GET", key)
+	if err != nil {
+		return nil, false, err
+	}
+	if reply == nil {
+		return nil, false, nil
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("GET 返回了非字符串: %v", reply)
+	}
+	return value, true, nil
+}
+
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", key, string(value)}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	}
+	_, err := r.do(ctx, args...)
+	return err
+}
+
+func (r *Redis) Incr(ctx context.Context, key string) (int64, error) {
+	reply, err := r.do(ctx, "INCR", key)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("INCR 返回了非整数: %v", reply)
+	}
+	return n, nil
+}
+
+// do 发送一条命令并读取回复
+func (r *Redis) do(ctx context.Context, args ...string) (any, error) {
+	c, err := r.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(r.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = c.conn.SetDeadline(deadline)
+
+	reply, err := c.command(args...)
+	var redisErr redisError
+	if err != nil && !errors.As(err, &redisErr) {
+		// 网络错误，连接状态未知，丢弃
+		_ = c.conn.Close()
+		return nil, err
+	}
+	r.put(c)
+	return reply, err
+}
+
+// get 从池里取连接，没有空闲连接时新建并完成 AUTH/SELECT
+func (r *Redis) get(ctx context.Context) (*redisConn, error) {
+	select {
+	case c := <-r.pool:
+		return c, nil
+	default:
+	}
+	dialer := net.Dialer{Timeout: r.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", r.addr)
+	if err != nil {
+		return nil, fmt.Errorf("连接 Redis 失败: %v", err)
+	}
+	c := &redisConn{conn: conn, rd: bufio.NewReader(conn)}
+	_ = conn.SetDeadline(time.Now().Add(r.timeout))
+	if r.password != "" {
+		if _, err := c.command("AUTH", r.password); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("Redis 认证失败: %v", err)
+		}
+	}
+	if r.db != 0 {
+		if _, err := c.command("SELECT", strconv.Itoa(r.db)); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("Redis 切换 DB 失败: %v", err)
+		}
+	}
+	return c, nil
+}
+
+// put 连接放回池，池满时关闭
+func (r *Redis) put(c *redisConn) {
+	select {
+	case r.pool <- c:
+	default:
+		_ = c.conn.Close()
+	}
+}
+
+// redisError 服务端返回的 -ERR，连接仍然可用
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+// command 按 RESP 数组格式写出命令：*<n>\r\n$<len>\r\n<arg>\r\n...
+func (c *redisConn) command(args ...string) (any, error) {
+	var sb strings.Builder
+	sb.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		sb.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n" + arg + "\r\n")
+	}
+	if _, err := c.conn.Write([]byte(sb.String())); err != nil {
+		return nil, err
+	}
+	return c.readReply()
+}
+
+// readReply 解析一条回复：+简单字符串 -错误 :整数 $字符串 *数组
+func (c *redisConn) readReply() (any, error) {
+	line, err := c.rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("Redis 回复为空")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.rd, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = c.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("无法解析的 Redis 回复: %q", line)
+}
@@ -23,7 +23,8 @@ func (e *ESIndexer) GetClient() *elasticsearch.Client {
 	return e.client
 }
 
-// NewESIndexer 初始化 ES 客户端并确保索引存在
+// NewESIndexer 初始化 ES 客户端并确保别名和索引存在，indexName 为读写别名
+// mapping 与期望不一致时只告警，需要执行 esindex reindex
 func NewESIndexer(addresses []string, indexName string) (*ESIndexer, error) {
 	cfg := elasticsearch.Config{
 		Addresses: addresses,
@@ -35,80 +36,28 @@ func NewESIndexer(addresses []string, indexName string) (*ESIndexer, error) {
 
 	indexer := &ESIndexer{client: es, index: indexName}
 
-	// 初始化索引 Mapping (定义字段类型)
-	if err := indexer.initMapping(context.Background()); err != nil {
+	// 初始化别名和索引 Mapping (定义字段类型)，并校验线上 mapping
+	if _, err := NewIndexManager(es, indexName).Ensure(context.Background()); err != nil {
 		return nil, err
 	}
 
 	return indexer, nil
 }
 
-func (e *ESIndexer) initMapping(ctx context.Context) error {
-	// 1. 检查索引是否存在
-	res, err := e.client.Indices.Exists([]string{e.index})
-	if err != nil {
-		return err
-	}
-	if res.StatusCode == 200 {
-		return nil // 已存在，跳过
-	}
-
-	// 2. 定义 Mapping (核心：配置 ik_max_word)
-	mapping := `
-	{
-	  "settings": {
-		"number_of_shards": 1,
-		"number_of_replicas": 0
-	  },
-	  "mappings": {
-		"properties": {
-		  "doc_id":    { "type": "keyword" },
-		  "chunk_id":  { "type": "keyword" },
-		  "content": {
-			"type": "text",
-			"analyzer": "ik_max_word",
-			"search_analyzer": "ik_smart"
-		  },
-		  "keywords":  { "type": "keyword" },
-		  "party_a": {
-			"type": "text",
-			"analyzer": "ik_max_word",
-			"fields": {
-			  "keyword": { "type": "keyword" }
-			}
-		  },
-		  "party_b": {
-			"type": "text",
-			"analyzer": "ik_max_word",
-			"fields": {
-			  "keyword": { "type": "keyword" }
-			}
-		  },
-		  "sign_date":       { "type": "date" },
-		  "end_date":        { "type": "date" },
-		  "amount":          { "type": "double" },
-		  "contract_type":   { "type": "keyword" },
-		  "contract_status": { "type": "short" }
-		}
-	  }
-	}`
-
-	log.Printf(">>> [ES] Creating index %s with IK analyzer...", e.index)
-	res, err = e.client.Indices.Create(
-		e.index,
-		e.client.Indices.Create.WithBody(strings.NewReader(mapping)),
-	)
-	if err != nil {
-		return fmt.Errorf("create index error: %v", err)
-	}
-	if res.IsError() {
-		return fmt.Errorf("create index response error: %s", res.String())
-	}
-	return nil
+// IndexDoc 一个合同的全部切片，keywords 为 LLM 提取的关键词，写到每个切片上
+type IndexDoc struct {
+	DocID    string
+	Chunks   []*schema.Document
+	Keywords []string
 }
 
 // Store 批量存储
 func (e *ESIndexer) Store(ctx context.Context, docID string, chunks []*schema.Document, keywords []string) error {
+	return e.StoreDocs(ctx, []IndexDoc{{DocID: docID, Chunks: chunks, Keywords: keywords}})
+}
+
+// StoreDocs 多个合同的切片合并为一次 bulk 写入，任一切片写入失败返回错误
+func (e *ESIndexer) StoreDocs(ctx context.Context, docs []IndexDoc) error {
 	bi, err := esutil.NewBulkIndexer(esutil.BulkIndexerConfig{
 		Index:         e.index,
 		Client:        e.client,
@@ -118,61 +67,70 @@ func (e *ESIndexer) Store(ctx context.Context, docID string, chunks []*schema.Do
 		return err
 	}
 
-	for _, chunk := range chunks {
-		// 构造数据
-		docModel := map[string]interface{}{
-			"doc_id":          docID,
-			"chunk_id":        chunk.ID,
-			"content":         chunk.Content,
-			"keywords":        keywords, // LLM 提取出的关键词列表
-			"party_a":         chunk.MetaData["party_a"],
-			"party_b":         chunk.MetaData["party_b"],
-			"sign_date":       chunk.MetaData["sign_date"],
-			"end_date":        chunk.MetaData["end_date"],
-			"amount":          chunk.MetaData["amount"],
-			"contract_type":   chunk.MetaData["contract_type"],
-			"contract_status": chunk.MetaData["contract_status"],
-		}
+	for _, doc := range docs {
+		docID, keywords := doc.DocID, doc.Keywords
+		for _, chunk := range doc.Chunks {
+			// 构造数据
+			docModel := map[string]interface{}{
+				"doc_id":          docID,
+				"chunk_id":        chunk.ID,
+				"content":         chunk.Content,
+				"keywords":        keywords, // LLM 提取出的关键词列表
+				"party_a":         chunk.MetaData["party_a"],
+				"party_b":         chunk.MetaData["party_b"],
+				"parties":         chunk.MetaData["parties"], // 全部参与方 (含担保人、签署代表)
+				"party_ids":       chunk.MetaData["party_ids"],
+				"sign_date":       chunk.MetaData["sign_date"],
+				"end_date":        chunk.MetaData["end_date"],
+				"amount":          chunk.MetaData["amount"],
+				"contract_type":   chunk.MetaData["contract_type"],
+				"contract_status": chunk.MetaData["contract_status"],
+				"clause_type":     chunk.MetaData["clause_type"],
+			}
 
-		// 提取结构化字段（如果存在）
-		if val, ok := chunk.MetaData["party_a"]; ok {
-			docModel["party_a"] = val
-		}
-		if val, ok := chunk.MetaData["party_b"]; ok {
-			docModel["party_b"] = val
-		}
-		if val, ok := chunk.MetaData["sign_date"]; ok {
-			docModel["sign_date"] = val
-		}
-		if val, ok := chunk.MetaData["end_date"]; ok {
-			docModel["end_date"] = val
-		}
-		if val, ok := chunk.MetaData["amount"]; ok {
-			docModel["amount"] = val
-		}
-		if val, ok := chunk.MetaData["contract_type"]; ok {
-			docModel["contract_type"] = val
-		}
-		if val, ok := chunk.MetaData["contract_status"]; ok {
-			docModel["contract_status"] = val
-		}
+			// 提取结构化字段（如果存在）
+			if val, ok := chunk.MetaData["party_a"]; ok {
+				docModel["party_a"] = val
+			}
+			if val, ok := chunk.MetaData["party_b"]; ok {
+				docModel["party_b"] = val
+			}
+			if val, ok := chunk.MetaData["sign_date"]; ok {
+				docModel["sign_date"] = val
+			}
+			if val, ok := chunk.MetaData["end_date"]; ok {
+				docModel["end_date"] = val
+			}
+			if val, ok := chunk.MetaData["amount"]; ok {
+				docModel["amount"] = val
+			}
+			if val, ok := chunk.MetaData["contract_type"]; ok {
+				docModel["contract_type"] = val
+			}
+			if val, ok := chunk.MetaData["contract_status"]; ok {
+				docModel["contract_status"] = val
+			}
 
-		data, _ := json.Marshal(docModel)
+			data, _ := json.Marshal(docModel)
 
-		// 加入批量队列
-		err = bi.Add(ctx, esutil.BulkIndexerItem{
-			Action:     "index",
-			DocumentID: chunk.ID, // 使用 ChunkID 作为 ES 的 _id，避免重复
-			Body:       strings.NewReader(string(data)),
-		})
-		if err != nil {
-			return err
+			// 加入批量队列
+			err = bi.Add(ctx, esutil.BulkIndexerItem{
+				Action:     "index",
+				DocumentID: chunk.ID, // 使用 ChunkID 作为 ES 的 _id，避免重复
+				Body:       strings.NewReader(string(data)),
+			})
+			if err != nil {
+				return err
+			}
 		}
 	}
 
 	if err := bi.Close(ctx); err != nil {
 		return err
 	}
+	if stats := bi.Stats(); stats.NumFailed > 0 {
+		return fmt.Errorf("ES bulk 写入失败 %d 条", stats.NumFailed)
+	}
 	return nil
 }
 
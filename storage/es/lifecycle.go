@@ -0,0 +1,534 @@
+package es
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// MappingVersion 当前 mapping 的版本，修改 indexBody 后加一，再执行 esindex reindex 迁移数据
+const MappingVersion = 2
+
+// legacyIndex 引入别名之前写死的索引名，启动时自动挂到别名上
+const legacyIndex = "contract_chunks_v1"
+
+// indexBody 切片索引的 settings 和 mapping，_meta.version 记录 mapping 版本
+func indexBody() map[string]any {
+	partyText := map[string]any{
+		"type":     "text",
+		"analyzer": "ik_max_word",
+		"fields": map[string]any{
+			"keyword": map[string]any{"type": "keyword"},
+		},
+	}
+	return map[string]any{
+		"settings": map[string]any{
+			"number_of_shards":   1,
+			"number_of_replicas": 0,
+		},
+		"mappings": map[string]any{
+			"_meta": map[string]any{"version": MappingVersion},
+			"properties": map[string]any{
+				"doc_id":   map[string]any{"type": "keyword"},
+				"chunk_id": map[string]any{"type": "keyword"},
+				"content": map[string]any{
+					"type":            "text",
+					"analyzer":        "ik_max_word",
+					"search_analyzer": "ik_smart",
+				},
+				"keywords":        map[string]any{"type": "keyword"},
+				"party_a":         partyText,
+				"party_b":         partyText,
+				"parties":         partyText,
+				"party_ids":       map[string]any{"type": "keyword"},
+				"sign_date":       map[string]any{"type": "date"},
+				"end_date":        map[string]any{"type": "date"},
+				"amount":          map[string]any{"type": "double"},
+				"contract_type":   map[string]any{"type": "keyword"},
+				"contract_status": map[string]any{"type": "short"},
+				"clause_type":     map[string]any{"type": "keyword"},
+			},
+		},
+	}
+}
+
+// IndexManager 切片索引的生命周期：读写都走别名，物理索引按 mapping 版本命名
+//
+//	contract_chunks (别名) -> contract_chunks_v2 / contract_chunks_v3_20250101120000 ...
+type IndexManager struct {
+	client *elasticsearch.Client
+	alias  string
+}
+
+func NewIndexManager(client *elasticsearch.Client, alias string) *IndexManager {
+	return &IndexManager{client: client, alias: alias}
+}
+
+// MappingStatus 别名当前指向的索引及其 mapping 与期望的差异
+type MappingStatus struct {
+	Alias           string   `json:"alias"`
+	Index           string   `json:"index"`
+	LiveVersion     int      `json:"live_version"`
+	ExpectedVersion int      `json:"expected_version"`
+	Problems        []string `json:"problems,omitempty"`
+}
+
+// OK mapping 与期望一致
+func (s *MappingStatus) OK() bool {
+	return len(s.Problems) == 0
+}
+
+// ReindexResult 一次重建的结果
+type ReindexResult struct {
+	From       string `json:"from"`
+	To         string `json:"to"`
+	Copied     int64  `json:"copied"`      // 第一轮复制的文档数
+	CaughtUp   int64  `json:"caught_up"`   // 补齐时新增或覆盖的文档数 (复制期间新增、修改的)
+	Removed    int64  `json:"removed"`     // 补齐时从新索引删除的文档数 (复制期间删除的)
+	OldDeleted bool   `json:"old_deleted"` // 旧索引是否已删除
+}
+
+// Ensure 启动时调用：别名不存在时挂到旧索引上或新建索引，然后校验 mapping，不一致只告警不阻止启动
+func (m *IndexManager) Ensure(ctx context.Context) (*MappingStatus, error) {
+	current, err := m.Current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if current == "" {
+		exists, err := m.exists(ctx, legacyIndex)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			log.Printf(">>> [ES] 为旧索引 %s 创建别名 %s", legacyIndex, m.alias)
+			if err := m.swapAlias(ctx, "", legacyIndex); err != nil {
+				return nil, err
+			}
+		} else {
+			index := fmt.Sprintf("%s_v%d", m.alias, MappingVersion)
+			if err := m.createIndex(ctx, index); err != nil {
+				return nil, err
+			}
+			if err := m.swapAlias(ctx, "", index); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	status, err := m.Check(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if status.OK() {
+		log.Printf(">>> [ES] 别名 %s -> %s，mapping v%d", status.Alias, status.Index, status.LiveVersion)
+	} else {
+		log.Printf("⚠️ [ES] %s 的 mapping 与期望 (v%d) 不一致，请执行 esindex reindex:\n  %s",
+			status.Index, status.ExpectedVersion, strings.Join(status.Problems, "\n  "))
+	}
+	return status, nil
+}
+
+// Current 别名当前指向的索引，别名不存在时返回空串
+func (m *IndexManager) Current(ctx context.Context) (string, error) {
+	res, err := m.client.Indices.GetAlias(
+		m.client.Indices.GetAlias.WithContext(ctx),
+		m.client.Indices.GetAlias.WithName(m.alias),
+	)
+	if err != nil {
+		return "", fmt.Errorf("查询别名失败: %v", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode == 404 {
+		return "", nil
+	}
+	var result map[string]any
+	if err := decodeResponse(res, &result); err != nil {
+		return "", err
+	}
+	indices := make([]string, 0, len(result))
+	for index := range result {
+		indices = append(indices, index)
+	}
+	if len(indices) != 1 {
+		sort.Strings(indices)
+		return "", fmt.Errorf("别名 %s 指向了 %d 个索引: %v", m.alias, len(indices), indices)
+	}
+	return indices[0], nil
+}
+
+// Check 比较别名指向的索引的 mapping 和 indexBody
+func (m *IndexManager) Check(ctx context.Context) (*MappingStatus, error) {
+	index, err := m.Current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if index == "" {
+		return nil, fmt.Errorf("别名 %s 不存在", m.alias)
+	}
+	res, err := m.client.Indices.GetMapping(
+		m.client.Indices.GetMapping.WithContext(ctx),
+		m.client.Indices.GetMapping.WithIndex(index),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("查询 mapping 失败: %v", err)
+	}
+	defer res.Body.Close()
+	var result map[string]struct {
+		Mappings map[string]any `json:"mappings"`
+	}
+	if err := decodeResponse(res, &result); err != nil {
+		return nil, err
+	}
+
+	live := result[index].Mappings
+	status := &MappingStatus{Alias: m.alias, Index: index, ExpectedVersion: MappingVersion}
+	if meta, ok := live["_meta"].(map[string]any); ok {
+		if v, ok := meta["version"].(float64); ok {
+			status.LiveVersion = int(v)
+		}
+	}
+	if status.LiveVersion != MappingVersion {
+		status.Problems = append(status.Problems, fmt.Sprintf("mapping 版本为 v%d，期望 v%d", status.LiveVersion, MappingVersion))
+	}
+	expected := indexBody()["mappings"].(map[string]any)["properties"].(map[string]any)
+	liveProps, _ := live["properties"].(map[string]any)
+	status.Problems = append(status.Problems, diffMapping("", expected, liveProps)...)
+	return status, nil
+}
+
+// diffMapping 期望的每个设置在线上 mapping 中都要存在且相同 (线上多出的字段不算差异)
+func diffMapping(prefix string, expected, live map[string]any) []string {
+	keys := make([]string, 0, len(expected))
+	for k := range expected {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var problems []string
+	for _, k := range keys {
+		path := k
+		if prefix != "" {
+			path = prefix + "." + k
+		}
+		lv, ok := live[k]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("缺少 %s", path))
+			continue
+		}
+		if ev, ok := expected[k].(map[string]any); ok {
+			lm, _ := lv.(map[string]any)
+			problems = append(problems, diffMapping(path, ev, lm)...)
+			continue
+		}
+		if fmt.Sprint(expected[k]) != fmt.Sprint(lv) {
+			problems = append(problems, fmt.Sprintf("%s 为 %v，期望 %v", path, lv, expected[k]))
+		}
+	}
+	return problems
+}
+
+// Reindex 零停机重建：按当前 mapping 新建索引 -> _reindex 复制数据 -> 旧索引只读 -> 补齐复制期间的写入和删除 -> 原子切换别名 -> 删除旧索引
+// 第一轮复制期间读写照常走旧索引；从禁止写入到切换别名这段时间写入会失败 (入库任务报错，重试即可)，
+// 这样补齐时新索引不会有其他写入，补齐结果就是旧索引的最终状态。keepOld 为 true 时保留旧索引 (恢复可写) 以便回退
+func (m *IndexManager) Reindex(ctx context.Context, keepOld bool) (*ReindexResult, error) {
+	from, err := m.Current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if from == "" {
+		return nil, fmt.Errorf("别名 %s 不存在", m.alias)
+	}
+	to := fmt.Sprintf("%s_v%d_%s", m.alias, MappingVersion, time.Now().Format("20060102150405"))
+	result := &ReindexResult{From: from, To: to}
+
+	if err := m.createIndex(ctx, to); err != nil {
+		return nil, err
+	}
+	log.Printf(">>> [ES] 开始复制 %s -> %s", from, to)
+	if result.Copied, err = m.copyDocs(ctx, from, to); err != nil {
+		_ = m.deleteIndex(ctx, to)
+		return nil, fmt.Errorf("复制数据失败，已删除新索引: %v", err)
+	}
+
+	log.Printf(">>> [ES] 暂停 %s 的写入，补齐复制期间的变更", from)
+	if err := m.setWriteBlock(ctx, from, true); err != nil {
+		_ = m.deleteIndex(ctx, to)
+		return nil, fmt.Errorf("暂停旧索引写入失败，已删除新索引: %v", err)
+	}
+	if err := m.catchUp(ctx, from, to, result); err != nil {
+		_ = m.setWriteBlock(ctx, from, false)
+		_ = m.deleteIndex(ctx, to)
+		return nil, fmt.Errorf("补齐变更失败，已删除新索引: %v", err)
+	}
+	if err := m.swapAlias(ctx, from, to); err != nil {
+		_ = m.setWriteBlock(ctx, from, false)
+		_ = m.deleteIndex(ctx, to)
+		return nil, err
+	}
+	log.Printf(">>> [ES] 别名 %s 已切换到 %s", m.alias, to)
+
+	if keepOld {
+		if err := m.setWriteBlock(ctx, from, false); err != nil {
+			return result, fmt.Errorf("别名已切换，但恢复旧索引 %s 写入失败: %v", from, err)
+		}
+	} else {
+		if err := m.deleteIndex(ctx, from); err != nil {
+			return result, fmt.Errorf("删除旧索引 %s 失败: %v", from, err)
+		}
+		result.OldDeleted = true
+	}
+	log.Printf(">>> [ES] 重建完成: %s -> %s，复制 %d 条，补齐 %d 条，删除 %d 条",
+		from, to, result.Copied, result.CaughtUp, result.Removed)
+	return result, nil
+}
+
+// catchUp 旧索引只读后补齐第一轮复制期间的变更：新增和修改过的文档再复制一次，已从旧索引删除的文档从新索引删除
+func (m *IndexManager) catchUp(ctx context.Context, from, to string, result *ReindexResult) error {
+	var err error
+	if result.CaughtUp, err = m.copyDocs(ctx, from, to); err != nil {
+		return err
+	}
+	result.Removed, err = m.removeDeleted(ctx, from, to)
+	return err
+}
+
+// copyDocs 用 _reindex 复制文档，返回新建和覆盖的文档数
+// 目标索引沿用源文档的 _version (version_type external)：只有源文档版本更高 (新增或复制后又修改过) 才写入，
+// 版本相同的跳过，所以补齐时只重写有变化的文档；切片 _id 每次入库重新生成，不会出现删除后以更低版本重建的情况
+func (m *IndexManager) copyDocs(ctx context.Context, from, to string) (int64, error) {
+	body := map[string]any{
+		"conflicts": "proceed",
+		"source":    map[string]any{"index": from},
+		"dest":      map[string]any{"index": to, "version_type": "external"},
+	}
+	data, _ := json.Marshal(body)
+	res, err := m.client.Reindex(strings.NewReader(string(data)),
+		m.client.Reindex.WithContext(ctx),
+		m.client.Reindex.WithWaitForCompletion(true),
+		m.client.Reindex.WithRefresh(true),
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	var result struct {
+		Created  int64 `json:"created"`
+		Updated  int64 `json:"updated"`
+		Failures []any `json:"failures"`
+	}
+	if err := decodeResponse(res, &result); err != nil {
+		return 0, err
+	}
+	if len(result.Failures) > 0 {
+		return result.Created + result.Updated, fmt.Errorf("%d 条文档复制失败: %v", len(result.Failures), result.Failures[0])
+	}
+	return result.Created + result.Updated, nil
+}
+
+// reconcileBatch 比对删除时每批读取的文档数
+const reconcileBatch = 1000
+
+// removeDeleted 按 chunk_id 分批读取新索引的 _id，到旧索引中查是否还在，不在的从新索引删除
+func (m *IndexManager) removeDeleted(ctx context.Context, from, to string) (int64, error) {
+	var removed int64
+	var after []any
+	for {
+		ids, next, err := m.scanIDs(ctx, to, map[string]any{"match_all": map[string]any{}}, after, reconcileBatch)
+		if err != nil {
+			return removed, err
+		}
+		if len(ids) > 0 {
+			existing, _, err := m.scanIDs(ctx, from, map[string]any{"ids": map[string]any{"values": ids}}, nil, len(ids))
+			if err != nil {
+				return removed, err
+			}
+			kept := make(map[string]bool, len(existing))
+			for _, id := range existing {
+				kept[id] = true
+			}
+			var missing []string
+			for _, id := range ids {
+				if !kept[id] {
+					missing = append(missing, id)
+				}
+			}
+			n, err := m.deleteIDs(ctx, to, missing)
+			removed += n
+			if err != nil {
+				return removed, err
+			}
+		}
+		if len(ids) < reconcileBatch {
+			return removed, nil
+		}
+		after = next
+	}
+}
+
+// scanIDs 按 chunk_id 排序读取一批文档的 _id，after 为上一批返回的 sort 值
+func (m *IndexManager) scanIDs(ctx context.Context, index string, query map[string]any, after []any, size int) ([]string, []any, error) {
+	body := map[string]any{
+		"query":   query,
+		"sort":    []any{map[string]any{"chunk_id": "asc"}},
+		"size":    size,
+		"_source": false,
+	}
+	if after != nil {
+		body["search_after"] = after
+	}
+	data, _ := json.Marshal(body)
+	res, err := m.client.Search(
+		m.client.Search.WithContext(ctx),
+		m.client.Search.WithIndex(index),
+		m.client.Search.WithBody(strings.NewReader(string(data))),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+	var result struct {
+		Hits struct {
+			Hits []struct {
+				ID   string `json:"_id"`
+				Sort []any  `json:"sort"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := decodeResponse(res, &result); err != nil {
+		return nil, nil, err
+	}
+	ids := make([]string, 0, len(result.Hits.Hits))
+	var last []any
+	for _, hit := range result.Hits.Hits {
+		ids = append(ids, hit.ID)
+		last = hit.Sort
+	}
+	return ids, last, nil
+}
+
+// deleteIDs 用 bulk 删除文档，返回删除的条数
+func (m *IndexManager) deleteIDs(ctx context.Context, index string, ids []string) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	var sb strings.Builder
+	for _, id := range ids {
+		action, _ := json.Marshal(map[string]any{"delete": map[string]any{"_index": index, "_id": id}})
+		sb.Write(action)
+		sb.WriteByte('\n')
+	}
+	res, err := m.client.Bulk(strings.NewReader(sb.String()),
+		m.client.Bulk.WithContext(ctx),
+		m.client.Bulk.WithRefresh("true"),
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	var result struct {
+		Items []map[string]struct {
+			Result string `json:"result"`
+			Error  any    `json:"error"`
+		} `json:"items"`
+	}
+	if err := decodeResponse(res, &result); err != nil {
+		return 0, err
+	}
+	var deleted int64
+	for _, item := range result.Items {
+		for _, r := range item {
+			if r.Error != nil {
+				return deleted, fmt.Errorf("删除文档失败: %v", r.Error)
+			}
+			if r.Result == "deleted" {
+				deleted++
+			}
+		}
+	}
+	return deleted, nil
+}
+
+// setWriteBlock 设置索引的 index.blocks.write，禁止写入时读请求不受影响
+func (m *IndexManager) setWriteBlock(ctx context.Context, index string, block bool) error {
+	data, _ := json.Marshal(map[string]any{"index": map[string]any{"blocks": map[string]any{"write": block}}})
+	res, err := m.client.Indices.PutSettings(strings.NewReader(string(data)),
+		m.client.Indices.PutSettings.WithContext(ctx),
+		m.client.Indices.PutSettings.WithIndex(index),
+	)
+	if err != nil {
+		return fmt.Errorf("修改索引 %s 设置失败: %v", index, err)
+	}
+	defer res.Body.Close()
+	return decodeResponse(res, nil)
+}
+
+// swapAlias 一次 _aliases 请求内移除旧索引、挂上新索引，对读写方是原子的；from 为空时只添加
+func (m *IndexManager) swapAlias(ctx context.Context, from, to string) error {
+	var actions []any
+	if from != "" {
+		actions = append(actions, map[string]any{"remove": map[string]any{"index": from, "alias": m.alias}})
+	}
+	actions = append(actions, map[string]any{"add": map[string]any{"index": to, "alias": m.alias, "is_write_index": true}})
+	data, _ := json.Marshal(map[string]any{"actions": actions})
+	res, err := m.client.Indices.UpdateAliases(strings.NewReader(string(data)), m.client.Indices.UpdateAliases.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("切换别名失败: %v", err)
+	}
+	defer res.Body.Close()
+	return decodeResponse(res, nil)
+}
+
+func (m *IndexManager) createIndex(ctx context.Context, index string) error {
+	data, _ := json.Marshal(indexBody())
+	log.Printf(">>> [ES] Creating index %s (mapping v%d) with IK analyzer...", index, MappingVersion)
+	res, err := m.client.Indices.Create(index,
+		m.client.Indices.Create.WithContext(ctx),
+		m.client.Indices.Create.WithBody(strings.NewReader(string(data))),
+	)
+	if err != nil {
+		return fmt.Errorf("create index error: %v", err)
+	}
+	defer res.Body.Close()
+	return decodeResponse(res, nil)
+}
+
+func (m *IndexManager) deleteIndex(ctx context.Context, index string) error {
+	res, err := m.client.Indices.Delete([]string{index}, m.client.Indices.Delete.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	return decodeResponse(res, nil)
+}
+
+func (m *IndexManager) exists(ctx context.Context, index string) (bool, error) {
+	res, err := m.client.Indices.Exists([]string{index}, m.client.Indices.Exists.WithContext(ctx))
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	return res.StatusCode == 200, nil
+}
+
+// decodeResponse 错误响应转为 error，out 不为 nil 时解析响应体
+func decodeResponse(res *esapi.Response, out any) error {
+	if res.IsError() {
+		body, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("ES 返回错误 %s: %s", res.Status(), body)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("error parsing response body: %s", err)
+	}
+	return nil
+}
@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"log"
 	"strings"
-	"time"
+
+	"eino-demo/storage/filter"
+	"eino-demo/types"
 
 	"github.com/cloudwego/eino/schema"
 	"github.com/elastic/go-elasticsearch/v8"
@@ -15,77 +17,50 @@ import (
 
 // Filter ES 检索的过滤条件
 type Filter struct {
-	AnyParty       []string   // 参与方过滤（支持多个实体，匹配 party_a 或 party_b）
-	PartyA         string     // 甲方过滤
-	PartyB         string     // 乙方过滤
-	ContractType   string     // 合同类型过滤
-	ContractStatus *int       // 合同状态过滤（0=过期, 1=生效）
-	SignDateStart  *time.Time // 签约日期起始
-	SignDateEnd    *time.Time // 签约日期截止
-	EndDateStart   *time.Time // 截止日期起始
-	EndDateEnd     *time.Time // 截止日期截止
-	AmountMin      *float64   // 金额最小值
-	AmountMax      *float64   // 金额最大值
-	DocIDs         []string   // 文档 ID 列表（用于混合检索时限定范围）
+	Conditions *types.FilterConditions // 结构化条件，由 filter 包统一编译
+	DocIDs     []string                // 文档 ID 列表（用于混合检索时限定范围）
+}
+
+// Page ES 分页参数，使用 search_after 翻页
+type Page struct {
+	Size   int
+	SortBy string // relevance(默认), sign_date, amount, end_date
+	Desc   bool
+	After  []any // 上一页最后一条的 sort 值，nil 表示首页
 }
 
+// partyBatchSize 参与方检索每批拉取的 doc_id 数
+const partyBatchSize = 500
+
 // Retrieve 执行 ES 检索
 // query: 关键词查询语句（用于 BM25）
 // filters: 可选的过滤条件（nil 表示无过滤）
-// topK: 返回结果数量
-func Retriever(ctx context.Context, client *elasticsearch.Client, index string, query string, filters *Filter, topK int) ([]*schema.Document, error) {
+// page: 分页和排序，返回本页最后一条的 sort 值，作为下一页的 After
+func Retriever(ctx context.Context, client *elasticsearch.Client, index string, query string, filters *Filter, page Page) ([]*schema.Document, []any, error) {
 
 	// 1. 构建查询语句
-	esQuery := buildESQuery(query, filters, topK)
-
-	// 2. 序列化查询
-	var buf strings.Builder
-	if err := json.NewEncoder(&buf).Encode(esQuery); err != nil {
-		return nil, fmt.Errorf("error encoding query: %s", err)
-	}
-
-	log.Printf(">>> [ES] Query: %s", buf.String())
-
-	// 3. 执行搜索
-	req := esapi.SearchRequest{
-		Index: []string{index},
-		Body:  strings.NewReader(buf.String()),
-	}
-
-	res, err := req.Do(ctx, client)
+	esQuery, err := buildESQuery(query, filters, page)
 	if err != nil {
-		return nil, fmt.Errorf("error getting response: %s", err)
-	}
-	defer res.Body.Close()
-
-	if res.IsError() {
-		return nil, fmt.Errorf("error response: %s", res.String())
-	}
-
-	// 4. 解析结果
-	var result map[string]interface{}
-	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("error parsing response body: %s", err)
-	}
-
-	// 5. 提取 hits
-	hits, ok := result["hits"].(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("invalid response format")
+		return nil, nil, err
 	}
 
-	hitsList, ok := hits["hits"].([]interface{})
-	if !ok {
-		return []*schema.Document{}, nil // 无结果
+	// 2. 执行搜索
+	hitsList, err := search(ctx, client, index, esQuery, "ES")
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// 6. 转换为 []*schema.Document
+	// 3. 转换为 []*schema.Document
+	var lastSort []any
 	docs := make([]*schema.Document, 0, len(hitsList))
 	for _, hit := range hitsList {
 		hitMap, ok := hit.(map[string]interface{})
 		if !ok {
 			continue
 		}
+		if sortVals, ok := hitMap["sort"].([]interface{}); ok {
+			lastSort = sortVals
+		}
 
 		// 提取 _id 和 _source
 		id, _ := hitMap["_id"].(string)
@@ -121,6 +96,9 @@ func Retriever(ctx context.Context, client *elasticsearch.Client, index string,
 		if val, ok := source["party_b"]; ok {
 			doc.MetaData["party_b"] = val
 		}
+		if val, ok := source["parties"]; ok {
+			doc.MetaData["parties"] = val
+		}
 		if val, ok := source["sign_date"]; ok {
 			doc.MetaData["sign_date"] = val
 		}
@@ -136,6 +114,9 @@ func Retriever(ctx context.Context, client *elasticsearch.Client, index string,
 		if val, ok := source["contract_status"]; ok {
 			doc.MetaData["contract_status"] = val
 		}
+		if val, ok := source["clause_type"]; ok {
+			doc.MetaData["clause_type"] = val
+		}
 
 		docs = append(docs, doc)
 	}
@@ -145,51 +126,161 @@ func Retriever(ctx context.Context, client *elasticsearch.Client, index string,
 		log.Printf("Rank %d | Score: %.4f | ID: %s | Content: %v | metadata: %v\n", i+1, doc.Score(), doc.ID, doc.Content, doc.MetaData)
 	}
 
-	return docs, nil
+	return docs, lastSort, nil
 }
 
-// SearchByParties 只在 party_a/party_b 字段进行模糊匹配（用于结构化检索）
-// 返回去重后的 doc_id 列表
-func SearchByParties(ctx context.Context, client *elasticsearch.Client, index string, parties []string) ([]string, error) {
-	if len(parties) == 0 {
+// SearchByParties 只按参与方条件过滤（用于结构化检索）
+// 未解析名称做子串匹配，已解析主体按 party_ids 和别名精确匹配，与 PG/Milvus 口径一致
+// 按 doc_id 折叠 (collapse) 并用 search_after 分批拉取，返回全部去重后的 doc_id
+func SearchByParties(ctx context.Context, client *elasticsearch.Client, index string, conditions *types.FilterConditions) ([]string, error) {
+	partyQuery, err := filter.ToES(filter.PartyNode(conditions))
+	if err != nil {
+		return nil, err
+	}
+	if partyQuery == nil {
 		return []string{}, nil
 	}
 
-	// 1. 构建 should 条件（匹配 party_a 或 party_b）
-	var shouldConditions []map[string]interface{}
-	for _, party := range parties {
-		shouldConditions = append(shouldConditions, map[string]interface{}{
-			"match": map[string]interface{}{
-				"party_a": party,
-			},
-		})
-		shouldConditions = append(shouldConditions, map[string]interface{}{
-			"match": map[string]interface{}{
-				"party_b": party,
+	docIDs := make([]string, 0)
+	var after []interface{}
+	for {
+		// 1. 构建查询：每个合同只返回一条，按 doc_id 排序翻页
+		esQuery := map[string]interface{}{
+			"query": map[string]interface{}{
+				"bool": map[string]interface{}{
+					"filter": []interface{}{partyQuery},
+				},
 			},
-		})
+			"collapse": map[string]interface{}{"field": "doc_id"},
+			"sort":     []interface{}{map[string]interface{}{"doc_id": "asc"}},
+			"size":     partyBatchSize,
+			"_source":  []string{"doc_id"}, // 只返回 doc_id，减少传输
+		}
+		if after != nil {
+			esQuery["search_after"] = after
+		}
+
+		// 2. 执行搜索
+		hitsList, err := search(ctx, client, index, esQuery, "ES Party Search")
+		if err != nil {
+			return nil, err
+		}
+
+		// 3. 提取 doc_id 和 sort 值
+		for _, hit := range hitsList {
+			hitMap, ok := hit.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if sortVals, ok := hitMap["sort"].([]interface{}); ok {
+				after = sortVals
+			}
+			source, ok := hitMap["_source"].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if docID, ok := source["doc_id"].(string); ok {
+				docIDs = append(docIDs, docID)
+			}
+		}
+
+		// 不足一批说明已经取完
+		if len(hitsList) < partyBatchSize || after == nil {
+			break
+		}
 	}
 
-	esQuery := map[string]interface{}{
+	log.Printf(">>> [ES Party Search] 找到 %d 个唯一 doc_id", len(docIDs))
+	return docIDs, nil
+}
+
+// CountByDoc 统计合同的切片数
+func CountByDoc(ctx context.Context, client *elasticsearch.Client, index string, docID string) (int, error) {
+	body, err := json.Marshal(map[string]interface{}{
 		"query": map[string]interface{}{
-			"bool": map[string]interface{}{
-				"should":               shouldConditions,
-				"minimum_should_match": 1, // 至少匹配一个条件
-			},
+			"term": map[string]interface{}{"doc_id": docID},
 		},
-		"size": 100, // 返回足够多的结果
-		"_source": []string{"doc_id"}, // 只返回 doc_id，减少传输
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error encoding query: %s", err)
+	}
+	res, err := esapi.CountRequest{Index: []string{index}, Body: strings.NewReader(string(body))}.Do(ctx, client)
+	if err != nil {
+		return 0, fmt.Errorf("error getting response: %s", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return 0, fmt.Errorf("error response: %s", res.String())
+	}
+	var result struct {
+		Count int `json:"count"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("error parsing response body: %s", err)
+	}
+	return result.Count, nil
+}
+
+// ChunkSource ES 中一个切片的原文
+type ChunkSource struct {
+	ChunkID    string
+	DocID      string
+	ClauseType string
+	Content    string
+}
+
+// ScanChunks 按 chunk_id 排序，用 search_after 分批读取全部切片 (回填 PG 切片表用)
+// after 为上一批返回的 sort 值，首批传 nil；返回的切片数小于 size 说明已经读完
+func ScanChunks(ctx context.Context, client *elasticsearch.Client, index string, after []any, size int) ([]ChunkSource, []any, error) {
+	esQuery := map[string]interface{}{
+		"query":   map[string]interface{}{"match_all": map[string]interface{}{}},
+		"sort":    []interface{}{map[string]interface{}{"chunk_id": "asc"}},
+		"size":    size,
+		"_source": []string{"chunk_id", "doc_id", "clause_type", "content"},
+	}
+	if after != nil {
+		esQuery["search_after"] = after
+	}
+	hitsList, err := search(ctx, client, index, esQuery, "ES Scan")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	chunks := make([]ChunkSource, 0, len(hitsList))
+	var lastSort []any
+	for _, hit := range hitsList {
+		hitMap, ok := hit.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if sortVals, ok := hitMap["sort"].([]interface{}); ok {
+			lastSort = sortVals
+		}
+		source, ok := hitMap["_source"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		chunks = append(chunks, ChunkSource{
+			ChunkID:    toString(source["chunk_id"]),
+			DocID:      toString(source["doc_id"]),
+			ClauseType: toString(source["clause_type"]),
+			Content:    toString(source["content"]),
+		})
 	}
+	return chunks, lastSort, nil
+}
 
-	// 2. 序列化查询
+// search 执行查询并返回 hits.hits，tag 用于日志
+func search(ctx context.Context, client *elasticsearch.Client, index string, esQuery map[string]interface{}, tag string) ([]interface{}, error) {
+	// 1. 序列化查询
 	var buf strings.Builder
 	if err := json.NewEncoder(&buf).Encode(esQuery); err != nil {
 		return nil, fmt.Errorf("error encoding query: %s", err)
 	}
 
-	log.Printf(">>> [ES Party Search] Query: %s", buf.String())
+	log.Printf(">>> [%s] Query: %s", tag, buf.String())
 
-	// 3. 执行搜索
+	// 2. 执行搜索
 	req := esapi.SearchRequest{
 		Index: []string{index},
 		Body:  strings.NewReader(buf.String()),
@@ -205,13 +296,13 @@ func SearchByParties(ctx context.Context, client *elasticsearch.Client, index st
 		return nil, fmt.Errorf("error response: %s", res.String())
 	}
 
-	// 4. 解析结果
+	// 3. 解析结果
 	var result map[string]interface{}
 	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
 		return nil, fmt.Errorf("error parsing response body: %s", err)
 	}
 
-	// 5. 提取 hits 并去重 doc_ids
+	// 4. 提取 hits
 	hits, ok := result["hits"].(map[string]interface{})
 	if !ok {
 		return nil, fmt.Errorf("invalid response format")
@@ -219,38 +310,37 @@ func SearchByParties(ctx context.Context, client *elasticsearch.Client, index st
 
 	hitsList, ok := hits["hits"].([]interface{})
 	if !ok {
-		return []string{}, nil // 无结果
+		return []interface{}{}, nil // 无结果
 	}
+	return hitsList, nil
+}
 
-	docIDSet := make(map[string]struct{})
-	for _, hit := range hitsList {
-		hitMap, ok := hit.(map[string]interface{})
-		if !ok {
-			continue
-		}
-
-		source, ok := hitMap["_source"].(map[string]interface{})
-		if !ok {
-			continue
-		}
+// esSortFields 排序字段对应的 ES 字段
+var esSortFields = map[string]string{
+	types.SortSignDate: "sign_date",
+	types.SortEndDate:  "end_date",
+	types.SortAmount:   "amount",
+}
 
-		if docID, ok := source["doc_id"].(string); ok {
-			docIDSet[docID] = struct{}{}
-		}
+// buildSort 构建排序，最后按 chunk_id 决胜，保证 search_after 翻页稳定
+func buildSort(page Page) []interface{} {
+	tieBreaker := map[string]interface{}{"chunk_id": "asc"}
+	field, ok := esSortFields[page.SortBy]
+	if !ok {
+		return []interface{}{map[string]interface{}{"_score": "desc"}, tieBreaker}
 	}
-
-	// 转换为数组
-	docIDs := make([]string, 0, len(docIDSet))
-	for id := range docIDSet {
-		docIDs = append(docIDs, id)
+	order := "asc"
+	if page.Desc {
+		order = "desc"
+	}
+	return []interface{}{
+		map[string]interface{}{field: map[string]interface{}{"order": order, "missing": "_last"}},
+		tieBreaker,
 	}
-
-	log.Printf(">>> [ES Party Search] 找到 %d 个唯一 doc_id", len(docIDs))
-	return docIDs, nil
 }
 
-// buildESQuery 构建 ES 查询语句（BM25 + 过滤）
-func buildESQuery(query string, filters *Filter, topK int) map[string]interface{} {
+// buildESQuery 构建 ES 查询语句（BM25 + 过滤 + 排序分页）
+func buildESQuery(query string, filters *Filter, page Page) (map[string]interface{}, error) {
 	// 1. 构建必须的查询条件（bool.must）
 	mustQueries := []map[string]interface{}{
 		{
@@ -263,7 +353,10 @@ func buildESQuery(query string, filters *Filter, topK int) map[string]interface{
 	}
 
 	// 2. 构建过滤条件（bool.filter）
-	filterQueries := buildFilterQueries(filters)
+	filterQueries, err := buildFilterQueries(filters)
+	if err != nil {
+		return nil, err
+	}
 
 	// 3. 组合查询
 	esQuery := map[string]interface{}{
@@ -273,127 +366,27 @@ func buildESQuery(query string, filters *Filter, topK int) map[string]interface{
 				"filter": filterQueries,
 			},
 		},
-		"size": topK,
+		"size": page.Size,
+		"sort": buildSort(page),
+	}
+	if page.After != nil {
+		esQuery["search_after"] = page.After
 	}
 
-	return esQuery
+	return esQuery, nil
 }
 
 // buildFilterQueries 构建过滤条件列表
-func buildFilterQueries(filters *Filter) []map[string]interface{} {
+func buildFilterQueries(filters *Filter) ([]interface{}, error) {
 	if filters == nil {
-		return nil
+		return nil, nil
 	}
-
-	var filterQueries []map[string]interface{}
-
-	// 处理 AnyParty（多个实体，使用 bool should 查询）
-	if len(filters.AnyParty) > 0 {
-		// 构建 should 条件列表
-		var shouldConditions []map[string]interface{}
-		for _, party := range filters.AnyParty {
-			shouldConditions = append(shouldConditions, map[string]interface{}{
-				"term": map[string]interface{}{
-					"party_a": party,
-				},
-			})
-			shouldConditions = append(shouldConditions, map[string]interface{}{
-				"term": map[string]interface{}{
-					"party_b": party,
-				},
-			})
-		}
-		// 用 bool should 包装（至少匹配一个）
-		filterQueries = append(filterQueries, map[string]interface{}{
-			"bool": map[string]interface{}{
-				"should":               shouldConditions,
-				"minimum_should_match": 1, // 至少匹配一个条件
-			},
-		})
-	}
-
-	// 精确匹配过滤
-	if filters.PartyA != "" {
-		filterQueries = append(filterQueries, map[string]interface{}{
-			"term": map[string]interface{}{
-				"party_a": filters.PartyA,
-			},
-		})
-	}
-	if filters.PartyB != "" {
-		filterQueries = append(filterQueries, map[string]interface{}{
-			"term": map[string]interface{}{
-				"party_b": filters.PartyB,
-			},
-		})
-	}
-	if filters.ContractType != "" {
-		filterQueries = append(filterQueries, map[string]interface{}{
-			"term": map[string]interface{}{
-				"contract_type": filters.ContractType,
-			},
-		})
+	node := filter.And(filter.FromConditions(filters.Conditions), filter.In(filter.FieldDocID, filters.DocIDs...))
+	q, err := filter.ToES(node)
+	if err != nil || q == nil {
+		return nil, err
 	}
-	if filters.ContractStatus != nil {
-		filterQueries = append(filterQueries, map[string]interface{}{
-			"term": map[string]interface{}{
-				"contract_status": *filters.ContractStatus,
-			},
-		})
-	}
-
-	// 范围查询
-	rangeFilters := make(map[string]interface{})
-
-	if filters.SignDateStart != nil || filters.SignDateEnd != nil {
-		signDateRange := make(map[string]interface{})
-		if filters.SignDateStart != nil {
-			signDateRange["gte"] = filters.SignDateStart.Format(time.RFC3339)
-		}
-		if filters.SignDateEnd != nil {
-			signDateRange["lte"] = filters.SignDateEnd.Format(time.RFC3339)
-		}
-		rangeFilters["sign_date"] = signDateRange
-	}
-
-	if filters.EndDateStart != nil || filters.EndDateEnd != nil {
-		endDateRange := make(map[string]interface{})
-		if filters.EndDateStart != nil {
-			endDateRange["gte"] = filters.EndDateStart.Format(time.RFC3339)
-		}
-		if filters.EndDateEnd != nil {
-			endDateRange["lte"] = filters.EndDateEnd.Format(time.RFC3339)
-		}
-		rangeFilters["end_date"] = endDateRange
-	}
-
-	if filters.AmountMin != nil || filters.AmountMax != nil {
-		amountRange := make(map[string]interface{})
-		if filters.AmountMin != nil {
-			amountRange["gte"] = *filters.AmountMin
-		}
-		if filters.AmountMax != nil {
-			amountRange["lte"] = *filters.AmountMax
-		}
-		rangeFilters["amount"] = amountRange
-	}
-
-	if len(rangeFilters) > 0 {
-		filterQueries = append(filterQueries, map[string]interface{}{
-			"range": rangeFilters,
-		})
-	}
-
-	// doc_id 列表过滤（用于混合检索）
-	if len(filters.DocIDs) > 0 {
-		filterQueries = append(filterQueries, map[string]interface{}{
-			"terms": map[string]interface{}{
-				"doc_id": filters.DocIDs,
-			},
-		})
-	}
-
-	return filterQueries
+	return []interface{}{q}, nil
 }
 
 // toString 安全地将任意类型转为 string
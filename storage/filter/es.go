@@ -0,0 +1,115 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ToES 编译为 ES 查询子句 (放在 bool.filter 中使用)，节点为 nil 时返回 nil
+func ToES(n *Node) (map[string]any, error) {
+	if n == nil {
+		return nil, nil
+	}
+	return compileES(n)
+}
+
+func compileES(n *Node) (map[string]any, error) {
+	switch n.Op {
+	case OpAnd, OpOr:
+		clauses := make([]any, 0, len(n.Children))
+		for _, c := range n.Children {
+			q, err := compileES(c)
+			if err != nil {
+				return nil, err
+			}
+			clauses = append(clauses, q)
+		}
+		if n.Op == OpAnd {
+			return boolQuery("filter", clauses), nil
+		}
+		return boolQuery("should", clauses), nil
+
+	case OpEq, OpIn, OpContains:
+		fields, err := esFields(n.Field)
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) == 1 {
+			return esMatch(n, fields[0]), nil
+		}
+		clauses := make([]any, 0, len(fields))
+		for _, f := range fields {
+			clauses = append(clauses, esMatch(n, f))
+		}
+		return boolQuery("should", clauses), nil
+
+	case OpRange:
+		col, ok := columns[n.Field]
+		if !ok || col.es == "" {
+			return nil, fmt.Errorf("filter: 字段 %s 不支持范围查询", n.Field)
+		}
+		r := map[string]any{}
+		if n.Min != nil {
+			r["gte"] = esValue(n.Min)
+		}
+		if n.Max != nil {
+			r["lte"] = esValue(n.Max)
+		}
+		return map[string]any{"range": map[string]any{col.es: r}}, nil
+	}
+	return nil, fmt.Errorf("filter: 未知节点类型 %s", n.Op)
+}
+
+// esFields 逻辑字段对应的 ES 字段，AnyParty 展开为多个字段取 "或"
+func esFields(field Field) ([]string, error) {
+	switch field {
+	case FieldAnyParty:
+		return anyPartyES, nil
+	case FieldPartyID:
+		return []string{"party_ids"}, nil
+	}
+	col, ok := columns[field]
+	if !ok || col.es == "" {
+		return nil, fmt.Errorf("filter: 未知字段 %s", field)
+	}
+	return []string{col.es}, nil
+}
+
+func esMatch(n *Node, field string) map[string]any {
+	switch n.Op {
+	case OpIn:
+		values := make([]any, len(n.Values))
+		for i, v := range n.Values {
+			values[i] = esValue(v)
+		}
+		return map[string]any{"terms": map[string]any{field: values}}
+	case OpContains:
+		pattern := "*" + escapeWildcard(fmt.Sprint(n.Values[0])) + "*"
+		return map[string]any{"wildcard": map[string]any{field: map[string]any{"value": pattern}}}
+	}
+	return map[string]any{"term": map[string]any{field: esValue(n.Values[0])}}
+}
+
+func boolQuery(occur string, clauses []any) map[string]any {
+	b := map[string]any{occur: clauses}
+	if occur == "should" {
+		b["minimum_should_match"] = 1
+	}
+	return map[string]any{"bool": b}
+}
+
+// esValue 时间统一转为 UTC RFC3339，与入库格式一致
+func esValue(v any) any {
+	if t, ok := v.(time.Time); ok {
+		return t.UTC().Format(time.RFC3339)
+	}
+	return v
+}
+
+// escapeWildcard 转义 wildcard 查询的通配符
+func escapeWildcard(s string) string {
+	return wildcardEscaper.Replace(s)
+}
+
+var wildcardEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`)
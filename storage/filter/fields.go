@@ -0,0 +1,43 @@
+package filter
+
+// column 逻辑字段在三个存储里的字段名
+type column struct {
+	sql    string // contracts 表列名
+	es     string // ES 字段 (精确匹配用 keyword 子字段)
+	milvus string // Milvus 标量字段
+}
+
+// columns 可以直接比较的单值字段；AnyParty/PartyID/ClauseType 在 PG 里需要子查询，单独处理
+var columns = map[Field]column{
+	FieldDocID:        {sql: "doc_id", es: "doc_id", milvus: "doc_id"},
+	FieldPartyA:       {sql: "party_a", es: "party_a.keyword", milvus: "party_a"},
+	FieldPartyB:       {sql: "party_b", es: "party_b.keyword", milvus: "party_b"},
+	FieldContractType: {sql: "contract_type", es: "contract_type", milvus: "contract_type"},
+	FieldClauseType:   {sql: "", es: "clause_type", milvus: "clause_type"},
+	FieldStatus:       {sql: "contract_status", es: "contract_status", milvus: "contract_status"},
+	FieldSignDate:     {sql: "sign_date", es: "sign_date", milvus: "sign_date"},
+	FieldEndDate:      {sql: "end_date", es: "end_date", milvus: "end_date"},
+	FieldAmount:       {sql: "total_amount", es: "amount", milvus: "amount"},
+}
+
+// AnyParty 在各存储里覆盖的字段：甲方、乙方和全部参与方列表
+var (
+	anyPartyES = []string{"party_a.keyword", "party_b.keyword", "parties.keyword"}
+	// Milvus 的 parties 在 metadata JSON 里，只能做精确的 json_contains；
+	// 子串匹配依赖 party_names 字段 ("|名称1|名称2|")
+	milvusPartiesJSON = `metadata["parties"]`
+	milvusPartyIDs    = `metadata["party_ids"]`
+	milvusPartyNames  = "party_names"
+)
+
+// PartyNamesValue 生成 Milvus party_names 字段的值，首尾加分隔符保证子串不会跨名称匹配
+func PartyNamesValue(names []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	v := "|"
+	for _, n := range names {
+		v += n + "|"
+	}
+	return v
+}
@@ -0,0 +1,220 @@
+package filter
+
+import (
+	"eino-demo/types"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Field 逻辑字段，由各编译器映射到 PG 列、ES 字段和 Milvus 字段
+type Field string
+
+const (
+	FieldDocID        Field = "doc_id"
+	FieldPartyA       Field = "party_a"
+	FieldPartyB       Field = "party_b"
+	FieldAnyParty     Field = "any_party" // party_a ∪ party_b ∪ 全部参与方名称
+	FieldPartyID      Field = "party_id"  // 参与方主体 ID
+	FieldContractType Field = "contract_type"
+	FieldClauseType   Field = "clause_type"
+	FieldStatus       Field = "contract_status"
+	FieldSignDate     Field = "sign_date"
+	FieldEndDate      Field = "end_date"
+	FieldAmount       Field = "amount"
+)
+
+// Op 节点类型
+type Op string
+
+const (
+	OpAnd      Op = "and"
+	OpOr       Op = "or"
+	OpEq       Op = "eq"       // 精确相等
+	OpIn       Op = "in"       // 等于列表中任一值
+	OpContains Op = "contains" // 子串匹配 (区分大小写)
+	OpRange    Op = "range"    // 闭区间 [Min, Max]，任一端为 nil 表示不限
+)
+
+// Node 过滤条件 AST
+// 叶子节点的值只允许 string / int64 / float64 / time.Time
+type Node struct {
+	Op       Op
+	Children []*Node
+	Field    Field
+	Values   []any
+	Min, Max any
+}
+
+// And 组合子条件，自动忽略 nil
+func And(children ...*Node) *Node {
+	return combine(OpAnd, children)
+}
+
+// Or 组合子条件，自动忽略 nil
+func Or(children ...*Node) *Node {
+	return combine(OpOr, children)
+}
+
+func combine(op Op, children []*Node) *Node {
+	var kept []*Node
+	for _, c := range children {
+		if c != nil {
+			kept = append(kept, c)
+		}
+	}
+	switch len(kept) {
+	case 0:
+		return nil
+	case 1:
+		return kept[0]
+	}
+	return &Node{Op: op, Children: kept}
+}
+
+// Eq 字段等于 value
+func Eq(field Field, value any) *Node {
+	return &Node{Op: OpEq, Field: field, Values: []any{value}}
+}
+
+// In 字段等于 values 中任一值，values 为空时返回 nil
+func In(field Field, values ...string) *Node {
+	if len(values) == 0 {
+		return nil
+	}
+	vs := make([]any, len(values))
+	for i, v := range values {
+		vs[i] = v
+	}
+	return &Node{Op: OpIn, Field: field, Values: vs}
+}
+
+// Contains 字段包含子串 value
+func Contains(field Field, value string) *Node {
+	return &Node{Op: OpContains, Field: field, Values: []any{value}}
+}
+
+// Range 字段位于 [min, max]，两端都为 nil 时返回 nil
+func Range(field Field, min, max any) *Node {
+	if min == nil && max == nil {
+		return nil
+	}
+	return &Node{Op: OpRange, Field: field, Min: min, Max: max}
+}
+
+// ParseStatus 将 LLM/用户输入的状态文字转为状态码，无法识别返回 false
+func ParseStatus(s string) (int64, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "已过期", "过期", "失效", "已失效", "expired":
+		return types.StatusExpired, true
+	case "生效中", "生效", "有效", "履行中", "active":
+		return types.StatusActive, true
+	}
+	return 0, false
+}
+
+// PartyNode 参与方条件：未解析名称做子串匹配，已解析主体按 ID 或别名精确匹配，三者取 "或"
+func PartyNode(fc *types.FilterConditions) *Node {
+	if fc == nil {
+		return nil
+	}
+	var nodes []*Node
+	for _, name := range fc.AnyParty {
+		if name = strings.TrimSpace(name); name != "" {
+			nodes = append(nodes, Contains(FieldAnyParty, name))
+		}
+	}
+	nodes = append(nodes, In(FieldPartyID, fc.PartyIDs...), In(FieldAnyParty, fc.PartyAliases...))
+	return Or(nodes...)
+}
+
+// FromConditions 把 FilterConditions 转为 AST，没有任何条件时返回 nil
+func FromConditions(fc *types.FilterConditions) *Node {
+	if fc == nil {
+		return nil
+	}
+	nodes := []*Node{PartyNode(fc)}
+
+	if fc.PartyA != "" {
+		nodes = append(nodes, Contains(FieldPartyA, fc.PartyA))
+	}
+	if fc.PartyB != "" {
+		nodes = append(nodes, Contains(FieldPartyB, fc.PartyB))
+	}
+	if fc.ContractType != "" {
+		nodes = append(nodes, Contains(FieldContractType, fc.ContractType))
+	}
+	if fc.ClauseType != "" {
+		nodes = append(nodes, Eq(FieldClauseType, fc.ClauseType))
+	}
+	if status, ok := ParseStatus(fc.Status); ok {
+		nodes = append(nodes, Eq(FieldStatus, status))
+	}
+
+	// 签署日期按天闭区间：start 当天 00:00:00 到 end 当天 23:59:59 (UTC，与入库一致)
+	if fc.DateRange != nil {
+		var min, max any
+		if t, err := time.Parse("2006-01-02", fc.DateRange.Start); err == nil {
+			min = t
+		}
+		if t, err := time.Parse("2006-01-02", fc.DateRange.End); err == nil {
+			max = t.Add(24*time.Hour - time.Second)
+		}
+		nodes = append(nodes, Range(FieldSignDate, min, max))
+	}
+
+	// 金额：min == 0 也是合法下界，不能丢弃
+	if fc.AmountRange != nil {
+		var min, max any
+		if fc.AmountRange.Min != nil {
+			min = *fc.AmountRange.Min
+		}
+		if fc.AmountRange.Max != nil {
+			max = *fc.AmountRange.Max
+		}
+		nodes = append(nodes, Range(FieldAmount, min, max))
+	}
+
+	return And(nodes...)
+}
+
+// WithoutParty 返回去掉参与方条件后的副本 (参与方已由其他存储预先过滤时使用)
+func WithoutParty(fc *types.FilterConditions) *types.FilterConditions {
+	if fc == nil {
+		return nil
+	}
+	c := *fc
+	c.AnyParty = nil
+	c.PartyIDs = nil
+	c.PartyAliases = nil
+	return &c
+}
+
+// String AST 的可读形式，用于日志和缓存 key
+func (n *Node) String() string {
+	if n == nil {
+		return ""
+	}
+	switch n.Op {
+	case OpAnd, OpOr:
+		parts := make([]string, len(n.Children))
+		for i, c := range n.Children {
+			parts[i] = c.String()
+		}
+		return fmt.Sprintf("%s(%s)", n.Op, strings.Join(parts, ", "))
+	case OpRange:
+		return fmt.Sprintf("%s %s [%v, %v]", n.Field, n.Op, formatValue(n.Min), formatValue(n.Max))
+	}
+	vals := make([]string, len(n.Values))
+	for i, v := range n.Values {
+		vals[i] = formatValue(v)
+	}
+	return fmt.Sprintf("%s %s %v", n.Field, n.Op, vals)
+}
+
+func formatValue(v any) string {
+	if t, ok := v.(time.Time); ok {
+		return t.UTC().Format(time.RFC3339)
+	}
+	return fmt.Sprintf("%v", v)
+}
@@ -0,0 +1,891 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"eino-demo/types"
+)
+
+// 同一组随机过滤条件分别编译为 SQL / ES / Milvus，在三个假存储上执行，命中的 doc_id 集合必须一致
+
+// ==================== 测试数据 ====================
+
+var (
+	partyPool = []string{
+		"深圳市腾讯计算机系统有限公司", "腾讯科技(深圳)有限公司", "阿里巴巴集团", "张三", "李四",
+		`O'Brien "Trading" Ltd`, "100%_股权公司", `back\slash公司`, `a" || doc_id != "x`,
+	}
+	partyQueries = []string{"腾讯", "阿里", "张三", "Brien", `"Trading"`, "100%", "_股权", `\slash`, `" || doc_id != "`, "不存在"}
+	typePool     = []string{"物资采购合同", "借款合同", "房屋租赁合同", "技术服务合同"}
+	typeQueries  = []string{"采购", "借款", "合同", "租赁", "服务合同", "劳动"}
+	clausePool   = []string{"payment", "delivery", "liability", "confidentiality"}
+	statusPool   = []string{"已过期", "生效中", "expired", "active", "过期", "未知状态"}
+	amountPool   = []float64{0, 5000, 10000, 250000.5, 1000000}
+)
+
+type fakeContract struct {
+	docID        string
+	partyA       string
+	partyB       string
+	parties      []string // 全部参与方 (含甲乙方)
+	partyIDs     []string
+	contractType string
+	fileName     string
+	clauseTypes  []string
+	status       int64
+	signDate     time.Time
+	amount       float64
+}
+
+func partyID(name string) string {
+	for i, p := range partyPool {
+		if p == name {
+			return fmt.Sprintf("p%d", i)
+		}
+	}
+	return ""
+}
+
+func randomContracts(r *rand.Rand, n int) []fakeContract {
+	contracts := make([]fakeContract, n)
+	for i := range contracts {
+		c := fakeContract{
+			docID:        fmt.Sprintf("doc-%03d", i),
+			partyA:       partyPool[r.Intn(len(partyPool))],
+			partyB:       partyPool[r.Intn(len(partyPool))],
+			contractType: typePool[r.Intn(len(typePool))],
+			status:       int64(1 + r.Intn(2)),
+			signDate:     time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, r.Intn(730)),
+			amount:       amountPool[r.Intn(len(amountPool))],
+		}
+		// 文件名与 contract_type 独立随机 (类型可能为空或与文件名里的不同)，文件名不能影响任何存储的命中结果
+		if r.Intn(4) == 0 {
+			c.contractType = ""
+		}
+		c.fileName = c.signDate.Format("2006-01-02") + "_" + typePool[r.Intn(len(typePool))] + fmt.Sprintf("_%d.pdf", i)
+		c.parties = []string{c.partyA, c.partyB}
+		if r.Intn(3) == 0 {
+			c.parties = append(c.parties, partyPool[r.Intn(len(partyPool))])
+		}
+		for _, p := range c.parties {
+			c.partyIDs = append(c.partyIDs, partyID(p))
+		}
+		for _, idx := range r.Perm(len(clausePool))[:1+r.Intn(2)] {
+			c.clauseTypes = append(c.clauseTypes, clausePool[idx])
+		}
+		contracts[i] = c
+	}
+	return contracts
+}
+
+func randomConditions(r *rand.Rand) *types.FilterConditions {
+	fc := &types.FilterConditions{}
+	if r.Intn(3) == 0 {
+		for i := 0; i <= r.Intn(2); i++ {
+			fc.AnyParty = append(fc.AnyParty, partyQueries[r.Intn(len(partyQueries))])
+		}
+	}
+	if r.Intn(4) == 0 {
+		name := partyPool[r.Intn(len(partyPool))]
+		fc.PartyIDs = []string{partyID(name)}
+		fc.PartyAliases = []string{name, partyPool[r.Intn(len(partyPool))]}
+	}
+	if r.Intn(5) == 0 {
+		fc.PartyA = partyQueries[r.Intn(len(partyQueries))]
+	}
+	if r.Intn(5) == 0 {
+		fc.PartyB = partyQueries[r.Intn(len(partyQueries))]
+	}
+	if r.Intn(3) == 0 {
+		fc.ContractType = typeQueries[r.Intn(len(typeQueries))]
+	}
+	if r.Intn(3) == 0 {
+		fc.ClauseType = clausePool[r.Intn(len(clausePool))]
+	}
+	if r.Intn(3) == 0 {
+		fc.Status = statusPool[r.Intn(len(statusPool))]
+	}
+	if r.Intn(3) == 0 {
+		start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, r.Intn(730))
+		fc.DateRange = &types.DateRange{}
+		if r.Intn(4) != 0 {
+			fc.DateRange.Start = start.Format("2006-01-02")
+		}
+		if r.Intn(4) != 0 {
+			fc.DateRange.End = start.AddDate(0, 0, r.Intn(200)).Format("2006-01-02")
+		}
+	}
+	if r.Intn(3) == 0 {
+		fc.AmountRange = &types.AmountRange{}
+		if r.Intn(3) != 0 {
+			v := amountPool[r.Intn(len(amountPool))]
+			fc.AmountRange.Min = &v
+		}
+		if r.Intn(3) != 0 {
+			v := amountPool[r.Intn(len(amountPool))]
+			fc.AmountRange.Max = &v
+		}
+	}
+	return fc
+}
+
+// ==================== 属性测试 ====================
+
+func TestCompilersAgree(t *testing.T) {
+	r := rand.New(rand.NewSource(20240601))
+	for round := 0; round < 20; round++ {
+		contracts := randomContracts(r, 60)
+		for i := 0; i < 100; i++ {
+			fc := randomConditions(r)
+			node := FromConditions(fc)
+
+			sqlDocs, err := runSQL(node, contracts)
+			if err != nil {
+				t.Fatalf("sql: %v\nfilter: %s", err, node)
+			}
+			esDocs, err := runES(node, contracts)
+			if err != nil {
+				t.Fatalf("es: %v\nfilter: %s", err, node)
+			}
+			milvusDocs, err := runMilvus(node, contracts)
+			if err != nil {
+				t.Fatalf("milvus: %v\nfilter: %s", err, node)
+			}
+			if !equalSets(sqlDocs, esDocs) || !equalSets(sqlDocs, milvusDocs) {
+				t.Fatalf("doc sets differ for %s\nsql:    %v\nes:     %v\nmilvus: %v", node, sqlDocs, esDocs, milvusDocs)
+			}
+		}
+	}
+}
+
+func TestStatusCodesConsistent(t *testing.T) {
+	for s, want := range map[string]int64{"已过期": types.StatusExpired, "expired": types.StatusExpired, "生效中": types.StatusActive, "active": types.StatusActive} {
+		node := FromConditions(&types.FilterConditions{Status: s})
+		_, args, _ := ToSQL(node)
+		expr, _ := ToMilvus(node)
+		q, _ := ToES(node)
+		if args[0] != want {
+			t.Errorf("sql status %s = %v, want %d", s, args[0], want)
+		}
+		if expr != fmt.Sprintf("contract_status == %d", want) {
+			t.Errorf("milvus status %s = %s", s, expr)
+		}
+		if q["term"].(map[string]any)["contract_status"] != want {
+			t.Errorf("es status %s = %v", s, q)
+		}
+	}
+	if FromConditions(&types.FilterConditions{Status: "未知状态"}) != nil {
+		t.Error("unknown status should be ignored")
+	}
+}
+
+func TestContractTypeIgnoresFileName(t *testing.T) {
+	// 类型为空的合同在入库时已按文件名补齐，过滤只看 contract_type，三个存储的命中一致
+	contracts := []fakeContract{
+		{docID: "typed", contractType: "物资采购合同", fileName: "a.pdf", clauseTypes: []string{"payment"}},
+		{docID: "untyped", contractType: "", fileName: "2023-01-03_众信_深蓝_物资采购合同.pdf", clauseTypes: []string{"payment"}},
+		{docID: "other", contractType: "借款合同", fileName: "b_采购.pdf", clauseTypes: []string{"payment"}},
+	}
+	node := FromConditions(&types.FilterConditions{ContractType: "采购"})
+	for name, run := range map[string]func(*Node, []fakeContract) ([]string, error){"sql": runSQL, "es": runES, "milvus": runMilvus} {
+		docs, err := run(node, contracts)
+		if err != nil || !equalSets(docs, []string{"typed"}) {
+			t.Errorf("%s 应只按 contract_type 匹配: %v, %v", name, docs, err)
+		}
+	}
+}
+
+func TestAmountMinZeroKept(t *testing.T) {
+	zero := 0.0
+	expr, err := ToMilvus(FromConditions(&types.FilterConditions{AmountRange: &types.AmountRange{Min: &zero}}))
+	if err != nil || expr != "(amount >= 0)" {
+		t.Fatalf("expr = %q, err = %v", expr, err)
+	}
+}
+
+func TestMilvusWithoutPartyNames(t *testing.T) {
+	node := FromConditions(&types.FilterConditions{AnyParty: []string{"腾讯"}, PartyAliases: []string{"阿里巴巴集团"}})
+	expr, err := ToMilvusWith(node, MilvusOptions{NoPartyNames: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(expr, milvusPartyNames) {
+		t.Errorf("旧集合没有 party_names 字段，不能引用: %s", expr)
+	}
+	// 降级后仍能按甲乙方命中
+	contracts := []fakeContract{
+		{docID: "a", partyA: "深圳市腾讯计算机系统有限公司", partyB: "张三", clauseTypes: []string{"payment"}},
+		{docID: "b", partyA: "李四", partyB: "阿里巴巴集团", clauseTypes: []string{"payment"}},
+		{docID: "c", partyA: "李四", partyB: "张三", clauseTypes: []string{"payment"}},
+	}
+	for i := range contracts {
+		contracts[i].parties = []string{contracts[i].partyA, contracts[i].partyB}
+	}
+	p := &parser{tokens: tokenize(expr)}
+	parsed, err := p.parseOr()
+	if err != nil {
+		t.Fatalf("%s: %v", expr, err)
+	}
+	var docs []string
+	for _, c := range contracts {
+		row := map[string]any{"party_a": c.partyA, "party_b": c.partyB, `metadata["parties"]`: c.parties}
+		if parsed.eval(row, nil) {
+			docs = append(docs, c.docID)
+		}
+	}
+	if !equalSets(docs, []string{"a", "b"}) {
+		t.Errorf("docs = %v", docs)
+	}
+}
+
+func TestMilvusEscaping(t *testing.T) {
+	name := `a" || doc_id != "x`
+	expr, err := ToMilvus(FromConditions(&types.FilterConditions{PartyA: name}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `party_a like "%a\" || doc\\_id != \"x%"`
+	if expr != want {
+		t.Fatalf("expr = %s, want %s", expr, want)
+	}
+}
+
+// ==================== 假 PG ====================
+
+func runSQL(node *Node, contracts []fakeContract) ([]string, error) {
+	where, args, err := ToSQL(node)
+	if err != nil {
+		return nil, err
+	}
+	tables := map[string][]map[string]any{}
+	for _, c := range contracts {
+		tables["contracts"] = append(tables["contracts"], map[string]any{
+			"doc_id": c.docID, "party_a": c.partyA, "party_b": c.partyB, "contract_type": c.contractType,
+			"file_name": c.fileName, "contract_status": c.status, "sign_date": c.signDate, "total_amount": c.amount,
+		})
+		for i, p := range c.parties {
+			tables["contract_parties"] = append(tables["contract_parties"], map[string]any{
+				"doc_id": c.docID, "normalized_name": p, "party_id": c.partyIDs[i],
+			})
+		}
+		for _, ct := range c.clauseTypes {
+			tables["contract_clauses"] = append(tables["contract_clauses"], map[string]any{"doc_id": c.docID, "clause_type": ct})
+		}
+	}
+	var expr exprNode
+	if where != "" {
+		p := &parser{tokens: tokenize(where), args: args, sql: true}
+		if expr, err = p.parseOr(); err != nil {
+			return nil, err
+		}
+		if p.pos != len(p.tokens) || p.argPos != len(args) {
+			return nil, fmt.Errorf("trailing tokens or args in %q", where)
+		}
+	}
+	var docs []string
+	for _, row := range tables["contracts"] {
+		if expr == nil || expr.eval(row, tables) {
+			docs = append(docs, row["doc_id"].(string))
+		}
+	}
+	return docs, nil
+}
+
+// ==================== 假 Milvus ====================
+
+func runMilvus(node *Node, contracts []fakeContract) ([]string, error) {
+	exprStr, err := ToMilvus(node)
+	if err != nil {
+		return nil, err
+	}
+	var expr exprNode
+	if exprStr != "" {
+		p := &parser{tokens: tokenize(exprStr)}
+		if expr, err = p.parseOr(); err != nil {
+			return nil, err
+		}
+		if p.pos != len(p.tokens) {
+			return nil, fmt.Errorf("trailing tokens in %q", exprStr)
+		}
+	}
+	seen := map[string]bool{}
+	var docs []string
+	for _, c := range contracts {
+		// 每类条款一个 chunk，文档级字段在所有 chunk 上相同
+		for _, ct := range c.clauseTypes {
+			row := map[string]any{
+				"doc_id": c.docID, "party_a": c.partyA, "party_b": c.partyB, "party_names": PartyNamesValue(c.parties),
+				"contract_type": c.contractType, "clause_type": ct, "contract_status": c.status,
+				"sign_date": c.signDate.Unix(), "amount": c.amount,
+				`metadata["parties"]`: c.parties, `metadata["party_ids"]`: c.partyIDs,
+			}
+			if (expr == nil || expr.eval(row, nil)) && !seen[c.docID] {
+				seen[c.docID] = true
+				docs = append(docs, c.docID)
+			}
+		}
+	}
+	return docs, nil
+}
+
+// ==================== 假 ES ====================
+
+func runES(node *Node, contracts []fakeContract) ([]string, error) {
+	q, err := ToES(node)
+	if err != nil {
+		return nil, err
+	}
+	// 经过一次 JSON 序列化，和真实请求一致
+	var query map[string]any
+	if q != nil {
+		raw, err := json.Marshal(q)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(raw, &query); err != nil {
+			return nil, err
+		}
+	}
+	seen := map[string]bool{}
+	var docs []string
+	for _, c := range contracts {
+		for _, ct := range c.clauseTypes {
+			source := map[string]any{
+				"doc_id": c.docID, "party_a": c.partyA, "party_b": c.partyB, "parties": c.parties, "party_ids": c.partyIDs,
+				"contract_type": c.contractType, "clause_type": ct, "contract_status": c.status,
+				"sign_date": c.signDate.Format(time.RFC3339), "amount": c.amount,
+			}
+			ok, err := esEval(query, source)
+			if err != nil {
+				return nil, err
+			}
+			if ok && !seen[c.docID] {
+				seen[c.docID] = true
+				docs = append(docs, c.docID)
+			}
+		}
+	}
+	return docs, nil
+}
+
+func esEval(q map[string]any, source map[string]any) (bool, error) {
+	if q == nil {
+		return true, nil
+	}
+	for kind, body := range q {
+		b := body.(map[string]any)
+		switch kind {
+		case "bool":
+			if filters, ok := b["filter"].([]any); ok {
+				for _, f := range filters {
+					if ok, err := esEval(f.(map[string]any), source); err != nil || !ok {
+						return false, err
+					}
+				}
+			}
+			if should, ok := b["should"].([]any); ok {
+				for _, s := range should {
+					if ok, err := esEval(s.(map[string]any), source); err != nil || ok {
+						return ok, err
+					}
+				}
+				return false, nil
+			}
+			return true, nil
+		case "term", "terms", "wildcard", "range":
+			for field, cond := range b {
+				values := esFieldValues(source, field)
+				for _, v := range values {
+					if esLeaf(kind, cond, v) {
+						return true, nil
+					}
+				}
+				return false, nil
+			}
+		default:
+			return false, fmt.Errorf("unsupported es query %s", kind)
+		}
+	}
+	return false, nil
+}
+
+func esFieldValues(source map[string]any, field string) []any {
+	v := source[strings.TrimSuffix(field, ".keyword")]
+	if list, ok := v.([]string); ok {
+		out := make([]any, len(list))
+		for i, s := range list {
+			out[i] = s
+		}
+		return out
+	}
+	return []any{v}
+}
+
+func esLeaf(kind string, cond any, v any) bool {
+	switch kind {
+	case "term":
+		return compare(v, cond) == 0
+	case "terms":
+		for _, c := range cond.([]any) {
+			if compare(v, c) == 0 {
+				return true
+			}
+		}
+		return false
+	case "wildcard":
+		s, _ := v.(string)
+		return wildcardRegexp(cond.(map[string]any)["value"].(string)).MatchString(s)
+	case "range":
+		r := cond.(map[string]any)
+		if gte, ok := r["gte"]; ok && compare(v, gte) < 0 {
+			return false
+		}
+		if lte, ok := r["lte"]; ok && compare(v, lte) > 0 {
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// ==================== SQL / Milvus 表达式解析 ====================
+
+type token struct {
+	kind string // ident, string, number, op
+	text string
+}
+
+func tokenize(s string) []token {
+	var tokens []token
+	rs := []rune(s)
+	for i := 0; i < len(rs); {
+		c := rs[i]
+		switch {
+		case c == ' ':
+			i++
+		case c == '"':
+			var sb strings.Builder
+			i++
+			for ; rs[i] != '"'; i++ {
+				if rs[i] == '\\' {
+					i++
+					switch rs[i] {
+					case 'n':
+						sb.WriteRune('\n')
+					case 'r':
+						sb.WriteRune('\r')
+					case 't':
+						sb.WriteRune('\t')
+					default:
+						sb.WriteRune(rs[i])
+					}
+					continue
+				}
+				sb.WriteRune(rs[i])
+			}
+			i++
+			tokens = append(tokens, token{"string", sb.String()})
+		case c >= '0' && c <= '9' || c == '-':
+			j := i + 1
+			for j < len(rs) && (rs[j] >= '0' && rs[j] <= '9' || rs[j] == '.' || rs[j] == 'e' || rs[j] == '+') {
+				j++
+			}
+			tokens = append(tokens, token{"number", string(rs[i:j])})
+			i = j
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			j := i + 1
+			for j < len(rs) && (rs[j] == '_' || rs[j] >= 'a' && rs[j] <= 'z' || rs[j] >= 'A' && rs[j] <= 'Z' || rs[j] >= '0' && rs[j] <= '9') {
+				j++
+			}
+			tokens = append(tokens, token{"ident", string(rs[i:j])})
+			i = j
+		default:
+			if i+1 < len(rs) {
+				two := string(rs[i : i+2])
+				if two == "==" || two == ">=" || two == "<=" || two == "&&" || two == "||" || two == "!=" {
+					tokens = append(tokens, token{"op", two})
+					i += 2
+					continue
+				}
+			}
+			tokens = append(tokens, token{"op", string(c)})
+			i++
+		}
+	}
+	return tokens
+}
+
+type exprNode interface {
+	eval(row map[string]any, tables map[string][]map[string]any) bool
+}
+
+type boolExpr struct {
+	and      bool
+	children []exprNode
+}
+
+func (b *boolExpr) eval(row map[string]any, tables map[string][]map[string]any) bool {
+	for _, c := range b.children {
+		if c.eval(row, tables) != b.and {
+			return !b.and
+		}
+	}
+	return b.and
+}
+
+type cmpExpr struct {
+	field string
+	op    string // = == >= <= in like json_contains_any
+	value any
+}
+
+func (c *cmpExpr) eval(row map[string]any, _ map[string][]map[string]any) bool {
+	v := row[c.field]
+	switch c.op {
+	case "=", "==":
+		return compare(v, c.value) == 0
+	case ">=":
+		return compare(v, c.value) >= 0
+	case "<=":
+		return compare(v, c.value) <= 0
+	case "in":
+		for _, x := range c.value.([]any) {
+			if compare(v, x) == 0 {
+				return true
+			}
+		}
+		return false
+	case "like":
+		return likeRegexp(c.value.(string)).MatchString(v.(string))
+	case "json_contains_any":
+		for _, item := range v.([]string) {
+			for _, x := range c.value.([]any) {
+				if compare(item, x) == 0 {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// subqueryExpr doc_id IN (SELECT doc_id FROM table WHERE cond)
+type subqueryExpr struct {
+	table string
+	cond  exprNode
+}
+
+func (s *subqueryExpr) eval(row map[string]any, tables map[string][]map[string]any) bool {
+	for _, r := range tables[s.table] {
+		if r["doc_id"] == row["doc_id"] && s.cond.eval(r, tables) {
+			return true
+		}
+	}
+	return false
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	args   []any
+	argPos int
+	sql    bool
+}
+
+func (p *parser) peek() token {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return token{}
+}
+
+func (p *parser) next() token {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *parser) expect(text string) error {
+	if t := p.next(); !strings.EqualFold(t.text, text) {
+		return fmt.Errorf("expected %q, got %q at %d", text, t.text, p.pos-1)
+	}
+	return nil
+}
+
+func (p *parser) isKeyword(words ...string) bool {
+	t := p.peek()
+	for _, w := range words {
+		if strings.EqualFold(t.text, w) && t.kind != "string" {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) parseOr() (exprNode, error) {
+	return p.parseBool(false, "OR", "||")
+}
+
+func (p *parser) parseBool(and bool, ops ...string) (exprNode, error) {
+	parse := p.parseOrOperand
+	if and {
+		parse = p.parseUnary
+	}
+	first, err := parse()
+	if err != nil {
+		return nil, err
+	}
+	children := []exprNode{first}
+	for p.isKeyword(ops...) {
+		p.next()
+		c, err := parse()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, c)
+	}
+	if len(children) == 1 {
+		return first, nil
+	}
+	return &boolExpr{and: and, children: children}, nil
+}
+
+func (p *parser) parseOrOperand() (exprNode, error) {
+	return p.parseBool(true, "AND", "&&")
+}
+
+func (p *parser) parseUnary() (exprNode, error) {
+	if p.peek().text == "(" {
+		p.next()
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return e, p.expect(")")
+	}
+	field := p.next()
+	if field.kind != "ident" {
+		return nil, fmt.Errorf("expected field, got %q", field.text)
+	}
+	name := field.text
+
+	if name == "json_contains_any" {
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		target, err := p.parseField()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		list, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		return &cmpExpr{field: target, op: "json_contains_any", value: list}, p.expect(")")
+	}
+	if p.peek().text == "[" {
+		p.pos--
+		var err error
+		if name, err = p.parseField(); err != nil {
+			return nil, err
+		}
+	}
+
+	op := strings.ToLower(p.next().text)
+	switch op {
+	case "=", "==", ">=", "<=", "like":
+	case "in":
+		if p.sql && p.peek().text == "(" {
+			return p.parseSubquery(name)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported operator %q", op)
+	}
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	return &cmpExpr{field: name, op: op, value: value}, nil
+}
+
+// parseField 解析 field 或 metadata["key"]
+func (p *parser) parseField() (string, error) {
+	name := p.next().text
+	if p.peek().text != "[" {
+		return name, nil
+	}
+	p.next()
+	key := p.next()
+	if key.kind != "string" {
+		return "", fmt.Errorf("expected json key")
+	}
+	return fmt.Sprintf("%s[%q]", name, key.text), p.expect("]")
+}
+
+func (p *parser) parseSubquery(field string) (exprNode, error) {
+	if field != "doc_id" {
+		return nil, fmt.Errorf("subquery on %s", field)
+	}
+	for _, w := range []string{"(", "SELECT", "doc_id", "FROM"} {
+		if err := p.expect(w); err != nil {
+			return nil, err
+		}
+	}
+	table := p.next().text
+	if err := p.expect("WHERE"); err != nil {
+		return nil, err
+	}
+	cond, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	return &subqueryExpr{table: table, cond: cond}, p.expect(")")
+}
+
+func (p *parser) parseValue() (any, error) {
+	t := p.next()
+	switch {
+	case t.text == "?" && p.sql:
+		if p.argPos >= len(p.args) {
+			return nil, fmt.Errorf("not enough args")
+		}
+		v := p.args[p.argPos]
+		p.argPos++
+		if list, ok := v.([]any); ok {
+			return list, nil
+		}
+		return v, nil
+	case t.kind == "string":
+		return t.text, nil
+	case t.kind == "number":
+		return strconv.ParseFloat(t.text, 64)
+	case t.text == "[":
+		var list []any
+		for p.peek().text != "]" {
+			v, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+			if p.peek().text == "," {
+				p.next()
+			}
+		}
+		p.next()
+		return list, nil
+	}
+	return nil, fmt.Errorf("unexpected value token %q", t.text)
+}
+
+// ==================== 工具 ====================
+
+// compare 比较两个值：数字统一转 float64，时间按时刻比较，RFC3339 字符串按时间解析
+func compare(a, b any) int {
+	if fa, ok := toFloat(a); ok {
+		if fb, ok := toFloat(b); ok {
+			switch {
+			case fa < fb:
+				return -1
+			case fa > fb:
+				return 1
+			}
+			return 0
+		}
+	}
+	if ta, ok := toTime(a); ok {
+		if tb, ok := toTime(b); ok {
+			return ta.Compare(tb)
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func toFloat(v any) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+func toTime(v any) (time.Time, bool) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		t, err := time.Parse(time.RFC3339, v)
+		return t, err == nil
+	}
+	return time.Time{}, false
+}
+
+// likeRegexp LIKE 模式 (% _ 通配，反斜杠转义) 转正则
+func likeRegexp(pattern string) *regexp.Regexp {
+	return patternRegexp(pattern, '%', '_')
+}
+
+// wildcardRegexp ES wildcard 模式 (* ? 通配，反斜杠转义) 转正则
+func wildcardRegexp(pattern string) *regexp.Regexp {
+	return patternRegexp(pattern, '*', '?')
+}
+
+var patternCache = map[string]*regexp.Regexp{}
+
+func patternRegexp(pattern string, many, one rune) *regexp.Regexp {
+	key := string(many) + pattern
+	if re, ok := patternCache[key]; ok {
+		return re
+	}
+	var sb strings.Builder
+	sb.WriteString("(?s)^")
+	rs := []rune(pattern)
+	for i := 0; i < len(rs); i++ {
+		switch rs[i] {
+		case '\\':
+			i++
+			sb.WriteString(regexp.QuoteMeta(string(rs[i])))
+		case many:
+			sb.WriteString(".*")
+		case one:
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(rs[i])))
+		}
+	}
+	sb.WriteString("$")
+	re := regexp.MustCompile(sb.String())
+	patternCache[key] = re
+	return re
+}
+
+func equalSets(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sa := append([]string(nil), a...)
+	sb := append([]string(nil), b...)
+	sort.Strings(sa)
+	sort.Strings(sb)
+	for i := range sa {
+		if sa[i] != sb[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,135 @@
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MilvusOptions 编译选项，与集合实际的 schema 版本对应
+type MilvusOptions struct {
+	// NoPartyNames 集合还没有 party_names 字段 (旧版本 schema，重建前)，AnyParty 子串匹配只查甲方和乙方
+	NoPartyNames bool
+}
+
+// ToMilvus 按最新 schema 编译为 Milvus 布尔表达式，节点为 nil 时返回空字符串
+// 字符串一律用双引号字面量并转义，不再直接拼接用户输入
+func ToMilvus(n *Node) (string, error) {
+	return ToMilvusWith(n, MilvusOptions{})
+}
+
+// ToMilvusWith 按集合实际的字段编译
+func ToMilvusWith(n *Node, opts MilvusOptions) (string, error) {
+	if n == nil {
+		return "", nil
+	}
+	return compileMilvus(n, opts)
+}
+
+func compileMilvus(n *Node, opts MilvusOptions) (string, error) {
+	switch n.Op {
+	case OpAnd, OpOr:
+		sep := " && "
+		if n.Op == OpOr {
+			sep = " || "
+		}
+		parts := make([]string, 0, len(n.Children))
+		for _, c := range n.Children {
+			part, err := compileMilvus(c, opts)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, part)
+		}
+		return "(" + strings.Join(parts, sep) + ")", nil
+
+	case OpEq, OpIn, OpContains:
+		return compileMilvusMatch(n, opts)
+
+	case OpRange:
+		col, ok := columns[n.Field]
+		if !ok || col.milvus == "" {
+			return "", fmt.Errorf("filter: 字段 %s 不支持范围查询", n.Field)
+		}
+		var parts []string
+		if n.Min != nil {
+			parts = append(parts, fmt.Sprintf("%s >= %s", col.milvus, milvusLiteral(n.Min)))
+		}
+		if n.Max != nil {
+			parts = append(parts, fmt.Sprintf("%s <= %s", col.milvus, milvusLiteral(n.Max)))
+		}
+		return "(" + strings.Join(parts, " && ") + ")", nil
+	}
+	return "", fmt.Errorf("filter: 未知节点类型 %s", n.Op)
+}
+
+// compileMilvusMatch 编译 Eq/In/Contains
+func compileMilvusMatch(n *Node, opts MilvusOptions) (string, error) {
+	switch n.Field {
+	case FieldAnyParty:
+		if n.Op == OpContains {
+			p := milvusLikePattern(n.Values[0])
+			if opts.NoPartyNames {
+				return fmt.Sprintf("(party_a like %[1]s || party_b like %[1]s)", p), nil
+			}
+			return fmt.Sprintf("(party_a like %[1]s || party_b like %[1]s || %[2]s like %[1]s)", p, milvusPartyNames), nil
+		}
+		list := milvusList(n.Values)
+		return fmt.Sprintf("(party_a in %[1]s || party_b in %[1]s || json_contains_any(%[2]s, %[1]s))", list, milvusPartiesJSON), nil
+	case FieldPartyID:
+		if n.Op == OpContains {
+			return "", fmt.Errorf("filter: 字段 %s 不支持子串匹配", n.Field)
+		}
+		return fmt.Sprintf("json_contains_any(%s, %s)", milvusPartyIDs, milvusList(n.Values)), nil
+	}
+
+	col, ok := columns[n.Field]
+	if !ok || col.milvus == "" {
+		return "", fmt.Errorf("filter: 未知字段 %s", n.Field)
+	}
+	switch n.Op {
+	case OpIn:
+		return fmt.Sprintf("%s in %s", col.milvus, milvusList(n.Values)), nil
+	case OpContains:
+		return fmt.Sprintf("%s like %s", col.milvus, milvusLikePattern(n.Values[0])), nil
+	}
+	return fmt.Sprintf("%s == %s", col.milvus, milvusLiteral(n.Values[0])), nil
+}
+
+// milvusLiteral 值转为表达式字面量：时间转 Unix 秒 (与入库一致)，字符串加引号转义
+func milvusLiteral(v any) string {
+	switch v := v.(type) {
+	case time.Time:
+		return strconv.FormatInt(v.Unix(), 10)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return quoteMilvus(v)
+	}
+	return quoteMilvus(fmt.Sprint(v))
+}
+
+func milvusList(values []any) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = milvusLiteral(v)
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// milvusLikePattern 子串匹配模式 "%value%"，value 中的 % 和 _ 转义为字面量
+func milvusLikePattern(v any) string {
+	return quoteMilvus("%" + escapeLike(fmt.Sprint(v)) + "%")
+}
+
+// quoteMilvus 双引号字符串字面量，转义反斜杠和双引号
+func quoteMilvus(s string) string {
+	return `"` + milvusEscaper.Replace(s) + `"`
+}
+
+var milvusEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
@@ -0,0 +1,103 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ToSQL 编译为 contracts 表的 WHERE 子句和参数，可直接传给 gorm 的 Where
+// 节点为 nil 时返回空字符串
+func ToSQL(n *Node) (string, []any, error) {
+	if n == nil {
+		return "", nil, nil
+	}
+	var args []any
+	sql, err := compileSQL(n, &args)
+	if err != nil {
+		return "", nil, err
+	}
+	return sql, args, nil
+}
+
+func compileSQL(n *Node, args *[]any) (string, error) {
+	switch n.Op {
+	case OpAnd, OpOr:
+		sep := " AND "
+		if n.Op == OpOr {
+			sep = " OR "
+		}
+		parts := make([]string, 0, len(n.Children))
+		for _, c := range n.Children {
+			part, err := compileSQL(c, args)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, part)
+		}
+		return "(" + strings.Join(parts, sep) + ")", nil
+
+	case OpEq, OpIn, OpContains:
+		return compileSQLMatch(n, args)
+
+	case OpRange:
+		col, ok := columns[n.Field]
+		if !ok || col.sql == "" {
+			return "", fmt.Errorf("filter: 字段 %s 不支持范围查询", n.Field)
+		}
+		var parts []string
+		if n.Min != nil {
+			parts = append(parts, col.sql+" >= ?")
+			*args = append(*args, n.Min)
+		}
+		if n.Max != nil {
+			parts = append(parts, col.sql+" <= ?")
+			*args = append(*args, n.Max)
+		}
+		return "(" + strings.Join(parts, " AND ") + ")", nil
+	}
+	return "", fmt.Errorf("filter: 未知节点类型 %s", n.Op)
+}
+
+// compileSQLMatch 编译 Eq/In/Contains
+func compileSQLMatch(n *Node, args *[]any) (string, error) {
+	op, arg := sqlOperand(n)
+
+	switch n.Field {
+	case FieldAnyParty:
+		// 甲方、乙方或 contract_parties 中任一参与方
+		*args = append(*args, arg, arg, arg)
+		return fmt.Sprintf("(party_a %[1]s ? OR party_b %[1]s ? OR doc_id IN (SELECT doc_id FROM contract_parties WHERE normalized_name %[1]s ?))", op), nil
+	case FieldPartyID:
+		*args = append(*args, arg)
+		return fmt.Sprintf("doc_id IN (SELECT doc_id FROM contract_parties WHERE party_id %s ?)", op), nil
+	case FieldClauseType:
+		// 合同包含该类条款即命中
+		*args = append(*args, arg)
+		return fmt.Sprintf("doc_id IN (SELECT doc_id FROM contract_clauses WHERE clause_type %s ?)", op), nil
+	}
+
+	col, ok := columns[n.Field]
+	if !ok || col.sql == "" {
+		return "", fmt.Errorf("filter: 未知字段 %s", n.Field)
+	}
+	*args = append(*args, arg)
+	return fmt.Sprintf("%s %s ?", col.sql, op), nil
+}
+
+// sqlOperand 返回比较运算符和对应参数
+func sqlOperand(n *Node) (string, any) {
+	switch n.Op {
+	case OpIn:
+		return "IN", n.Values
+	case OpContains:
+		return "LIKE", "%" + escapeLike(fmt.Sprint(n.Values[0])) + "%"
+	}
+	return "=", n.Values[0]
+}
+
+// escapeLike 转义 LIKE 通配符，PG 默认以反斜杠作为 LIKE 的转义字符
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
@@ -0,0 +1,36 @@
+package milvus
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/milvus-io/milvus-sdk-go/v2/client"
+)
+
+// PointAlias 把别名指向 collection：别名已存在时 AlterAlias (Milvus 内部原子切换)，否则 CreateAlias
+func PointAlias(ctx context.Context, cli client.Client, alias, collection string) error {
+	alterErr := cli.AlterAlias(ctx, collection, alias)
+	if alterErr == nil {
+		fmt.Printf(">>> [Milvus] 别名 %s 已切换到 %s\n", alias, collection)
+		return nil
+	}
+	if err := cli.CreateAlias(ctx, collection, alias); err != nil {
+		return fmt.Errorf("切换别名 %s -> %s 失败: alter: %v, create: %v", alias, collection, alterErr, err)
+	}
+	fmt.Printf(">>> [Milvus] 已创建别名 %s -> %s\n", alias, collection)
+	return nil
+}
+
+// DeleteByIDs 按主键删除切片；配合 Store 实现幂等写入 (Milvus 主键不去重)
+func DeleteByIDs(ctx context.Context, cli client.Client, collection string, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	quoted := make([]string, len(ids))
+	for i, id := range ids {
+		quoted[i] = strconv.Quote(id)
+	}
+	return cli.Delete(ctx, collection, "", fmt.Sprintf("id in [%s]", strings.Join(quoted, ",")))
+}
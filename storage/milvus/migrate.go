@@ -0,0 +1,334 @@
+package milvus
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/milvus-io/milvus-sdk-go/v2/client"
+	"github.com/milvus-io/milvus-sdk-go/v2/entity"
+)
+
+// Migration 集合 schema 的一次变更，Version 从 1 开始连续递增，已发布的不要修改
+// Milvus 2.4 不支持给已有集合加字段：带 Fields 的变更只在新建集合时生效，已有集合需要重建 (POST /api/v1/embedding/versions)
+// 只带 Indexes 的变更直接在已有集合上补建
+type Migration struct {
+	Version int
+	Name    string
+	Fields  []*entity.Field
+	Indexes []IndexSpec
+}
+
+// IndexSpec 字段索引
+type IndexSpec struct {
+	Field string
+	Index entity.Index
+}
+
+// MigrationStore 记录每个集合已执行的 schema 版本 (实现：postgres.MilvusMigrationRepo)
+type MigrationStore interface {
+	// AppliedVersion 集合当前的 schema 版本，没有记录时 ok 为 false
+	AppliedVersion(ctx context.Context, collection string) (version int, ok bool, err error)
+	RecordMigration(ctx context.Context, collection string, version int, name string) error
+}
+
+// partyNamesVersion 加入 party_names 字段的版本，低于该版本的集合过滤时不能引用这个字段
+const partyNamesVersion = 4
+
+// dimPlaceholder vector 字段的维度在建集合时按 embedder 实际输出填入
+const dimPlaceholder = "{dim}"
+
+func mustHNSW() entity.Index {
+	idx, err := entity.NewIndexHNSW(entity.L2, 16, 200)
+	if err != nil {
+		panic(err)
+	}
+	return idx
+}
+
+// Migrations 集合 schema 的全部历史，新增字段或索引时在末尾追加
+var Migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "初始字段",
+		Fields: []*entity.Field{
+			{
+				Name:       "id", // 主键
+				DataType:   entity.FieldTypeVarChar,
+				PrimaryKey: true,
+				AutoID:     false, // Eino 通常生成 UUID 字符串作为 ID
+				TypeParams: map[string]string{"max_length": "64"},
+			},
+			{
+				Name:       "doc_id", // 全局id
+				DataType:   entity.FieldTypeVarChar,
+				AutoID:     false,
+				TypeParams: map[string]string{"max_length": "64"},
+			},
+			{
+				Name:       "vector", // 向量字段
+				DataType:   entity.FieldTypeFloatVector,
+				TypeParams: map[string]string{"dim": dimPlaceholder},
+			},
+			{
+				Name:       "content", // 文本内容
+				DataType:   entity.FieldTypeVarChar,
+				TypeParams: map[string]string{"max_length": "65535"},
+			},
+			{
+				Name: "party_a", DataType: entity.FieldTypeVarChar,
+				TypeParams: map[string]string{"max_length": "255"},
+			},
+			{
+				Name: "party_b", DataType: entity.FieldTypeVarChar,
+				TypeParams: map[string]string{"max_length": "255"},
+			},
+			{
+				Name: "sign_date", DataType: entity.FieldTypeInt64, // Unix 时间戳，范围查询最快
+			},
+			{
+				Name:     "metadata",           // 元数据
+				DataType: entity.FieldTypeJSON, // JSON 通用性好
+			},
+		},
+		Indexes: []IndexSpec{
+			{Field: "vector", Index: mustHNSW()},
+			{Field: "party_a", Index: entity.NewScalarIndex()},
+			{Field: "party_b", Index: entity.NewScalarIndex()},
+			{Field: "sign_date", Index: entity.NewScalarIndex()},
+		},
+	},
+	{
+		Version: 2,
+		Name:    "到期日、类型、状态、金额",
+		Fields: []*entity.Field{
+			{Name: "end_date", DataType: entity.FieldTypeInt64},
+			{
+				Name: "contract_type", DataType: entity.FieldTypeVarChar,
+				TypeParams: map[string]string{"max_length": "255"},
+			},
+			{Name: "contract_status", DataType: entity.FieldTypeInt64},
+			{Name: "amount", DataType: entity.FieldTypeDouble},
+		},
+		Indexes: []IndexSpec{
+			{Field: "end_date", Index: entity.NewScalarIndex()},
+			{Field: "amount", Index: entity.NewScalarIndex()},
+			{Field: "contract_type", Index: entity.NewScalarIndex()},
+			{Field: "contract_status", Index: entity.NewScalarIndex()},
+		},
+	},
+	{
+		Version: 3,
+		Name:    "条款类型",
+		Fields: []*entity.Field{
+			{
+				Name: "clause_type", DataType: entity.FieldTypeVarChar,
+				TypeParams: map[string]string{"max_length": "32"},
+			},
+		},
+		Indexes: []IndexSpec{
+			{Field: "clause_type", Index: entity.NewScalarIndex()},
+		},
+	},
+	{
+		Version: 4,
+		Name:    "全部参与方名称",
+		Fields: []*entity.Field{
+			{
+				// 全部参与方名称 "|名称1|名称2|"，用于 like 子串匹配 (metadata 里的列表只能精确匹配)
+				Name: "party_names", DataType: entity.FieldTypeVarChar,
+				TypeParams: map[string]string{"max_length": "2048"},
+			},
+		},
+	},
+}
+
+// LatestVersion 最新的 schema 版本
+func LatestVersion() int {
+	return Migrations[len(Migrations)-1].Version
+}
+
+// FieldsAt 截止 version 的全部字段，vector 维度填 dim
+func FieldsAt(version, dim int) []*entity.Field {
+	var fields []*entity.Field
+	for _, m := range Migrations {
+		if m.Version > version {
+			break
+		}
+		for _, f := range m.Fields {
+			field := *f
+			if field.TypeParams[entity.TypeParamDim] == dimPlaceholder {
+				field.TypeParams = map[string]string{entity.TypeParamDim: strconv.Itoa(dim)}
+			}
+			fields = append(fields, &field)
+		}
+	}
+	return fields
+}
+
+// detectVersion 没有执行记录的旧集合，按已有字段推断版本：字段齐全的最高版本
+func detectVersion(schema *entity.Schema) int {
+	existing := make(map[string]bool, len(schema.Fields))
+	for _, f := range schema.Fields {
+		existing[f.Name] = true
+	}
+	version := 0
+	for _, m := range Migrations {
+		for _, f := range m.Fields {
+			if !existing[f.Name] {
+				return version
+			}
+		}
+		version = m.Version
+	}
+	return version
+}
+
+// CollectionDim 集合向量字段的维度，集合不存在时返回 0
+func CollectionDim(ctx context.Context, cli client.Client, collection string) (int, error) {
+	has, err := cli.HasCollection(ctx, collection)
+	if err != nil || !has {
+		return 0, err
+	}
+	coll, err := cli.DescribeCollection(ctx, collection)
+	if err != nil {
+		return 0, fmt.Errorf("查询集合 %s 失败: %v", collection, err)
+	}
+	for _, f := range coll.Schema.Fields {
+		if f.DataType == entity.FieldTypeFloatVector {
+			return strconv.Atoi(f.TypeParams[entity.TypeParamDim])
+		}
+	}
+	return 0, fmt.Errorf("集合 %s 没有向量字段", collection)
+}
+
+// Migrator 集合的建表和 schema 迁移
+type Migrator struct {
+	cli   client.Client
+	store MigrationStore
+}
+
+func NewMigrator(cli client.Client, store MigrationStore) *Migrator {
+	return &Migrator{cli: cli, store: store}
+}
+
+// Ensure 确保集合存在且是最新 schema，返回集合实际的 schema 版本
+//   - 集合不存在：按最新 schema 建表、建全部索引
+//   - 已是最新版本：只确认已加载，不做其他操作
+//   - 有待执行的索引变更：补建缺失的索引
+//   - 有待执行的字段变更：无法原地执行，停在当前版本并提示重建
+func (m *Migrator) Ensure(ctx context.Context, collection string, dim int) (int, error) {
+	has, err := m.cli.HasCollection(ctx, collection)
+	if err != nil {
+		return 0, fmt.Errorf("检查集合 %s 失败: %v", collection, err)
+	}
+	if !has {
+		return m.create(ctx, collection, dim)
+	}
+
+	applied, ok, err := m.store.AppliedVersion(ctx, collection)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		coll, err := m.cli.DescribeCollection(ctx, collection)
+		if err != nil {
+			return 0, fmt.Errorf("查询集合 %s 失败: %v", collection, err)
+		}
+		applied = detectVersion(coll.Schema)
+		fmt.Printf(">>> [Milvus Migrate] %s 没有迁移记录，按字段推断为 v%d\n", collection, applied)
+		for _, mig := range Migrations {
+			if mig.Version > applied {
+				break
+			}
+			// 推断出的版本，索引可能不全，补建一遍 (已存在的跳过)
+			if err := m.ensureIndexes(ctx, collection, mig.Indexes); err != nil {
+				return applied, err
+			}
+			if err := m.store.RecordMigration(ctx, collection, mig.Version, mig.Name); err != nil {
+				return applied, err
+			}
+		}
+	}
+
+	for _, mig := range Migrations {
+		if mig.Version <= applied {
+			continue
+		}
+		if len(mig.Fields) > 0 {
+			fmt.Printf("⚠️ [Milvus Migrate] %s 停在 v%d：v%d (%s) 需要新增字段，请重建集合 (POST /api/v1/embedding/versions)，重建前过滤条件按 v%d 的字段降级\n",
+				collection, applied, mig.Version, mig.Name, applied)
+			break
+		}
+		fmt.Printf(">>> [Milvus Migrate] %s 执行 v%d: %s\n", collection, mig.Version, mig.Name)
+		if err := m.ensureIndexes(ctx, collection, mig.Indexes); err != nil {
+			return applied, err
+		}
+		if err := m.store.RecordMigration(ctx, collection, mig.Version, mig.Name); err != nil {
+			return applied, err
+		}
+		applied = mig.Version
+	}
+
+	if err := m.load(ctx, collection); err != nil {
+		return applied, err
+	}
+	return applied, nil
+}
+
+// create 按最新 schema 建集合和全部索引，然后加载
+func (m *Migrator) create(ctx context.Context, collection string, dim int) (int, error) {
+	latest := LatestVersion()
+	schema := entity.NewSchema().WithName(collection)
+	for _, f := range FieldsAt(latest, dim) {
+		schema.WithField(f)
+	}
+	fmt.Printf(">>> [Milvus Migrate] 新建集合 %s (v%d, dim=%d)\n", collection, latest, dim)
+	if err := m.cli.CreateCollection(ctx, schema, entity.DefaultShardNumber); err != nil {
+		return 0, fmt.Errorf("创建集合 %s 失败: %v", collection, err)
+	}
+	for _, mig := range Migrations {
+		if err := m.ensureIndexes(ctx, collection, mig.Indexes); err != nil {
+			return 0, err
+		}
+		if err := m.store.RecordMigration(ctx, collection, mig.Version, mig.Name); err != nil {
+			return 0, err
+		}
+	}
+	if err := m.load(ctx, collection); err != nil {
+		return latest, err
+	}
+	return latest, nil
+}
+
+// ensureIndexes 创建缺失的索引；集合已加载时先释放，建完由 load 重新加载
+func (m *Migrator) ensureIndexes(ctx context.Context, collection string, specs []IndexSpec) error {
+	released := false
+	for _, spec := range specs {
+		if existing, err := m.cli.DescribeIndex(ctx, collection, spec.Field); err == nil && len(existing) > 0 {
+			continue
+		}
+		if !released {
+			_ = m.cli.ReleaseCollection(ctx, collection)
+			released = true
+		}
+		if err := m.cli.CreateIndex(ctx, collection, spec.Field, spec.Index, false); err != nil {
+			return fmt.Errorf("❌ 创建 %s 索引失败: %v", spec.Field, err)
+		}
+		fmt.Printf(">>> [Milvus Migrate] %s 已创建 %s 索引\n", collection, spec.Field)
+	}
+	return nil
+}
+
+// load 未加载时加载集合
+func (m *Migrator) load(ctx context.Context, collection string) error {
+	state, err := m.cli.GetLoadState(ctx, collection, nil)
+	if err == nil && state == entity.LoadStateLoaded {
+		return nil
+	}
+	fmt.Printf(">>> [Milvus] 正在 Load Collection %s...\n", collection)
+	if err := m.cli.LoadCollection(ctx, collection, false); err != nil {
+		return fmt.Errorf("Load Collection 失败: %v", err)
+	}
+	return nil
+}
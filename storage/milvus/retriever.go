@@ -2,14 +2,12 @@ package milvus
 
 import (
 	"context"
+	"eino-demo/storage/filter"
 	"eino-demo/types"
 	"fmt"
 	"log"
-	"strings"
 	"time"
 
-	"eino-demo/vars"
-
 	"github.com/cloudwego/eino-ext/components/retriever/milvus"
 	"github.com/cloudwego/eino/components/embedding"
 	"github.com/cloudwego/eino/schema"
@@ -17,10 +15,29 @@ import (
 	"github.com/milvus-io/milvus-sdk-go/v2/entity"
 )
 
+// Filter Milvus 检索的过滤条件
+type Filter struct {
+	Conditions *types.FilterConditions // 结构化条件，由 filter 包统一编译
+	DocIDs     []string                // 文档 ID 列表（限定在指定合同内检索）
+	// SchemaVersion 检索集合实际的 schema 版本 (VectorIndex.SchemaVersion)，按它决定能引用哪些字段；0 表示最新
+	SchemaVersion int
+}
+
 // Retrieve 执行向量检索（接收外部创建的 Client）
 // query: 语义查询语句 (semantic_query)
 // filters: 标量过滤
-func Retriever(ctx context.Context, cli client.Client, query string, filters *types.FilterConditions, emb embedding.Embedder) ([]*schema.Document, error) {
+// topK/offset: 分页，第 n 页 offset = (n-1)*topK
+// alias: 检索的别名 (milvus.alias)，换向量模型后切换别名即可，不用改这里
+func Retriever(ctx context.Context, cli client.Client, alias string, query string, filters *Filter, emb embedding.Embedder, topK, offset int) ([]*schema.Document, error) {
+	return retrieve(ctx, cli, alias, query, filters, emb, topK, offset)
+}
+
+// SearchCollection 在指定集合上检索，用于切换别名前校验新集合的召回
+func SearchCollection(ctx context.Context, cli client.Client, collection string, query string, emb embedding.Embedder, topK int) ([]*schema.Document, error) {
+	return retrieve(ctx, cli, collection, query, nil, emb, topK, 0)
+}
+
+func retrieve(ctx context.Context, cli client.Client, collection string, query string, filters *Filter, emb embedding.Embedder, topK, offset int) ([]*schema.Document, error) {
 
 	// 2. 自定义 DocumentConverter，包含分数信息
 	customConverter := func(ctx context.Context, result client.SearchResult) ([]*schema.Document, error) {
@@ -55,7 +72,7 @@ func Retriever(ctx context.Context, cli client.Client, query string, filters *ty
 					if err == nil {
 						doc.Content = value.(string)
 					}
-				case "party_a", "party_b", "contract_type":
+				case "doc_id", "party_a", "party_b", "party_names", "contract_type", "clause_type":
 					// VarChar 类型字段
 					value, err = field.GetAsString(i)
 					if err == nil {
@@ -93,12 +110,12 @@ func Retriever(ctx context.Context, cli client.Client, query string, filters *ty
 	// 3. 配置 Retriever
 	retr, err := milvus.NewRetriever(ctx, &milvus.RetrieverConfig{
 		Client:            cli,
-		Collection:        vars.COLLECTION,
+		Collection:        collection,
 		VectorField:       "vector",
-		OutputFields:      []string{"content"}, // 先只返回 content，测试是否能工作
+		OutputFields:      outputFields,
 		DocumentConverter: customConverter,
 		MetricType:        entity.L2,
-		TopK:              10,
+		TopK:              topK,
 		Embedding:         emb,
 	})
 	if err != nil {
@@ -107,7 +124,7 @@ func Retriever(ctx context.Context, cli client.Client, query string, filters *ty
 
 	// 4. 确保 Collection 已加载到内存（关键优化！）
 	loadStart := time.Now()
-	err = cli.LoadCollection(ctx, vars.COLLECTION, false)
+	err = cli.LoadCollection(ctx, collection, false)
 	if err != nil {
 		log.Printf("⚠️ LoadCollection warning: %v", err)
 		// 不中断，继续尝试查询
@@ -115,7 +132,7 @@ func Retriever(ctx context.Context, cli client.Client, query string, filters *ty
 		// 等待加载完成（最多 5 秒）
 		loadDeadline := time.Now().Add(5 * time.Second)
 		for time.Now().Before(loadDeadline) {
-			loadState, _ := cli.GetLoadState(ctx, vars.COLLECTION, []string{})
+			loadState, _ := cli.GetLoadState(ctx, collection, []string{})
 			// 3 = LoadStateLoaded
 			if loadState == 3 {
 				break
@@ -125,10 +142,16 @@ func Retriever(ctx context.Context, cli client.Client, query string, filters *ty
 		log.Printf(">>> [Milvus] Collection 加载耗时: %v", time.Since(loadStart))
 	}
 
-	// 5. 构建 doc_id 过滤表达式（如果有）
+	// 5. 构建过滤表达式
+	expr, err := BuildExpr(filters)
+	if err != nil {
+		return nil, fmt.Errorf("build milvus filter failed: %v", err)
+	}
 
-	fmt.Println(">>> [Milvus] 全局语义检索")
-	docs, err := retr.Retrieve(ctx, query, milvus.WithFilter(BuildExpr(filters)))
+	fmt.Printf(">>> [Milvus] 全局语义检索 (%s), filter: %s, topK: %d, offset: %d\n", collection, expr, topK, offset)
+	docs, err := retr.Retrieve(ctx, query, milvus.WithFilter(expr), milvus.WithSearchQueryOptFn(func(o *client.SearchQueryOption) {
+		o.Offset = int64(offset)
+	}))
 
 	if err != nil {
 		return nil, fmt.Errorf("milvus retrieve failed: %v", err)
@@ -145,80 +168,24 @@ func Retriever(ctx context.Context, cli client.Client, query string, filters *ty
 	return docs, nil
 }
 
-// 构建过滤表达式
-func BuildExpr(filters *types.FilterConditions) string {
-	var exprs []string
-
-	// 1. 处理 AnyParty (多个实体，每个都要匹配 party_a 或 party_b)
-	if len(filters.AnyParty) > 0 {
-		var partyExprs []string
-		for _, party := range filters.AnyParty {
-			partyExprs = append(partyExprs, fmt.Sprintf("(party_a == '%s' || party_b == '%s')", party, party))
-		}
-		// 将所有实体条件用 || 连接：(p1匹配) || (p2匹配) || (p3匹配)
-		exprs = append(exprs, fmt.Sprintf("(%s)", strings.Join(partyExprs, " || ")))
-	}
-
-	// 2. 精确匹配 PartyA
-	if filters.PartyA != "" {
-		exprs = append(exprs, fmt.Sprintf("party_a == '%s'", filters.PartyA))
-	}
-
-	// 3. 精确匹配 PartyB
-	if filters.PartyB != "" {
-		exprs = append(exprs, fmt.Sprintf("party_b == '%s'", filters.PartyB))
-	}
-
-	// 4. 合同类型
-	if filters.ContractType != "" {
-		exprs = append(exprs, fmt.Sprintf("contract_type == '%s'", filters.ContractType))
-	}
-
-	// 5. 状态 (假设 Service 层已转为 int，如果是字符串则加单引号)
-	if filters.Status != "" {
-		// 示例：如果存储的是字符串
-		exprs = append(exprs, fmt.Sprintf("contract_status == '%s'", filters.Status))
-	}
-
-	// 6. 日期范围 (需要转换为 Unix 时间戳)
-	if filters.DateRange != nil {
-		if filters.DateRange.Start != "" {
-			// 将 "2023-01-01" 转换为 Unix 时间戳
-			if t, err := parseDateToTimestamp(filters.DateRange.Start); err == nil {
-				exprs = append(exprs, fmt.Sprintf("sign_date >= %d", t))
-			}
-		}
-		if filters.DateRange.End != "" {
-			// 结束日期需要设置为当天 23:59:59
-			if t, err := parseDateToTimestamp(filters.DateRange.End); err == nil {
-				// 加上 86399 秒 (一天减去1秒) 以覆盖全天
-				exprs = append(exprs, fmt.Sprintf("sign_date <= %d", t+86399))
-			}
-		}
-	}
+// outputFields 检索返回的标量字段 (分页结果需要 doc_id 和排序字段)
+var outputFields = []string{
+	"content", "doc_id", "party_a", "party_b", "contract_type", "clause_type",
+	"sign_date", "end_date", "contract_status", "amount",
+}
 
-	// 7. 金额范围 (假设字段名为 amount)
-	if filters.AmountRange != nil {
-		if filters.AmountRange.Min != nil && *filters.AmountRange.Min > 0 {
-			exprs = append(exprs, fmt.Sprintf("amount >= %f", *filters.AmountRange.Min))
-		}
-		if filters.AmountRange.Max != nil && *filters.AmountRange.Max > 0 {
-			exprs = append(exprs, fmt.Sprintf("amount <= %f", *filters.AmountRange.Max))
-		}
+// BuildExpr 构建过滤表达式，由 filter 包统一编译 (字符串已转义)
+func BuildExpr(filters *Filter) (string, error) {
+	if filters == nil {
+		return "", nil
 	}
-
-	// 使用 && 连接所有条件
-	return strings.Join(exprs, " && ")
+	node := filter.And(filter.FromConditions(filters.Conditions), filter.In(filter.FieldDocID, filters.DocIDs...))
+	return filter.ToMilvusWith(node, filterOptions(filters.SchemaVersion))
 }
 
-// parseDateToTimestamp 将 "YYYY-MM-DD" 格式的日期转换为 Unix 时间戳
-func parseDateToTimestamp(dateStr string) (int64, error) {
-	layout := "2006-01-02"
-	t, err := time.Parse(layout, dateStr)
-	if err != nil {
-		return 0, fmt.Errorf("failed to parse date %s: %w", dateStr, err)
-	}
-	return t.Unix(), nil
+// filterOptions 旧版本集合缺少的字段在过滤时降级
+func filterOptions(version int) filter.MilvusOptions {
+	return filter.MilvusOptions{NoPartyNames: version > 0 && version < partyNamesVersion}
 }
 
 // truncateString 截断字符串用于显示
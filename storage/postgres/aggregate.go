@@ -0,0 +1,113 @@
+package postgres
+
+import (
+	"context"
+	"eino-demo/types"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// 聚合 SQL 只允许白名单里的表达式，LLM 输出的指标/维度名不会直接拼进 SQL
+var (
+	metricExprs = map[string]string{
+		types.MetricCount: "COUNT(*)",
+		types.MetricSum:   "COALESCE(SUM(c.total_amount), 0)::float8",
+		types.MetricAvg:   "COALESCE(AVG(c.total_amount), 0)::float8",
+		types.MetricMax:   "COALESCE(MAX(c.total_amount), 0)::float8",
+		types.MetricMin:   "COALESCE(MIN(c.total_amount), 0)::float8",
+	}
+	groupExprs = map[string]string{
+		types.GroupByParty:        partyNameExpr,
+		types.GroupByContractType: "COALESCE(NULLIF(c.contract_type, ''), '未知')",
+		types.GroupByYear:         "COALESCE(to_char(c.sign_date, 'YYYY'), '未知')",
+		types.GroupByMonth:        "COALESCE(to_char(c.sign_date, 'YYYY-MM'), '未知')",
+		types.GroupByStatus:       "c.contract_status::int8",
+	}
+)
+
+// partyNameExpr 参与方主体规范名 (需配合 joinParties)
+const partyNameExpr = "p.canonical_name"
+
+// joinParties 关联合同的参与方主体，同一合同对同一主体只计一次
+func joinParties(tx *gorm.DB) *gorm.DB {
+	return tx.Joins("JOIN (SELECT DISTINCT doc_id, party_id FROM contract_parties) cp ON cp.doc_id = c.doc_id").
+		Joins("JOIN parties p ON p.id = cp.party_id")
+}
+
+// maxAggregateGroups 聚合结果最多返回的分组数
+const maxAggregateGroups = 100
+
+// Aggregate 按结构化条件过滤合同后做聚合统计
+// 按 party 分组时，一份合同对它的每个参与方主体各计一次
+func (r *ContractRepo) Aggregate(ctx context.Context, conditions *types.FilterConditions, spec types.AggregationSpec) (*types.AggregateResult, error) {
+	metricExpr, ok := metricExprs[spec.Metric]
+	if !ok {
+		return nil, fmt.Errorf("不支持的聚合指标: %s", spec.Metric)
+	}
+
+	// 1. 先按条件过滤出合同，作为子查询 c，避免与关联表的列名冲突
+	base, err := applyFilter(r.db.Model(&Contract{}), conditions)
+	if err != nil {
+		return nil, err
+	}
+	tx := r.db.WithContext(ctx).Table("(?) AS c", base)
+
+	// 2. 分组维度
+	var selects, groups, orders []string
+	for i, g := range spec.GroupBy {
+		expr, ok := groupExprs[g]
+		if !ok {
+			return nil, fmt.Errorf("不支持的分组维度: %s", g)
+		}
+		alias := fmt.Sprintf("g%d", i)
+		selects = append(selects, fmt.Sprintf("%s AS %s", expr, alias))
+		groups = append(groups, alias)
+		// 时间维度按时间顺序排列，其余维度按指标降序
+		if g == types.GroupByYear || g == types.GroupByMonth {
+			orders = append(orders, alias)
+		}
+		if g == types.GroupByParty {
+			tx = joinParties(tx)
+		}
+	}
+	selects = append(selects, metricExpr+" AS value")
+	orders = append(orders, "value DESC")
+
+	tx = tx.Select(strings.Join(selects, ", "))
+	if len(groups) > 0 {
+		tx = tx.Group(strings.Join(groups, ", ")).Order(strings.Join(orders, ", ")).Limit(maxAggregateGroups)
+	}
+
+	// 3. 扫描结果
+	rows, err := tx.Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := &types.AggregateResult{
+		Metric:  spec.Metric,
+		GroupBy: spec.GroupBy,
+		Columns: append(append([]string{}, spec.GroupBy...), spec.Metric),
+		Rows:    [][]any{},
+	}
+	for rows.Next() {
+		values := make([]any, len(result.Columns))
+		ptrs := make([]any, len(values))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		for i, v := range values {
+			if b, ok := v.([]byte); ok {
+				values[i] = string(b)
+			}
+		}
+		result.Rows = append(result.Rows, values)
+	}
+	return result, rows.Err()
+}
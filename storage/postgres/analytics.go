@@ -0,0 +1,113 @@
+package postgres
+
+import (
+	"context"
+	"eino-demo/storage/filter"
+	"eino-demo/types"
+	"fmt"
+)
+
+// 分析维度的分组表达式 (白名单)
+var statGroupExprs = map[string]string{
+	types.GroupByContractType: "COALESCE(NULLIF(c.contract_type, ''), '未知')",
+	types.GroupByStatus:       "c.contract_status::text",
+	types.GroupByParty:        partyNameExpr,
+}
+
+// periodExpr 日期列按年/月取周期，列名只来自代码内常量
+func periodExpr(column, period string) (string, error) {
+	switch period {
+	case types.PeriodYear:
+		return fmt.Sprintf("COALESCE(to_char(c.%s, 'YYYY'), '未知')", column), nil
+	case types.PeriodMonth:
+		return fmt.Sprintf("COALESCE(to_char(c.%s, 'YYYY-MM'), '未知')", column), nil
+	}
+	return "", fmt.Errorf("不支持的统计周期: %s", period)
+}
+
+// statRow 分组统计的原始行
+type statRow struct {
+	K      string  `gorm:"column:k"`
+	Count  int64   `gorm:"column:count"`
+	Amount float64 `gorm:"column:amount"`
+}
+
+// groupStats 过滤后按 keyExpr 分组统计合同数和金额
+// byValue 为 true 时按金额降序，否则按分组键升序；limit <= 0 表示不限
+func (r *ContractRepo) groupStats(ctx context.Context, node *filter.Node, keyExpr string, withParties, byValue bool, limit int) ([]statRow, error) {
+	base, err := applyNode(r.db.Model(&Contract{}), node)
+	if err != nil {
+		return nil, err
+	}
+	tx := r.db.WithContext(ctx).Table("(?) AS c", base)
+	if withParties {
+		tx = joinParties(tx)
+	}
+	tx = tx.Select(keyExpr + " AS k, COUNT(*) AS count, COALESCE(SUM(c.total_amount), 0)::float8 AS amount").Group("k")
+	if byValue {
+		tx = tx.Order("amount DESC, count DESC")
+	} else {
+		tx = tx.Order("k")
+	}
+	if limit > 0 {
+		tx = tx.Limit(limit)
+	}
+	var rows []statRow
+	err = tx.Scan(&rows).Error
+	return rows, err
+}
+
+// StatsByPeriod 按签署年/月统计合同数和金额
+func (r *ContractRepo) StatsByPeriod(ctx context.Context, conditions *types.FilterConditions, period string) (types.PeriodStats, error) {
+	expr, err := periodExpr("sign_date", period)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.groupStats(ctx, filter.FromConditions(conditions), expr, false, false, 0)
+	if err != nil {
+		return nil, err
+	}
+	stats := make(types.PeriodStats, len(rows))
+	for i, row := range rows {
+		stats[i] = types.PeriodStat{Period: row.K, Count: row.Count, Amount: row.Amount}
+	}
+	return stats, nil
+}
+
+// ExpiringByPeriod 按截止日期年/月统计到期的合同，endRange 作用于 end_date
+func (r *ContractRepo) ExpiringByPeriod(ctx context.Context, conditions *types.FilterConditions, endRange *filter.Node, period string) (types.PeriodStats, error) {
+	expr, err := periodExpr("end_date", period)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.groupStats(ctx, filter.And(filter.FromConditions(conditions), endRange), expr, false, false, 0)
+	if err != nil {
+		return nil, err
+	}
+	stats := make(types.PeriodStats, 0, len(rows))
+	for _, row := range rows {
+		// 没有截止日期 (长期合同) 的不算到期
+		if row.K == "未知" {
+			continue
+		}
+		stats = append(stats, types.PeriodStat{Period: row.K, Count: row.Count, Amount: row.Amount})
+	}
+	return stats, nil
+}
+
+// StatsByGroup 按合同类型、状态或参与方主体统计，limit <= 0 表示不限
+func (r *ContractRepo) StatsByGroup(ctx context.Context, conditions *types.FilterConditions, dim string, limit int) (types.GroupStats, error) {
+	expr, ok := statGroupExprs[dim]
+	if !ok {
+		return nil, fmt.Errorf("不支持的统计维度: %s", dim)
+	}
+	rows, err := r.groupStats(ctx, filter.FromConditions(conditions), expr, dim == types.GroupByParty, true, limit)
+	if err != nil {
+		return nil, err
+	}
+	stats := make(types.GroupStats, len(rows))
+	for i, row := range rows {
+		stats[i] = types.GroupStat{Key: row.K, Label: row.K, Count: row.Count, Amount: row.Amount}
+	}
+	return stats, nil
+}
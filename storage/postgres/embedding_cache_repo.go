@@ -0,0 +1,55 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// embeddingCacheBatch 单次 IN 查询/批量写入的条数
+const embeddingCacheBatch = 500
+
+// EmbeddingCacheRepo 向量缓存表的读写，实现 cache.VectorStore
+type EmbeddingCacheRepo struct {
+	db *gorm.DB
+}
+
+func NewEmbeddingCacheRepo(db *gorm.DB) *EmbeddingCacheRepo {
+	return &EmbeddingCacheRepo{db: db}
+}
+
+// GetVectors 按文本 hash 批量查询
+func (r *EmbeddingCacheRepo) GetVectors(ctx context.Context, model string, hashes []string) (map[string][]byte, error) {
+	vectors := make(map[string][]byte, len(hashes))
+	for start := 0; start < len(hashes); start += embeddingCacheBatch {
+		end := min(start+embeddingCacheBatch, len(hashes))
+		var rows []EmbeddingCache
+		err := r.db.WithContext(ctx).
+			Where("model = ? AND text_hash IN ?", model, hashes[start:end]).
+			Find(&rows).Error
+		if err != nil {
+			return vectors, err
+		}
+		for _, row := range rows {
+			vectors[row.TextHash] = row.Vector
+		}
+	}
+	return vectors, nil
+}
+
+// SaveVectors 批量写入，主键冲突 (并发入库相同文本) 时忽略
+func (r *EmbeddingCacheRepo) SaveVectors(ctx context.Context, model string, vectors map[string][]byte) error {
+	if len(vectors) == 0 {
+		return nil
+	}
+	now := time.Now()
+	rows := make([]EmbeddingCache, 0, len(vectors))
+	for hash, vector := range vectors {
+		rows = append(rows, EmbeddingCache{Model: model, TextHash: hash, Dim: len(vector) / 8, Vector: vector, CreatedAt: now})
+	}
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		CreateInBatches(rows, embeddingCacheBatch).Error
+}
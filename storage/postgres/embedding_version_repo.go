@@ -0,0 +1,85 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EmbeddingVersionRepo 向量集合版本的读写
+type EmbeddingVersionRepo struct {
+	db *gorm.DB
+}
+
+func NewEmbeddingVersionRepo(db *gorm.DB) *EmbeddingVersionRepo {
+	return &EmbeddingVersionRepo{db: db}
+}
+
+func (r *EmbeddingVersionRepo) Create(ctx context.Context, v *EmbeddingVersion) error {
+	return r.db.WithContext(ctx).Create(v).Error
+}
+
+// Get 按集合名查询
+func (r *EmbeddingVersionRepo) Get(ctx context.Context, collection string) (*EmbeddingVersion, error) {
+	var v EmbeddingVersion
+	if err := r.db.WithContext(ctx).Where("collection = ?", collection).First(&v).Error; err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// GetActive 当前在用的版本，没有时返回 nil
+func (r *EmbeddingVersionRepo) GetActive(ctx context.Context) (*EmbeddingVersion, error) {
+	var v EmbeddingVersion
+	err := r.db.WithContext(ctx).Where("status = ?", EmbeddingStatusActive).First(&v).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// LatestRetired 最近一次下线的版本 (回滚目标)，没有时返回 nil
+func (r *EmbeddingVersionRepo) LatestRetired(ctx context.Context) (*EmbeddingVersion, error) {
+	var v EmbeddingVersion
+	err := r.db.WithContext(ctx).
+		Where("status = ?", EmbeddingStatusRetired).
+		Order("activated_at DESC NULLS LAST").
+		First(&v).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// List 全部版本，新建的在前
+func (r *EmbeddingVersionRepo) List(ctx context.Context) ([]EmbeddingVersion, error) {
+	var versions []EmbeddingVersion
+	err := r.db.WithContext(ctx).Order("created_at DESC").Find(&versions).Error
+	return versions, err
+}
+
+// Update 更新指定字段
+func (r *EmbeddingVersionRepo) Update(ctx context.Context, collection string, fields map[string]any) error {
+	return r.db.WithContext(ctx).Model(&EmbeddingVersion{}).Where("collection = ?", collection).Updates(fields).Error
+}
+
+// Activate 事务内把当前在用的版本标为 retired，目标版本标为 active
+func (r *EmbeddingVersionRepo) Activate(ctx context.Context, collection string, now time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&EmbeddingVersion{}).
+			Where("status = ? AND collection <> ?", EmbeddingStatusActive, collection).
+			Update("status", EmbeddingStatusRetired).Error; err != nil {
+			return err
+		}
+		return tx.Model(&EmbeddingVersion{}).Where("collection = ?", collection).
+			Updates(map[string]any{"status": EmbeddingStatusActive, "activated_at": now}).Error
+	})
+}
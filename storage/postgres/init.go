@@ -33,3 +33,20 @@ func InitDB(dsn string) (*gorm.DB, error) {
 	log.Println("PostgreSQL connected successfully")
 	return db, nil
 }
+
+// AutoMigrate 根据 model 自动建表/补齐字段
+func AutoMigrate(db *gorm.DB) error {
+	return db.AutoMigrate(
+		&Contract{},
+		&ContractClause{},
+		&ContractParty{},
+		&Party{},
+		&PartyAlias{},
+		&EmbeddingCache{},
+		&ContractChunk{},
+		&EmbeddingVersion{},
+		&MilvusMigration{},
+		&SearchLog{},
+		&IntentExample{},
+	)
+}
@@ -0,0 +1,40 @@
+package postgres
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// IntentExampleRepo 意图示例的读写
+type IntentExampleRepo struct {
+	db *gorm.DB
+}
+
+func NewIntentExampleRepo(db *gorm.DB) *IntentExampleRepo {
+	return &IntentExampleRepo{db: db}
+}
+
+// ListAll 全部示例，按创建时间排序
+func (r *IntentExampleRepo) ListAll(ctx context.Context) ([]IntentExample, error) {
+	var examples []IntentExample
+	err := r.db.WithContext(ctx).Order("created_at").Find(&examples).Error
+	return examples, err
+}
+
+func (r *IntentExampleRepo) Get(ctx context.Context, id string) (*IntentExample, error) {
+	var e IntentExample
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&e).Error; err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// Save 新增或整条覆盖
+func (r *IntentExampleRepo) Save(ctx context.Context, e *IntentExample) error {
+	return r.db.WithContext(ctx).Save(e).Error
+}
+
+func (r *IntentExampleRepo) Delete(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Where("id = ?", id).Delete(&IntentExample{}).Error
+}
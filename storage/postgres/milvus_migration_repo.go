@@ -0,0 +1,38 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MilvusMigrationRepo Milvus 集合 schema 迁移记录，实现 milvus.MigrationStore
+type MilvusMigrationRepo struct {
+	db *gorm.DB
+}
+
+func NewMilvusMigrationRepo(db *gorm.DB) *MilvusMigrationRepo {
+	return &MilvusMigrationRepo{db: db}
+}
+
+// AppliedVersion 集合已执行的最高版本
+func (r *MilvusMigrationRepo) AppliedVersion(ctx context.Context, collection string) (int, bool, error) {
+	var version *int
+	err := r.db.WithContext(ctx).Model(&MilvusMigration{}).
+		Where("collection = ?", collection).
+		Select("MAX(version)").
+		Scan(&version).Error
+	if err != nil || version == nil {
+		return 0, false, err
+	}
+	return *version, true, nil
+}
+
+// RecordMigration 记录一次迁移，重复记录时忽略
+func (r *MilvusMigrationRepo) RecordMigration(ctx context.Context, collection string, version int, name string) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&MilvusMigration{Collection: collection, Version: version, Name: name, AppliedAt: time.Now()}).Error
+}
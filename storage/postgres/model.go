@@ -19,6 +19,8 @@ type Contract struct {
 	TotalAmount    float64    `gorm:"column:total_amount;type:decimal(15,2)"`
 	//RawContent  string     `gorm:"column:raw_content;type:text"`
 	Summary string `gorm:"column:summary;type:text"`
+	// PromptVersion 结构化提取所用的提示词版本，如 extract/v1
+	PromptVersion string `gorm:"column:prompt_version;type:varchar(64);index"`
 
 	CreatedAt time.Time
 	UpdatedAt time.Time
@@ -32,3 +34,197 @@ func (Contract) TableName() string {
 func (c *Contract) IsActive() bool {
 	return c.ContractStatus == types.StatusActive
 }
+
+// ContractClause 对应 contract_clauses 表，合同按条款切分后的结果
+type ContractClause struct {
+	ID          string `gorm:"column:id;primaryKey;type:uuid"`
+	DocID       string `gorm:"column:doc_id;type:uuid;not null;index"`
+	Seq         int    `gorm:"column:seq"`                                // 条款在合同中的顺序
+	ClauseType  string `gorm:"column:clause_type;type:varchar(32);index"` // payment, delivery ...
+	Title       string `gorm:"column:title;type:varchar(255)"`
+	Content     string `gorm:"column:content;type:text"`
+	StartOffset int    `gorm:"column:start_offset"` // 原文 rune 偏移
+	EndOffset   int    `gorm:"column:end_offset"`
+
+	CreatedAt time.Time
+}
+
+func (ContractClause) TableName() string {
+	return "contract_clauses"
+}
+
+// ContractParty 对应 contract_parties 表，记录合同的全部参与方及角色
+type ContractParty struct {
+	ID             string `gorm:"column:id;primaryKey;type:uuid"`
+	DocID          string `gorm:"column:doc_id;type:uuid;not null;index"`
+	Role           string `gorm:"column:role;type:varchar(16);index"` // 甲方/乙方/丙方/担保人/签署代表
+	Name           string `gorm:"column:name;type:varchar(255)"`      // 原文名称
+	NormalizedName string `gorm:"column:normalized_name;type:varchar(255);index"`
+	EntityType     string `gorm:"column:entity_type;type:varchar(16)"`    // person / organization
+	PartyID        string `gorm:"column:party_id;type:varchar(36);index"` // 归一后的主体 ID (parties 表)
+
+	CreatedAt time.Time
+}
+
+func (ContractParty) TableName() string {
+	return "contract_parties"
+}
+
+// Party 对应 parties 表，参与方主体登记簿，一个主体对应多个别名
+type Party struct {
+	ID            string       `gorm:"column:id;primaryKey;type:uuid" json:"id"`
+	CanonicalName string       `gorm:"column:canonical_name;type:varchar(255);not null" json:"canonical_name"`
+	EntityType    string       `gorm:"column:entity_type;type:varchar(16)" json:"entity_type"`
+	Aliases       []PartyAlias `gorm:"foreignKey:PartyID" json:"aliases,omitempty"`
+	// 入库时与已有主体模糊匹配达到阈值，不自动归并，而是单独建主体并记下建议合并的目标，
+	// 人工确认后调用 merge 接口合并，或忽略建议
+	SuggestedPartyID string  `gorm:"column:suggested_party_id;type:varchar(36);index" json:"suggested_party_id,omitempty"`
+	SuggestionScore  float64 `gorm:"column:suggestion_score" json:"suggestion_score,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (Party) TableName() string {
+	return "parties"
+}
+
+// 别名来源
+const (
+	AliasSourceCanonical = "canonical" // 主体的规范名本身
+	AliasSourceAuto      = "auto"      // 早期版本入库时模糊匹配自动挂载，待确认 (现在改为建议合并，见 Party.SuggestedPartyID)
+	AliasSourceManual    = "manual"    // 通过 API 人工添加
+)
+
+// PartyAlias 对应 party_aliases 表，规范化后的别名全局唯一
+type PartyAlias struct {
+	ID        string  `gorm:"column:id;primaryKey;type:uuid" json:"id"`
+	PartyID   string  `gorm:"column:party_id;type:uuid;not null;index" json:"party_id"`
+	Alias     string  `gorm:"column:alias;type:varchar(255);not null;uniqueIndex" json:"alias"`
+	Source    string  `gorm:"column:source;type:varchar(16)" json:"source"`
+	Score     float64 `gorm:"column:score" json:"score"` // 自动挂载时的相似度
+	Confirmed bool    `gorm:"column:confirmed;default:false" json:"confirmed"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (PartyAlias) TableName() string {
+	return "party_aliases"
+}
+
+// EmbeddingCache 对应 embedding_cache 表，按 (模型名, 文本 sha256) 缓存向量
+type EmbeddingCache struct {
+	Model    string `gorm:"column:model;primaryKey;type:varchar(64)"`
+	TextHash string `gorm:"column:text_hash;primaryKey;type:char(64)"`
+	Dim      int    `gorm:"column:dim"`
+	Vector   []byte `gorm:"column:vector;type:bytea;not null"` // float64 小端序
+
+	CreatedAt time.Time
+}
+
+func (EmbeddingCache) TableName() string {
+	return "embedding_cache"
+}
+
+// ContractChunk 对应 contract_chunks 表，保存切片原文
+// ES/Milvus 里的切片都以这里为准，换向量模型时从这张表重新向量化
+type ContractChunk struct {
+	ID         string `gorm:"column:id;primaryKey;type:uuid"` // 与 ES _id、Milvus 主键一致
+	DocID      string `gorm:"column:doc_id;type:uuid;not null;index"`
+	Seq        int    `gorm:"column:seq"` // 切片在合同中的顺序
+	ClauseType string `gorm:"column:clause_type;type:varchar(32)"`
+	Content    string `gorm:"column:content;type:text"`
+
+	CreatedAt time.Time `gorm:"index"`
+}
+
+func (ContractChunk) TableName() string {
+	return "contract_chunks"
+}
+
+// 向量集合版本状态
+const (
+	EmbeddingStatusBuilding = "building" // 正在重新向量化
+	EmbeddingStatusReady    = "ready"    // 构建完成且召回校验通过，可以切换
+	EmbeddingStatusFailed   = "failed"   // 构建失败或召回校验不通过
+	EmbeddingStatusActive   = "active"   // 检索别名当前指向的集合
+	EmbeddingStatusRetired  = "retired"  // 曾经在用，保留用于回滚
+)
+
+// EmbeddingVersion 对应 embedding_versions 表，每个 Milvus 集合一条，记录所用模型和构建进度
+type EmbeddingVersion struct {
+	Collection  string     `gorm:"column:collection;primaryKey;type:varchar(255)" json:"collection"`
+	Model       string     `gorm:"column:model;type:varchar(64);not null" json:"model"`
+	Dim         int        `gorm:"column:dim" json:"dim"`
+	Status      string     `gorm:"column:status;type:varchar(16);index" json:"status"`
+	TotalChunks int64      `gorm:"column:total_chunks" json:"total_chunks"`
+	DoneChunks  int64      `gorm:"column:done_chunks" json:"done_chunks"`
+	Recall      float64    `gorm:"column:recall" json:"recall"` // 抽样自检的召回率
+	SampleSize  int        `gorm:"column:sample_size" json:"sample_size"`
+	Error       string     `gorm:"column:error;type:text" json:"error,omitempty"`
+	SyncedAt    *time.Time `gorm:"column:synced_at" json:"synced_at,omitempty"` // 已追平到这个时间之前入库的切片
+	ActivatedAt *time.Time `gorm:"column:activated_at" json:"activated_at,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (EmbeddingVersion) TableName() string {
+	return "embedding_versions"
+}
+
+// MilvusMigration 对应 milvus_migrations 表，每个 Milvus 集合已执行的 schema 迁移
+type MilvusMigration struct {
+	Collection string `gorm:"column:collection;primaryKey;type:varchar(255)"`
+	Version    int    `gorm:"column:version;primaryKey"`
+	Name       string `gorm:"column:name;type:varchar(255)"`
+
+	AppliedAt time.Time `gorm:"column:applied_at"`
+}
+
+func (MilvusMigration) TableName() string {
+	return "milvus_migrations"
+}
+
+// SearchLog 对应 search_logs 表，每次检索请求一条，按提示词版本对比意图解析的效果
+type SearchLog struct {
+	ID            string `gorm:"column:id;primaryKey;type:uuid" json:"id"`
+	Query         string `gorm:"column:query;type:text" json:"query"`
+	Intent        string `gorm:"column:intent;type:varchar(32);index" json:"intent"` // 实际执行的计划
+	PromptVersion string `gorm:"column:prompt_version;type:varchar(64);index" json:"prompt_version"`
+	Parsed        string `gorm:"column:parsed;type:text" json:"parsed,omitempty"` // 解析出的完整意图 JSON，人工纠正后可加入意图示例
+	Page          bool   `gorm:"column:page" json:"page"`                         // 翻页请求，意图沿用游标
+	Cached        bool   `gorm:"column:cached" json:"cached"`                     // 命中结果缓存
+	Fallback      string `gorm:"column:fallback;type:text" json:"fallback,omitempty"`
+	Hits          int    `gorm:"column:hits" json:"hits"` // 返回的合同和片段数
+	LatencyMs     int64  `gorm:"column:latency_ms" json:"latency_ms"`
+	Error         string `gorm:"column:error;type:text" json:"error,omitempty"`
+
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+func (SearchLog) TableName() string {
+	return "search_logs"
+}
+
+// 意图示例来源
+const (
+	ExampleSourceManual    = "manual"     // 通过 API 直接添加
+	ExampleSourceSearchLog = "search_log" // 纠正线上查询 (search_logs) 的解析结果
+)
+
+// IntentExample 对应 intent_examples 表，意图解析的标注示例 (查询 -> 正确的 SearchIntent)
+type IntentExample struct {
+	ID          string `gorm:"column:id;primaryKey;type:uuid"`
+	Query       string `gorm:"column:query;type:text;not null"`
+	Intent      string `gorm:"column:intent;type:text;not null"` // types.SearchIntent 的 JSON
+	Source      string `gorm:"column:source;type:varchar(16)"`
+	SearchLogID string `gorm:"column:search_log_id;type:varchar(36);index"`
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (IntentExample) TableName() string {
+	return "intent_examples"
+}
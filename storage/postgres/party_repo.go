@@ -0,0 +1,196 @@
+package postgres
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// PartyRepo 封装参与方主体登记簿 (parties / party_aliases) 的操作
+type PartyRepo struct {
+	db *gorm.DB
+}
+
+// NewPartyRepo 构造函数
+func NewPartyRepo(db *gorm.DB) *PartyRepo {
+	return &PartyRepo{db: db}
+}
+
+// GetAlias 按规范化后的别名精确查询，未找到返回 gorm.ErrRecordNotFound
+func (r *PartyRepo) GetAlias(ctx context.Context, alias string) (*PartyAlias, error) {
+	var a PartyAlias
+	err := r.db.WithContext(ctx).Where("alias = ?", alias).First(&a).Error
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// ListAll 查询全部主体及其别名 (用于模糊匹配)
+func (r *PartyRepo) ListAll(ctx context.Context) ([]Party, error) {
+	var parties []Party
+	err := r.db.WithContext(ctx).Preload("Aliases").Find(&parties).Error
+	return parties, err
+}
+
+// ListParties 分页查询主体，keyword 非空时按规范名或别名模糊过滤
+func (r *PartyRepo) ListParties(ctx context.Context, keyword string, limit, offset int) ([]Party, int64, error) {
+	tx := r.db.WithContext(ctx).Model(&Party{})
+	if keyword != "" {
+		pattern := "%" + keyword + "%"
+		tx = tx.Where("canonical_name LIKE ? OR id IN (?)", pattern,
+			r.db.Model(&PartyAlias{}).Select("party_id").Where("alias LIKE ?", pattern))
+	}
+	var total int64
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var parties []Party
+	err := tx.Preload("Aliases").Order("canonical_name").Limit(limit).Offset(offset).Find(&parties).Error
+	return parties, total, err
+}
+
+// GetParty 查询单个主体及其别名
+func (r *PartyRepo) GetParty(ctx context.Context, id string) (*Party, error) {
+	var p Party
+	err := r.db.WithContext(ctx).Preload("Aliases").Where("id = ?", id).First(&p).Error
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// CreateParty 新建主体，同时登记其规范名别名
+func (r *PartyRepo) CreateParty(ctx context.Context, party *Party, alias *PartyAlias) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Aliases").Create(party).Error; err != nil {
+			return err
+		}
+		return tx.Create(alias).Error
+	})
+}
+
+// UpdateParty 修改主体规范名或主体类型
+func (r *PartyRepo) UpdateParty(ctx context.Context, id string, canonicalName, entityType string) error {
+	updates := map[string]interface{}{}
+	if canonicalName != "" {
+		updates["canonical_name"] = canonicalName
+	}
+	if entityType != "" {
+		updates["entity_type"] = entityType
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Model(&Party{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// AddAlias 给主体添加别名
+func (r *PartyRepo) AddAlias(ctx context.Context, alias *PartyAlias) error {
+	return r.db.WithContext(ctx).Create(alias).Error
+}
+
+// DeleteAlias 删除主体的某个别名
+func (r *PartyRepo) DeleteAlias(ctx context.Context, partyID, aliasID string) error {
+	return r.db.WithContext(ctx).Where("id = ? AND party_id = ?", aliasID, partyID).Delete(&PartyAlias{}).Error
+}
+
+// ConfirmAlias 人工确认自动挂载的别名
+func (r *PartyRepo) ConfirmAlias(ctx context.Context, partyID, aliasID string) error {
+	return r.db.WithContext(ctx).Model(&PartyAlias{}).
+		Where("id = ? AND party_id = ?", aliasID, partyID).
+		Update("confirmed", true).Error
+}
+
+// ListMergeSuggestions 查询带合并建议的主体 (含别名)，按相似度降序
+func (r *PartyRepo) ListMergeSuggestions(ctx context.Context) ([]Party, error) {
+	var parties []Party
+	err := r.db.WithContext(ctx).Preload("Aliases").
+		Where("suggested_party_id IS NOT NULL AND suggested_party_id <> ''").
+		Order("suggestion_score DESC").
+		Find(&parties).Error
+	return parties, err
+}
+
+// ClearSuggestion 忽略主体的合并建议
+func (r *PartyRepo) ClearSuggestion(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Model(&Party{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"suggested_party_id": "", "suggestion_score": 0}).Error
+}
+
+// ListSuggestions 查询自动挂载、尚未确认的别名
+func (r *PartyRepo) ListSuggestions(ctx context.Context) ([]PartyAlias, error) {
+	var aliases []PartyAlias
+	err := r.db.WithContext(ctx).
+		Where("source = ? AND confirmed = ?", AliasSourceAuto, false).
+		Order("score").
+		Find(&aliases).Error
+	return aliases, err
+}
+
+// MoveAlias 把别名改挂到另一个主体 (纠正自动挂载错误)
+func (r *PartyRepo) MoveAlias(ctx context.Context, aliasID, toPartyID string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var a PartyAlias
+		if err := tx.Where("id = ?", aliasID).First(&a).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&PartyAlias{}).Where("id = ?", aliasID).
+			Updates(map[string]interface{}{"party_id": toPartyID, "source": AliasSourceManual, "confirmed": true}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&ContractParty{}).
+			Where("party_id = ? AND normalized_name = ?", a.PartyID, a.Alias).
+			Update("party_id", toPartyID).Error
+	})
+}
+
+// MergeParties 把 sourceID 主体合并到 targetID：别名和合同关联全部迁移，然后删除 source
+func (r *PartyRepo) MergeParties(ctx context.Context, targetID, sourceID string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&PartyAlias{}).Where("party_id = ?", sourceID).
+			Updates(map[string]interface{}{"party_id": targetID, "confirmed": true}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&ContractParty{}).Where("party_id = ?", sourceID).
+			Update("party_id", targetID).Error; err != nil {
+			return err
+		}
+		// 指向 source 的合并建议改指 target，target 自身指向 source 的建议已完成
+		if err := tx.Model(&Party{}).Where("suggested_party_id = ?", sourceID).
+			Update("suggested_party_id", targetID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Party{}).Where("id = ? AND suggested_party_id = ?", targetID, targetID).
+			Updates(map[string]interface{}{"suggested_party_id": "", "suggestion_score": 0}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", sourceID).Delete(&Party{}).Error
+	})
+}
+
+// AliasesByPartyIDs 查询一组主体的全部别名
+func (r *PartyRepo) AliasesByPartyIDs(ctx context.Context, partyIDs []string) ([]string, error) {
+	var aliases []string
+	if len(partyIDs) == 0 {
+		return aliases, nil
+	}
+	err := r.db.WithContext(ctx).Model(&PartyAlias{}).
+		Where("party_id IN ?", partyIDs).
+		Pluck("alias", &aliases).Error
+	return aliases, err
+}
+
+// ListUnresolvedContractParties 查询尚未关联主体的合同参与方 (历史数据回填用)
+func (r *PartyRepo) ListUnresolvedContractParties(ctx context.Context) ([]ContractParty, error) {
+	var rows []ContractParty
+	err := r.db.WithContext(ctx).Where("party_id IS NULL OR party_id = ''").Find(&rows).Error
+	return rows, err
+}
+
+// SetContractPartyID 关联合同参与方与主体
+func (r *PartyRepo) SetContractPartyID(ctx context.Context, contractPartyID, partyID string) error {
+	return r.db.WithContext(ctx).Model(&ContractParty{}).
+		Where("id = ?", contractPartyID).
+		Update("party_id", partyID).Error
+}
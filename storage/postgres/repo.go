@@ -2,11 +2,15 @@ package postgres
 
 import (
 	"context"
+	"eino-demo/storage/filter"
 	"eino-demo/types"
+	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // ContractRepo 封装对 Contract 表的所有操作
@@ -37,6 +41,16 @@ func (r *ContractRepo) GetByDocID(ctx context.Context, docID string) (*Contract,
 	return &contract, nil
 }
 
+// GetByDocIDs 批量查询合同
+func (r *ContractRepo) GetByDocIDs(ctx context.Context, docIDs []string) ([]Contract, error) {
+	var contracts []Contract
+	if len(docIDs) == 0 {
+		return contracts, nil
+	}
+	err := r.db.WithContext(ctx).Where("doc_id IN ?", docIDs).Find(&contracts).Error
+	return contracts, err
+}
+
 // GetByDocID 根据 FileName 查询合同详情
 func (r *ContractRepo) GetByFileName(ctx context.Context, filename string) (*Contract, error) {
 	var contract Contract
@@ -61,6 +75,16 @@ func (r *ContractRepo) SearchByKeyword(ctx context.Context, keyword string) ([]C
 }
 
 func (r *ContractRepo) Delete(ctx context.Context, id string) error {
+	// 先删条款、参与方和切片，再删合同本身
+	if err := r.db.WithContext(ctx).Where("doc_id = ?", id).Delete(&ContractClause{}).Error; err != nil {
+		return err
+	}
+	if err := r.db.WithContext(ctx).Where("doc_id = ?", id).Delete(&ContractParty{}).Error; err != nil {
+		return err
+	}
+	if err := r.db.WithContext(ctx).Where("doc_id = ?", id).Delete(&ContractChunk{}).Error; err != nil {
+		return err
+	}
 	// 这里的 &Contract{} 是为了告诉 GORM 要删哪张表
 	// WithContext(ctx) 确保链路追踪和超时控制生效
 	result := r.db.WithContext(ctx).Where("doc_id = ?", id).Delete(&Contract{})
@@ -70,78 +94,160 @@ func (r *ContractRepo) Delete(ctx context.Context, id string) error {
 
 // SearchContracts 核心：根据结构化条件筛选 DocID
 // docIDs: 可选的文档ID列表（用于 ES 先过滤后再传给 PG）
+// 过滤条件统一由 filter 包编译，与 ES/Milvus 的匹配口径保持一致
 func (r *ContractRepo) SearchContracts(ctx context.Context, conditions *types.FilterConditions, docIDs ...[]string) ([]string, error) {
 	// 只查 doc_id，性能最高
 	tx := r.db.WithContext(ctx).Model(&Contract{}).Select("doc_id")
 
-	// 1. 如果传入了 docIDs（ES 先过滤的结果），用 IN 查询缩小范围
-	if docIDs != nil && len(docIDs) > 0 && docIDs[0] != nil && len(docIDs[0]) > 0 {
+	// 如果传入了 docIDs（ES 已按参与方过滤），用 IN 缩小范围，参与方条件不再重复过滤
+	if len(docIDs) > 0 && len(docIDs[0]) > 0 {
 		tx = tx.Where("doc_id IN ?", docIDs[0])
+		conditions = filter.WithoutParty(conditions)
+	}
+
+	tx, err := applyFilter(tx, conditions)
+	if err != nil {
+		return nil, err
 	}
 
-	// 2. 处理模糊参与方 (AnyParty) - 仅当没有传入 docIDs 时才使用（避免重复过滤）
-	// 如果 docIDs 已经由 ES 过滤了，这里就不需要再用 LIKE 查 AnyParty
-	if (len(docIDs) == 0 || docIDs[0] == nil || len(docIDs[0]) == 0) && len(conditions.AnyParty) > 0 {
-		// 构建子查询：(party_a LIKE '%p1%' OR party_b LIKE '%p1%') OR (party_a LIKE '%p2%' OR party_b LIKE '%p2%')
-		var orConditions []string
-		var orValues []interface{}
-		for _, party := range conditions.AnyParty {
-			pattern := "%" + party + "%"
-			orConditions = append(orConditions, "(party_a LIKE ? OR party_b LIKE ?)")
-			orValues = append(orValues, pattern, pattern)
-		}
-		// 用 OR 连接所有实体的条件
-		tx = tx.Where(strings.Join(orConditions, " OR "), orValues...)
+	var resultDocIDs []string
+	err = tx.Find(&resultDocIDs).Error
+	return resultDocIDs, err
+}
+
+// applyFilter 把结构化条件编译为 WHERE 子句追加到 contracts 查询上
+func applyFilter(tx *gorm.DB, conditions *types.FilterConditions) (*gorm.DB, error) {
+	return applyNode(tx, filter.FromConditions(conditions))
+}
+
+// applyNode 把过滤 AST 编译为 WHERE 子句追加到 contracts 查询上
+func applyNode(tx *gorm.DB, node *filter.Node) (*gorm.DB, error) {
+	where, args, err := filter.ToSQL(node)
+	if err != nil {
+		return nil, err
 	}
+	if where != "" {
+		tx = tx.Where(where, args...)
+	}
+	return tx, nil
+}
 
-	// 3. 处理精确参与方
-	if conditions.PartyA != "" {
-		tx = tx.Where("party_a LIKE ?", "%"+conditions.PartyA+"%")
+// CreateClauses 批量写入合同条款
+func (r *ContractRepo) CreateClauses(ctx context.Context, clauses []*ContractClause) error {
+	if len(clauses) == 0 {
+		return nil
 	}
-	if conditions.PartyB != "" {
-		tx = tx.Where("party_b LIKE ?", "%"+conditions.PartyB+"%")
+	return r.db.WithContext(ctx).CreateInBatches(clauses, 100).Error
+}
+
+// ListClauses 查询合同的条款，clauseType 为空时返回全部，按原文顺序排列
+func (r *ContractRepo) ListClauses(ctx context.Context, docID string, clauseType string) ([]ContractClause, error) {
+	var clauses []ContractClause
+	tx := r.db.WithContext(ctx).Where("doc_id = ?", docID)
+	if clauseType != "" {
+		tx = tx.Where("clause_type = ?", clauseType)
 	}
+	err := tx.Order("seq").Find(&clauses).Error
+	return clauses, err
+}
 
-	// 4. 合同类型
-	if conditions.ContractType != "" {
-		// 假设类型可能存在于 contract_type 字段或 file_name 中
-		tx = tx.Where("contract_type = ? OR file_name LIKE ?", conditions.ContractType, "%"+conditions.ContractType+"%")
+// CreateParties 批量写入合同参与方
+func (r *ContractRepo) CreateParties(ctx context.Context, parties []*ContractParty) error {
+	if len(parties) == 0 {
+		return nil
 	}
+	return r.db.WithContext(ctx).Create(parties).Error
+}
+
+// ListParties 查询合同的全部参与方
+func (r *ContractRepo) ListParties(ctx context.Context, docID string) ([]ContractParty, error) {
+	var parties []ContractParty
+	err := r.db.WithContext(ctx).Where("doc_id = ?", docID).Order("created_at").Find(&parties).Error
+	return parties, err
+}
 
-	if conditions.Status != "" {
-		if conditions.Status == "已过期" || conditions.Status == "expired" {
-			tx = tx.Where("contract_status = ?", types.StatusExpired)
-		} else {
-			tx = tx.Where("contract_status = ?", types.StatusActive)
-		}
-	} else {
-		// 默认策略：如果用户没提，是否只查生效中？这里假设默认查生效中
-		// tx = tx.Where("contract_status = ?", types.StatusActive)
+// CreateChunks 批量写入切片原文；ignoreConflict 为 true 时已存在的切片跳过 (从 ES 回填)
+func (r *ContractRepo) CreateChunks(ctx context.Context, chunks []*ContractChunk, ignoreConflict bool) error {
+	if len(chunks) == 0 {
+		return nil
+	}
+	tx := r.db.WithContext(ctx)
+	if ignoreConflict {
+		tx = tx.Clauses(clause.OnConflict{DoNothing: true})
 	}
+	return tx.CreateInBatches(chunks, 200).Error
+}
+
+// ChunkRange 按入库时间划分的切片范围 [Since, Before)，零值表示不限
+type ChunkRange struct {
+	Since  time.Time
+	Before time.Time
+}
 
-	// 5. 日期范围
-	if conditions.DateRange != nil {
-		if conditions.DateRange.Start != "" {
-			tx = tx.Where("sign_date >= ?", conditions.DateRange.Start)
-		}
-		if conditions.DateRange.End != "" {
-			tx = tx.Where("sign_date <= ?", conditions.DateRange.End)
-		}
+func (r *ContractRepo) chunkRange(ctx context.Context, rng ChunkRange) *gorm.DB {
+	tx := r.db.WithContext(ctx).Model(&ContractChunk{})
+	if !rng.Since.IsZero() {
+		tx = tx.Where("created_at >= ?", rng.Since)
 	}
+	if !rng.Before.IsZero() {
+		tx = tx.Where("created_at < ?", rng.Before)
+	}
+	return tx
+}
 
-	// 6. 金额范围
-	if conditions.AmountRange != nil {
-		if conditions.AmountRange.Min != nil {
-			tx = tx.Where("total_amount >= ?", *conditions.AmountRange.Min)
-		}
-		if conditions.AmountRange.Max != nil {
-			tx = tx.Where("total_amount <= ?", *conditions.AmountRange.Max)
-		}
+// CountChunks 统计范围内的切片数
+func (r *ContractRepo) CountChunks(ctx context.Context, rng ChunkRange) (int64, error) {
+	var total int64
+	err := r.chunkRange(ctx, rng).Count(&total).Error
+	return total, err
+}
+
+// ListChunks 按 id 游标翻页读取范围内的切片，afterID 为上一页最后一条的 id
+func (r *ContractRepo) ListChunks(ctx context.Context, rng ChunkRange, afterID string, limit int) ([]ContractChunk, error) {
+	var chunks []ContractChunk
+	tx := r.chunkRange(ctx, rng)
+	if afterID != "" {
+		tx = tx.Where("id > ?", afterID)
 	}
+	err := tx.Order("id").Limit(limit).Find(&chunks).Error
+	return chunks, err
+}
 
-	var resultDocIDs []string
-	err := tx.Find(&resultDocIDs).Error
-	return resultDocIDs, err
+// SampleChunks 随机抽取切片，用于召回自检
+func (r *ContractRepo) SampleChunks(ctx context.Context, rng ChunkRange, n int) ([]ContractChunk, error) {
+	var chunks []ContractChunk
+	err := r.chunkRange(ctx, rng).Clauses(clause.OrderBy{Expression: clause.Expr{SQL: "RANDOM()"}}).Limit(n).Find(&chunks).Error
+	return chunks, err
+}
+
+// FindChunkIDs 查询合同中条款类型为 clauseType、原文包含 contains 的切片 ID，按切片顺序返回；参数为空表示不限
+func (r *ContractRepo) FindChunkIDs(ctx context.Context, docID, clauseType, contains string) ([]string, error) {
+	tx := r.db.WithContext(ctx).Model(&ContractChunk{}).Where("doc_id = ?", docID)
+	if clauseType != "" {
+		tx = tx.Where("clause_type = ?", clauseType)
+	}
+	if contains != "" {
+		tx = tx.Where("content LIKE ?", "%"+likeEscaper.Replace(contains)+"%")
+	}
+	var ids []string
+	err := tx.Order("seq").Pluck("id", &ids).Error
+	return ids, err
+}
+
+// ListPartiesByDocIDs 批量查询多个合同的参与方，按合同分组
+func (r *ContractRepo) ListPartiesByDocIDs(ctx context.Context, docIDs []string) (map[string][]ContractParty, error) {
+	grouped := make(map[string][]ContractParty, len(docIDs))
+	if len(docIDs) == 0 {
+		return grouped, nil
+	}
+	var parties []ContractParty
+	if err := r.db.WithContext(ctx).Where("doc_id IN ?", docIDs).Order("created_at").Find(&parties).Error; err != nil {
+		return nil, err
+	}
+	for _, p := range parties {
+		grouped[p.DocID] = append(grouped[p.DocID], p)
+	}
+	return grouped, nil
 }
 
 // ExpireContracts 用于定时任务批量更新过期状态
@@ -152,3 +258,101 @@ func (r *ContractRepo) ExpireContracts(ctx context.Context, now time.Time) (int6
 		Update("contract_status", types.StatusExpired)
 	return result.RowsAffected, result.Error
 }
+
+// sortColumns 排序字段对应的排序表达式，NULL 日期按 epoch 处理，保证 keyset 比较有序
+var sortColumns = map[string]string{
+	types.SortSignDate: "COALESCE(sign_date, 'epoch'::timestamptz)",
+	types.SortEndDate:  "COALESCE(end_date, 'epoch'::timestamptz)",
+	types.SortAmount:   "total_amount",
+}
+
+// ContractPage 结构化检索的 keyset 分页参数
+type ContractPage struct {
+	SortBy     string // sign_date, end_date, amount；其他值 (含 relevance) 按 sign_date
+	Desc       bool
+	Limit      int
+	After      any    // 上一页最后一条的排序值 (time.Time 或 float64)，nil 表示首页
+	AfterDocID string // 排序值相同时按 doc_id 决胜
+}
+
+// ListContracts 按结构化条件分页查询合同
+// docIDs 非空时 (ES 已按参与方过滤) 只在其中查找，参与方条件不再重复过滤
+func (r *ContractRepo) ListContracts(ctx context.Context, conditions *types.FilterConditions, docIDs []string, page ContractPage) ([]Contract, error) {
+	tx := r.db.WithContext(ctx).Model(&Contract{})
+	if len(docIDs) > 0 {
+		tx = tx.Where("doc_id IN ?", docIDs)
+		conditions = filter.WithoutParty(conditions)
+	}
+	tx, err := applyFilter(tx, conditions)
+	if err != nil {
+		return nil, err
+	}
+
+	expr, ok := sortColumns[page.SortBy]
+	if !ok {
+		expr = sortColumns[types.SortSignDate]
+	}
+	dir, cmp := "ASC", ">"
+	if page.Desc {
+		dir, cmp = "DESC", "<"
+	}
+	if page.After != nil {
+		tx = tx.Where(fmt.Sprintf("(%s, doc_id) %s (?, ?)", expr, cmp), page.After, page.AfterDocID)
+	}
+
+	var contracts []Contract
+	err = tx.Order(fmt.Sprintf("%s %s, doc_id %s", expr, dir, dir)).Limit(page.Limit).Find(&contracts).Error
+	return contracts, err
+}
+
+// ResolveContracts 定位用户提到的合同：结构化条件 + 文件名包含 name (可为空)
+// 文件名完全一致 (忽略扩展名) 的排在最前，其余按签署日期从新到旧，最多返回 limit 份
+func (r *ContractRepo) ResolveContracts(ctx context.Context, conditions *types.FilterConditions, docIDs []string, name string, limit int) ([]Contract, error) {
+	tx := r.db.WithContext(ctx).Model(&Contract{})
+	if len(docIDs) > 0 {
+		tx = tx.Where("doc_id IN ?", docIDs)
+		conditions = filter.WithoutParty(conditions)
+	}
+	tx, err := applyFilter(tx, conditions)
+	if err != nil {
+		return nil, err
+	}
+	order := clause.Expr{SQL: "sign_date DESC NULLS LAST, doc_id"}
+	if name != "" {
+		pattern := "%" + likeEscaper.Replace(name) + "%"
+		tx = tx.Where("file_name ILIKE ?", pattern)
+		order = clause.Expr{SQL: "(regexp_replace(file_name, '\\.[^.]*$', '') = ?) DESC, " + order.SQL, Vars: []any{name}}
+	}
+	var contracts []Contract
+	err = tx.Clauses(clause.OrderBy{Expression: order}).Limit(limit).Find(&contracts).Error
+	return contracts, err
+}
+
+// likeEscaper 转义 LIKE 通配符
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// SortValue 合同在排序字段上的取值 (编码进游标)，与 sortColumns 的 COALESCE 保持一致
+func (c *Contract) SortValue(sortBy string) string {
+	switch sortBy {
+	case types.SortAmount:
+		return strconv.FormatFloat(c.TotalAmount, 'f', -1, 64)
+	case types.SortEndDate:
+		return formatSortTime(c.EndDate)
+	}
+	return formatSortTime(c.SignDate)
+}
+
+// ParseSortValue 解析游标中的排序值
+func ParseSortValue(sortBy, v string) (any, error) {
+	if sortBy == types.SortAmount {
+		return strconv.ParseFloat(v, 64)
+	}
+	return time.Parse(time.RFC3339Nano, v)
+}
+
+func formatSortTime(t *time.Time) string {
+	if t == nil {
+		return time.Unix(0, 0).UTC().Format(time.RFC3339Nano)
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
@@ -0,0 +1,29 @@
+package postgres
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// SearchLogRepo 检索日志
+type SearchLogRepo struct {
+	db *gorm.DB
+}
+
+func NewSearchLogRepo(db *gorm.DB) *SearchLogRepo {
+	return &SearchLogRepo{db: db}
+}
+
+func (r *SearchLogRepo) Create(ctx context.Context, l *SearchLog) error {
+	return r.db.WithContext(ctx).Create(l).Error
+}
+
+// Get 按 ID 查询
+func (r *SearchLogRepo) Get(ctx context.Context, id string) (*SearchLog, error) {
+	var l SearchLog
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&l).Error; err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
@@ -0,0 +1,54 @@
+package types
+
+// --- 聚合统计 ---
+
+// 聚合指标 (作用于 total_amount，count 除外)
+const (
+	MetricCount = "count"
+	MetricSum   = "sum"
+	MetricAvg   = "avg"
+	MetricMax   = "max"
+	MetricMin   = "min"
+)
+
+// 分组维度
+const (
+	GroupByParty        = "party"
+	GroupByContractType = "contract_type"
+	GroupByYear         = "year"
+	GroupByMonth        = "month"
+	GroupByStatus       = "status"
+)
+
+// AggregationSpec LLM 解析出的聚合需求
+type AggregationSpec struct {
+	Metric  string   `json:"metric"`             // count, sum, avg, max, min
+	GroupBy []string `json:"group_by,omitempty"` // party, contract_type, year, month, status
+}
+
+// AggregateResult 聚合结果表：Columns 为分组维度 + 指标，Rows 与 Columns 一一对应
+type AggregateResult struct {
+	Metric  string   `json:"metric"`
+	GroupBy []string `json:"group_by"`
+	Columns []string `json:"columns"`
+	Rows    [][]any  `json:"rows"`
+	Summary string   `json:"summary,omitempty"`
+}
+
+// IsValidMetric 是否为支持的聚合指标
+func IsValidMetric(m string) bool {
+	switch m {
+	case MetricCount, MetricSum, MetricAvg, MetricMax, MetricMin:
+		return true
+	}
+	return false
+}
+
+// IsValidGroupBy 是否为支持的分组维度
+func IsValidGroupBy(g string) bool {
+	switch g {
+	case GroupByParty, GroupByContractType, GroupByYear, GroupByMonth, GroupByStatus:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package types
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// --- 合同组合分析 (analytics) ---
+
+// 统计周期
+const (
+	PeriodMonth = "month"
+	PeriodYear  = "year"
+)
+
+// AnalyticsFilter 分析接口的通用过滤条件
+type AnalyticsFilter struct {
+	Start        string `form:"start"` // 签署日期起 YYYY-MM-DD
+	End          string `form:"end"`   // 签署日期止 YYYY-MM-DD
+	ContractType string `form:"type"`  // 合同类型 (包含匹配)
+	Period       string `form:"period"`
+	Limit        int    `form:"limit"`
+}
+
+// PeriodStat 按周期统计的合同数与金额
+type PeriodStat struct {
+	Period string  `json:"period"`
+	Count  int64   `json:"count"`
+	Amount float64 `json:"amount"`
+}
+
+// GroupStat 按维度 (类型/状态/参与方) 统计的合同数与金额
+type GroupStat struct {
+	Key    string  `json:"key"`
+	Label  string  `json:"label"`
+	Count  int64   `json:"count"`
+	Amount float64 `json:"amount"`
+}
+
+// FlowStat 每个周期内签署与到期的合同
+type FlowStat struct {
+	Period         string  `json:"period"`
+	Signed         int64   `json:"signed"`
+	SignedAmount   float64 `json:"signed_amount"`
+	Expiring       int64   `json:"expiring"`
+	ExpiringAmount float64 `json:"expiring_amount"`
+}
+
+// YoYStat 同比：本期与去年同期对比，去年同期为 0 时增长率为空
+type YoYStat struct {
+	Period       string   `json:"period"`
+	Count        int64    `json:"count"`
+	Amount       float64  `json:"amount"`
+	PrevCount    int64    `json:"prev_count"`
+	PrevAmount   float64  `json:"prev_amount"`
+	CountGrowth  *float64 `json:"count_growth"`
+	AmountGrowth *float64 `json:"amount_growth"`
+}
+
+type (
+	PeriodStats []PeriodStat
+	GroupStats  []GroupStat
+	FlowStats   []FlowStat
+	YoYStats    []YoYStat
+)
+
+// Table 统计结果导出为表格 (CSV)
+type Table interface {
+	Header() []string
+	Records() [][]string
+}
+
+func (s PeriodStats) Header() []string { return []string{"period", "count", "amount"} }
+func (s PeriodStats) Records() [][]string {
+	rows := make([][]string, len(s))
+	for i, r := range s {
+		rows[i] = []string{r.Period, itoa(r.Count), ftoa(r.Amount)}
+	}
+	return rows
+}
+
+func (s GroupStats) Header() []string { return []string{"key", "label", "count", "amount"} }
+func (s GroupStats) Records() [][]string {
+	rows := make([][]string, len(s))
+	for i, r := range s {
+		rows[i] = []string{r.Key, r.Label, itoa(r.Count), ftoa(r.Amount)}
+	}
+	return rows
+}
+
+func (s FlowStats) Header() []string {
+	return []string{"period", "signed", "signed_amount", "expiring", "expiring_amount"}
+}
+func (s FlowStats) Records() [][]string {
+	rows := make([][]string, len(s))
+	for i, r := range s {
+		rows[i] = []string{r.Period, itoa(r.Signed), ftoa(r.SignedAmount), itoa(r.Expiring), ftoa(r.ExpiringAmount)}
+	}
+	return rows
+}
+
+func (s YoYStats) Header() []string {
+	return []string{"period", "count", "amount", "prev_count", "prev_amount", "count_growth", "amount_growth"}
+}
+func (s YoYStats) Records() [][]string {
+	rows := make([][]string, len(s))
+	for i, r := range s {
+		rows[i] = []string{r.Period, itoa(r.Count), ftoa(r.Amount), itoa(r.PrevCount), ftoa(r.PrevAmount), ptoa(r.CountGrowth), ptoa(r.AmountGrowth)}
+	}
+	return rows
+}
+
+func itoa(v int64) string   { return strconv.FormatInt(v, 10) }
+func ftoa(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) }
+func ptoa(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprintf("%.4f", *v)
+}
@@ -0,0 +1,45 @@
+package types
+
+// --- 合同比较 ---
+
+// 比较结论
+const (
+	CompareSame      = "same"      // 各合同约定一致
+	CompareDifferent = "different" // 约定不同
+	CompareMissing   = "missing"   // 部分合同没有约定
+	CompareUnknown   = "unknown"   // 未能判断 (LLM 不可用时)
+)
+
+// CompareRequest 比较请求：直接给出 doc_ids，或由 query 定位合同
+type CompareRequest struct {
+	Query       string   `json:"query"`
+	DocIDs      []string `json:"doc_ids,omitempty"`
+	ClauseTypes []string `json:"clause_types,omitempty"` // 为空时从 query 中识别，仍为空则比较全部已抽取的条款类型
+}
+
+// CompareEvidence 比较依据：合同条款原文或检索到的片段，Ref 用于引用 (如 "E3")
+type CompareEvidence struct {
+	Ref        string `json:"ref"`
+	DocID      string `json:"doc_id"`
+	ClauseType string `json:"clause_type"`
+	Source     string `json:"source"` // clause: 来自 contract_clauses；chunk: 条款缺失时在该合同内检索到的片段
+	Content    string `json:"content"`
+}
+
+// CompareRow 差异表的一行：某类条款中的一个要点在各合同中的约定
+type CompareRow struct {
+	ClauseType string            `json:"clause_type"`
+	Term       string            `json:"term"`   // 要点，如 "付款期限"
+	Status     string            `json:"status"` // same, different, missing, unknown
+	Values     map[string]string `json:"values"` // doc_id -> 该合同的约定，未约定为空
+	Citations  []string          `json:"citations"`
+}
+
+// CompareResult 合同比较结果
+type CompareResult struct {
+	Contracts   []ContractHit     `json:"contracts"`
+	ClauseTypes []string          `json:"clause_types"`
+	Rows        []CompareRow      `json:"rows"`
+	Evidence    []CompareEvidence `json:"evidence"`
+	Table       string            `json:"table"` // Markdown 差异表
+}
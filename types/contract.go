@@ -11,6 +11,14 @@ type ContractRawData struct {
 	TotalAmount  interface{} `json:"total_amount" jsonschema:"description=合同总金额，提取纯数字"`
 	Summary      string      `json:"summary" jsonschema:"description=合同内容的简短摘要"`
 	Keywords     []string    `json:"keywords" jsonschema:"description=提取合同内容的3到5个关键术语或标签"`
+	Parties      []PartyRaw  `json:"parties" jsonschema:"description=合同的全部参与方，包括丙方、担保人和签署代表"`
+}
+
+// PartyRaw LLM 提取出的单个参与方
+type PartyRaw struct {
+	Name       string `json:"name" jsonschema:"description=参与方全称或姓名"`
+	Role       string `json:"role" jsonschema:"description=角色：甲方/乙方/丙方/担保人/签署代表"`
+	EntityType string `json:"entity_type" jsonschema:"description=主体类型：person 或 organization"`
 }
 
 // 2. 存入 PostgreSQL 的最终模型 (强类型)
@@ -0,0 +1,18 @@
+package types
+
+// --- 导出 ---
+
+// 证据导出条数
+const (
+	DefaultExportEvidence = 200
+	MaxExportEvidence     = 2000
+)
+
+// ExportRequest 导出请求，按自然语言问题导出合同列表、统计表或检索证据
+type ExportRequest struct {
+	Query  string `json:"query" binding:"required"`
+	Format string `json:"format,omitempty"`  // csv(默认), xlsx, jsonl
+	SortBy string `json:"sort_by,omitempty"` // 同 SearchRequest，证据导出只支持 relevance
+	Order  string `json:"order,omitempty"`
+	Limit  int    `json:"limit,omitempty"` // 仅证据导出：最多导出的片段数，默认 200，最大 2000
+}
@@ -0,0 +1,31 @@
+package types
+
+// --- 入库流水线 ---
+
+// IngestResult 单个上传文件的入库结果
+type IngestResult struct {
+	FileName string   `json:"file_name"`
+	DocIDs   []string `json:"doc_ids"`
+	Skipped  int      `json:"skipped,omitempty"` // 已存在 (按文件名查重) 而跳过的文档数
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// StageStats 流水线单个阶段的运行指标 (进程启动以来)
+type StageStats struct {
+	Stage     string  `json:"stage"`
+	Workers   int     `json:"workers"`
+	Queued    int     `json:"queued"` // 阶段入口队列中等待的任务数
+	QueueCap  int     `json:"queue_cap"`
+	InFlight  int64   `json:"in_flight"`
+	Processed int64   `json:"processed"`
+	Failed    int64   `json:"failed"`
+	AvgMillis float64 `json:"avg_ms"` // 单个任务平均耗时
+}
+
+// PipelineStats 入库流水线运行指标
+type PipelineStats struct {
+	Stages []StageStats `json:"stages"`
+	// 写入阶段跨文档批量写 ES/Milvus 的批次数和切片数
+	WriteBatches int64 `json:"write_batches"`
+	WriteChunks  int64 `json:"write_chunks"`
+}
@@ -0,0 +1,22 @@
+package types
+
+import "time"
+
+// IntentExample 意图解析的标注示例：查询和人工确认的正确意图
+type IntentExample struct {
+	ID          string       `json:"id"`
+	Query       string       `json:"query"`
+	Intent      SearchIntent `json:"intent"`
+	Source      string       `json:"source"` // manual 或 search_log
+	SearchLogID string       `json:"search_log_id,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// IntentExampleRequest 添加或纠正意图示例
+type IntentExampleRequest struct {
+	Query string `json:"query"`
+	// SearchLogID 纠正线上查询时填写检索日志 ID，query 为空时取日志里的查询原文
+	SearchLogID string        `json:"search_log_id,omitempty"`
+	Intent      *SearchIntent `json:"intent" binding:"required"`
+}
@@ -0,0 +1,75 @@
+package types
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Cursor 分页游标，对调用方不透明 (base64 编码的 JSON + "." + HMAC-SHA256 签名，防止调用方改写其中的意图和过滤条件)
+// 结构化检索用 keyset (上一页最后一条的排序值 + doc_id)；
+// 混合检索分别记录 Milvus 的 offset 和 ES 的 search_after。
+// 首页解析出的意图也放在游标里，翻页时不再调用 LLM，保证各页的过滤条件一致
+type Cursor struct {
+	SortBy string        `json:"s"`
+	Order  string        `json:"o"`
+	Intent *SearchIntent `json:"i"`
+
+	// 结构化检索 (PG keyset)
+	After string `json:"a,omitempty"`
+	DocID string `json:"d,omitempty"`
+
+	// 混合检索
+	MilvusOffset int   `json:"mo,omitempty"`
+	MilvusDone   bool  `json:"md,omitempty"`
+	ESAfter      []any `json:"ea,omitempty"`
+	ESDone       bool  `json:"ed,omitempty"`
+}
+
+// EncodeCursor 编码游标并用 key 签名
+func EncodeCursor(c *Cursor, key []byte) string {
+	if c == nil {
+		return ""
+	}
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data) + "." + base64.RawURLEncoding.EncodeToString(signCursor(data, key))
+}
+
+// DecodeCursor 校验签名并解码游标，空字符串返回 nil；签名不对或排序参数与请求不一致时报错
+func DecodeCursor(s string, key []byte, sortBy, order string) (*Cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	payload, sig, ok := strings.Cut(s, ".")
+	if !ok {
+		return nil, fmt.Errorf("cursor 无效: 缺少签名")
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, fmt.Errorf("cursor 无效: %v", err)
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, signCursor(data, key)) {
+		return nil, fmt.Errorf("cursor 无效或已过期，请从第一页重新查询")
+	}
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("cursor 无效: %v", err)
+	}
+	if c.Intent == nil {
+		return nil, fmt.Errorf("cursor 无效: 缺少检索意图")
+	}
+	if c.SortBy != sortBy || c.Order != order {
+		return nil, fmt.Errorf("cursor 与排序参数不一致，请从第一页重新查询")
+	}
+	return &c, nil
+}
+
+func signCursor(data, key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,44 @@
+package types
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestCursorSignature(t *testing.T) {
+	key := []byte("test-key")
+	cursor := &Cursor{SortBy: "amount", Order: "desc", Intent: &SearchIntent{Intent: "structured_only"}, After: "1000", DocID: "doc-1"}
+	s := EncodeCursor(cursor, key)
+
+	got, err := DecodeCursor(s, key, "amount", "desc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.After != "1000" || got.DocID != "doc-1" || got.Intent.Intent != "structured_only" {
+		t.Errorf("解码结果不一致: %+v", got)
+	}
+
+	// 改写意图后重新编码 payload，签名对不上
+	payload, sig, _ := strings.Cut(s, ".")
+	forged := base64.RawURLEncoding.EncodeToString([]byte(`{"s":"amount","o":"desc","i":{"intent":"hybrid"}}`))
+	cases := map[string]string{
+		"改写内容": forged + "." + sig,
+		"缺少签名": payload,
+		"签名损坏": payload + ".!!",
+	}
+	for name, s := range cases {
+		if _, err := DecodeCursor(s, key, "amount", "desc"); err == nil {
+			t.Errorf("%s: 应报错", name)
+		}
+	}
+	if _, err := DecodeCursor(s, []byte("other-key"), "amount", "desc"); err == nil {
+		t.Error("密钥不同 (重启或其他实例) 应报错")
+	}
+	if _, err := DecodeCursor(s, key, "amount", "asc"); err == nil || !strings.Contains(err.Error(), "排序参数") {
+		t.Errorf("排序参数不一致应报错: %v", err)
+	}
+	if c, err := DecodeCursor("", key, "", ""); c != nil || err != nil {
+		t.Errorf("空游标返回 nil: %v %v", c, err)
+	}
+}
@@ -1,5 +1,7 @@
 package types
 
+import "time"
+
 // --- 常量定义 ---
 
 // 使用 int 0/1 表示状态，比 string 更高效
@@ -10,30 +12,139 @@ const (
 
 // --- 结构体定义 ---
 
+// 排序字段
+const (
+	SortRelevance = "relevance"
+	SortSignDate  = "sign_date"
+	SortAmount    = "amount"
+	SortEndDate   = "end_date"
+)
+
+// 分页大小
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 200
+)
+
 type SearchRequest struct {
-	Query string `json:"query" binding:"required"`
+	Query    string `json:"query" binding:"required"`
+	SortBy   string `json:"sort_by,omitempty"`   // relevance(默认), sign_date, amount, end_date；混合检索只支持 relevance
+	Order    string `json:"order,omitempty"`     // desc(默认) 或 asc
+	PageSize int    `json:"page_size,omitempty"` // 默认 20，最大 200
+	Cursor   string `json:"cursor,omitempty"`    // 上一页返回的 next_cursor，首页留空
+	DocID    string `json:"doc_id,omitempty"`    // 单合同问答时用户从 candidates 中选定的合同
+	// PartyIDs 用户从 filters.party_candidates 中选定的主体
+	PartyIDs []string `json:"party_ids,omitempty"`
+}
+
+// Normalize 校验排序和分页参数，非法值回落到默认
+func (r *SearchRequest) Normalize() {
+	switch r.SortBy {
+	case SortSignDate, SortAmount, SortEndDate:
+	default:
+		r.SortBy = SortRelevance
+	}
+	if r.Order != "asc" {
+		r.Order = "desc"
+	}
+	if r.PageSize <= 0 {
+		r.PageSize = DefaultPageSize
+	}
+	if r.PageSize > MaxPageSize {
+		r.PageSize = MaxPageSize
+	}
+}
+
+// SearchResponse 检索结果，NextCursor 为空表示没有下一页
+type SearchResponse struct {
+	Intent        string            `json:"intent"`
+	Filters       *FilterConditions `json:"filters,omitempty"`        // 解析出的过滤条件，供排查和离线评测比对
+	PromptVersion string            `json:"prompt_version,omitempty"` // 解析意图所用的提示词版本
+	Plan          *ExecutionPlan    `json:"plan,omitempty"`
+	Answer        string            `json:"answer"`
+	Contracts     []ContractHit     `json:"contracts,omitempty"`
+	Chunks        []ChunkHit        `json:"chunks,omitempty"`
+	Aggregate     *AggregateResult  `json:"aggregate,omitempty"`
+	Compare       *CompareResult    `json:"compare,omitempty"`
+	Candidates    []ContractHit     `json:"candidates,omitempty"` // 单合同问答命中多份合同时，供用户选择 (带 doc_id 重新提问)
+	NextCursor    string            `json:"next_cursor,omitempty"`
+}
+
+// ExecutionPlan 意图对应的执行计划，随结果返回，便于排查走了哪条链路
+type ExecutionPlan struct {
+	Intent   string   `json:"intent"`
+	Steps    []string `json:"steps"`
+	Fallback string   `json:"fallback,omitempty"` // 计划无法执行、降级到其他计划的原因
+}
+
+// ContractHit 结构化检索命中的合同
+type ContractHit struct {
+	DocID        string     `json:"doc_id"`
+	FileName     string     `json:"file_name"`
+	PartyA       string     `json:"party_a"`
+	PartyB       string     `json:"party_b"`
+	ContractType string     `json:"contract_type"`
+	Status       int        `json:"status"`
+	SignDate     *time.Time `json:"sign_date,omitempty"`
+	EndDate      *time.Time `json:"end_date,omitempty"`
+	TotalAmount  float64    `json:"total_amount"`
+}
+
+// ChunkHit 混合检索命中的合同片段
+type ChunkHit struct {
+	ID       string         `json:"id"`
+	DocID    string         `json:"doc_id"`
+	Content  string         `json:"content"`
+	Score    float64        `json:"score"`
+	Sources  []string       `json:"sources"`
+	Metadata map[string]any `json:"metadata,omitempty"`
 }
 
 // SearchIntent LLM 解析后的用户意图
 type SearchIntent struct {
-	Intent        string           `json:"intent"` // "structured_only", "semantic_only", "hybrid"
+	Intent        string           `json:"intent"` // structured_only, semantic_only, hybrid, aggregate, contract_qa, clause_lookup, compare
 	Filters       FilterConditions `json:"filters"`
 	SemanticQuery string           `json:"semantic_query"`
 	Keywords      []string         `json:"keywords"`
+	Aggregation   *AggregationSpec `json:"aggregation,omitempty"` // intent 为 aggregate 时有效
+	Contract      string           `json:"contract,omitempty"`    // 用户提到的合同名称 (用于按文件名定位合同)
+	// DocIDs 检索限定在这些合同内 (单合同问答、合同比较)，由服务端定位合同后填充，不由 LLM 输出
+	DocIDs []string `json:"doc_ids,omitempty"`
+	// PromptVersion 解析意图所用的提示词版本 (如 intent/v2)，由服务端填充，翻页时随游标沿用
+	PromptVersion string `json:"prompt_version,omitempty"`
+	// Degraded LLM 输出无法解析时的兜底意图 (hybrid + 原始查询)，不写入意图缓存
+	Degraded bool `json:"-"`
 }
 
 // FilterConditions 过滤条件 (用于 Repo 查询)
 type FilterConditions struct {
-	AnyParty     []string `json:"any_party,omitempty"` // 改为数组，支持多个实体
+	AnyParty []string `json:"any_party,omitempty"` // 改为数组，支持多个实体
+	// PartyIDs/PartyAliases 由 PartyService 根据 AnyParty 解析填充，不由 LLM 输出
+	// 与 AnyParty 是 "或" 的关系：命中任一主体 ID、别名或未解析名称即可
+	PartyIDs     []string `json:"party_ids,omitempty"`
+	PartyAliases []string `json:"party_aliases,omitempty"`
 	PartyA       string   `json:"party_a,omitempty"`
 	PartyB       string   `json:"party_b,omitempty"`
 	ContractType string   `json:"contract_type,omitempty"`
+	ClauseType   string   `json:"clause_type,omitempty"` // 条款类型: payment, delivery, liability ...
 
 	// 这里用 string 接收 LLM 的输出 (如 "生效中"), Service 层负责转为 int
 	Status string `json:"status,omitempty"`
 
 	DateRange   *DateRange   `json:"date_range,omitempty"`
 	AmountRange *AmountRange `json:"amount_range,omitempty"`
+
+	// PartyCandidates 由 PartyService 填充：不能唯一确定主体的名称 (仍在 AnyParty 里按模糊匹配) 的相似主体，
+	// 供用户选择后带 party_ids 重新查询
+	PartyCandidates []PartyCandidate `json:"party_candidates,omitempty"`
+}
+
+// PartyCandidate any_party 中某个名称可能指向的主体
+type PartyCandidate struct {
+	Name          string  `json:"name"` // 查询中的名称
+	PartyID       string  `json:"party_id"`
+	CanonicalName string  `json:"canonical_name"`
+	Score         float64 `json:"score"`
 }
 
 type DateRange struct {
@@ -45,3 +156,8 @@ type AmountRange struct {
 	Min *float64 `json:"min,omitempty"`
 	Max *float64 `json:"max,omitempty"`
 }
+
+// HasPartyFilter 是否有参与方过滤 (未解析名称、主体 ID 或别名)
+func (f *FilterConditions) HasPartyFilter() bool {
+	return len(f.AnyParty) > 0 || len(f.PartyIDs) > 0 || len(f.PartyAliases) > 0
+}